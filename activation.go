@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+)
+
+// listenFDsStart is the first file descriptor systemd passes to a
+// socket-activated service
+const listenFDsStart = 3
+
+// listenFDs returns how many sockets systemd passed to this process, or 0
+// when it wasn't socket activated. The variables are only meant for the
+// process systemd started, so LISTEN_PID must name this process.
+func listenFDs() int {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return 0
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+// activationListener returns the listening socket systemd passed in when
+// autorun is socket activated, or nil when it wasn't. Only the first
+// socket is used. The variables are cleared so that child processes don't
+// think the socket is theirs.
+func activationListener() (net.Listener, error) {
+	n := listenFDs()
+	if n == 0 {
+		return nil, nil
+	}
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	f := os.NewFile(uintptr(listenFDsStart), "LISTEN_FD_3")
+	defer f.Close()
+	listener, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("socket from systemd is not a listening socket: %w", err)
+	}
+	return listener, nil
+}
+
+// isLocalhost reports whether host only accepts connections from this
+// machine
+func isLocalhost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestListenFDs(t *testing.T) {
+	self := strconv.Itoa(os.Getpid())
+	for _, tc := range []struct {
+		pid, fds string
+		want     int
+	}{
+		{"", "", 0},
+		{self, "1", 1},
+		{self, "2", 2},
+		{strconv.Itoa(os.Getpid() + 1), "1", 0}, // meant for another process
+		{self, "lots", 0},
+		{self, "-1", 0},
+	} {
+		t.Setenv("LISTEN_PID", tc.pid)
+		t.Setenv("LISTEN_FDS", tc.fds)
+		if got := listenFDs(); got != tc.want {
+			t.Errorf("LISTEN_PID=%q LISTEN_FDS=%q: expected %d, got %d", tc.pid, tc.fds, tc.want, got)
+		}
+	}
+}
+
+func TestActivationListener_NotActivated(t *testing.T) {
+	t.Setenv("LISTEN_PID", "")
+	t.Setenv("LISTEN_FDS", "")
+	listener, err := activationListener()
+	if listener != nil || err != nil {
+		t.Fatalf("expected no listener, got %v, %v", listener, err)
+	}
+}
+
+func TestIsLocalhost(t *testing.T) {
+	for host, want := range map[string]bool{
+		"localhost": true,
+		"127.0.0.1": true,
+		"::1":       true,
+		"0.0.0.0":   false,
+		"":          false,
+		"10.0.0.5":  false,
+	} {
+		if got := isLocalhost(host); got != want {
+			t.Errorf("isLocalhost(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// loadConfigFile sets flags from a YAML or JSON file whose keys are flag
+// names, for example:
+//
+//	port: 9000
+//	listen: 0.0.0.0
+//	cors-origins: [http://localhost:5173, https://ops.example.com]
+//	command-timeouts: {list: 1m, action: 2m}
+//
+// Lists are joined with commas and maps become key=value lists, matching
+// how those flags are written on the command line. Flags given on the
+// command line win over the file, and the file wins over the defaults.
+// Unknown keys are an error so that typos don't go unnoticed.
+func loadConfigFile(fs *flag.FlagSet, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	var values map[string]any
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	onCommandLine := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { onCommandLine[f.Name] = true })
+
+	for _, name := range slices.Sorted(maps.Keys(values)) {
+		if name == "config" || fs.Lookup(name) == nil {
+			return fmt.Errorf("config file %s: unknown option %q", path, name)
+		}
+		if onCommandLine[name] {
+			continue
+		}
+		value, err := configValue(values[name])
+		if err != nil {
+			return fmt.Errorf("config file %s: %s: %w", path, name, err)
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("config file %s: invalid %s: %w", path, name, err)
+		}
+	}
+	return nil
+}
+
+// configValue turns a value from the config file into flag syntax
+func configValue(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			if !isScalar(item) {
+				return "", fmt.Errorf("list items must be plain values")
+			}
+			items[i] = fmt.Sprint(item)
+		}
+		return strings.Join(items, ","), nil
+	case map[string]any:
+		items := make([]string, 0, len(v))
+		for _, key := range slices.Sorted(maps.Keys(v)) {
+			if !isScalar(v[key]) {
+				return "", fmt.Errorf("map values must be plain values")
+			}
+			items = append(items, fmt.Sprintf("%s=%v", key, v[key]))
+		}
+		return strings.Join(items, ","), nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+func isScalar(v any) bool {
+	switch v.(type) {
+	case []any, map[string]any, nil:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "autorun.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfigFile_FlagsWinOverFile(t *testing.T) {
+	fs := flag.NewFlagSet("autorun", flag.ContinueOnError)
+	port := fs.Int("port", 8080, "")
+	listen := fs.String("listen", "127.0.0.1", "")
+	verbose := fs.Bool("verbose", false, "")
+	backoff := fs.Duration("stream-retry-backoff", time.Second, "")
+	origins := fs.String("cors-origins", "", "")
+	timeouts := fs.String("command-timeouts", "", "")
+	if err := fs.Parse([]string{"-port", "3000"}); err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	path := writeConfig(t, `
+port: 9000
+listen: 0.0.0.0
+verbose: true
+stream-retry-backoff: 250ms
+cors-origins: [http://localhost:5173, https://ops.example.com]
+command-timeouts: {list: 1m, action: 2m}
+`)
+	if err := loadConfigFile(fs, path); err != nil {
+		t.Fatalf("loadConfigFile: %v", err)
+	}
+
+	if *port != 3000 {
+		t.Errorf("expected the command-line port to win, got %d", *port)
+	}
+	if *listen != "0.0.0.0" || !*verbose || *backoff != 250*time.Millisecond {
+		t.Errorf("expected file values, got listen=%q verbose=%v backoff=%s", *listen, *verbose, *backoff)
+	}
+	if *origins != "http://localhost:5173,https://ops.example.com" {
+		t.Errorf("expected the list joined with commas, got %q", *origins)
+	}
+	if *timeouts != "action=2m,list=1m" {
+		t.Errorf("expected the map as key=value pairs, got %q", *timeouts)
+	}
+}
+
+func TestLoadConfigFile_JSON(t *testing.T) {
+	fs := flag.NewFlagSet("autorun", flag.ContinueOnError)
+	port := fs.Int("port", 8080, "")
+	if err := loadConfigFile(fs, writeConfig(t, `{"port": 9000}`)); err != nil {
+		t.Fatalf("loadConfigFile: %v", err)
+	}
+	if *port != 9000 {
+		t.Fatalf("expected port 9000, got %d", *port)
+	}
+}
+
+func TestLoadConfigFile_Errors(t *testing.T) {
+	for name, tc := range map[string]struct{ content, want string }{
+		"unknown key":   {"prot: 9000", `unknown option "prot"`},
+		"config key":    {"config: other.yaml", `unknown option "config"`},
+		"invalid value": {"port: eighty", "invalid port"},
+		"nested list":   {"port: [[1]]", "list items must be plain values"},
+		"not yaml":      {"port: [", "invalid config file"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			fs := flag.NewFlagSet("autorun", flag.ContinueOnError)
+			fs.String("config", "", "")
+			fs.Int("port", 8080, "")
+			err := loadConfigFile(fs, writeConfig(t, tc.content))
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("expected an error containing %q, got %v", tc.want, err)
+			}
+		})
+	}
+
+	fs := flag.NewFlagSet("autorun", flag.ContinueOnError)
+	if err := loadConfigFile(fs, filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Fatal("expected an error for a missing file")
+	}
+}
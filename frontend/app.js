@@ -10,7 +10,8 @@ const state = {
     searchQuery: '',
     logSocket: null,
     platform: null,
-    elevated: false
+    elevated: false,
+    capabilities: {}
 };
 
 // ═══════════════════════════════════════════════════════════
@@ -32,6 +33,7 @@ const elements = {
     logContent: document.getElementById('log-content'),
     logStatus: document.getElementById('log-status'),
     controlButtons: document.querySelectorAll('.ctrl-btn'),
+    controlPanel: document.querySelector('.control-panel'),
     toastContainer: document.getElementById('toast-container'),
     // Create service elements
     createBtn: document.getElementById('create-btn'),
@@ -62,6 +64,10 @@ async function api(method, path, body = null) {
     const data = await response.json();
 
     if (!response.ok) {
+        // A 403 while not elevated means sudo is the fix, so say so
+        if (response.status === 403 && !state.elevated) {
+            throw new Error(data.remediation || `${data.error} (autorun is not running as root)`);
+        }
         throw new Error(data.error || 'API request failed');
     }
 
@@ -86,6 +92,19 @@ async function fetchPlatform() {
     }
 }
 
+async function fetchCapabilities() {
+    try {
+        state.capabilities = await api('GET', '/api/capabilities');
+        // Changes would all be refused, so don't offer them
+        if (state.capabilities.readOnly) {
+            elements.createBtn.style.display = 'none';
+            elements.controlPanel.style.display = 'none';
+        }
+    } catch (err) {
+        console.error('Failed to fetch capabilities:', err);
+    }
+}
+
 function showElevationWarning() {
     const warning = document.createElement('div');
     warning.className = 'elevation-warning';
@@ -162,6 +181,7 @@ async function performAction(action) {
 function openCreateModal() {
     elements.createModal.style.display = 'flex';
     document.getElementById('create-name').value = '';
+    document.getElementById('create-name').setCustomValidity('');
     document.getElementById('create-program').value = '';
     document.getElementById('create-arguments').value = '';
     document.getElementById('create-description').value = '';
@@ -176,6 +196,26 @@ function closeCreateModal() {
     elements.createModal.style.display = 'none';
 }
 
+// Warn before submit if the name is already taken in the chosen scope
+async function checkCreateNameExists() {
+    const input = document.getElementById('create-name');
+    const name = input.value.trim();
+    const scope = document.getElementById('create-scope').value;
+    input.setCustomValidity('');
+    if (!name) return;
+
+    try {
+        const result = await api('GET', `/api/services/${encodeURIComponent(name)}/exists?scope=${scope}`);
+        if (result.exists) {
+            input.setCustomValidity(`A ${scope} service named ${name} already exists`);
+            input.reportValidity();
+        }
+    } catch (err) {
+        // Invalid names are reported by the create request itself
+        console.error('Failed to check service name:', err);
+    }
+}
+
 async function handleCreateService(e) {
     e.preventDefault();
 
@@ -297,6 +337,14 @@ function connectLogStream(serviceName, scope) {
         state.logSocket = null;
     }
 
+    // The platform's log tool is missing, so don't try to stream
+    if (state.capabilities.logs === false) {
+        elements.logContent.innerHTML = '<div class="log-placeholder">Logs are unavailable on this host</div>';
+        elements.logStatus.classList.remove('connected');
+        elements.logStatus.innerHTML = '<span class="log-dot"></span>UNAVAILABLE';
+        return;
+    }
+
     elements.logContent.innerHTML = '<div class="log-placeholder">Connecting to log stream...</div>';
     elements.logStatus.classList.remove('connected');
     elements.logStatus.innerHTML = '<span class="log-dot"></span>CONNECTING';
@@ -323,9 +371,11 @@ function connectLogStream(serviceName, scope) {
         elements.logStatus.innerHTML = '<span class="log-dot"></span>ERROR';
     };
 
-    ws.onclose = () => {
+    ws.onclose = (event) => {
         elements.logStatus.classList.remove('connected');
-        elements.logStatus.innerHTML = '<span class="log-dot"></span>DISCONNECTED';
+        // 1001 (going away) is sent when the server shuts down
+        const label = event.code === 1001 ? 'SERVER STOPPED' : 'DISCONNECTED';
+        elements.logStatus.innerHTML = `<span class="log-dot"></span>${label}`;
         state.logSocket = null;
     };
 }
@@ -393,8 +443,9 @@ function renderServiceList() {
                     <div class="service-name">${escapeHtml(service.name)}</div>
                     <div class="service-scope">${service.scope.toUpperCase()}</div>
                 </div>
-                <div class="service-enabled ${service.enabled ? 'enabled' : ''}">
-                    ${service.enabled ? 'ON' : 'OFF'}
+                <div class="service-enabled ${service.enabled ? 'enabled' : ''}"
+                     title="${escapeHtml(service.enableState || '')}">
+                    ${service.enabled ? 'ON' : service.enableState === 'unknown' ? '?' : 'OFF'}
                 </div>
             </div>
         `;
@@ -413,6 +464,20 @@ function renderServiceList() {
     });
 }
 
+function statusLabel(service) {
+    if (service.failureReason || service.exitCode) {
+        const details = [service.failureReason, service.exitCode ? `exit ${service.exitCode}` : '']
+            .filter(Boolean)
+            .join(', ');
+        return `${service.status} (${details})`;
+    }
+    if (service.status === 'stopped') {
+        if (service.lastExitClean) return 'stopped (exited cleanly)';
+        if (service.neverRan) return 'stopped (never ran)';
+    }
+    return service.status;
+}
+
 function selectService(service) {
     state.selectedService = service;
 
@@ -430,6 +495,7 @@ function selectService(service) {
     elements.detailName.textContent = service.name;
     elements.detailDescription.textContent = service.description || 'No description available';
     elements.detailStatus.className = `status-indicator ${service.status}`;
+    elements.detailStatus.title = statusLabel(service);
     elements.detailScope.textContent = service.scope.toUpperCase();
 
     // Update control button states
@@ -442,6 +508,8 @@ function selectService(service) {
 function updateControlButtons(service) {
     const isRunning = service.status === 'running';
     const isEnabled = service.enabled;
+    // systemd units enabled through other units can't be enabled or disabled themselves
+    const fixedEnablement = ['static', 'indirect', 'generated', 'alias'].includes(service.enableState);
 
     elements.controlButtons.forEach(btn => {
         const action = btn.dataset.action;
@@ -458,7 +526,7 @@ function updateControlButtons(service) {
                 btn.disabled = isEnabled;
                 break;
             case 'disable':
-                btn.disabled = !isEnabled;
+                btn.disabled = !isEnabled || fixedEnablement;
                 break;
         }
     });
@@ -524,6 +592,8 @@ function setupEventListeners() {
     elements.createModalClose.addEventListener('click', closeCreateModal);
     elements.createCancel.addEventListener('click', closeCreateModal);
     elements.createForm.addEventListener('submit', handleCreateService);
+    document.getElementById('create-name').addEventListener('change', checkCreateNameExists);
+    document.getElementById('create-scope').addEventListener('change', checkCreateNameExists);
     elements.createModal.addEventListener('click', (e) => {
         if (e.target === elements.createModal) closeCreateModal();
     });
@@ -577,6 +647,7 @@ function escapeHtml(text) {
 async function init() {
     setupEventListeners();
     await fetchPlatform();
+    await fetchCapabilities();
     await fetchServices();
 
     // Auto-refresh services every 10 seconds
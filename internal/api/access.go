@@ -0,0 +1,99 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"autorun/internal/models"
+)
+
+// serviceAccess limits which services the API shows and manages. Patterns
+// are globs such as myapp-*; a name matching a deny pattern is always
+// refused, and with allow patterns set only names matching one of them are
+// permitted. Names are matched both as given and without a systemd unit
+// suffix, so a deny pattern for sshd also covers sshd.service and one for
+// backup covers backup.timer. The zero value permits everything.
+type serviceAccess struct {
+	allow []string
+	deny  []string
+}
+
+// ValidatePatterns reports the first malformed glob in patterns
+func ValidatePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid service pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// permits reports whether the service called name may be seen and managed
+func (a serviceAccess) permits(name string) bool {
+	base := unitBaseName(name)
+	if matchAny(a.deny, name) || matchAny(a.deny, base) {
+		return false
+	}
+	return len(a.allow) == 0 || matchAny(a.allow, name) || matchAny(a.allow, base)
+}
+
+// accessSuffixes are the systemd unit suffixes a service name can be given
+// with: systemctl takes sshd and sshd.service for the same unit, and timers
+// and sockets are named after the service they activate
+var accessSuffixes = []string{".service", ".timer", ".socket"}
+
+// unitBaseName returns name without a systemd unit suffix
+func unitBaseName(name string) string {
+	for _, suffix := range accessSuffixes {
+		if base, ok := strings.CutSuffix(name, suffix); ok && base != "" {
+			return base
+		}
+	}
+	return name
+}
+
+// restricted reports whether an allowlist is in effect
+func (a serviceAccess) restricted() bool {
+	return len(a.allow) > 0
+}
+
+// firstDenied returns the first of names that isn't permitted, or "" if
+// they all are
+func (a serviceAccess) firstDenied(names []string) string {
+	for _, name := range names {
+		if !a.permits(name) {
+			return name
+		}
+	}
+	return ""
+}
+
+// filter drops the services that aren't permitted
+func (a serviceAccess) filter(services []models.Service) []models.Service {
+	if len(a.allow) == 0 && len(a.deny) == 0 {
+		return services
+	}
+	permitted := make([]models.Service, 0, len(services))
+	for _, svc := range services {
+		if a.permits(svc.Name) {
+			permitted = append(permitted, svc)
+		}
+	}
+	return permitted
+}
+
+// forbiddenService responds 403 for a service outside the permitted set
+func forbiddenService(w http.ResponseWriter, name string) {
+	errorResponse(w, http.StatusForbidden, fmt.Sprintf("service %q is not managed by this server", name))
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
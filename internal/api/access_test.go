@@ -0,0 +1,103 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"autorun/internal/models"
+)
+
+func TestServiceAccess_Permits(t *testing.T) {
+	access := serviceAccess{allow: []string{"myapp-*", "worker"}, deny: []string{"myapp-db*"}}
+	for name, want := range map[string]bool{
+		"myapp-web":    true,
+		"worker":       true,
+		"myapp-db":     false, // denied even though allowed
+		"myapp-dbsync": false,
+		"sshd":         false,
+		"worker-2":     false,
+	} {
+		if got := access.permits(name); got != want {
+			t.Errorf("permits(%q) = %v, want %v", name, got, want)
+		}
+	}
+
+	denyOnly := serviceAccess{deny: []string{"ssh*"}}
+	if denyOnly.permits("sshd") || !denyOnly.permits("nginx") {
+		t.Fatal("expected a deny list alone to refuse only matching services")
+	}
+	if !(serviceAccess{}).permits("anything") {
+		t.Fatal("expected no patterns to permit everything")
+	}
+}
+
+func TestServiceAccess_PermitsUnitSuffixes(t *testing.T) {
+	access := serviceAccess{deny: []string{"sshd", "backup"}}
+	for _, name := range []string{"sshd", "sshd.service", "sshd.socket", "backup.timer"} {
+		if access.permits(name) {
+			t.Errorf("expected %q to be denied", name)
+		}
+	}
+	if !access.permits("nginx.service") {
+		t.Fatal("expected nginx.service to be permitted")
+	}
+
+	allow := serviceAccess{allow: []string{"myapp-*"}}
+	if !allow.permits("myapp-web.service") || allow.permits("sshd.service") {
+		t.Fatal("expected allow patterns to match the unit base name")
+	}
+}
+
+func TestValidatePatterns(t *testing.T) {
+	if err := ValidatePatterns([]string{"myapp-*", "db-[0-9]"}); err != nil {
+		t.Fatalf("ValidatePatterns: %v", err)
+	}
+	if err := ValidatePatterns([]string{"myapp-[*"}); err == nil {
+		t.Fatal("expected an error for a malformed pattern")
+	}
+}
+
+func TestRouter_ServiceAccess(t *testing.T) {
+	provider := &fakeProvider{userServices: []models.Service{
+		{Name: "myapp-web", Status: "running", Scope: models.ScopeUser},
+		{Name: "sshd", Status: "running", Scope: models.ScopeUser},
+	}}
+	router := NewRouter(provider, nil, Options{AllowPatterns: []string{"myapp-*"}})
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/services?scope=user", nil))
+	var services []models.Service
+	if err := json.Unmarshal(rr.Body.Bytes(), &services); err != nil {
+		t.Fatalf("failed to decode list: %v: %s", err, rr.Body.String())
+	}
+	if len(services) != 1 || services[0].Name != "myapp-web" {
+		t.Fatalf("expected only myapp-web to be listed, got %+v", services)
+	}
+
+	for _, tc := range []struct {
+		method, target, body string
+		want                 int
+	}{
+		{http.MethodPost, "/api/services/myapp-web/start", "", http.StatusOK},
+		{http.MethodPost, "/api/services/sshd/stop", "", http.StatusForbidden},
+		{http.MethodGet, "/api/services/sshd", "", http.StatusForbidden},
+		{http.MethodDelete, "/api/timers/sshd", "", http.StatusForbidden},
+		{http.MethodPost, "/api/services", `{"name": "sshd", "program": "/usr/sbin/sshd"}`, http.StatusForbidden},
+		{http.MethodPost, "/api/timers", `{"name": "sshd", "program": "/bin/true", "onCalendar": "daily"}`, http.StatusForbidden},
+		{http.MethodPost, "/api/services/status", `{"names": ["myapp-web", "sshd"]}`, http.StatusForbidden},
+		{http.MethodPost, "/api/services/rolling-restart", `{"names": ["myapp-web", "sshd"]}`, http.StatusForbidden},
+		{http.MethodPost, "/api/run", `{"program": "/bin/true"}`, http.StatusForbidden},
+	} {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body)))
+		if rr.Code != tc.want {
+			t.Errorf("%s %s: expected status %d, got %d: %s", tc.method, tc.target, tc.want, rr.Code, rr.Body.String())
+		}
+	}
+	if len(provider.startCalls) != 1 || len(provider.restartCalls) != 0 {
+		t.Fatalf("expected only the permitted start to reach the provider, got starts %v restarts %v", provider.startCalls, provider.restartCalls)
+	}
+}
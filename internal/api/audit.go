@@ -0,0 +1,83 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"autorun/internal/logger"
+	"autorun/internal/models"
+)
+
+// auditEntry is one line of the audit log
+type auditEntry struct {
+	Time       time.Time    `json:"time"`
+	Action     string       `json:"action"`
+	Service    string       `json:"service"`
+	Scope      models.Scope `json:"scope"`
+	Result     string       `json:"result"` // success or failure
+	Error      string       `json:"error,omitempty"`
+	RemoteAddr string       `json:"remoteAddr"`
+}
+
+// AuditLogger appends a JSON line for every change made to a service
+// through the API. A nil AuditLogger records nothing.
+type AuditLogger struct {
+	mu  sync.Mutex
+	out io.WriteCloser
+	now func() time.Time
+}
+
+// NewAuditLogger opens path for appending, creating it if needed. The file
+// is only readable by its owner, since it records who changed what.
+func NewAuditLogger(path string) (*AuditLogger, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return &AuditLogger{out: f, now: time.Now}, nil
+}
+
+// record appends an entry for action on a service. A failed write is
+// logged rather than returned so it never fails the request itself.
+func (a *AuditLogger) record(action, name string, scope models.Scope, remoteAddr string, err error) {
+	if a == nil {
+		return
+	}
+	entry := auditEntry{
+		Time:       a.now().UTC(),
+		Action:     action,
+		Service:    name,
+		Scope:      scope,
+		Result:     "success",
+		RemoteAddr: remoteAddr,
+	}
+	if err != nil {
+		entry.Result = "failure"
+		entry.Error = err.Error()
+	}
+	data, marshalErr := json.Marshal(entry)
+	if marshalErr != nil {
+		logger.Error("failed to encode audit entry", "action", action, "name", name, "error", marshalErr)
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, writeErr := a.out.Write(append(data, '\n')); writeErr != nil {
+		logger.Error("failed to write audit log", "action", action, "name", name, "error", writeErr)
+	}
+}
+
+// Close closes the audit log file
+func (a *AuditLogger) Close() error {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.out.Close()
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"autorun/internal/models"
+)
+
+func TestAuditLogger_RecordsMutations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	audit, err := NewAuditLogger(path)
+	if err != nil {
+		t.Fatalf("NewAuditLogger: %v", err)
+	}
+	defer audit.Close()
+	audit.now = func() time.Time { return time.Date(2025, 3, 1, 4, 0, 0, 0, time.UTC) }
+
+	provider := &fakeProvider{startErr: map[string]error{"broken": errors.New("exit status 1")}}
+	router := NewRouter(provider, nil, Options{Audit: audit})
+	for _, call := range []struct{ method, target string }{
+		{http.MethodPost, "/api/services/web/start?scope=system"},
+		{http.MethodPost, "/api/services/broken/start"},
+		{http.MethodGet, "/api/services/web"}, // reads aren't audited
+	} {
+		req := httptest.NewRequest(call.method, call.target, nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 audit entries, got %q", data)
+	}
+
+	want := []auditEntry{
+		{Time: audit.now(), Action: "start", Service: "web", Scope: models.ScopeSystem, Result: "success", RemoteAddr: "192.0.2.1:1234"},
+		{Time: audit.now(), Action: "start", Service: "broken", Scope: models.ScopeUser, Result: "failure", Error: "exit status 1", RemoteAddr: "192.0.2.1:1234"},
+	}
+	for i, line := range lines {
+		var got auditEntry
+		if err := json.Unmarshal([]byte(line), &got); err != nil {
+			t.Fatalf("entry %d is not JSON: %q", i, line)
+		}
+		if got != want[i] {
+			t.Fatalf("entry %d: expected %+v, got %+v", i, want[i], got)
+		}
+	}
+}
+
+func TestAuditLogger_WriteFailureDoesNotFailRequest(t *testing.T) {
+	audit, err := NewAuditLogger(filepath.Join(t.TempDir(), "audit.log"))
+	if err != nil {
+		t.Fatalf("NewAuditLogger: %v", err)
+	}
+	audit.Close() // every write now fails
+
+	router := NewRouter(&fakeProvider{}, nil, Options{Audit: audit})
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/services/web/start", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+}
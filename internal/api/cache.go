@@ -0,0 +1,66 @@
+package api
+
+import (
+	"sync"
+	"time"
+
+	"autorun/internal/models"
+)
+
+// listCache keeps recent ListServices results per scope so that a dashboard
+// polling every few seconds reuses a snapshot instead of shelling out each
+// time. A zero TTL disables it.
+type listCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[models.Scope]cachedList
+}
+
+type cachedList struct {
+	services []models.Service
+	at       time.Time
+}
+
+func newListCache(ttl time.Duration) *listCache {
+	return &listCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[models.Scope]cachedList),
+	}
+}
+
+// get returns a copy of the cached list for scope if it is still fresh
+func (c *listCache) get(scope models.Scope) ([]models.Service, bool) {
+	if c.ttl <= 0 {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[scope]
+	if !ok || c.now().Sub(entry.at) >= c.ttl {
+		return nil, false
+	}
+	// Callers sort and filter in place, so never hand out the cached slice
+	return append([]models.Service(nil), entry.services...), true
+}
+
+// put stores a copy of services as the latest list for scope
+func (c *listCache) put(scope models.Scope, services []models.Service) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[scope] = cachedList{services: append([]models.Service(nil), services...), at: c.now()}
+}
+
+// invalidate drops every cached list, e.g. after a service changed state
+func (c *listCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.entries)
+}
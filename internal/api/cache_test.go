@@ -0,0 +1,89 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"autorun/internal/models"
+)
+
+func listOnce(t *testing.T, router *Router) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/api/services?scope=user", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+}
+
+func TestListCache_ReusesRecentList(t *testing.T) {
+	provider := &fakeProvider{userServices: []models.Service{{Name: "b"}, {Name: "a"}}}
+	router := NewRouter(provider, nil, Options{ListCacheTTL: 2 * time.Second})
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	router.handler.lists.now = func() time.Time { return now }
+
+	listOnce(t, router)
+	listOnce(t, router)
+	if len(provider.listCalls) != 1 {
+		t.Fatalf("expected 1 provider call within the TTL, got %d", len(provider.listCalls))
+	}
+
+	// Sorting the response must not reorder the cached snapshot
+	if cached, _ := router.handler.lists.get(models.ScopeUser); cached[0].Name != "b" {
+		t.Fatalf("expected the cached list to be left unsorted, got %+v", cached)
+	}
+
+	now = now.Add(2 * time.Second)
+	listOnce(t, router)
+	if len(provider.listCalls) != 2 {
+		t.Fatalf("expected the list to be refetched after the TTL, got %d calls", len(provider.listCalls))
+	}
+}
+
+func TestListCache_InvalidatedByActions(t *testing.T) {
+	actions := []struct {
+		method  string
+		path    string
+		enabled bool
+	}{
+		{http.MethodPost, "/api/services/web/start?scope=user", false},
+		{http.MethodPost, "/api/services/web/stop?scope=user", false},
+		{http.MethodPost, "/api/services/web/restart?scope=user", false},
+		{http.MethodPost, "/api/services/web/enable?scope=user", false},
+		{http.MethodPost, "/api/services/web/disable?scope=user", true},
+		{http.MethodDelete, "/api/services/web?scope=user", false},
+	}
+	for _, action := range actions {
+		t.Run(action.path, func(t *testing.T) {
+			provider := &fakeProvider{
+				userServices: []models.Service{{Name: "web"}},
+				enabled:      map[string]bool{"web": action.enabled},
+			}
+			router := NewRouter(provider, nil, Options{ListCacheTTL: time.Hour})
+
+			listOnce(t, router)
+			req := httptest.NewRequest(action.method, action.path, nil)
+			router.ServeHTTP(httptest.NewRecorder(), req)
+			listOnce(t, router)
+
+			if len(provider.listCalls) != 2 {
+				t.Fatalf("expected the list to be refetched after the action, got %d calls", len(provider.listCalls))
+			}
+		})
+	}
+}
+
+func TestListCache_ZeroTTLDisables(t *testing.T) {
+	provider := &fakeProvider{userServices: []models.Service{{Name: "web"}}}
+	router := NewRouter(provider, nil, Options{})
+
+	listOnce(t, router)
+	listOnce(t, router)
+	if len(provider.listCalls) != 2 {
+		t.Fatalf("expected every request to reach the provider, got %d calls", len(provider.listCalls))
+	}
+}
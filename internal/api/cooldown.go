@@ -0,0 +1,58 @@
+package api
+
+import (
+	"sync"
+	"time"
+
+	"autorun/internal/models"
+)
+
+// restartCooldown rejects restarts of a service that was restarted within
+// the last window, so a runaway automation loop can't hammer one service
+type restartCooldown struct {
+	mu     sync.Mutex
+	window time.Duration
+	now    func() time.Time
+	last   map[serviceKey]time.Time
+}
+
+func newRestartCooldown(window time.Duration) *restartCooldown {
+	return &restartCooldown{
+		window: window,
+		now:    time.Now,
+		last:   make(map[serviceKey]time.Time),
+	}
+}
+
+// reserve records a restart of the service starting now. If the service is
+// still cooling down it returns false and the time remaining instead.
+func (c *restartCooldown) reserve(name string, scope models.Scope) (time.Duration, bool) {
+	if c.window <= 0 {
+		return 0, true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := serviceKey{name: name, scope: scope}
+	now := c.now()
+	if last, ok := c.last[key]; ok {
+		if remaining := last.Add(c.window).Sub(now); remaining > 0 {
+			return remaining, false
+		}
+	}
+	c.last[key] = now
+	return 0, true
+}
+
+// release forgets a reservation whose restart failed, so it can be retried
+// straight away
+func (c *restartCooldown) release(name string, scope models.Scope) {
+	if c.window <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.last, serviceKey{name: name, scope: scope})
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"autorun/internal/models"
+)
+
+func TestRestartCooldown(t *testing.T) {
+	provider := &fakeProvider{}
+	router := NewRouter(provider, nil, Options{RestartCooldown: time.Minute})
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	router.handler.cooldown.now = func() time.Time { return now }
+
+	restart := func(name string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/services/"+name+"/restart?scope=user", nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := restart("web"); rr.Code != http.StatusOK {
+		t.Fatalf("expected first restart to succeed, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	now = now.Add(40 * time.Second)
+	rr := restart("web")
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status %d within cooldown, got %d", http.StatusTooManyRequests, rr.Code)
+	}
+	if got := rr.Header().Get("Retry-After"); got != "20" {
+		t.Fatalf("expected Retry-After 20, got %q", got)
+	}
+
+	// Other services have their own cooldown
+	if rr := restart("db"); rr.Code != http.StatusOK {
+		t.Fatalf("expected restart of another service to succeed, got %d", rr.Code)
+	}
+
+	now = now.Add(20 * time.Second)
+	if rr := restart("web"); rr.Code != http.StatusOK {
+		t.Fatalf("expected restart after cooldown to succeed, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(provider.restartCalls) != 3 {
+		t.Fatalf("expected 3 restarts to reach the provider, got %d", len(provider.restartCalls))
+	}
+}
+
+func TestRestartCooldown_FailedRestartNotCounted(t *testing.T) {
+	provider := &fakeProvider{restartErr: map[string]error{"web": errors.New("boom")}}
+	router := NewRouter(provider, nil, Options{RestartCooldown: time.Minute})
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/api/services/web/restart", nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusInternalServerError {
+			t.Fatalf("attempt %d: expected status %d, got %d", i+1, http.StatusInternalServerError, rr.Code)
+		}
+	}
+}
+
+func TestRestartCooldown_RollingRestart(t *testing.T) {
+	provider := &fakeProvider{restartErr: map[string]error{"db": errors.New("boom")}}
+	router := NewRouter(provider, nil, Options{RestartCooldown: time.Minute})
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	router.handler.cooldown.now = func() time.Time { return now }
+
+	rolling := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/services/rolling-restart", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := rolling(`{"names":["web","api"]}`); rr.Code != http.StatusOK {
+		t.Fatalf("expected first rolling restart to succeed, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	now = now.Add(45 * time.Second)
+	rr := rolling(`{"names":["api","web"]}`)
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status %d within cooldown, got %d: %s", http.StatusTooManyRequests, rr.Code, rr.Body.String())
+	}
+	if got := rr.Header().Get("Retry-After"); got != "15" {
+		t.Fatalf("expected Retry-After 15, got %q", got)
+	}
+	var result rollingRestartResult
+	if err := json.NewDecoder(rr.Body).Decode(&result); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(result.Restarted) != 0 || result.Failed == nil || result.Failed.Name != "api" || result.Failed.RetryAfter != 15 {
+		t.Fatalf("expected to halt on api with retryAfter 15, got %+v", result)
+	}
+
+	// A single restart is held back by the rolling restart's reservation
+	req := httptest.NewRequest(http.MethodPost, "/api/services/web/restart", nil)
+	single := httptest.NewRecorder()
+	router.ServeHTTP(single, req)
+	if single.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected single restart within cooldown to get %d, got %d", http.StatusTooManyRequests, single.Code)
+	}
+
+	// A failed restart releases its reservation
+	for i := 0; i < 2; i++ {
+		if rr := rolling(`{"names":["db"]}`); rr.Code != http.StatusInternalServerError {
+			t.Fatalf("attempt %d: expected status %d, got %d: %s", i+1, http.StatusInternalServerError, rr.Code, rr.Body.String())
+		}
+	}
+	if len(provider.restartCalls) != 4 {
+		t.Fatalf("expected 4 restarts to reach the provider, got %d", len(provider.restartCalls))
+	}
+}
+
+func TestRestartCooldown_Disabled(t *testing.T) {
+	c := newRestartCooldown(0)
+	for i := 0; i < 3; i++ {
+		if _, ok := c.reserve("web", models.ScopeUser); !ok {
+			t.Fatalf("expected no limit when the cooldown is disabled")
+		}
+	}
+}
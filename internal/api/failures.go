@@ -0,0 +1,62 @@
+package api
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"autorun/internal/models"
+)
+
+// startFailure records a start attempt made through the API that failed
+type startFailure struct {
+	Name  string       `json:"name"`
+	Scope models.Scope `json:"scope"`
+	Error string       `json:"error"`
+	Time  time.Time    `json:"time"`
+}
+
+// failureTracker remembers the most recent failed start per service. A later
+// successful start clears the entry.
+type failureTracker struct {
+	mu       sync.Mutex
+	failures map[serviceKey]startFailure
+}
+
+// serviceKey identifies a service within a scope
+type serviceKey struct {
+	name  string
+	scope models.Scope
+}
+
+func newFailureTracker() *failureTracker {
+	return &failureTracker{failures: make(map[serviceKey]startFailure)}
+}
+
+// record stores the outcome of a start attempt
+func (t *failureTracker) record(name string, scope models.Scope, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := serviceKey{name: name, scope: scope}
+	if err == nil {
+		delete(t.failures, key)
+		return
+	}
+	t.failures[key] = startFailure{Name: name, Scope: scope, Error: err.Error(), Time: time.Now()}
+}
+
+// list returns recorded failures, most recent first
+func (t *failureTracker) list() []startFailure {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	failures := make([]startFailure, 0, len(t.failures))
+	for _, f := range t.failures {
+		failures = append(failures, f)
+	}
+	sort.Slice(failures, func(i, j int) bool {
+		return failures[i].Time.After(failures[j].Time)
+	})
+	return failures
+}
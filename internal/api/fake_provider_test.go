@@ -2,6 +2,7 @@ package api
 
 import (
 	"context"
+	"time"
 
 	"autorun/internal/models"
 )
@@ -12,9 +13,57 @@ type fakeProvider struct {
 	systemServices []models.Service
 	userServices   []models.Service
 
-	listCalls  []models.Scope
-	getCalls   []getCall
-	startCalls []serviceCall
+	// listErr makes ListServices fail for the given scope
+	listErr map[models.Scope]error
+
+	// statuses and enabled override the state GetService reports, keyed by name
+	statuses map[string]string
+	enabled  map[string]bool
+
+	// startErr makes Start fail for the given service name
+	startErr map[string]error
+
+	// restartErr makes Restart fail for the given service name
+	restartErr map[string]error
+
+	// streamErrs are returned by successive StreamLogs calls before it
+	// succeeds; streamLines (or streamEntries for StreamLogEntries) are
+	// then sent on the returned channel
+	streamErrs    []error
+	streamLines   []string
+	streamEntries []models.LogEntry
+
+	// streamDone, if set, keeps StreamLogs' channel open until the stream's
+	// context is cancelled and is then closed
+	streamDone chan struct{}
+
+	// processes is returned by Processes, keyed by name
+	processes map[string][]models.Process
+
+	// logCounts is returned by LogCounts; logSince records the last window start
+	logCounts models.LogCounts
+	logSince  time.Time
+
+	// resetErr is returned by ResetFailed
+	resetErr error
+
+	// getErr, createErr and deleteErr are returned by GetService,
+	// CreateService and DeleteService
+	getErr    error
+	createErr error
+	deleteErr error
+
+	listCalls    []models.Scope
+	getCalls     []getCall
+	stateCalls   [][]string
+	startCalls   []serviceCall
+	restartCalls []serviceCall
+	resetCalls   []serviceCall
+	runConfigs   []models.ServiceConfig
+	timerConfigs []models.TimerConfig
+	timerDeletes []serviceCall
+	streamCalls  int
+	streamOpts   models.LogStreamOptions
 }
 
 type serviceCall struct {
@@ -34,39 +83,148 @@ func (p *fakeProvider) Name() string {
 	return p.name
 }
 
-func (p *fakeProvider) ListServices(scope models.Scope) ([]models.Service, error) {
+func (p *fakeProvider) Capabilities() models.Capabilities {
+	return models.Capabilities{Reload: true, Timers: true, Dependencies: true, Logs: true}
+}
+
+func (p *fakeProvider) ListServices(ctx context.Context, scope models.Scope) ([]models.Service, error) {
 	p.listCalls = append(p.listCalls, scope)
+	if err := p.listErr[scope]; err != nil {
+		return nil, err
+	}
 	if scope == models.ScopeSystem {
 		return append([]models.Service(nil), p.systemServices...), nil
 	}
 	return append([]models.Service(nil), p.userServices...), nil
 }
 
-func (p *fakeProvider) GetService(name string, scope models.Scope) (*models.Service, error) {
+func (p *fakeProvider) GetService(ctx context.Context, name string, scope models.Scope) (*models.Service, error) {
 	p.getCalls = append(p.getCalls, getCall{name: name, scope: scope})
-	return &models.Service{Name: name, Scope: scope}, nil
+	if p.getErr != nil {
+		return nil, p.getErr
+	}
+	return &models.Service{Name: name, Scope: scope, Status: p.statuses[name], Enabled: p.enabled[name]}, nil
+}
+
+func (p *fakeProvider) ServiceStates(ctx context.Context, names []string, scope models.Scope) (map[string]models.ServiceState, error) {
+	p.stateCalls = append(p.stateCalls, names)
+	states := make(map[string]models.ServiceState, len(names))
+	for _, name := range names {
+		status, ok := p.statuses[name]
+		if !ok {
+			states[name] = models.ServiceState{Status: models.StatusUnknown, NotFound: true}
+			continue
+		}
+		states[name] = models.ServiceState{Status: status, Enabled: p.enabled[name]}
+	}
+	return states, nil
 }
 
-func (p *fakeProvider) Start(name string, scope models.Scope) error {
+func (p *fakeProvider) Start(ctx context.Context, name string, scope models.Scope) error {
 	p.startCalls = append(p.startCalls, serviceCall{name: name, scope: scope})
+	return p.startErr[name]
+}
+
+func (p *fakeProvider) Restart(ctx context.Context, name string, scope models.Scope) error {
+	p.restartCalls = append(p.restartCalls, serviceCall{name: name, scope: scope})
+	return p.restartErr[name]
+}
+
+func (p *fakeProvider) Stop(ctx context.Context, name string, scope models.Scope) error   { return nil }
+func (p *fakeProvider) Enable(ctx context.Context, name string, scope models.Scope) error { return nil }
+func (p *fakeProvider) Disable(ctx context.Context, name string, scope models.Scope) error {
 	return nil
 }
 
-func (p *fakeProvider) Stop(name string, scope models.Scope) error    { return nil }
-func (p *fakeProvider) Restart(name string, scope models.Scope) error { return nil }
-func (p *fakeProvider) Enable(name string, scope models.Scope) error  { return nil }
-func (p *fakeProvider) Disable(name string, scope models.Scope) error { return nil }
+func (p *fakeProvider) ResetFailed(ctx context.Context, name string, scope models.Scope) error {
+	p.resetCalls = append(p.resetCalls, serviceCall{name: name, scope: scope})
+	return p.resetErr
+}
 
-func (p *fakeProvider) StreamLogs(ctx context.Context, name string, scope models.Scope) (<-chan string, error) {
-	ch := make(chan string)
+func (p *fakeProvider) Processes(ctx context.Context, name string, scope models.Scope) ([]models.Process, error) {
+	if procs, ok := p.processes[name]; ok {
+		return procs, nil
+	}
+	return []models.Process{}, nil
+}
+
+func (p *fakeProvider) LogCounts(ctx context.Context, name string, scope models.Scope, since time.Time) (models.LogCounts, error) {
+	p.logSince = since
+	return p.logCounts, nil
+}
+
+func (p *fakeProvider) StreamLogs(ctx context.Context, name string, scope models.Scope, opts models.LogStreamOptions) (<-chan string, error) {
+	p.streamCalls++
+	p.streamOpts = opts
+	if len(p.streamErrs) > 0 {
+		err := p.streamErrs[0]
+		p.streamErrs = p.streamErrs[1:]
+		return nil, err
+	}
+	ch := make(chan string, len(p.streamLines))
+	for _, line := range p.streamLines {
+		ch <- line
+	}
+	if p.streamDone == nil {
+		close(ch)
+		return ch, nil
+	}
+	go func() {
+		<-ctx.Done()
+		close(ch)
+		close(p.streamDone)
+	}()
+	return ch, nil
+}
+
+func (p *fakeProvider) StreamLogEntries(ctx context.Context, name string, scope models.Scope, opts models.LogStreamOptions) (<-chan models.LogEntry, error) {
+	p.streamCalls++
+	p.streamOpts = opts
+	if len(p.streamErrs) > 0 {
+		err := p.streamErrs[0]
+		p.streamErrs = p.streamErrs[1:]
+		return nil, err
+	}
+	ch := make(chan models.LogEntry, len(p.streamEntries))
+	for _, entry := range p.streamEntries {
+		ch <- entry
+	}
 	close(ch)
 	return ch, nil
 }
 
-func (p *fakeProvider) CreateService(config models.ServiceConfig, scope models.Scope) error {
-	return nil
+func (p *fakeProvider) ServiceExists(ctx context.Context, name string, scope models.Scope) (bool, error) {
+	services := p.userServices
+	if scope == models.ScopeSystem {
+		services = p.systemServices
+	}
+	for _, svc := range services {
+		if svc.Name == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (p *fakeProvider) CreateService(ctx context.Context, config models.ServiceConfig, scope models.Scope) error {
+	return p.createErr
+}
+
+func (p *fakeProvider) DeleteService(ctx context.Context, name string, scope models.Scope) error {
+	return p.deleteErr
+}
+
+func (p *fakeProvider) RunTransient(ctx context.Context, config models.ServiceConfig, scope models.Scope) (string, error) {
+	p.runConfigs = append(p.runConfigs, config)
+	return "run-0badf00d", nil
+}
+
+func (p *fakeProvider) CreateTimer(ctx context.Context, config models.TimerConfig, scope models.Scope) ([]string, error) {
+	p.timerConfigs = append(p.timerConfigs, config)
+	return []string{config.Name + ".service", config.Name + ".timer"}, nil
 }
 
-func (p *fakeProvider) DeleteService(name string, scope models.Scope) error {
+func (p *fakeProvider) DeleteTimer(ctx context.Context, name string, scope models.Scope) error {
+	p.timerDeletes = append(p.timerDeletes, serviceCall{name: name, scope: scope})
 	return nil
 }
@@ -1,24 +1,117 @@
 package api
 
 import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
 	"net/http"
 	"os"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"autorun/internal/logger"
 	"autorun/internal/models"
 	"autorun/internal/platform"
 )
 
+// Options configures optional API behavior
+type Options struct {
+	// Version and Commit identify the running build for /api/version
+	Version string
+	Commit  string
+
+	// InstanceName labels this server when several instances are run side
+	// by side; reported by /api/platform and /api/version
+	InstanceName string
+
+	// StreamRetries is how many times a log stream that fails to start is
+	// retried before giving up (zero disables retries; see
+	// DefaultStreamRetries); StreamRetryBackoff is the delay before the
+	// first retry. StreamStartTimeout bounds each attempt to start a stream.
+	// Zero durations select the defaults.
+	StreamRetries      int
+	StreamRetryBackoff time.Duration
+	StreamStartTimeout time.Duration
+
+	// StreamPingInterval is how often log stream clients are pinged; a
+	// client that hasn't answered within two intervals is disconnected.
+	// Status event streams send a ping comment as often. Zero selects the
+	// default.
+	StreamPingInterval time.Duration
+
+	// WatchInterval is how often the status watcher polls for changes
+	// (zero selects DefaultWatchInterval)
+	WatchInterval time.Duration
+
+	// RestartCooldown rejects a restart with 429 if the same service was
+	// restarted less than this long ago (zero disables the limit)
+	RestartCooldown time.Duration
+
+	// ListCacheTTL is how long a service list is reused for repeated list
+	// requests; any action that changes a service clears it. Zero disables
+	// caching.
+	ListCacheTTL time.Duration
+
+	// MaxListSize caps how many services a list returns; longer lists are
+	// truncated and flagged. Zero selects DefaultMaxListSize.
+	MaxListSize int
+
+	// Audit records every change made to a service; nil disables auditing
+	Audit *AuditLogger
+
+	// Metrics collects counters served at /metrics; nil disables the
+	// endpoint
+	Metrics *Metrics
+
+	// AllowPatterns and DenyPatterns are globs restricting which services
+	// are listed and can be managed; see serviceAccess
+	AllowPatterns []string
+	DenyPatterns  []string
+
+	// ReadOnly refuses every request that would change a service with
+	// 403; lists, details and logs still work
+	ReadOnly bool
+
+	// CORSOrigins are the browser origins allowed to call the API from
+	// another site, e.g. a frontend dev server; "*" allows any. Empty sends
+	// no CORS headers.
+	CORSOrigins []string
+}
+
+// DefaultMaxListSize is the list cap used when Options.MaxListSize is unset
+const DefaultMaxListSize = 10000
+
 // Handler wraps the service provider and provides HTTP handlers
 type Handler struct {
 	provider platform.ServiceProvider
+	opts     Options
+	failures *failureTracker
+	watcher  *statusWatcher
+	cooldown *restartCooldown
+	lists    *listCache
+	access   serviceAccess
 }
 
 // NewHandler creates a new API handler
-func NewHandler(provider platform.ServiceProvider) *Handler {
-	return &Handler{provider: provider}
+func NewHandler(provider platform.ServiceProvider, opts Options) *Handler {
+	return &Handler{
+		provider: provider,
+		opts:     opts,
+		failures: newFailureTracker(),
+		watcher:  newStatusWatcher(provider, opts.WatchInterval),
+		cooldown: newRestartCooldown(opts.RestartCooldown),
+		lists:    newListCache(opts.ListCacheTTL),
+		access:   serviceAccess{allow: opts.AllowPatterns, deny: opts.DenyPatterns},
+	}
 }
 
 // jsonResponse writes a JSON response
@@ -28,199 +121,1058 @@ func jsonResponse(w http.ResponseWriter, status int, data interface{}) {
 	json.NewEncoder(w).Encode(data)
 }
 
-// errorResponse writes an error response
+// conditionalJSONResponse writes a 200 JSON response with an ETag, or 304
+// with no body if the client's If-None-Match already names it. The ETag
+// covers the query string as well as the body, so a list fetched with
+// different scope or filter parameters never matches.
+func conditionalJSONResponse(w http.ResponseWriter, r *http.Request, data interface{}) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	body = append(body, '\n')
+
+	hash := sha256.New()
+	hash.Write([]byte(r.URL.RawQuery))
+	hash.Write([]byte{0})
+	hash.Write(body)
+	etag := `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// etagMatches reports whether an If-None-Match header names etag, allowing
+// a list of tags, weak tags and "*"
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// errorResponse writes an error response. It includes the request ID the
+// router put in the response headers so users can quote it.
 func errorResponse(w http.ResponseWriter, status int, message string) {
-	jsonResponse(w, status, map[string]string{"error": message})
+	body := map[string]string{"error": message}
+	if id := w.Header().Get(requestIDHeader); id != "" {
+		body["requestId"] = id
+	}
+	jsonResponse(w, status, body)
+}
+
+// The errors reported with 403 responses for system and user services; the
+// provider's own message is kept in detail. Elevated permissions only help
+// with system services.
+const (
+	insufficientPrivileges     = "insufficient privileges; run with elevated permissions"
+	insufficientUserPrivileges = "insufficient privileges; run autorun as the user that owns the service"
+)
+
+// providerErrorResponse writes an error response for a failed provider call
+// on a service in scope, including the exit code of the underlying command
+// when one is known and a remediation hint for permission failures
+func providerErrorResponse(w http.ResponseWriter, status int, err error, scope models.Scope) {
+	body := map[string]interface{}{"error": err.Error()}
+	if status == http.StatusForbidden {
+		body["error"] = insufficientPrivileges
+		if scope == models.ScopeUser {
+			body["error"] = insufficientUserPrivileges
+		}
+		body["detail"] = err.Error()
+	}
+	if code, ok := platform.ExitCode(err); ok {
+		body["exitCode"] = code
+	}
+	if hint := platform.PermissionRemediation(err, scope); hint != "" {
+		body["remediation"] = hint
+	}
+	if id := w.Header().Get(requestIDHeader); id != "" {
+		body["requestId"] = id
+	}
+	jsonResponse(w, status, body)
+}
+
+// providerStatus maps a provider error to an HTTP status: 504 for
+// ErrTimeout, 404 for ErrNotFound, 409 for ErrAlreadyExists, 501 for
+// ErrNotSupported, 403 for ErrPermission and 500 for anything else
+func providerStatus(err error) int {
+	switch {
+	case errors.Is(err, platform.ErrTimeout):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, platform.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, platform.ErrAlreadyExists):
+		return http.StatusConflict
+	case errors.Is(err, platform.ErrNotSupported):
+		return http.StatusNotImplemented
+	case errors.Is(err, platform.ErrPermission):
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+// parseScope extracts and validates the scope from query parameters.
+// A missing scope defaults to user; an unrecognized one is an error.
+func parseScope(r *http.Request) (models.Scope, error) {
+	return scopeFromString(r.URL.Query().Get("scope"))
 }
 
-// parseScope extracts and validates the scope from query parameters
-func parseScope(r *http.Request) models.Scope {
-	scope := r.URL.Query().Get("scope")
+// scopeFromString validates a scope value, defaulting empty to user
+func scopeFromString(scope string) (models.Scope, error) {
 	switch scope {
+	case "":
+		return models.ScopeUser, nil
 	case "system":
-		return models.ScopeSystem
+		return models.ScopeSystem, nil
 	case "user":
-		return models.ScopeUser
+		return models.ScopeUser, nil
 	default:
-		return models.ScopeUser
+		return "", fmt.Errorf("invalid scope: %s", scope)
 	}
 }
 
+// validateServiceNames checks every name in a batch request with
+// models.ValidateServiceName
+func validateServiceNames(names []string) error {
+	for _, name := range names {
+		if err := models.ValidateServiceName(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // GetPlatform returns the current platform name and elevation status
 func (h *Handler) GetPlatform(w http.ResponseWriter, r *http.Request) {
 	jsonResponse(w, http.StatusOK, map[string]interface{}{
 		"platform": h.provider.Name(),
 		"elevated": os.Geteuid() == 0,
+		"instance": h.opts.InstanceName,
+	})
+}
+
+// GetCapabilities returns the optional features the platform supports
+func (h *Handler) GetCapabilities(w http.ResponseWriter, r *http.Request) {
+	caps := h.provider.Capabilities()
+	caps.ReadOnly = h.opts.ReadOnly
+	jsonResponse(w, http.StatusOK, caps)
+}
+
+// GetVersion returns build information for the running binary
+func (h *Handler) GetVersion(w http.ResponseWriter, r *http.Request) {
+	jsonResponse(w, http.StatusOK, map[string]string{
+		"version":   h.opts.Version,
+		"commit":    h.opts.Commit,
+		"goVersion": runtime.Version(),
+		"platform":  h.provider.Name(),
+		"instance":  h.opts.InstanceName,
 	})
 }
 
+// readyTimeout bounds how long the readiness probe waits on the provider
+const readyTimeout = 5 * time.Second
+
+// Healthz reports that the server is up
+func (h *Handler) Healthz(w http.ResponseWriter, r *http.Request) {
+	jsonResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Readyz reports whether the platform backend can be queried. It runs a
+// user-scope ListServices and returns 503 if it fails or times out.
+func (h *Handler) Readyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+
+	_, err := h.provider.ListServices(ctx, models.ScopeUser)
+	if errors.Is(err, platform.ErrTimeout) || errors.Is(err, context.DeadlineExceeded) {
+		logger.WarnContext(r.Context(), "readiness check timed out", "timeout", readyTimeout, "error", err)
+		jsonResponse(w, http.StatusServiceUnavailable, map[string]string{"status": "unavailable", "error": "provider timed out"})
+		return
+	}
+	if err != nil {
+		logger.WarnContext(r.Context(), "readiness check failed", "error", err)
+		jsonResponse(w, http.StatusServiceUnavailable, map[string]string{"status": "unavailable", "error": err.Error()})
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// listMeta describes how a service list was produced, so clients can tell a
+// legitimately empty list from a failed query
+type listMeta struct {
+	Queried       bool           `json:"queried"`
+	ScopesQueried []models.Scope `json:"scopesQueried"`
+	ScopesFailed  []models.Scope `json:"scopesFailed,omitempty"`
+
+	// Truncated is set when more services matched than MaxListSize allows
+	Truncated   bool `json:"truncated,omitempty"`
+	MaxListSize int  `json:"maxListSize,omitempty"`
+}
+
+// serviceList is the envelope returned by ListServices when paginating or
+// when ?meta=true. Total counts matching services before pagination.
+type serviceList struct {
+	Total int         `json:"total"`
+	Items interface{} `json:"items"` // []models.Service, or projected objects with ?fields=
+	Meta  *listMeta   `json:"meta,omitempty"`
+}
+
+// listServices lists a scope's services through the list cache, leaving
+// out services outside the permitted set
+func (h *Handler) listServices(ctx context.Context, scope models.Scope) ([]models.Service, error) {
+	if services, ok := h.lists.get(scope); ok {
+		logger.DebugContext(ctx, "using cached service list", "scope", scope)
+		return services, nil
+	}
+	services, err := h.provider.ListServices(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+	services = h.access.filter(services)
+	h.lists.put(scope, services)
+	return services, nil
+}
+
 // ListServices returns all services for the requested scope
 func (h *Handler) ListServices(w http.ResponseWriter, r *http.Request) {
 	scopeParam := r.URL.Query().Get("scope")
-	logger.Debug("listing services", "scope", scopeParam)
+	logger.DebugContext(r.Context(), "listing services", "scope", scopeParam)
+
+	withMeta := false
+	if v := r.URL.Query().Get("meta"); v != "" {
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			errorResponse(w, http.StatusBadRequest, "invalid meta value: "+v)
+			return
+		}
+		withMeta = parsed
+	}
+
+	filter, err := parseServiceFilter(r)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	order, err := parseSort(r)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, err := parsePagination(r)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	projection, err := parseFields(r)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	var allServices []models.Service
+	allServices := []models.Service{}
+	meta := &listMeta{Queried: true, ScopesQueried: []models.Scope{}}
 
 	if scopeParam == "all" || scopeParam == "" {
 		// Get both system and user services
-		systemServices, err := h.provider.ListServices(models.ScopeSystem)
+		systemServices, err := h.listServices(r.Context(), models.ScopeSystem)
 		if err != nil {
-			logger.Warn("failed to list system services", "error", err)
+			logger.WarnContext(r.Context(), "failed to list system services", "error", err)
+			meta.ScopesFailed = append(meta.ScopesFailed, models.ScopeSystem)
 		} else {
 			allServices = append(allServices, systemServices...)
-			logger.Debug("listed system services", "count", len(systemServices))
+			meta.ScopesQueried = append(meta.ScopesQueried, models.ScopeSystem)
+			logger.DebugContext(r.Context(), "listed system services", "count", len(systemServices))
 		}
 
-		userServices, err := h.provider.ListServices(models.ScopeUser)
+		userServices, err := h.listServices(r.Context(), models.ScopeUser)
 		if err != nil {
-			logger.Warn("failed to list user services", "error", err)
+			logger.WarnContext(r.Context(), "failed to list user services", "error", err)
+			meta.ScopesFailed = append(meta.ScopesFailed, models.ScopeUser)
 		} else {
 			allServices = append(allServices, userServices...)
-			logger.Debug("listed user services", "count", len(userServices))
+			meta.ScopesQueried = append(meta.ScopesQueried, models.ScopeUser)
+			logger.DebugContext(r.Context(), "listed user services", "count", len(userServices))
 		}
 	} else {
-		scope := parseScope(r)
-		services, err := h.provider.ListServices(scope)
+		scope, err := parseScope(r)
+		if err != nil {
+			errorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		services, err := h.listServices(r.Context(), scope)
 		if err != nil {
-			logger.Error("failed to list services", "scope", scope, "error", err)
-			errorResponse(w, http.StatusInternalServerError, err.Error())
+			logger.ErrorContext(r.Context(), "failed to list services", "scope", scope, "error", err)
+			providerErrorResponse(w, providerStatus(err), err, scope)
 			return
 		}
-		allServices = services
-		logger.Debug("listed services", "scope", scope, "count", len(services))
+		allServices = append(allServices, services...)
+		meta.ScopesQueried = append(meta.ScopesQueried, scope)
+		logger.DebugContext(r.Context(), "listed services", "scope", scope, "count", len(services))
 	}
 
-	jsonResponse(w, http.StatusOK, allServices)
+	allServices = filter.apply(allServices)
+	order.apply(allServices)
+
+	maxSize := h.opts.MaxListSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxListSize
+	}
+	if len(allServices) > maxSize {
+		logger.WarnContext(r.Context(), "service list truncated", "count", len(allServices), "max", maxSize)
+		allServices = allServices[:maxSize]
+		meta.Truncated = true
+		meta.MaxListSize = maxSize
+		w.Header().Set("X-Truncated", "true")
+	}
+
+	if !page.enabled && !withMeta {
+		conditionalJSONResponse(w, r, projection.apply(allServices))
+		return
+	}
+
+	list := serviceList{Total: len(allServices)}
+	if page.enabled {
+		list.Items = projection.apply(page.apply(allServices))
+	} else {
+		list.Items = projection.apply(allServices)
+	}
+	if withMeta {
+		list.Meta = meta
+	}
+	conditionalJSONResponse(w, r, list)
 }
 
 // GetService returns details for a specific service
 func (h *Handler) GetService(w http.ResponseWriter, r *http.Request, name string) {
-	scope := parseScope(r)
-	logger.Debug("getting service", "name", name, "scope", scope)
-	service, err := h.provider.GetService(name, scope)
+	scope, err := parseScope(r)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	logger.DebugContext(r.Context(), "getting service", "name", name, "scope", scope)
+	service, err := h.provider.GetService(r.Context(), name, scope)
 	if err != nil {
-		logger.Debug("service not found", "name", name, "scope", scope, "error", err)
-		errorResponse(w, http.StatusNotFound, err.Error())
+		logger.DebugContext(r.Context(), "failed to get service", "name", name, "scope", scope, "error", err)
+		providerErrorResponse(w, providerStatus(err), err, scope)
 		return
 	}
 	jsonResponse(w, http.StatusOK, service)
 }
 
+// ListProcesses returns the processes belonging to a service
+func (h *Handler) ListProcesses(w http.ResponseWriter, r *http.Request, name string) {
+	scope, err := parseScope(r)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	logger.DebugContext(r.Context(), "listing service processes", "name", name, "scope", scope)
+	processes, err := h.provider.Processes(r.Context(), name, scope)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "failed to list service processes", "name", name, "scope", scope, "error", err)
+		providerErrorResponse(w, providerStatus(err), err, scope)
+		return
+	}
+	jsonResponse(w, http.StatusOK, processes)
+}
+
+// actionResponse reports the outcome of a service action. changed is false
+// when the service was already in the requested state and nothing was done.
+func actionResponse(w http.ResponseWriter, status string, changed bool) {
+	jsonResponse(w, http.StatusOK, map[string]interface{}{"status": status, "changed": changed})
+}
+
+// Target states checked by alreadyInState
+func isRunning(svc *models.Service) bool  { return svc.Status == models.StatusRunning }
+func isStopped(svc *models.Service) bool  { return svc.Status == models.StatusStopped }
+func isEnabled(svc *models.Service) bool  { return svc.Enabled }
+func isDisabled(svc *models.Service) bool { return !svc.Enabled }
+
+// alreadyInState reports whether the service is already in the state an
+// action would put it in. If the current state can't be read it returns
+// false, so the action still runs and reports its own error.
+func (h *Handler) alreadyInState(ctx context.Context, name string, scope models.Scope, inState func(*models.Service) bool) bool {
+	svc, err := h.provider.GetService(ctx, name, scope)
+	if err != nil {
+		logger.DebugContext(ctx, "could not read service state before action", "name", name, "scope", scope, "error", err)
+		return false
+	}
+	return inState(svc)
+}
+
+// ServiceExists reports whether a service of that name is already defined,
+// so a create form can warn before it is submitted
+func (h *Handler) ServiceExists(w http.ResponseWriter, r *http.Request, name string) {
+	scope, err := parseScope(r)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	exists, err := h.provider.ServiceExists(r.Context(), name, scope)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "failed to check whether service exists", "name", name, "scope", scope, "error", err)
+		providerErrorResponse(w, providerStatus(err), err, scope)
+		return
+	}
+	jsonResponse(w, http.StatusOK, map[string]bool{"exists": exists})
+}
+
+// ErrorCount returns how many warning- and error-level log entries a service
+// produced since boot, or within ?since=-1h (a Go duration, sign optional)
+func (h *Handler) ErrorCount(w http.ResponseWriter, r *http.Request, name string) {
+	scope, err := parseScope(r)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var since time.Time
+	window := "boot"
+	if v := r.URL.Query().Get("since"); v != "" {
+		d, err := time.ParseDuration(strings.TrimPrefix(v, "-"))
+		if err != nil || d <= 0 {
+			errorResponse(w, http.StatusBadRequest, "invalid since: "+v)
+			return
+		}
+		since = time.Now().Add(-d)
+		window = d.String()
+	}
+
+	logger.DebugContext(r.Context(), "counting service log errors", "name", name, "scope", scope, "window", window)
+	counts, err := h.provider.LogCounts(r.Context(), name, scope, since)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "failed to count service log errors", "name", name, "scope", scope, "error", err)
+		providerErrorResponse(w, providerStatus(err), err, scope)
+		return
+	}
+	counts.Window = window
+	jsonResponse(w, http.StatusOK, counts)
+}
+
 // StartService starts a service
 func (h *Handler) StartService(w http.ResponseWriter, r *http.Request, name string) {
-	scope := parseScope(r)
-	logger.Info("starting service", "name", name, "scope", scope)
-	if err := h.provider.Start(name, scope); err != nil {
-		logger.Error("failed to start service", "name", name, "scope", scope, "error", err)
-		errorResponse(w, http.StatusInternalServerError, err.Error())
+	scope, err := parseScope(r)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if h.alreadyInState(r.Context(), name, scope, isRunning) {
+		logger.DebugContext(r.Context(), "service already running", "name", name, "scope", scope)
+		h.failures.record(name, scope, nil)
+		actionResponse(w, "started", false)
 		return
 	}
-	logger.Info("service started", "name", name, "scope", scope)
-	jsonResponse(w, http.StatusOK, map[string]string{"status": "started"})
+	logger.InfoContext(r.Context(), "starting service", "name", name, "scope", scope)
+	err = h.provider.Start(r.Context(), name, scope)
+	h.lists.invalidate()
+	h.recordChange(r, "start", name, scope, err)
+	h.failures.record(name, scope, err)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "failed to start service", "name", name, "scope", scope, "error", err)
+		providerErrorResponse(w, providerStatus(err), err, scope)
+		return
+	}
+	logger.InfoContext(r.Context(), "service started", "name", name, "scope", scope)
+	actionResponse(w, "started", true)
+}
+
+// RecentFailures returns services whose most recent start through the API
+// failed, most recent first
+func (h *Handler) RecentFailures(w http.ResponseWriter, r *http.Request) {
+	failures := h.failures.list()
+	failures = slices.DeleteFunc(failures, func(f startFailure) bool { return !h.access.permits(f.Name) })
+	jsonResponse(w, http.StatusOK, failures)
+}
+
+// recordChange audits a change made to a service and counts it in the
+// metrics
+func (h *Handler) recordChange(r *http.Request, action, name string, scope models.Scope, err error) {
+	h.opts.Audit.record(action, name, scope, r.RemoteAddr, err)
+	h.opts.Metrics.serviceAction(action, err)
+}
+
+// StatusEvents streams service status changes as server-sent events. The
+// watcher only polls the provider while at least one client is connected.
+func (h *Handler) StatusEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		errorResponse(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	events, unsubscribe := h.watcher.subscribe()
+	defer unsubscribe()
+
+	// The stream outlives the server's write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	// Pings keep proxies from closing a quiet stream and notice a client
+	// that has gone away without the request context ending
+	pingInterval := h.opts.StreamPingInterval
+	if pingInterval <= 0 {
+		pingInterval = defaultPingInterval
+	}
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	logger.DebugContext(r.Context(), "status event client connected", "remote", r.RemoteAddr)
+	for {
+		select {
+		case <-r.Context().Done():
+			logger.DebugContext(r.Context(), "status event client disconnected", "remote", r.RemoteAddr)
+			return
+		case <-ticker.C:
+			if _, err := io.WriteString(w, ": ping\n\n"); err != nil {
+				logger.DebugContext(r.Context(), "status event ping failed", "remote", r.RemoteAddr, "error", err)
+				return
+			}
+			flusher.Flush()
+		case batch := <-events:
+			for _, event := range batch {
+				if !h.access.permits(event.Service.Name) {
+					continue
+				}
+				data, err := json.Marshal(event)
+				if err != nil {
+					continue
+				}
+				if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+					logger.DebugContext(r.Context(), "status event write failed", "remote", r.RemoteAddr, "error", err)
+					return
+				}
+			}
+			flusher.Flush()
+		}
+	}
 }
 
 // StopService stops a service
 func (h *Handler) StopService(w http.ResponseWriter, r *http.Request, name string) {
-	scope := parseScope(r)
-	logger.Info("stopping service", "name", name, "scope", scope)
-	if err := h.provider.Stop(name, scope); err != nil {
-		logger.Error("failed to stop service", "name", name, "scope", scope, "error", err)
-		errorResponse(w, http.StatusInternalServerError, err.Error())
+	scope, err := parseScope(r)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if h.alreadyInState(r.Context(), name, scope, isStopped) {
+		logger.DebugContext(r.Context(), "service already stopped", "name", name, "scope", scope)
+		actionResponse(w, "stopped", false)
+		return
+	}
+	logger.InfoContext(r.Context(), "stopping service", "name", name, "scope", scope)
+	err = h.provider.Stop(r.Context(), name, scope)
+	h.lists.invalidate()
+	h.recordChange(r, "stop", name, scope, err)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "failed to stop service", "name", name, "scope", scope, "error", err)
+		providerErrorResponse(w, providerStatus(err), err, scope)
 		return
 	}
-	logger.Info("service stopped", "name", name, "scope", scope)
-	jsonResponse(w, http.StatusOK, map[string]string{"status": "stopped"})
+	logger.InfoContext(r.Context(), "service stopped", "name", name, "scope", scope)
+	actionResponse(w, "stopped", true)
 }
 
 // RestartService restarts a service
 func (h *Handler) RestartService(w http.ResponseWriter, r *http.Request, name string) {
-	scope := parseScope(r)
-	logger.Info("restarting service", "name", name, "scope", scope)
-	if err := h.provider.Restart(name, scope); err != nil {
-		logger.Error("failed to restart service", "name", name, "scope", scope, "error", err)
-		errorResponse(w, http.StatusInternalServerError, err.Error())
+	scope, err := parseScope(r)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if remaining, ok := h.cooldown.reserve(name, scope); !ok {
+		logger.WarnContext(r.Context(), "restart rejected during cooldown", "name", name, "scope", scope, "remaining", remaining)
+		retryAfter := int(math.Ceil(remaining.Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		jsonResponse(w, http.StatusTooManyRequests, map[string]interface{}{
+			"error":      fmt.Sprintf("service %s was restarted recently; retry in %s", name, remaining.Round(time.Second)),
+			"retryAfter": retryAfter,
+		})
+		return
+	}
+	logger.InfoContext(r.Context(), "restarting service", "name", name, "scope", scope)
+	err = h.provider.Restart(r.Context(), name, scope)
+	h.lists.invalidate()
+	h.recordChange(r, "restart", name, scope, err)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "failed to restart service", "name", name, "scope", scope, "error", err)
+		h.cooldown.release(name, scope)
+		providerErrorResponse(w, providerStatus(err), err, scope)
 		return
 	}
-	logger.Info("service restarted", "name", name, "scope", scope)
-	jsonResponse(w, http.StatusOK, map[string]string{"status": "restarted"})
+	logger.InfoContext(r.Context(), "service restarted", "name", name, "scope", scope)
+	actionResponse(w, "restarted", true)
+}
+
+// ResetFailed clears a service's failed state (systemd only)
+func (h *Handler) ResetFailed(w http.ResponseWriter, r *http.Request, name string) {
+	scope, err := parseScope(r)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	logger.InfoContext(r.Context(), "resetting failed state", "name", name, "scope", scope)
+	err = h.provider.ResetFailed(r.Context(), name, scope)
+	h.lists.invalidate()
+	h.recordChange(r, "reset-failed", name, scope, err)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "failed to reset failed state", "name", name, "scope", scope, "error", err)
+		providerErrorResponse(w, providerStatus(err), err, scope)
+		return
+	}
+	logger.InfoContext(r.Context(), "failed state reset", "name", name, "scope", scope)
+	actionResponse(w, "reset", true)
 }
 
 // EnableService enables a service
 func (h *Handler) EnableService(w http.ResponseWriter, r *http.Request, name string) {
-	scope := parseScope(r)
-	logger.Info("enabling service", "name", name, "scope", scope)
-	if err := h.provider.Enable(name, scope); err != nil {
-		logger.Error("failed to enable service", "name", name, "scope", scope, "error", err)
-		errorResponse(w, http.StatusInternalServerError, err.Error())
+	scope, err := parseScope(r)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	logger.Info("service enabled", "name", name, "scope", scope)
-	jsonResponse(w, http.StatusOK, map[string]string{"status": "enabled"})
+	if h.alreadyInState(r.Context(), name, scope, isEnabled) {
+		logger.DebugContext(r.Context(), "service already enabled", "name", name, "scope", scope)
+		actionResponse(w, "enabled", false)
+		return
+	}
+	logger.InfoContext(r.Context(), "enabling service", "name", name, "scope", scope)
+	err = h.provider.Enable(r.Context(), name, scope)
+	h.lists.invalidate()
+	h.recordChange(r, "enable", name, scope, err)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "failed to enable service", "name", name, "scope", scope, "error", err)
+		providerErrorResponse(w, providerStatus(err), err, scope)
+		return
+	}
+	logger.InfoContext(r.Context(), "service enabled", "name", name, "scope", scope)
+	actionResponse(w, "enabled", true)
 }
 
 // DisableService disables a service
 func (h *Handler) DisableService(w http.ResponseWriter, r *http.Request, name string) {
-	scope := parseScope(r)
-	logger.Info("disabling service", "name", name, "scope", scope)
-	if err := h.provider.Disable(name, scope); err != nil {
-		logger.Error("failed to disable service", "name", name, "scope", scope, "error", err)
-		errorResponse(w, http.StatusInternalServerError, err.Error())
+	scope, err := parseScope(r)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if h.alreadyInState(r.Context(), name, scope, isDisabled) {
+		logger.DebugContext(r.Context(), "service already disabled", "name", name, "scope", scope)
+		actionResponse(w, "disabled", false)
+		return
+	}
+	logger.InfoContext(r.Context(), "disabling service", "name", name, "scope", scope)
+	err = h.provider.Disable(r.Context(), name, scope)
+	h.lists.invalidate()
+	h.recordChange(r, "disable", name, scope, err)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "failed to disable service", "name", name, "scope", scope, "error", err)
+		providerErrorResponse(w, providerStatus(err), err, scope)
 		return
 	}
-	logger.Info("service disabled", "name", name, "scope", scope)
-	jsonResponse(w, http.StatusOK, map[string]string{"status": "disabled"})
+	logger.InfoContext(r.Context(), "service disabled", "name", name, "scope", scope)
+	actionResponse(w, "disabled", true)
 }
 
 // CreateService creates a new service
 func (h *Handler) CreateService(w http.ResponseWriter, r *http.Request) {
-	scope := parseScope(r)
+	scope, err := parseScope(r)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	var config models.ServiceConfig
 	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
-		logger.Warn("invalid create service request body", "error", err)
+		logger.WarnContext(r.Context(), "invalid create service request body", "error", err)
 		errorResponse(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
 		return
 	}
 
-	// Validate required fields
-	if config.Name == "" {
-		logger.Warn("create service missing name")
-		errorResponse(w, http.StatusBadRequest, "Service name is required")
+	if err := config.Validate(); err != nil {
+		logger.WarnContext(r.Context(), "invalid service config", "name", config.Name, "error", err)
+		errorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	if config.Program == "" {
-		logger.Warn("create service missing program", "name", config.Name)
-		errorResponse(w, http.StatusBadRequest, "Program path is required")
+	if !h.access.permits(config.Name) {
+		logger.WarnContext(r.Context(), "rejected service outside the permitted set", "name", config.Name)
+		forbiddenService(w, config.Name)
+		return
+	}
+	if !models.ValidServiceType(config.Type) {
+		logger.WarnContext(r.Context(), "create service with unknown type", "name", config.Name, "type", config.Type)
+		errorResponse(w, http.StatusBadRequest, fmt.Sprintf("Unknown service type %q (expected one of %s)", config.Type, strings.Join(models.ServiceTypes, ", ")))
+		return
+	}
+	if !models.ValidRestartPolicy(config.RestartPolicy) {
+		logger.WarnContext(r.Context(), "create service with unknown restart policy", "name", config.Name, "restartPolicy", config.RestartPolicy)
+		errorResponse(w, http.StatusBadRequest, fmt.Sprintf("Unknown restart policy %q (expected one of %s)", config.RestartPolicy, strings.Join(models.RestartPolicies, ", ")))
+		return
+	}
+	if (config.User != "" || config.Group != "") && scope != models.ScopeSystem {
+		logger.WarnContext(r.Context(), "create service with user/group in user scope", "name", config.Name)
+		errorResponse(w, http.StatusBadRequest, "User and group can only be set for system services")
 		return
 	}
 
-	logger.Info("creating service", "name", config.Name, "program", config.Program, "scope", scope)
-	if err := h.provider.CreateService(config, scope); err != nil {
-		logger.Error("failed to create service", "name", config.Name, "scope", scope, "error", err)
-		errorResponse(w, http.StatusInternalServerError, err.Error())
+	logger.InfoContext(r.Context(), "creating service", "name", config.Name, "program", config.Program, "scope", scope)
+	err = h.provider.CreateService(r.Context(), config, scope)
+	h.lists.invalidate()
+	h.recordChange(r, "create", config.Name, scope, err)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "failed to create service", "name", config.Name, "scope", scope, "error", err)
+		providerErrorResponse(w, providerStatus(err), err, scope)
 		return
 	}
 
-	logger.Info("service created", "name", config.Name, "scope", scope)
+	logger.InfoContext(r.Context(), "service created", "name", config.Name, "scope", scope)
 	jsonResponse(w, http.StatusCreated, map[string]string{
 		"status": "created",
 		"name":   config.Name,
 	})
 }
 
+// RunTransient runs a command under the service manager without creating a
+// service, returning the generated name to stream logs from or stop
+func (h *Handler) RunTransient(w http.ResponseWriter, r *http.Request) {
+	scope, err := parseScope(r)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var config models.ServiceConfig
+	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+		logger.WarnContext(r.Context(), "invalid run request body", "error", err)
+		errorResponse(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+
+	if config.Name != "" {
+		errorResponse(w, http.StatusBadRequest, "Transient runs are named automatically; omit name")
+		return
+	}
+	// The generated name can't be checked against an allowlist up front
+	if h.access.restricted() {
+		errorResponse(w, http.StatusForbidden, "Transient runs aren't available when services are restricted with -allow-pattern")
+		return
+	}
+	if config.Program == "" {
+		errorResponse(w, http.StatusBadRequest, "Program path is required")
+		return
+	}
+	if (config.User != "" || config.Group != "") && scope != models.ScopeSystem {
+		errorResponse(w, http.StatusBadRequest, "User and group can only be set for system services")
+		return
+	}
+
+	logger.InfoContext(r.Context(), "starting transient run", "program", config.Program, "scope", scope)
+	name, err := h.provider.RunTransient(r.Context(), config, scope)
+	h.lists.invalidate()
+	// A failed run has no name; the program says what was attempted
+	h.recordChange(r, "run", cmp.Or(name, config.Program), scope, err)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "failed to start transient run", "program", config.Program, "scope", scope, "error", err)
+		providerErrorResponse(w, providerStatus(err), err, scope)
+		return
+	}
+
+	logger.InfoContext(r.Context(), "transient run started", "name", name, "scope", scope)
+	jsonResponse(w, http.StatusCreated, map[string]string{
+		"status": "started",
+		"name":   name,
+	})
+}
+
 // DeleteService deletes a service
 func (h *Handler) DeleteService(w http.ResponseWriter, r *http.Request, name string) {
-	scope := parseScope(r)
-	logger.Info("deleting service", "name", name, "scope", scope)
-	if err := h.provider.DeleteService(name, scope); err != nil {
-		logger.Error("failed to delete service", "name", name, "scope", scope, "error", err)
-		errorResponse(w, http.StatusInternalServerError, err.Error())
+	scope, err := parseScope(r)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	logger.Info("service deleted", "name", name, "scope", scope)
+	logger.InfoContext(r.Context(), "deleting service", "name", name, "scope", scope)
+	err = h.provider.DeleteService(r.Context(), name, scope)
+	h.lists.invalidate()
+	h.recordChange(r, "delete", name, scope, err)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "failed to delete service", "name", name, "scope", scope, "error", err)
+		providerErrorResponse(w, providerStatus(err), err, scope)
+		return
+	}
+	logger.InfoContext(r.Context(), "service deleted", "name", name, "scope", scope)
 	jsonResponse(w, http.StatusOK, map[string]string{"status": "deleted"})
 }
 
+// CreateTimer creates a scheduled job
+func (h *Handler) CreateTimer(w http.ResponseWriter, r *http.Request) {
+	scope, err := parseScope(r)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var config models.TimerConfig
+	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+		logger.WarnContext(r.Context(), "invalid create timer request body", "error", err)
+		errorResponse(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+
+	if err := config.Validate(); err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !h.access.permits(config.Name) {
+		logger.WarnContext(r.Context(), "rejected timer outside the permitted set", "name", config.Name)
+		forbiddenService(w, config.Name)
+		return
+	}
+
+	logger.InfoContext(r.Context(), "creating timer", "name", config.Name, "onCalendar", config.OnCalendar, "scope", scope)
+	units, err := h.provider.CreateTimer(r.Context(), config, scope)
+	h.lists.invalidate()
+	h.recordChange(r, "create-timer", config.Name, scope, err)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "failed to create timer", "name", config.Name, "scope", scope, "error", err)
+		providerErrorResponse(w, providerStatus(err), err, scope)
+		return
+	}
+
+	logger.InfoContext(r.Context(), "timer created", "name", config.Name, "scope", scope, "units", units)
+	jsonResponse(w, http.StatusCreated, map[string]interface{}{
+		"status": "created",
+		"name":   config.Name,
+		"units":  units,
+	})
+}
+
+// DeleteTimer deletes a scheduled job
+func (h *Handler) DeleteTimer(w http.ResponseWriter, r *http.Request, name string) {
+	scope, err := parseScope(r)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	logger.InfoContext(r.Context(), "deleting timer", "name", name, "scope", scope)
+	err = h.provider.DeleteTimer(r.Context(), name, scope)
+	h.lists.invalidate()
+	h.recordChange(r, "delete-timer", name, scope, err)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "failed to delete timer", "name", name, "scope", scope, "error", err)
+		providerErrorResponse(w, providerStatus(err), err, scope)
+		return
+	}
+	logger.InfoContext(r.Context(), "timer deleted", "name", name, "scope", scope)
+	jsonResponse(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// defaultHealthyTimeout is how long a rolling restart waits for each service
+// to report running when no timeout is given
+const defaultHealthyTimeout = 30 * time.Second
+
+// healthPollInterval is how often a restarted service's status is polled
+var healthPollInterval = 500 * time.Millisecond
+
+// maxStatusNames caps how many services a bulk status query may name
+const maxStatusNames = 500
+
+// statusRequest is the body of POST /api/services/status
+type statusRequest struct {
+	Names []string     `json:"names"`
+	Scope models.Scope `json:"scope"`
+}
+
+// ServiceStatuses returns the current state of the named services, keyed
+// by name, without listing every service
+func (h *Handler) ServiceStatuses(w http.ResponseWriter, r *http.Request) {
+	var req statusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.WarnContext(r.Context(), "invalid status request body", "error", err)
+		errorResponse(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+
+	if len(req.Names) == 0 {
+		errorResponse(w, http.StatusBadRequest, "At least one service name is required")
+		return
+	}
+	if len(req.Names) > maxStatusNames {
+		errorResponse(w, http.StatusBadRequest, fmt.Sprintf("At most %d service names may be queried at once", maxStatusNames))
+		return
+	}
+	if err := validateServiceNames(req.Names); err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if name := h.access.firstDenied(req.Names); name != "" {
+		forbiddenService(w, name)
+		return
+	}
+
+	scope, err := scopeFromString(string(req.Scope))
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	logger.DebugContext(r.Context(), "querying service states", "count", len(req.Names), "scope", scope)
+	states, err := h.provider.ServiceStates(r.Context(), req.Names, scope)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "failed to query service states", "scope", scope, "error", err)
+		providerErrorResponse(w, providerStatus(err), err, scope)
+		return
+	}
+	jsonResponse(w, http.StatusOK, states)
+}
+
+// rollingRestartRequest is the body of POST /api/services/rolling-restart
+type rollingRestartRequest struct {
+	Names       []string     `json:"names"`
+	Scope       models.Scope `json:"scope"`
+	WaitHealthy bool         `json:"waitHealthy"`
+	Timeout     string       `json:"timeout"` // Go duration, e.g. "30s"
+}
+
+// rollingRestartFailure identifies the service that halted a rolling restart
+type rollingRestartFailure struct {
+	Name     string `json:"name"`
+	Error    string `json:"error"`
+	ExitCode *int   `json:"exitCode,omitempty"`
+
+	// RetryAfter is set, in seconds, when the service was still in its
+	// restart cooldown
+	RetryAfter int `json:"retryAfter,omitempty"`
+}
+
+// rollingRestartResult reports the outcome of a rolling restart
+type rollingRestartResult struct {
+	Restarted []string               `json:"restarted"`
+	Failed    *rollingRestartFailure `json:"failed,omitempty"`
+}
+
+// RollingRestart restarts a group of services one at a time, optionally
+// waiting for each to report running before moving on. It stops at the first
+// failure and reports which services were restarted.
+func (h *Handler) RollingRestart(w http.ResponseWriter, r *http.Request) {
+	var req rollingRestartRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.WarnContext(r.Context(), "invalid rolling restart request body", "error", err)
+		errorResponse(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+
+	if len(req.Names) == 0 {
+		errorResponse(w, http.StatusBadRequest, "At least one service name is required")
+		return
+	}
+	if err := validateServiceNames(req.Names); err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if name := h.access.firstDenied(req.Names); name != "" {
+		forbiddenService(w, name)
+		return
+	}
+
+	scope, err := scopeFromString(string(req.Scope))
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	timeout := defaultHealthyTimeout
+	if req.Timeout != "" {
+		parsed, err := time.ParseDuration(req.Timeout)
+		if err != nil || parsed <= 0 {
+			errorResponse(w, http.StatusBadRequest, "invalid timeout: "+req.Timeout)
+			return
+		}
+		timeout = parsed
+	}
+
+	logger.InfoContext(r.Context(), "rolling restart", "names", req.Names, "scope", scope, "waitHealthy", req.WaitHealthy)
+
+	result := rollingRestartResult{Restarted: []string{}}
+	for _, name := range req.Names {
+		// Rolling restarts count towards the same cooldown as single ones
+		if remaining, ok := h.cooldown.reserve(name, scope); !ok {
+			logger.WarnContext(r.Context(), "rolling restart halted during cooldown", "name", name, "scope", scope, "remaining", remaining)
+			retryAfter := int(math.Ceil(remaining.Seconds()))
+			result.Failed = &rollingRestartFailure{
+				Name:       name,
+				Error:      fmt.Sprintf("service %s was restarted recently; retry in %s", name, remaining.Round(time.Second)),
+				RetryAfter: retryAfter,
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			jsonResponse(w, http.StatusTooManyRequests, result)
+			return
+		}
+
+		err := h.provider.Restart(r.Context(), name, scope)
+		h.lists.invalidate()
+		h.recordChange(r, "restart", name, scope, err)
+		if err != nil {
+			h.cooldown.release(name, scope)
+		}
+		if err == nil && req.WaitHealthy {
+			err = h.waitRunning(r.Context(), name, scope, timeout)
+		}
+		if err != nil {
+			logger.ErrorContext(r.Context(), "rolling restart halted", "name", name, "scope", scope, "error", err)
+			result.Failed = &rollingRestartFailure{Name: name, Error: err.Error()}
+			if code, ok := platform.ExitCode(err); ok {
+				result.Failed.ExitCode = &code
+			}
+			jsonResponse(w, providerStatus(err), result)
+			return
+		}
+		result.Restarted = append(result.Restarted, name)
+	}
+
+	logger.InfoContext(r.Context(), "rolling restart complete", "names", req.Names, "scope", scope)
+	jsonResponse(w, http.StatusOK, result)
+}
+
+// waitRunning polls a service until it reports running or timeout elapses
+func (h *Handler) waitRunning(ctx context.Context, name string, scope models.Scope, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		service, err := h.provider.GetService(ctx, name, scope)
+		if err == nil && service.Status == models.StatusRunning {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("service %s did not become healthy within %s", name, timeout)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(healthPollInterval):
+		}
+	}
+}
+
 // extractServiceName extracts the service name from the URL path
 // Expects paths like /api/services/{name}/action
 func extractServiceName(path string) string {
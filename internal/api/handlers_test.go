@@ -1,40 +1,113 @@
 package api
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"autorun/internal/models"
+	"autorun/internal/platform"
 )
 
+// decodedServiceList decodes a serviceList envelope with unprojected items
+type decodedServiceList struct {
+	Total int              `json:"total"`
+	Items []models.Service `json:"items"`
+	Meta  *listMeta        `json:"meta"`
+}
+
+func TestProviderStatus(t *testing.T) {
+	cases := []struct {
+		err  error
+		want int
+	}{
+		{err: fmt.Errorf("service %w: web", platform.ErrNotFound), want: http.StatusNotFound},
+		{err: fmt.Errorf("service web %w", platform.ErrAlreadyExists), want: http.StatusConflict},
+		{err: fmt.Errorf("reset-failed: %w", platform.ErrNotSupported), want: http.StatusNotImplemented},
+		{err: &fs.PathError{Op: "open", Path: "/etc/systemd/system/web.service", Err: fs.ErrPermission}, want: http.StatusForbidden},
+		{err: fmt.Errorf("systemctl start web.service %w after 1m30s", platform.ErrTimeout), want: http.StatusGatewayTimeout},
+		{err: errors.New("exit status 1"), want: http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		if got := providerStatus(tc.err); got != tc.want {
+			t.Errorf("providerStatus(%v) = %d, want %d", tc.err, got, tc.want)
+		}
+	}
+}
+
 func TestParseScope_DefaultsToUser(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/api/services", nil)
-	if got := parseScope(req); got != models.ScopeUser {
+	got, err := parseScope(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != models.ScopeUser {
 		t.Fatalf("expected %q, got %q", models.ScopeUser, got)
 	}
 }
 
 func TestParseScope_System(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/api/services?scope=system", nil)
-	if got := parseScope(req); got != models.ScopeSystem {
+	got, err := parseScope(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != models.ScopeSystem {
 		t.Fatalf("expected %q, got %q", models.ScopeSystem, got)
 	}
 }
 
 func TestParseScope_User(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/api/services?scope=user", nil)
-	if got := parseScope(req); got != models.ScopeUser {
+	got, err := parseScope(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != models.ScopeUser {
 		t.Fatalf("expected %q, got %q", models.ScopeUser, got)
 	}
 }
 
+func TestParseScope_Invalid(t *testing.T) {
+	for _, scope := range []string{"systen", "all", "USER"} {
+		t.Run(scope, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/services?scope="+scope, nil)
+			if got, err := parseScope(req); err == nil {
+				t.Fatalf("expected error, got %q", got)
+			}
+		})
+	}
+}
+
+func TestListServices_InvalidScope(t *testing.T) {
+	provider := &fakeProvider{}
+	h := NewHandler(provider, Options{})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/services?scope=systen", nil)
+	rr := httptest.NewRecorder()
+	h.ListServices(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+	if len(provider.listCalls) != 0 {
+		t.Fatalf("expected no ListServices calls, got %d", len(provider.listCalls))
+	}
+}
+
 func TestListServices_ScopeAll_Default(t *testing.T) {
 	provider := &fakeProvider{
 		systemServices: []models.Service{{Name: "sys", Scope: models.ScopeSystem}},
 		userServices:   []models.Service{{Name: "usr", Scope: models.ScopeUser}},
 	}
-	h := NewHandler(provider)
+	h := NewHandler(provider, Options{})
 
 	req := httptest.NewRequest(http.MethodGet, "/api/services", nil)
 	rr := httptest.NewRecorder()
@@ -56,7 +129,7 @@ func TestListServices_ScopeAll_Default(t *testing.T) {
 
 func TestListServices_ScopeAll_Explicit(t *testing.T) {
 	provider := &fakeProvider{}
-	h := NewHandler(provider)
+	h := NewHandler(provider, Options{})
 
 	req := httptest.NewRequest(http.MethodGet, "/api/services?scope=all", nil)
 	rr := httptest.NewRecorder()
@@ -72,7 +145,7 @@ func TestListServices_ScopeAll_Explicit(t *testing.T) {
 
 func TestListServices_ScopeUser_OnlyOneProviderCall(t *testing.T) {
 	provider := &fakeProvider{}
-	h := NewHandler(provider)
+	h := NewHandler(provider, Options{})
 
 	req := httptest.NewRequest(http.MethodGet, "/api/services?scope=user", nil)
 	rr := httptest.NewRecorder()
@@ -110,3 +183,567 @@ func TestExtractServiceName(t *testing.T) {
 		})
 	}
 }
+
+func TestListServices_MetaReflectsQueriedScopes(t *testing.T) {
+	provider := &fakeProvider{
+		userServices: []models.Service{{Name: "usr", Scope: models.ScopeUser}},
+		listErr:      map[models.Scope]error{models.ScopeSystem: errors.New("permission denied")},
+	}
+	h := NewHandler(provider, Options{})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/services?scope=all&meta=true", nil)
+	rr := httptest.NewRecorder()
+	h.ListServices(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var body decodedServiceList
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to decode body: %v", err)
+	}
+	if body.Meta == nil || !body.Meta.Queried {
+		t.Fatalf("expected meta with queried=true, got %+v", body.Meta)
+	}
+	if len(body.Meta.ScopesQueried) != 1 || body.Meta.ScopesQueried[0] != models.ScopeUser {
+		t.Fatalf("expected scopesQueried [user], got %v", body.Meta.ScopesQueried)
+	}
+	if len(body.Meta.ScopesFailed) != 1 || body.Meta.ScopesFailed[0] != models.ScopeSystem {
+		t.Fatalf("expected scopesFailed [system], got %v", body.Meta.ScopesFailed)
+	}
+	if len(body.Items) != 1 {
+		t.Fatalf("expected 1 item, got %d", len(body.Items))
+	}
+}
+
+func TestListServices_ConditionalGet(t *testing.T) {
+	provider := &fakeProvider{
+		systemServices: []models.Service{{Name: "sys", Scope: models.ScopeSystem, Status: models.StatusRunning}},
+		userServices:   []models.Service{{Name: "usr", Scope: models.ScopeUser}},
+	}
+	h := NewHandler(provider, Options{})
+	list := func(target, etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		rr := httptest.NewRecorder()
+		h.ListServices(rr, req)
+		return rr
+	}
+
+	first := list("/api/services?scope=system", "")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" {
+		t.Fatalf("expected 200 with an ETag, got %d %q", first.Code, etag)
+	}
+
+	rr := list("/api/services?scope=system", etag)
+	if rr.Code != http.StatusNotModified || rr.Body.Len() != 0 {
+		t.Fatalf("expected an empty 304, got %d %q", rr.Code, rr.Body.String())
+	}
+	if rr := list("/api/services?scope=system", `W/"other", `+etag); rr.Code != http.StatusNotModified {
+		t.Fatalf("expected 304 for a matching tag in a list, got %d", rr.Code)
+	}
+
+	// The same services fetched with other parameters get their own tag
+	if rr := list("/api/services?scope=system&status=running", etag); rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 for different filters, got %d", rr.Code)
+	}
+
+	provider.systemServices[0].Status = models.StatusStopped
+	rr = list("/api/services?scope=system", etag)
+	if rr.Code != http.StatusOK || rr.Header().Get("ETag") == etag {
+		t.Fatalf("expected 200 with a new ETag after a change, got %d %q", rr.Code, rr.Header().Get("ETag"))
+	}
+}
+
+func TestListServices_EmptyListIsArrayNotNull(t *testing.T) {
+	h := NewHandler(&fakeProvider{}, Options{})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/services?scope=user", nil)
+	rr := httptest.NewRecorder()
+	h.ListServices(rr, req)
+
+	if got := strings.TrimSpace(rr.Body.String()); got != "[]" {
+		t.Fatalf("expected empty array, got %s", got)
+	}
+}
+
+func TestGetVersion(t *testing.T) {
+	h := NewHandler(&fakeProvider{}, Options{Version: "1.2.3", Commit: "abc123", InstanceName: "build-box"})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/version", nil)
+	rr := httptest.NewRecorder()
+	h.GetVersion(rr, req)
+
+	var body map[string]string
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to decode body: %v", err)
+	}
+	if body["version"] != "1.2.3" || body["commit"] != "abc123" {
+		t.Fatalf("unexpected build info: %v", body)
+	}
+	if body["platform"] != "fake" {
+		t.Fatalf("expected platform %q, got %q", "fake", body["platform"])
+	}
+	if body["instance"] != "build-box" {
+		t.Fatalf("expected instance %q, got %q", "build-box", body["instance"])
+	}
+}
+
+func TestGetPlatform_InstanceName(t *testing.T) {
+	h := NewHandler(&fakeProvider{}, Options{InstanceName: "rack-7"})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/platform", nil)
+	rr := httptest.NewRecorder()
+	h.GetPlatform(rr, req)
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to decode body: %v", err)
+	}
+	if body["instance"] != "rack-7" {
+		t.Fatalf("expected instance %q, got %v", "rack-7", body["instance"])
+	}
+}
+
+func TestRollingRestart_RestartsSequentially(t *testing.T) {
+	provider := &fakeProvider{
+		statuses: map[string]string{"a": models.StatusRunning, "b": models.StatusRunning, "c": models.StatusRunning},
+	}
+	h := NewHandler(provider, Options{})
+
+	body := `{"names":["a","b","c"],"scope":"system","waitHealthy":true,"timeout":"1s"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/services/rolling-restart", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	h.RollingRestart(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	want := []string{"a", "b", "c"}
+	if len(provider.restartCalls) != len(want) {
+		t.Fatalf("expected %d Restart calls, got %d", len(want), len(provider.restartCalls))
+	}
+	for i, call := range provider.restartCalls {
+		if call.name != want[i] || call.scope != models.ScopeSystem {
+			t.Fatalf("call %d: expected %s/%s, got %s/%s", i, want[i], models.ScopeSystem, call.name, call.scope)
+		}
+	}
+}
+
+func TestRollingRestart_HaltsOnFirstFailure(t *testing.T) {
+	provider := &fakeProvider{
+		restartErr: map[string]error{"b": errors.New("boom")},
+	}
+	h := NewHandler(provider, Options{})
+
+	body := `{"names":["a","b","c"]}`
+	req := httptest.NewRequest(http.MethodPost, "/api/services/rolling-restart", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	h.RollingRestart(rr, req)
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status %d, got %d", http.StatusInternalServerError, rr.Code)
+	}
+	if len(provider.restartCalls) != 2 {
+		t.Fatalf("expected restart to halt after 2 calls, got %d", len(provider.restartCalls))
+	}
+
+	var result rollingRestartResult
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("failed to decode body: %v", err)
+	}
+	if len(result.Restarted) != 1 || result.Restarted[0] != "a" {
+		t.Fatalf("expected restarted [a], got %v", result.Restarted)
+	}
+	if result.Failed == nil || result.Failed.Name != "b" {
+		t.Fatalf("expected failure on b, got %+v", result.Failed)
+	}
+}
+
+func TestRollingRestart_FailureStatusFollowsProviderError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{fmt.Errorf("restart b: %w", platform.ErrNotFound), http.StatusNotFound},
+		{fmt.Errorf("restart b: %w", platform.ErrPermission), http.StatusForbidden},
+		{fmt.Errorf("restart b: %w", platform.ErrTimeout), http.StatusGatewayTimeout},
+	}
+	for _, tt := range tests {
+		provider := &fakeProvider{restartErr: map[string]error{"b": tt.err}}
+		h := NewHandler(provider, Options{})
+
+		req := httptest.NewRequest(http.MethodPost, "/api/services/rolling-restart", strings.NewReader(`{"names":["a","b"]}`))
+		rr := httptest.NewRecorder()
+		h.RollingRestart(rr, req)
+
+		if rr.Code != tt.want {
+			t.Fatalf("%v: expected status %d, got %d", tt.err, tt.want, rr.Code)
+		}
+		var result rollingRestartResult
+		if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+			t.Fatalf("failed to decode body: %v", err)
+		}
+		if len(result.Restarted) != 1 || result.Failed == nil || result.Failed.Name != "b" {
+			t.Fatalf("%v: expected restarted [a] and failure on b, got %+v", tt.err, result)
+		}
+	}
+}
+
+func TestRollingRestart_UnhealthyServiceTimesOut(t *testing.T) {
+	healthPollInterval = time.Millisecond
+	provider := &fakeProvider{
+		statuses: map[string]string{"a": models.StatusFailed},
+	}
+	h := NewHandler(provider, Options{})
+
+	body := `{"names":["a","b"],"waitHealthy":true,"timeout":"10ms"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/services/rolling-restart", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	h.RollingRestart(rr, req)
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status %d, got %d", http.StatusInternalServerError, rr.Code)
+	}
+	if len(provider.restartCalls) != 1 {
+		t.Fatalf("expected 1 Restart call, got %d", len(provider.restartCalls))
+	}
+}
+
+func TestWaitRunning_StopsWhenRequestEnds(t *testing.T) {
+	interval := healthPollInterval
+	healthPollInterval = time.Minute
+	defer func() { healthPollInterval = interval }()
+	provider := &fakeProvider{
+		statuses: map[string]string{"a": models.StatusFailed},
+	}
+	h := NewHandler(provider, Options{})
+
+	// A client that gives up shouldn't leave the handler polling for the
+	// rest of the health timeout
+	ctx, cancel := context.WithTimeout(t.Context(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := h.waitRunning(ctx, "a", models.ScopeUser, time.Hour)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the request's deadline error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("waitRunning kept polling for %s after the request ended", elapsed)
+	}
+}
+
+func TestListServices_Filters(t *testing.T) {
+	provider := &fakeProvider{
+		userServices: []models.Service{
+			{Name: "sshd", Status: models.StatusRunning, Enabled: true, Description: "OpenSSH server"},
+			{Name: "backup", Status: models.StatusStopped, Enabled: true, Description: "Nightly SSH backup"},
+			{Name: "cron", Status: models.StatusRunning, Enabled: false},
+		},
+	}
+
+	cases := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{name: "no filters", query: "", want: []string{"backup", "cron", "sshd"}},
+		{name: "status", query: "&status=running", want: []string{"cron", "sshd"}},
+		{name: "enabled", query: "&enabled=false", want: []string{"cron"}},
+		{name: "q matches name or description case-insensitively", query: "&q=SSH", want: []string{"backup", "sshd"}},
+		{name: "combined", query: "&status=running&enabled=true&q=ssh", want: []string{"sshd"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := NewHandler(provider, Options{})
+			req := httptest.NewRequest(http.MethodGet, "/api/services?scope=user"+tc.query, nil)
+			rr := httptest.NewRecorder()
+			h.ListServices(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+			}
+			var services []models.Service
+			if err := json.Unmarshal(rr.Body.Bytes(), &services); err != nil {
+				t.Fatalf("failed to decode body: %v", err)
+			}
+			var got []string
+			for _, svc := range services {
+				got = append(got, svc.Name)
+			}
+			if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestListServices_InvalidFilters(t *testing.T) {
+	for _, query := range []string{"status=sleeping", "enabled=maybe"} {
+		t.Run(query, func(t *testing.T) {
+			h := NewHandler(&fakeProvider{}, Options{})
+			req := httptest.NewRequest(http.MethodGet, "/api/services?"+query, nil)
+			rr := httptest.NewRecorder()
+			h.ListServices(rr, req)
+
+			if rr.Code != http.StatusBadRequest {
+				t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+			}
+		})
+	}
+}
+
+func TestStartService_IncludesExitCode(t *testing.T) {
+	provider := &fakeProvider{
+		startErr: map[string]error{"demo": &platform.CommandError{Message: "systemctl start failed", ExitCode: 3}},
+	}
+	h := NewHandler(provider, Options{})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/services/demo/start", nil)
+	rr := httptest.NewRecorder()
+	h.StartService(rr, req, "demo")
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status %d, got %d", http.StatusInternalServerError, rr.Code)
+	}
+	var body struct {
+		Error    string `json:"error"`
+		ExitCode *int   `json:"exitCode"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to decode body: %v", err)
+	}
+	if body.ExitCode == nil || *body.ExitCode != 3 {
+		t.Fatalf("expected exitCode 3, got %v", body.ExitCode)
+	}
+}
+
+func TestListServices_Pagination(t *testing.T) {
+	provider := &fakeProvider{
+		userServices: []models.Service{{Name: "d"}, {Name: "b"}, {Name: "a"}, {Name: "c"}, {Name: "e"}},
+	}
+
+	cases := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{name: "first page", query: "&limit=2", want: []string{"a", "b"}},
+		{name: "middle page", query: "&limit=2&offset=2", want: []string{"c", "d"}},
+		{name: "last partial page", query: "&limit=2&offset=4", want: []string{"e"}},
+		{name: "offset past end", query: "&offset=10", want: []string{}},
+		{name: "paginate toggle", query: "&paginate=true", want: []string{"a", "b", "c", "d", "e"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := NewHandler(provider, Options{})
+			req := httptest.NewRequest(http.MethodGet, "/api/services?scope=user"+tc.query, nil)
+			rr := httptest.NewRecorder()
+			h.ListServices(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+			}
+			var list decodedServiceList
+			if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil {
+				t.Fatalf("failed to decode body: %v", err)
+			}
+			if list.Total != 5 {
+				t.Fatalf("expected total 5, got %d", list.Total)
+			}
+			got := []string{}
+			for _, svc := range list.Items {
+				got = append(got, svc.Name)
+			}
+			if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestListServices_InvalidPagination(t *testing.T) {
+	for _, query := range []string{"limit=-1", "offset=-5", "limit=ten", "paginate=sure"} {
+		t.Run(query, func(t *testing.T) {
+			h := NewHandler(&fakeProvider{}, Options{})
+			req := httptest.NewRequest(http.MethodGet, "/api/services?"+query, nil)
+			rr := httptest.NewRecorder()
+			h.ListServices(rr, req)
+
+			if rr.Code != http.StatusBadRequest {
+				t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+			}
+		})
+	}
+}
+
+func TestListServices_Sorting(t *testing.T) {
+	provider := &fakeProvider{
+		userServices: []models.Service{
+			{Name: "c", Status: models.StatusRunning, Enabled: true},
+			{Name: "a", Status: models.StatusStopped, Enabled: false},
+			{Name: "b", Status: models.StatusFailed, Enabled: true},
+		},
+	}
+
+	cases := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{name: "default name asc", query: "", want: []string{"a", "b", "c"}},
+		{name: "name desc", query: "&sort=name&order=desc", want: []string{"c", "b", "a"}},
+		{name: "status", query: "&sort=status", want: []string{"b", "c", "a"}},
+		{name: "enabled desc ties by name", query: "&sort=enabled&order=desc", want: []string{"b", "c", "a"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := NewHandler(provider, Options{})
+			req := httptest.NewRequest(http.MethodGet, "/api/services?scope=user"+tc.query, nil)
+			rr := httptest.NewRecorder()
+			h.ListServices(rr, req)
+
+			var services []models.Service
+			if err := json.Unmarshal(rr.Body.Bytes(), &services); err != nil {
+				t.Fatalf("failed to decode body: %v", err)
+			}
+			var got []string
+			for _, svc := range services {
+				got = append(got, svc.Name)
+			}
+			if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestListServices_InvalidSort(t *testing.T) {
+	for _, query := range []string{"sort=pid", "order=sideways"} {
+		t.Run(query, func(t *testing.T) {
+			h := NewHandler(&fakeProvider{}, Options{})
+			req := httptest.NewRequest(http.MethodGet, "/api/services?"+query, nil)
+			rr := httptest.NewRecorder()
+			h.ListServices(rr, req)
+
+			if rr.Code != http.StatusBadRequest {
+				t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+			}
+		})
+	}
+}
+
+func TestListServices_FieldProjection(t *testing.T) {
+	provider := &fakeProvider{
+		userServices: []models.Service{
+			{Name: "web", DisplayName: "Web", Status: models.StatusRunning, Enabled: true, Scope: models.ScopeUser},
+			{Name: "db", DisplayName: "DB", Status: models.StatusStopped, Scope: models.ScopeUser, Description: "Database"},
+		},
+	}
+
+	for _, query := range []string{"fields=name,status", "fields=name,status&limit=10"} {
+		t.Run(query, func(t *testing.T) {
+			h := NewHandler(provider, Options{})
+			req := httptest.NewRequest(http.MethodGet, "/api/services?scope=user&"+query, nil)
+			rr := httptest.NewRecorder()
+			h.ListServices(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+			}
+
+			var items []map[string]interface{}
+			if strings.Contains(query, "limit") {
+				var list struct {
+					Items []map[string]interface{} `json:"items"`
+				}
+				if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil {
+					t.Fatalf("failed to decode body: %v", err)
+				}
+				items = list.Items
+			} else if err := json.Unmarshal(rr.Body.Bytes(), &items); err != nil {
+				t.Fatalf("failed to decode body: %v", err)
+			}
+
+			if len(items) != 2 {
+				t.Fatalf("expected 2 items, got %v", items)
+			}
+			for _, item := range items {
+				if len(item) != 2 || item["name"] == nil || item["status"] == nil {
+					t.Fatalf("expected only name and status, got %v", item)
+				}
+			}
+		})
+	}
+}
+
+func TestListServices_InvalidField(t *testing.T) {
+	h := NewHandler(&fakeProvider{}, Options{})
+	req := httptest.NewRequest(http.MethodGet, "/api/services?scope=user&fields=name,password", nil)
+	rr := httptest.NewRecorder()
+	h.ListServices(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), "password") {
+		t.Fatalf("expected error to name the field, got %s", rr.Body.String())
+	}
+}
+
+func TestListServices_MaxListSize(t *testing.T) {
+	provider := &fakeProvider{
+		userServices: []models.Service{{Name: "d"}, {Name: "b"}, {Name: "a"}, {Name: "c"}, {Name: "e"}},
+	}
+	h := NewHandler(provider, Options{MaxListSize: 3})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/services?scope=user", nil)
+	rr := httptest.NewRecorder()
+	h.ListServices(rr, req)
+
+	if rr.Header().Get("X-Truncated") != "true" {
+		t.Fatalf("expected X-Truncated header, got %q", rr.Header().Get("X-Truncated"))
+	}
+	var services []models.Service
+	if err := json.Unmarshal(rr.Body.Bytes(), &services); err != nil {
+		t.Fatalf("failed to decode body: %v", err)
+	}
+	if len(services) != 3 || services[0].Name != "a" || services[2].Name != "c" {
+		t.Fatalf("expected the first 3 services by name, got %+v", services)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/services?scope=user&meta=true", nil)
+	rr = httptest.NewRecorder()
+	h.ListServices(rr, req)
+
+	var list decodedServiceList
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil {
+		t.Fatalf("failed to decode body: %v", err)
+	}
+	if list.Meta == nil || !list.Meta.Truncated || list.Meta.MaxListSize != 3 || len(list.Items) != 3 {
+		t.Fatalf("expected truncation noted in meta, got %+v", list)
+	}
+}
+
+func TestListServices_UnderMaxListSizeNotTruncated(t *testing.T) {
+	provider := &fakeProvider{userServices: []models.Service{{Name: "a"}, {Name: "b"}}}
+	h := NewHandler(provider, Options{MaxListSize: 2})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/services?scope=user&meta=true", nil)
+	rr := httptest.NewRecorder()
+	h.ListServices(rr, req)
+
+	if rr.Header().Get("X-Truncated") != "" {
+		t.Fatalf("expected no X-Truncated header, got %q", rr.Header().Get("X-Truncated"))
+	}
+	if strings.Contains(rr.Body.String(), "truncated") {
+		t.Fatalf("expected no truncation note, got %s", rr.Body.String())
+	}
+}
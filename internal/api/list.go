@@ -0,0 +1,268 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"autorun/internal/models"
+)
+
+// validStatuses are the status values accepted by the status filter
+var validStatuses = map[string]bool{
+	models.StatusRunning:  true,
+	models.StatusStopped:  true,
+	models.StatusFailed:   true,
+	models.StatusStarting: true,
+	models.StatusStopping: true,
+	models.StatusUnknown:  true,
+}
+
+// serviceFilter narrows a service list using the status, enabled and q
+// query parameters. Zero values match everything.
+type serviceFilter struct {
+	status  string
+	enabled *bool
+	query   string
+}
+
+// parseServiceFilter reads and validates filter query parameters
+func parseServiceFilter(r *http.Request) (serviceFilter, error) {
+	var f serviceFilter
+	q := r.URL.Query()
+
+	if status := q.Get("status"); status != "" {
+		if !validStatuses[status] {
+			return f, fmt.Errorf("invalid status: %s", status)
+		}
+		f.status = status
+	}
+
+	if enabled := q.Get("enabled"); enabled != "" {
+		parsed, err := strconv.ParseBool(enabled)
+		if err != nil {
+			return f, fmt.Errorf("invalid enabled value: %s", enabled)
+		}
+		f.enabled = &parsed
+	}
+
+	f.query = strings.ToLower(q.Get("q"))
+	return f, nil
+}
+
+// matches reports whether a service passes every filter
+func (f serviceFilter) matches(svc models.Service) bool {
+	if f.status != "" && svc.Status != f.status {
+		return false
+	}
+	if f.enabled != nil && svc.Enabled != *f.enabled {
+		return false
+	}
+	if f.query != "" &&
+		!strings.Contains(strings.ToLower(svc.Name), f.query) &&
+		!strings.Contains(strings.ToLower(svc.Description), f.query) {
+		return false
+	}
+	return true
+}
+
+// apply returns the services that match the filter
+func (f serviceFilter) apply(services []models.Service) []models.Service {
+	filtered := make([]models.Service, 0, len(services))
+	for _, svc := range services {
+		if f.matches(svc) {
+			filtered = append(filtered, svc)
+		}
+	}
+	return filtered
+}
+
+const (
+	// defaultPageLimit is the page size used when paginating without ?limit
+	defaultPageLimit = 100
+	// maxPageLimit caps ?limit so a single page stays reasonably small
+	maxPageLimit = 500
+)
+
+// pagination holds the limit/offset window requested via query parameters
+type pagination struct {
+	enabled bool
+	limit   int
+	offset  int
+}
+
+// parsePagination reads ?limit, ?offset and ?paginate. Pagination is enabled
+// when any of them is present; limit is capped at maxPageLimit.
+func parsePagination(r *http.Request) (pagination, error) {
+	q := r.URL.Query()
+	p := pagination{limit: defaultPageLimit}
+
+	if v := q.Get("paginate"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return p, fmt.Errorf("invalid paginate value: %s", v)
+		}
+		p.enabled = enabled
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			return p, fmt.Errorf("invalid limit: %s", v)
+		}
+		p.limit = min(limit, maxPageLimit)
+		p.enabled = true
+	}
+
+	if v := q.Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return p, fmt.Errorf("invalid offset: %s", v)
+		}
+		p.offset = offset
+		p.enabled = true
+	}
+
+	return p, nil
+}
+
+// apply returns the requested window of services
+func (p pagination) apply(services []models.Service) []models.Service {
+	if p.offset >= len(services) {
+		return []models.Service{}
+	}
+	end := min(p.offset+p.limit, len(services))
+	return services[p.offset:end]
+}
+
+// serviceSort orders a service list by a key and direction
+type serviceSort struct {
+	key  string
+	desc bool
+}
+
+// sortKeys are the values accepted by ?sort
+var sortKeys = map[string]bool{"name": true, "status": true, "enabled": true}
+
+// parseSort reads ?sort and ?order, defaulting to name ascending
+func parseSort(r *http.Request) (serviceSort, error) {
+	q := r.URL.Query()
+	s := serviceSort{key: "name"}
+
+	if key := q.Get("sort"); key != "" {
+		if !sortKeys[key] {
+			return s, fmt.Errorf("invalid sort key: %s", key)
+		}
+		s.key = key
+	}
+
+	switch order := q.Get("order"); order {
+	case "", "asc":
+	case "desc":
+		s.desc = true
+	default:
+		return s, fmt.Errorf("invalid order: %s", order)
+	}
+
+	return s, nil
+}
+
+// apply sorts services in place. Ties are broken by name then scope, always
+// ascending, so the result is deterministic.
+func (s serviceSort) apply(services []models.Service) {
+	sort.SliceStable(services, func(i, j int) bool {
+		a, b := services[i], services[j]
+		if c := s.compare(a, b); c != 0 {
+			if s.desc {
+				return c > 0
+			}
+			return c < 0
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Scope < b.Scope
+	})
+}
+
+// compare orders two services by the sort key alone
+func (s serviceSort) compare(a, b models.Service) int {
+	switch s.key {
+	case "status":
+		return strings.Compare(a.Status, b.Status)
+	case "enabled":
+		switch {
+		case a.Enabled == b.Enabled:
+			return 0
+		case !a.Enabled:
+			return -1
+		default:
+			return 1
+		}
+	default:
+		return strings.Compare(a.Name, b.Name)
+	}
+}
+
+// serviceFields maps each JSON field name of models.Service to its struct
+// field index, for projecting list results with ?fields=
+var serviceFields = func() map[string]int {
+	fields := make(map[string]int)
+	t := reflect.TypeOf(models.Service{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			fields[name] = i
+		}
+	}
+	return fields
+}()
+
+// fieldProjection selects which service fields a list response includes
+type fieldProjection struct {
+	fields []string // JSON field names; empty includes all fields
+}
+
+// parseFields reads the fields query parameter, e.g. "name,status"
+func parseFields(r *http.Request) (fieldProjection, error) {
+	var p fieldProjection
+	value := r.URL.Query().Get("fields")
+	if value == "" {
+		return p, nil
+	}
+
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if _, ok := serviceFields[name]; !ok {
+			return p, fmt.Errorf("invalid field: %s", name)
+		}
+		if !seen[name] {
+			seen[name] = true
+			p.fields = append(p.fields, name)
+		}
+	}
+	return p, nil
+}
+
+// apply returns services unchanged when no fields were requested, otherwise
+// one object per service holding only the requested fields
+func (p fieldProjection) apply(services []models.Service) interface{} {
+	if len(p.fields) == 0 {
+		return services
+	}
+
+	projected := make([]map[string]interface{}, len(services))
+	for i, svc := range services {
+		v := reflect.ValueOf(svc)
+		item := make(map[string]interface{}, len(p.fields))
+		for _, name := range p.fields {
+			item[name] = v.Field(serviceFields[name]).Interface()
+		}
+		projected[i] = item
+	}
+	return projected
+}
@@ -0,0 +1,202 @@
+package api
+
+import (
+	"context"
+	"sync"
+
+	"autorun/internal/models"
+)
+
+// subscriberBuffer is how many messages a log stream subscriber can fall
+// behind before its oldest unread messages are dropped
+const subscriberBuffer = 256
+
+// logSourceKey identifies a stream that subscribers can share. Streams
+// with different options produce different output, so they aren't shared.
+type logSourceKey struct {
+	name  string
+	scope models.Scope
+	opts  models.LogStreamOptions
+}
+
+// logSource is one running provider stream and the subscribers it feeds
+type logSource[T any] struct {
+	cancel context.CancelFunc
+	subs   map[chan T]struct{}
+
+	// ready is closed once the provider stream has started, or failed to
+	// start with err. Clients that arrive while it is starting wait on it
+	// rather than starting a process of their own; waiting counts them.
+	ready   chan struct{}
+	err     error
+	waiting int
+
+	// ended is set once the provider stream has closed
+	ended bool
+
+	// backlog holds the last opts.History messages, which are replayed to
+	// subscribers that join a stream that is already running
+	backlog []T
+}
+
+// logHub fans provider log streams out to subscribers, so any number of
+// clients watching the same service share a single journalctl or log
+// stream process. The process is stopped when the last subscriber leaves.
+type logHub[T any] struct {
+	mu      sync.Mutex
+	sources map[logSourceKey]*logSource[T]
+}
+
+func newLogHub[T any]() *logHub[T] {
+	return &logHub[T]{sources: make(map[logSourceKey]*logSource[T])}
+}
+
+// subscribe returns a channel of messages for key, calling start for a new
+// provider stream only when none is running. The subscription ends when
+// ctx is cancelled, and the channel is closed when the subscription or the
+// provider stream ends.
+func (h *logHub[T]) subscribe(ctx context.Context, key logSourceKey, start func(context.Context) (<-chan T, error)) (<-chan T, error) {
+	for {
+		h.mu.Lock()
+		src, ok := h.sources[key]
+		if !ok {
+			// The provider stream belongs to the hub rather than to the
+			// request that happened to start it
+			srcCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+			src = &logSource[T]{cancel: cancel, subs: make(map[chan T]struct{}), ready: make(chan struct{})}
+			h.sources[key] = src
+
+			// start runs without the hub lock, so a slow journalctl or ssh
+			// doesn't hold up other streams or their broadcasts
+			go h.start(srcCtx, key, src, start)
+		}
+		src.waiting++
+		h.mu.Unlock()
+
+		select {
+		case <-src.ready:
+		case <-ctx.Done():
+			h.mu.Lock()
+			src.waiting--
+			h.release(key, src)
+			h.mu.Unlock()
+			return nil, ctx.Err()
+		}
+
+		h.mu.Lock()
+		src.waiting--
+		if src.err != nil {
+			h.mu.Unlock()
+			return nil, src.err
+		}
+		if h.sources[key] != src && !src.ended {
+			// The last subscriber left and stopped the stream while this
+			// one was waiting, so start over
+			h.mu.Unlock()
+			continue
+		}
+		sub := make(chan T, subscriberBuffer+len(src.backlog))
+		for _, msg := range src.backlog {
+			sub <- msg
+		}
+		if src.ended {
+			close(sub)
+			h.mu.Unlock()
+			return sub, nil
+		}
+		src.subs[sub] = struct{}{}
+		h.mu.Unlock()
+
+		go func() {
+			<-ctx.Done()
+			h.unsubscribe(key, src, sub)
+		}()
+		return sub, nil
+	}
+}
+
+// start starts the provider stream for a pending src and marks it ready.
+// On failure src is removed again, so the next client retries. If every
+// waiting client gave up first, ctx is already cancelled and the stream is
+// only run until the provider notices.
+func (h *logHub[T]) start(ctx context.Context, key logSourceKey, src *logSource[T], start func(context.Context) (<-chan T, error)) {
+	ch, err := start(ctx)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err != nil {
+		src.cancel()
+		src.err = err
+		if h.sources[key] == src {
+			delete(h.sources, key)
+		}
+	} else {
+		go h.broadcast(key, src, ch)
+	}
+	close(src.ready)
+}
+
+// release stops src once nobody is subscribed to it or waiting for it to
+// start, so a start stuck in the provider is cancelled when its clients
+// give up and the next client starts afresh. h.mu must be held.
+func (h *logHub[T]) release(key logSourceKey, src *logSource[T]) {
+	if src.waiting > 0 || len(src.subs) > 0 {
+		return
+	}
+	if h.sources[key] == src {
+		delete(h.sources, key)
+	}
+	src.cancel()
+}
+
+// broadcast copies messages from the provider stream to every subscriber
+// until the stream ends. A subscriber that has fallen a full buffer behind
+// loses its oldest message, so one stuck client can't stall the others.
+func (h *logHub[T]) broadcast(key logSourceKey, src *logSource[T], ch <-chan T) {
+	for msg := range ch {
+		h.mu.Lock()
+		if key.opts.History > 0 {
+			src.backlog = append(src.backlog, msg)
+			if len(src.backlog) > key.opts.History {
+				src.backlog = src.backlog[len(src.backlog)-key.opts.History:]
+			}
+		}
+		for sub := range src.subs {
+			select {
+			case sub <- msg:
+			default:
+				select {
+				case <-sub:
+				default:
+				}
+				sub <- msg
+			}
+		}
+		h.mu.Unlock()
+	}
+
+	h.mu.Lock()
+	for sub := range src.subs {
+		close(sub)
+	}
+	clear(src.subs)
+	src.ended = true
+	if h.sources[key] == src {
+		delete(h.sources, key)
+	}
+	h.mu.Unlock()
+	src.cancel()
+}
+
+// unsubscribe removes sub from src, stopping the provider stream if it was
+// the last subscriber and no other client is about to join
+func (h *logHub[T]) unsubscribe(key logSourceKey, src *logSource[T], sub chan T) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := src.subs[sub]; !ok {
+		return // the stream already ended
+	}
+	delete(src.subs, sub)
+	close(sub)
+	h.release(key, src)
+}
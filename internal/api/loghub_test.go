@@ -0,0 +1,235 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"autorun/internal/models"
+)
+
+// hubSource is a provider stream the test feeds by hand
+type hubSource struct {
+	starts int
+	ch     chan string
+	ctx    context.Context
+}
+
+func (s *hubSource) start(ctx context.Context) (<-chan string, error) {
+	s.starts++
+	s.ctx = ctx
+	s.ch = make(chan string)
+	return s.ch, nil
+}
+
+func receive(t *testing.T, ch <-chan string) string {
+	t.Helper()
+	select {
+	case msg := <-ch:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a message")
+		return ""
+	}
+}
+
+func TestLogHub_SharesOneStream(t *testing.T) {
+	hub := newLogHub[string]()
+	src := &hubSource{}
+	key := logSourceKey{name: "web", scope: models.ScopeUser, opts: models.LogStreamOptions{History: 1}}
+
+	ctx1, cancel1 := context.WithCancel(t.Context())
+	sub1, err := hub.subscribe(ctx1, key, src.start)
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	src.ch <- "first"
+	if msg := receive(t, sub1); msg != "first" {
+		t.Fatalf("expected first, got %q", msg)
+	}
+
+	// A late subscriber shares the process and gets the history replayed
+	ctx2, cancel2 := context.WithCancel(t.Context())
+	sub2, err := hub.subscribe(ctx2, key, src.start)
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if src.starts != 1 {
+		t.Fatalf("expected 1 provider stream, got %d", src.starts)
+	}
+	if msg := receive(t, sub2); msg != "first" {
+		t.Fatalf("expected the backlog, got %q", msg)
+	}
+	src.ch <- "second"
+	if msg := receive(t, sub1); msg != "second" {
+		t.Fatalf("expected second, got %q", msg)
+	}
+	if msg := receive(t, sub2); msg != "second" {
+		t.Fatalf("expected second, got %q", msg)
+	}
+
+	// The provider stream outlives the first subscriber but not the last
+	cancel1()
+	time.Sleep(20 * time.Millisecond)
+	if src.ctx.Err() != nil {
+		t.Fatal("provider stream stopped while a subscriber remained")
+	}
+	cancel2()
+	select {
+	case <-src.ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("provider stream not stopped after the last subscriber left")
+	}
+
+	// The next subscriber starts a fresh stream
+	if _, err := hub.subscribe(t.Context(), key, src.start); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if src.starts != 2 {
+		t.Fatalf("expected a new provider stream, got %d starts", src.starts)
+	}
+}
+
+func TestLogHub_DropsOldestForSlowSubscriber(t *testing.T) {
+	hub := newLogHub[string]()
+	src := &hubSource{}
+	key := logSourceKey{name: "web", scope: models.ScopeUser}
+
+	slow, err := hub.subscribe(t.Context(), key, src.start)
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	fast, err := hub.subscribe(t.Context(), key, src.start)
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	// The slow subscriber never reads; the fast one keeps receiving
+	for i := range subscriberBuffer + 10 {
+		src.ch <- strconv.Itoa(i)
+		receive(t, fast)
+	}
+	close(src.ch)
+
+	var got []string
+	for msg := range slow {
+		got = append(got, msg)
+	}
+	if len(got) != subscriberBuffer {
+		t.Fatalf("expected %d buffered messages, got %d", subscriberBuffer, len(got))
+	}
+	if got[0] != "10" || got[len(got)-1] != strconv.Itoa(subscriberBuffer+9) {
+		t.Fatalf("expected the oldest 10 messages to be dropped, got %q to %q", got[0], got[len(got)-1])
+	}
+}
+
+func TestLogHub_StartDoesNotBlockOtherStreams(t *testing.T) {
+	hub := newLogHub[string]()
+	release := make(chan struct{})
+	starting := make(chan struct{})
+	slowStart := func(ctx context.Context) (<-chan string, error) {
+		close(starting)
+		<-release
+		return make(chan string), nil
+	}
+
+	slowKey := logSourceKey{name: "slow", scope: models.ScopeUser}
+	slowDone := make(chan error, 2)
+	go func() {
+		_, err := hub.subscribe(t.Context(), slowKey, slowStart)
+		slowDone <- err
+	}()
+	<-starting
+
+	// Another service's stream starts and delivers while the slow one is
+	// still starting
+	src := &hubSource{}
+	sub, err := hub.subscribe(t.Context(), logSourceKey{name: "web", scope: models.ScopeUser}, src.start)
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	src.ch <- "hello"
+	if msg := receive(t, sub); msg != "hello" {
+		t.Fatalf("expected hello, got %q", msg)
+	}
+
+	// A second client for the slow service waits for the pending start
+	// instead of starting its own process
+	go func() {
+		_, err := hub.subscribe(t.Context(), slowKey, func(context.Context) (<-chan string, error) {
+			t.Error("expected the pending stream to be shared")
+			return nil, nil
+		})
+		slowDone <- err
+	}()
+	close(release)
+	for range 2 {
+		select {
+		case err := <-slowDone:
+			if err != nil {
+				t.Fatalf("subscribe: %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the slow stream")
+		}
+	}
+}
+
+func TestLogHub_StartErrorIsNotCached(t *testing.T) {
+	hub := newLogHub[string]()
+	key := logSourceKey{name: "web", scope: models.ScopeUser}
+	failing := func(context.Context) (<-chan string, error) {
+		return nil, errors.New("journalctl failed")
+	}
+	if _, err := hub.subscribe(t.Context(), key, failing); err == nil {
+		t.Fatal("expected the start error")
+	}
+
+	src := &hubSource{}
+	if _, err := hub.subscribe(t.Context(), key, src.start); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if src.starts != 1 {
+		t.Fatalf("expected a fresh start after a failure, got %d", src.starts)
+	}
+}
+
+func TestLogHub_AbandonedStartIsCancelled(t *testing.T) {
+	hub := newLogHub[string]()
+	key := logSourceKey{name: "web", scope: models.ScopeUser}
+	stuck := make(chan context.Context, 1)
+	stuckStart := func(ctx context.Context) (<-chan string, error) {
+		stuck <- ctx
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	// The only client gives up while the provider is stuck starting
+	ctx, cancel := context.WithTimeout(t.Context(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := hub.subscribe(ctx, key, stuckStart); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the client's deadline error, got %v", err)
+	}
+	select {
+	case startCtx := <-stuck:
+		select {
+		case <-startCtx.Done():
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected the abandoned provider start to be cancelled")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("provider start was never called")
+	}
+
+	// A retry starts the provider stream again instead of waiting on the
+	// abandoned one
+	src := &hubSource{}
+	if _, err := hub.subscribe(t.Context(), key, src.start); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if src.starts != 1 {
+		t.Fatalf("expected the retry to start a new provider stream, got %d starts", src.starts)
+	}
+}
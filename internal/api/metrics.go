@@ -0,0 +1,188 @@
+package api
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"autorun/internal/logger"
+	"autorun/internal/models"
+	"autorun/internal/platform"
+)
+
+// defaultMetricsInterval is how often the services gauge is refreshed
+const defaultMetricsInterval = 30 * time.Second
+
+type requestKey struct {
+	method, path string
+	status       int
+}
+
+type actionKey struct {
+	action, result string
+}
+
+type servicesKey struct {
+	scope  models.Scope
+	status string
+}
+
+// Metrics collects counters for the /metrics endpoint, written in the
+// Prometheus text format. A nil Metrics records nothing.
+type Metrics struct {
+	mu       sync.Mutex
+	requests map[requestKey]uint64
+	actions  map[actionKey]uint64
+	services map[servicesKey]int
+
+	logStreams atomic.Int64
+	interval   time.Duration
+}
+
+// NewMetrics creates an empty set of metrics
+func NewMetrics() *Metrics {
+	return &Metrics{
+		requests: make(map[requestKey]uint64),
+		actions:  make(map[actionKey]uint64),
+		services: make(map[servicesKey]int),
+		interval: defaultMetricsInterval,
+	}
+}
+
+// recordRequest counts a served request. path is a route pattern rather
+// than the request path, so service names don't each get a series.
+func (m *Metrics) recordRequest(method, path string, status int) {
+	if m == nil {
+		return
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodOptions:
+	default:
+		method = "other"
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[requestKey{method, path, status}]++
+}
+
+// serviceAction counts a change made to a service through the API
+func (m *Metrics) serviceAction(action string, err error) {
+	if m == nil {
+		return
+	}
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.actions[actionKey{action, result}]++
+}
+
+// addLogStream adjusts the number of connected log stream clients
+func (m *Metrics) addLogStream(delta int64) {
+	if m == nil {
+		return
+	}
+	m.logStreams.Add(delta)
+}
+
+// Run refreshes the services gauge straight away and then periodically
+// until ctx is cancelled
+func (m *Metrics) Run(ctx context.Context, provider platform.ServiceProvider) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		m.refreshServices(ctx, provider)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refreshServices counts services by scope and status. A scope that can't
+// be listed keeps its previous counts.
+func (m *Metrics) refreshServices(ctx context.Context, provider platform.ServiceProvider) {
+	for _, scope := range []models.Scope{models.ScopeUser, models.ScopeSystem} {
+		services, err := provider.ListServices(ctx, scope)
+		if err != nil {
+			logger.WarnContext(ctx, "failed to list services for metrics", "scope", scope, "error", err)
+			continue
+		}
+		counts := make(map[string]int)
+		for _, svc := range services {
+			counts[svc.Status]++
+		}
+
+		m.mu.Lock()
+		maps.DeleteFunc(m.services, func(key servicesKey, _ int) bool { return key.scope == scope })
+		for status, n := range counts {
+			m.services[servicesKey{scope, status}] = n
+		}
+		m.mu.Unlock()
+	}
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.write(w)
+}
+
+func (m *Metrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	writeHeader(w, "autorun_requests_total", "counter", "HTTP requests served, by method, route and status.")
+	requests := slices.SortedFunc(maps.Keys(m.requests), func(a, b requestKey) int {
+		return cmp.Or(cmp.Compare(a.path, b.path), cmp.Compare(a.method, b.method), cmp.Compare(a.status, b.status))
+	})
+	for _, key := range requests {
+		fmt.Fprintf(w, "autorun_requests_total{method=%s,path=%s,status=%s} %d\n",
+			labelValue(key.method), labelValue(key.path), labelValue(strconv.Itoa(key.status)), m.requests[key])
+	}
+
+	writeHeader(w, "autorun_service_action_total", "counter", "Service changes made through the API, by action and result.")
+	actions := slices.SortedFunc(maps.Keys(m.actions), func(a, b actionKey) int {
+		return cmp.Or(cmp.Compare(a.action, b.action), cmp.Compare(a.result, b.result))
+	})
+	for _, key := range actions {
+		fmt.Fprintf(w, "autorun_service_action_total{action=%s,result=%s} %d\n",
+			labelValue(key.action), labelValue(key.result), m.actions[key])
+	}
+
+	writeHeader(w, "autorun_active_log_streams", "gauge", "Log stream clients currently connected.")
+	fmt.Fprintf(w, "autorun_active_log_streams %d\n", m.logStreams.Load())
+
+	writeHeader(w, "autorun_services", "gauge", "Services by scope and status, as of the last refresh.")
+	services := slices.SortedFunc(maps.Keys(m.services), func(a, b servicesKey) int {
+		return cmp.Or(cmp.Compare(a.scope, b.scope), cmp.Compare(a.status, b.status))
+	})
+	for _, key := range services {
+		fmt.Fprintf(w, "autorun_services{scope=%s,status=%s} %d\n",
+			labelValue(string(key.scope)), labelValue(key.status), m.services[key])
+	}
+}
+
+func writeHeader(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// labelValue quotes a label value as the text format requires
+func labelValue(v string) string {
+	return `"` + labelEscaper.Replace(v) + `"`
+}
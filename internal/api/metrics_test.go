@@ -0,0 +1,80 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"autorun/internal/models"
+)
+
+func TestMetrics_Endpoint(t *testing.T) {
+	provider := &fakeProvider{
+		userServices: []models.Service{
+			{Name: "web", Status: "running"},
+			{Name: "worker", Status: "running"},
+			{Name: "cron", Status: "failed"},
+		},
+		listErr:  map[models.Scope]error{models.ScopeSystem: errors.New("permission denied")},
+		startErr: map[string]error{"broken": errors.New("exit status 1")},
+	}
+	metrics := NewMetrics()
+	metrics.refreshServices(t.Context(), provider)
+	router := NewRouter(provider, nil, Options{Metrics: metrics})
+
+	for _, call := range []struct{ method, target string }{
+		{http.MethodPost, "/api/services/web/start"},
+		{http.MethodPost, "/api/services/broken/start"},
+		{http.MethodGet, "/api/services/web"},
+		{http.MethodGet, "/api/services/cron"},
+		{http.MethodGet, "/api/capabilities"},
+	} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(call.method, call.target, nil))
+	}
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	if got := rr.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/plain; version=0.0.4") {
+		t.Fatalf("expected the Prometheus text format, got %q", got)
+	}
+	body := rr.Body.String()
+	for _, want := range []string{
+		"# TYPE autorun_requests_total counter\n",
+		`autorun_requests_total{method="POST",path="/api/services/{name}/start",status="200"} 1` + "\n",
+		`autorun_requests_total{method="POST",path="/api/services/{name}/start",status="500"} 1` + "\n",
+		`autorun_requests_total{method="GET",path="/api/services/{name}",status="200"} 2` + "\n",
+		`autorun_requests_total{method="GET",path="/api/capabilities",status="200"} 1` + "\n",
+		`autorun_service_action_total{action="start",result="failure"} 1` + "\n",
+		`autorun_service_action_total{action="start",result="success"} 1` + "\n",
+		"autorun_active_log_streams 0\n",
+		`autorun_services{scope="user",status="failed"} 1` + "\n",
+		`autorun_services{scope="user",status="running"} 2` + "\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %q in:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, `scope="system"`) {
+		t.Errorf("expected no counts for a scope that failed to list:\n%s", body)
+	}
+}
+
+func TestMetrics_DisabledByDefault(t *testing.T) {
+	router := NewRouter(&fakeProvider{}, nil, Options{})
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d, got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestLabelValue_Escapes(t *testing.T) {
+	if got := labelValue("a\"b\\c\nd"); got != `"a\"b\\c\nd"` {
+		t.Fatalf("unexpected label value %s", got)
+	}
+}
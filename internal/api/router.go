@@ -1,29 +1,45 @@
 package api
 
 import (
+	"bufio"
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"io/fs"
+	"maps"
+	"net"
 	"net/http"
+	"slices"
 	"strings"
+	"time"
 
 	"autorun/internal/logger"
+	"autorun/internal/models"
 	"autorun/internal/platform"
 )
 
 // Router sets up the HTTP routes
 type Router struct {
-	handler    *Handler
-	streamer   *LogStreamer
-	mux        *http.ServeMux
-	frontendFS fs.FS
+	handler     *Handler
+	streamer    *LogStreamer
+	mux         *http.ServeMux
+	frontendFS  fs.FS
+	corsOrigins []string
+	metrics     *Metrics
+	readOnly    bool
 }
 
 // NewRouter creates a new router with all API endpoints
-func NewRouter(provider platform.ServiceProvider, frontendFS fs.FS) *Router {
+func NewRouter(provider platform.ServiceProvider, frontendFS fs.FS, opts Options) *Router {
 	r := &Router{
-		handler:    NewHandler(provider),
-		streamer:   NewLogStreamer(provider),
-		mux:        http.NewServeMux(),
-		frontendFS: frontendFS,
+		handler:     NewHandler(provider, opts),
+		streamer:    NewLogStreamer(provider, opts),
+		mux:         http.NewServeMux(),
+		frontendFS:  frontendFS,
+		corsOrigins: opts.CORSOrigins,
+		metrics:     opts.Metrics,
+		readOnly:    opts.ReadOnly,
 	}
 
 	r.setupRoutes()
@@ -31,30 +47,113 @@ func NewRouter(provider platform.ServiceProvider, frontendFS fs.FS) *Router {
 }
 
 func (r *Router) setupRoutes() {
+	// Health probes
+	r.mux.Handle("/healthz", route{http.MethodGet: r.handler.Healthz})
+	r.mux.Handle("/readyz", route{http.MethodGet: r.handler.Readyz})
+
 	// API routes
-	r.mux.HandleFunc("/api/platform", r.handler.GetPlatform)
-	r.mux.HandleFunc("/api/services", r.handleServices)
+	r.mux.Handle("/api/platform", route{http.MethodGet: r.handler.GetPlatform})
+	r.mux.Handle("/api/version", route{http.MethodGet: r.handler.GetVersion})
+	r.mux.Handle("/api/capabilities", route{http.MethodGet: r.handler.GetCapabilities})
+	r.mux.Handle("/api/services", route{
+		http.MethodGet:  r.handler.ListServices,
+		http.MethodPost: r.handler.CreateService,
+	})
 	r.mux.HandleFunc("/api/services/", r.handleServiceAction)
+	r.mux.Handle("/api/services/rolling-restart", route{http.MethodPost: r.handler.RollingRestart})
+	r.mux.Handle("/api/services/recent-failures", route{http.MethodGet: r.handler.RecentFailures})
+	r.mux.Handle("/api/services/events", route{http.MethodGet: r.handler.StatusEvents})
+	r.mux.Handle("/api/services/status", route{http.MethodPost: r.handler.ServiceStatuses})
+	r.mux.Handle("/api/run", route{http.MethodPost: r.handler.RunTransient})
+	r.mux.Handle("/api/timers", route{http.MethodPost: r.handler.CreateTimer})
+	r.mux.HandleFunc("/api/timers/", r.handleTimer)
+
+	if r.metrics != nil {
+		r.mux.Handle("/metrics", route{http.MethodGet: r.metrics.ServeHTTP})
+	}
 
-	// Frontend static files
+	// Frontend static files, with index.html as the fallback for
+	// client-side routes
 	if r.frontendFS != nil {
-		fileServer := http.FileServer(http.FS(r.frontendFS))
-		r.mux.Handle("/", fileServer)
+		r.mux.Handle("/", newStaticHandler(r.frontendFS))
 	}
 }
 
-// handleServices handles GET /api/services and POST /api/services (create)
-func (r *Router) handleServices(w http.ResponseWriter, req *http.Request) {
-	logger.Debug("handling services request", "method", req.Method, "path", req.URL.Path)
-	switch req.Method {
-	case http.MethodGet:
-		r.handler.ListServices(w, req)
-	case http.MethodPost:
-		r.handler.CreateService(w, req)
-	default:
-		logger.Debug("method not allowed", "method", req.Method, "path", req.URL.Path)
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+// Shutdown ends the log streams and waits for them to finish or for ctx to
+// expire. Call it before Server.Shutdown, which neither tracks WebSockets
+// nor ends event streams.
+func (r *Router) Shutdown(ctx context.Context) error {
+	return r.streamer.Shutdown(ctx)
+}
+
+// route maps the methods a path accepts to their handlers. HEAD is served
+// by the GET handler, with net/http dropping the body, and OPTIONS is
+// answered for every route.
+type route map[string]http.HandlerFunc
+
+// ServeHTTP calls the handler for the request's method, answers OPTIONS
+// with 204, or responds 405. Both carry an Allow header listing the methods
+// the route accepts.
+func (rt route) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	handle, ok := rt[req.Method]
+	if !ok && req.Method == http.MethodHead {
+		handle, ok = rt[http.MethodGet]
+	}
+	if ok {
+		handle(w, req)
+		return
 	}
+
+	allow := rt.allow()
+	w.Header().Set("Allow", allow)
+	if req.Method == http.MethodOptions {
+		// Only a preflight from an origin the router allowed is told what
+		// it may send
+		if w.Header().Get("Access-Control-Allow-Origin") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", allow)
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+requestIDHeader)
+			w.Header().Set("Access-Control-Max-Age", "600")
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	logger.DebugContext(req.Context(), "method not allowed", "method", req.Method, "path", req.URL.Path, "allow", allow)
+	http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+}
+
+// allow lists the methods the route accepts, sorted and comma-separated
+func (rt route) allow() string {
+	methods := slices.Collect(maps.Keys(rt))
+	if _, ok := rt[http.MethodGet]; ok {
+		methods = append(methods, http.MethodHead)
+	}
+	methods = append(methods, http.MethodOptions)
+	slices.Sort(methods)
+	return strings.Join(methods, ", ")
+}
+
+// handleTimer handles DELETE /api/timers/{name}
+func (r *Router) handleTimer(w http.ResponseWriter, req *http.Request) {
+	name := strings.TrimPrefix(req.URL.Path, "/api/timers/")
+	if name == "" || strings.Contains(name, "/") {
+		logger.DebugContext(req.Context(), "timer name required", "path", req.URL.Path)
+		http.Error(w, "Timer name required", http.StatusBadRequest)
+		return
+	}
+	if err := models.ValidateServiceName(name); err != nil {
+		logger.WarnContext(req.Context(), "rejected timer name", "path", req.URL.Path, "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !r.handler.access.permits(name) {
+		logger.WarnContext(req.Context(), "rejected timer outside the permitted set", "name", name)
+		forbiddenService(w, name)
+		return
+	}
+	setRoutePattern(w, "/api/timers/{name}")
+	route{
+		http.MethodDelete: func(w http.ResponseWriter, req *http.Request) { r.handler.DeleteTimer(w, req, name) },
+	}.ServeHTTP(w, req)
 }
 
 // handleServiceAction routes service-specific actions
@@ -64,83 +163,235 @@ func (r *Router) handleServiceAction(w http.ResponseWriter, req *http.Request) {
 	parts := strings.SplitN(path, "/", 2)
 
 	if len(parts) == 0 || parts[0] == "" {
-		logger.Debug("service name required", "path", req.URL.Path)
+		logger.DebugContext(req.Context(), "service name required", "path", req.URL.Path)
 		http.Error(w, "Service name required", http.StatusBadRequest)
 		return
 	}
 
 	serviceName := parts[0]
+	// Names end up in unit file and plist paths and in command arguments,
+	// so anything that could escape a directory or pass for an option is
+	// refused before any handler sees it
+	if err := models.ValidateServiceName(serviceName); err != nil {
+		logger.WarnContext(req.Context(), "rejected service name", "path", req.URL.Path, "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !r.handler.access.permits(serviceName) {
+		logger.WarnContext(req.Context(), "rejected service outside the permitted set", "service", serviceName)
+		forbiddenService(w, serviceName)
+		return
+	}
 	action := ""
 	if len(parts) > 1 {
 		action = parts[1]
 	}
 
-	logger.Debug("handling service action", "service", serviceName, "action", action, "method", req.Method)
+	logger.DebugContext(req.Context(), "handling service action", "service", serviceName, "action", action, "method", req.Method)
+
+	// named binds a handler to the service named in the path
+	named := func(handle func(http.ResponseWriter, *http.Request, string)) http.HandlerFunc {
+		return func(w http.ResponseWriter, req *http.Request) { handle(w, req, serviceName) }
+	}
 
+	var rt route
 	switch action {
 	case "":
-		// GET /api/services/{name} or DELETE /api/services/{name}
-		switch req.Method {
-		case http.MethodGet:
-			r.handler.GetService(w, req, serviceName)
-		case http.MethodDelete:
-			r.handler.DeleteService(w, req, serviceName)
-		default:
-			logger.Debug("method not allowed", "method", req.Method, "service", serviceName)
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		rt = route{
+			http.MethodGet:    named(r.handler.GetService),
+			http.MethodDelete: named(r.handler.DeleteService),
 		}
-
 	case "start":
-		if req.Method != http.MethodPost {
-			logger.Debug("method not allowed for start", "method", req.Method, "service", serviceName)
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-		r.handler.StartService(w, req, serviceName)
-
+		rt = route{http.MethodPost: named(r.handler.StartService)}
 	case "stop":
-		if req.Method != http.MethodPost {
-			logger.Debug("method not allowed for stop", "method", req.Method, "service", serviceName)
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-		r.handler.StopService(w, req, serviceName)
-
+		rt = route{http.MethodPost: named(r.handler.StopService)}
 	case "restart":
-		if req.Method != http.MethodPost {
-			logger.Debug("method not allowed for restart", "method", req.Method, "service", serviceName)
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-		r.handler.RestartService(w, req, serviceName)
-
+		rt = route{http.MethodPost: named(r.handler.RestartService)}
 	case "enable":
-		if req.Method != http.MethodPost {
-			logger.Debug("method not allowed for enable", "method", req.Method, "service", serviceName)
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-		r.handler.EnableService(w, req, serviceName)
-
+		rt = route{http.MethodPost: named(r.handler.EnableService)}
 	case "disable":
-		if req.Method != http.MethodPost {
-			logger.Debug("method not allowed for disable", "method", req.Method, "service", serviceName)
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-		r.handler.DisableService(w, req, serviceName)
-
+		rt = route{http.MethodPost: named(r.handler.DisableService)}
+	case "reset-failed":
+		rt = route{http.MethodPost: named(r.handler.ResetFailed)}
+	case "processes":
+		rt = route{http.MethodGet: named(r.handler.ListProcesses)}
+	case "exists":
+		rt = route{http.MethodGet: named(r.handler.ServiceExists)}
+	case "error-count":
+		rt = route{http.MethodGet: named(r.handler.ErrorCount)}
 	case "logs":
 		// WebSocket upgrade for log streaming
+		setRoutePattern(w, "/api/services/{name}/logs")
 		r.streamer.HandleLogStream(w, req, serviceName)
-
+		return
+	case "logs/stream":
+		rt = route{http.MethodGet: named(r.streamer.HandleLogEvents)}
 	default:
-		logger.Debug("unknown action", "action", action, "service", serviceName)
+		logger.DebugContext(req.Context(), "unknown action", "action", action, "service", serviceName)
 		http.Error(w, "Unknown action", http.StatusNotFound)
+		return
 	}
+	setRoutePattern(w, strings.TrimSuffix("/api/services/{name}/"+action, "/"))
+	rt.ServeHTTP(w, req)
 }
 
-// ServeHTTP implements http.Handler
+// ServeHTTP implements http.Handler. Each request gets an ID, echoed in the
+// X-Request-ID header and added to its log lines, and is logged once it is
+// done.
 func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	r.mux.ServeHTTP(w, req)
+	start := time.Now()
+	id := requestID(req)
+	w.Header().Set(requestIDHeader, id)
+	req = req.WithContext(logger.WithRequestID(req.Context(), id))
+	r.setCORSHeaders(w, req)
+
+	rw := &responseWriter{ResponseWriter: w}
+	if r.readOnly && !readOnlyAllowed(req) {
+		logger.DebugContext(req.Context(), "rejected change in read-only mode", "method", req.Method, "path", req.URL.Path)
+		errorResponse(rw, http.StatusForbidden, "autorun is running in read-only mode")
+	} else {
+		r.mux.ServeHTTP(rw, req)
+	}
+	logRequest(req, rw.statusCode(), time.Since(start))
+	r.metrics.recordRequest(req.Method, cmp.Or(rw.pattern, req.Pattern, "unmatched"), rw.statusCode())
+}
+
+// readOnlyAllowed reports whether req can be served in read-only mode:
+// reads, preflights, and the status lookup, which is a POST only because
+// it takes a list of names
+func readOnlyAllowed(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	case http.MethodPost:
+		return req.URL.Path == "/api/services/status"
+	}
+	return false
+}
+
+// setCORSHeaders allows the request's origin to read the response if it is
+// one of the configured CORS origins
+func (r *Router) setCORSHeaders(w http.ResponseWriter, req *http.Request) {
+	if len(r.corsOrigins) == 0 {
+		return
+	}
+	w.Header().Add("Vary", "Origin")
+	origin := req.Header.Get("Origin")
+	if origin == "" || !(slices.Contains(r.corsOrigins, origin) || slices.Contains(r.corsOrigins, "*")) {
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	w.Header().Set("Access-Control-Expose-Headers", requestIDHeader)
+}
+
+// requestIDHeader carries a request's ID in both directions
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength caps an inbound request ID
+const maxRequestIDLength = 128
+
+// requestID returns the client's X-Request-ID if it is usable, so IDs from
+// a proxy in front of autorun carry through, or a new random one. An ID is
+// only accepted if it is printable ASCII without spaces, since it is copied
+// into log lines and headers.
+func requestID(req *http.Request) string {
+	if id := req.Header.Get(requestIDHeader); validRequestID(id) {
+		return id
+	}
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// logRequest writes the access log line for a request. Reads are logged at
+// debug level so dashboard polling doesn't drown out changes made through
+// the API.
+func logRequest(req *http.Request, status int, duration time.Duration) {
+	log := logger.InfoContext
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		log = logger.DebugContext
+	}
+	log(req.Context(), "http request", "method", req.Method, "path", req.URL.Path, "status", status,
+		"duration", duration, "remote", req.RemoteAddr)
+}
+
+// responseWriter records the status code a handler sends. It passes
+// through Flush for the event stream and Hijack for WebSocket upgrades.
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+
+	// pattern is the route that served the request, when it is more
+	// specific than the ServeMux pattern
+	pattern string
+}
+
+// setRoutePattern records the route that is serving a request for the
+// metrics, e.g. /api/services/{name}/start rather than /api/services/
+func setRoutePattern(w http.ResponseWriter, pattern string) {
+	if rw, ok := w.(*responseWriter); ok {
+		rw.pattern = pattern
+	}
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseWriter) Flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	conn, rw, err := h.Hijack()
+	if err == nil && w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// statusCode returns the recorded status; a handler that wrote nothing
+// sent 200
+func (w *responseWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
 }
@@ -1,16 +1,27 @@
 package api
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"autorun/internal/logger"
 	"autorun/internal/models"
+	"autorun/internal/platform"
 )
 
 func TestRouter_ServiceAction_RequiresName(t *testing.T) {
 	provider := &fakeProvider{}
-	router := NewRouter(provider, nil)
+	router := NewRouter(provider, nil, Options{})
 
 	req := httptest.NewRequest(http.MethodGet, "/api/services/", nil)
 	rr := httptest.NewRecorder()
@@ -23,7 +34,7 @@ func TestRouter_ServiceAction_RequiresName(t *testing.T) {
 
 func TestRouter_ServiceAction_ParsesNameAndDefaultsScopeUser(t *testing.T) {
 	provider := &fakeProvider{}
-	router := NewRouter(provider, nil)
+	router := NewRouter(provider, nil, Options{})
 
 	req := httptest.NewRequest(http.MethodPost, "/api/services/com.example.demo/start", nil)
 	rr := httptest.NewRecorder()
@@ -45,7 +56,7 @@ func TestRouter_ServiceAction_ParsesNameAndDefaultsScopeUser(t *testing.T) {
 
 func TestRouter_ServiceAction_ParsesScopeSystem(t *testing.T) {
 	provider := &fakeProvider{}
-	router := NewRouter(provider, nil)
+	router := NewRouter(provider, nil, Options{})
 
 	req := httptest.NewRequest(http.MethodPost, "/api/services/com.example.demo/start?scope=system", nil)
 	rr := httptest.NewRecorder()
@@ -64,7 +75,7 @@ func TestRouter_ServiceAction_ParsesScopeSystem(t *testing.T) {
 
 func TestRouter_ServiceAction_UnknownAction(t *testing.T) {
 	provider := &fakeProvider{}
-	router := NewRouter(provider, nil)
+	router := NewRouter(provider, nil, Options{})
 
 	req := httptest.NewRequest(http.MethodPost, "/api/services/com.example.demo/unknown-action", nil)
 	rr := httptest.NewRecorder()
@@ -74,3 +85,884 @@ func TestRouter_ServiceAction_UnknownAction(t *testing.T) {
 		t.Fatalf("expected status %d, got %d", http.StatusNotFound, rr.Code)
 	}
 }
+
+func TestRouter_MethodNotAllowedSetsAllow(t *testing.T) {
+	router := NewRouter(&fakeProvider{}, nil, Options{})
+
+	cases := []struct {
+		method string
+		path   string
+		allow  string
+	}{
+		{http.MethodPut, "/api/services", "GET, HEAD, OPTIONS, POST"},
+		{http.MethodPost, "/api/services/demo", "DELETE, GET, HEAD, OPTIONS"},
+		{http.MethodGet, "/api/services/demo/start", "OPTIONS, POST"},
+		{http.MethodPost, "/api/services/demo/processes", "GET, HEAD, OPTIONS"},
+		{http.MethodPost, "/api/capabilities", "GET, HEAD, OPTIONS"},
+		{http.MethodGet, "/api/timers/backup", "DELETE, OPTIONS"},
+		{http.MethodPost, "/healthz", "GET, HEAD, OPTIONS"},
+		{http.MethodPost, "/readyz", "GET, HEAD, OPTIONS"},
+		{http.MethodDelete, "/api/platform", "GET, HEAD, OPTIONS"},
+		{http.MethodPut, "/api/version", "GET, HEAD, OPTIONS"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.method+" "+tc.path, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(tc.method, tc.path, nil))
+			if rr.Code != http.StatusMethodNotAllowed {
+				t.Fatalf("expected status %d, got %d", http.StatusMethodNotAllowed, rr.Code)
+			}
+			if got := rr.Header().Get("Allow"); got != tc.allow {
+				t.Fatalf("expected Allow %q, got %q", tc.allow, got)
+			}
+		})
+	}
+}
+
+func TestRouter_OptionsAndHead(t *testing.T) {
+	router := NewRouter(&fakeProvider{}, nil, Options{})
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodOptions, "/api/services/demo/start", nil))
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d", http.StatusNoContent, rr.Code)
+	}
+	if got := rr.Header().Get("Allow"); got != "OPTIONS, POST" {
+		t.Fatalf("expected Allow %q, got %q", "OPTIONS, POST", got)
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodOptions, "/healthz", nil))
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d for OPTIONS /healthz, got %d", http.StatusNoContent, rr.Code)
+	}
+
+	// net/http drops HEAD bodies, so a real server is needed to check that
+	server := httptest.NewServer(router)
+	defer server.Close()
+	resp, err := http.Head(server.URL + "/api/capabilities")
+	if err != nil {
+		t.Fatalf("HEAD: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/json" || len(body) != 0 {
+		t.Fatalf("expected 200 JSON headers without a body, got %d %q %q", resp.StatusCode, resp.Header.Get("Content-Type"), body)
+	}
+}
+
+func TestRouter_CORS(t *testing.T) {
+	preflight := func(router *Router, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodOptions, "/api/services/demo/start", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Without -cors-origins no CORS headers are sent at all
+	rr := preflight(NewRouter(&fakeProvider{}, nil, Options{}), "http://localhost:5173")
+	for _, header := range []string{"Access-Control-Allow-Origin", "Access-Control-Allow-Methods", "Vary"} {
+		if got := rr.Header().Get(header); got != "" {
+			t.Fatalf("expected no %s header, got %q", header, got)
+		}
+	}
+
+	router := NewRouter(&fakeProvider{}, nil, Options{CORSOrigins: []string{"http://localhost:5173"}})
+	rr = preflight(router, "http://localhost:5173")
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d", http.StatusNoContent, rr.Code)
+	}
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "http://localhost:5173" {
+		t.Fatalf("expected the origin allowed, got %q", got)
+	}
+	if got := rr.Header().Get("Access-Control-Allow-Methods"); got != "OPTIONS, POST" {
+		t.Fatalf("expected Access-Control-Allow-Methods %q, got %q", "OPTIONS, POST", got)
+	}
+	if !strings.Contains(rr.Header().Get("Access-Control-Allow-Headers"), "Content-Type") {
+		t.Fatalf("expected Content-Type in Access-Control-Allow-Headers, got %q", rr.Header().Get("Access-Control-Allow-Headers"))
+	}
+
+	rr = preflight(router, "http://evil.example")
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Fatalf("expected an unlisted origin to be refused, got %q", got)
+	}
+	if got := rr.Header().Get("Vary"); got != "Origin" {
+		t.Fatalf("expected Vary: Origin, got %q", got)
+	}
+
+	// The actual request gets the origin header too
+	req := httptest.NewRequest(http.MethodGet, "/api/capabilities", nil)
+	req.Header.Set("Origin", "http://any.example")
+	rr = httptest.NewRecorder()
+	NewRouter(&fakeProvider{}, nil, Options{CORSOrigins: []string{"*"}}).ServeHTTP(rr, req)
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "http://any.example" {
+		t.Fatalf("expected * to allow any origin, got %q", got)
+	}
+}
+
+func TestRouter_Healthz(t *testing.T) {
+	router := NewRouter(&fakeProvider{}, nil, Options{})
+
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+}
+
+func TestRouter_Readyz(t *testing.T) {
+	cases := []struct {
+		name      string
+		listErr   map[models.Scope]error
+		want      int
+		wantError string
+	}{
+		{name: "provider reachable", want: http.StatusOK},
+		{name: "provider failing", listErr: map[models.Scope]error{models.ScopeUser: errors.New("boom")}, want: http.StatusServiceUnavailable, wantError: "boom"},
+		{name: "provider timing out", listErr: map[models.Scope]error{models.ScopeUser: fmt.Errorf("list: %w", platform.ErrTimeout)}, want: http.StatusServiceUnavailable, wantError: "provider timed out"},
+		{name: "deadline exceeded", listErr: map[models.Scope]error{models.ScopeUser: context.DeadlineExceeded}, want: http.StatusServiceUnavailable, wantError: "provider timed out"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			router := NewRouter(&fakeProvider{listErr: tc.listErr}, nil, Options{})
+
+			req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tc.want {
+				t.Fatalf("expected status %d, got %d", tc.want, rr.Code)
+			}
+			var body map[string]string
+			if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode body: %v", err)
+			}
+			if body["error"] != tc.wantError {
+				t.Fatalf("expected error %q, got %q", tc.wantError, body["error"])
+			}
+		})
+	}
+}
+
+func TestRouter_AccessLog(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "")
+	var buf bytes.Buffer
+	if err := logger.Init(logger.Config{Output: &buf}); err != nil {
+		t.Fatalf("logger.Init: %v", err)
+	}
+	defer logger.Init(logger.Config{})
+	router := NewRouter(&fakeProvider{startErr: map[string]error{"broken": errors.New("exit status 1")}}, nil, Options{})
+
+	// Reads are logged at debug level, so they don't show up at info
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/platform", nil))
+	if strings.Contains(buf.String(), "http request") {
+		t.Fatalf("expected no access log for a GET at info level, got %q", buf.String())
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/services/broken/start", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	for _, want := range []string{`msg="http request"`, "method=POST", "path=/api/services/broken/start", "status=500", "remote=192.0.2.1:1234", "duration="} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("expected %s in the access log, got %q", want, buf.String())
+		}
+	}
+}
+
+func TestRouter_RequestID(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "")
+	var buf bytes.Buffer
+	if err := logger.Init(logger.Config{Output: &buf}); err != nil {
+		t.Fatalf("logger.Init: %v", err)
+	}
+	defer logger.Init(logger.Config{})
+	router := NewRouter(&fakeProvider{startErr: map[string]error{"broken": errors.New("exit status 1")}}, nil, Options{})
+
+	// An inbound ID is kept and shows up in the header, the body and the logs
+	req := httptest.NewRequest(http.MethodPost, "/api/services/broken/start", nil)
+	req.Header.Set("X-Request-ID", "abc-123")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if got := rr.Header().Get("X-Request-ID"); got != "abc-123" {
+		t.Fatalf("expected the inbound request ID echoed, got %q", got)
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to decode body: %v", err)
+	}
+	if body["requestId"] != "abc-123" {
+		t.Fatalf("expected requestId in the error body, got %v", body)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if !strings.Contains(line, "requestID=abc-123") {
+			t.Fatalf("expected every log line to carry the request ID, got %q", line)
+		}
+	}
+
+	// Missing and unusable IDs are replaced with generated ones
+	for _, inbound := range []string{"", "has space", strings.Repeat("x", 200)} {
+		req := httptest.NewRequest(http.MethodGet, "/api/services?scope=bogus", nil)
+		req.Header.Set("X-Request-ID", inbound)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		got := rr.Header().Get("X-Request-ID")
+		if got == "" || got == inbound {
+			t.Fatalf("expected a generated request ID for %q, got %q", inbound, got)
+		}
+		if !strings.Contains(rr.Body.String(), got) {
+			t.Fatalf("expected the request ID in the error body, got %s", rr.Body.String())
+		}
+	}
+}
+
+func TestRouter_Capabilities(t *testing.T) {
+	router := NewRouter(&fakeProvider{}, nil, Options{})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/capabilities", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var body map[string]bool
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to decode body: %v", err)
+	}
+	want := map[string]bool{"mask": false, "reload": true, "timers": true, "dependencies": true, "hooks": false, "resetFailed": false, "logs": true, "readOnly": false}
+	if len(body) != len(want) {
+		t.Fatalf("expected %v, got %v", want, body)
+	}
+	for key, value := range want {
+		if got, ok := body[key]; !ok || got != value {
+			t.Fatalf("expected %s=%v, got %v", key, value, body)
+		}
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/capabilities", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected status %d, got %d", http.StatusMethodNotAllowed, rr.Code)
+	}
+}
+
+func TestRouter_ServiceAction_InvalidScope(t *testing.T) {
+	provider := &fakeProvider{}
+	router := NewRouter(provider, nil, Options{})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/services/com.example.demo/start?scope=systen", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), "invalid scope: systen") {
+		t.Fatalf("expected invalid scope error, got %s", rr.Body.String())
+	}
+	if len(provider.startCalls) != 0 {
+		t.Fatalf("expected no Start calls, got %d", len(provider.startCalls))
+	}
+}
+
+func TestRouter_CreateTimer(t *testing.T) {
+	provider := &fakeProvider{}
+	router := NewRouter(provider, nil, Options{})
+
+	body := `{"name":"backup","program":"/usr/local/bin/backup","onCalendar":"daily"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/timers?scope=system", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	if len(provider.timerConfigs) != 1 || provider.timerConfigs[0].OnCalendar != "daily" {
+		t.Fatalf("expected timer config to reach provider, got %+v", provider.timerConfigs)
+	}
+	if !strings.Contains(rr.Body.String(), "backup.timer") {
+		t.Fatalf("expected unit names in response, got %s", rr.Body.String())
+	}
+}
+
+func TestRouter_CreateTimer_RejectsMultilineCalendar(t *testing.T) {
+	provider := &fakeProvider{}
+	router := NewRouter(provider, nil, Options{})
+
+	body := `{"name":"backup","program":"/usr/local/bin/backup","onCalendar":"daily\n[Service]\nExecStartPre=/bin/sh -c id"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/timers?scope=system", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusBadRequest, rr.Code, rr.Body.String())
+	}
+	if len(provider.timerConfigs) != 0 {
+		t.Fatalf("expected the timer not to reach the provider, got %+v", provider.timerConfigs)
+	}
+}
+
+func TestRouter_RunTransient(t *testing.T) {
+	cases := []struct {
+		name   string
+		method string
+		body   string
+		status int
+	}{
+		{name: "started", method: http.MethodPost, body: `{"program":"/usr/bin/rsync","arguments":["-a","src","dst"]}`, status: http.StatusCreated},
+		{name: "missing program", method: http.MethodPost, body: `{}`, status: http.StatusBadRequest},
+		{name: "name given", method: http.MethodPost, body: `{"name":"sync","program":"/usr/bin/rsync"}`, status: http.StatusBadRequest},
+		{name: "wrong method", method: http.MethodGet, status: http.StatusMethodNotAllowed},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			provider := &fakeProvider{}
+			router := NewRouter(provider, nil, Options{})
+
+			req := httptest.NewRequest(tc.method, "/api/run?scope=user", strings.NewReader(tc.body))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tc.status {
+				t.Fatalf("expected status %d, got %d: %s", tc.status, rr.Code, rr.Body.String())
+			}
+			if tc.status != http.StatusCreated {
+				if len(provider.runConfigs) != 0 {
+					t.Fatalf("expected no RunTransient calls, got %+v", provider.runConfigs)
+				}
+				return
+			}
+			if len(provider.runConfigs) != 1 || provider.runConfigs[0].Program != "/usr/bin/rsync" {
+				t.Fatalf("expected config to reach provider, got %+v", provider.runConfigs)
+			}
+			if !strings.Contains(rr.Body.String(), `"name":"run-0badf00d"`) {
+				t.Fatalf("expected generated name in response, got %s", rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestRouter_DeleteTimer(t *testing.T) {
+	provider := &fakeProvider{}
+	router := NewRouter(provider, nil, Options{})
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/timers/backup?scope=user", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	if len(provider.timerDeletes) != 1 || provider.timerDeletes[0].name != "backup" {
+		t.Fatalf("expected DeleteTimer(backup), got %+v", provider.timerDeletes)
+	}
+}
+
+func TestRouter_RecentFailures(t *testing.T) {
+	provider := &fakeProvider{
+		startErr: map[string]error{"broken": errors.New("exit status 1")},
+	}
+	router := NewRouter(provider, nil, Options{})
+
+	for _, name := range []string{"broken", "healthy"} {
+		req := httptest.NewRequest(http.MethodPost, "/api/services/"+name+"/start?scope=system", nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/services/recent-failures", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	var failures []startFailure
+	if err := json.Unmarshal(rr.Body.Bytes(), &failures); err != nil {
+		t.Fatalf("failed to decode body: %v", err)
+	}
+	if len(failures) != 1 {
+		t.Fatalf("expected 1 failure, got %+v", failures)
+	}
+	if failures[0].Name != "broken" || failures[0].Scope != models.ScopeSystem || failures[0].Error != "exit status 1" {
+		t.Fatalf("unexpected failure record: %+v", failures[0])
+	}
+}
+
+func TestRouter_RecentFailures_ClearedBySuccessfulStart(t *testing.T) {
+	provider := &fakeProvider{
+		startErr: map[string]error{"flaky": errors.New("exit status 1")},
+	}
+	router := NewRouter(provider, nil, Options{})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/services/flaky/start", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	delete(provider.startErr, "flaky")
+	req = httptest.NewRequest(http.MethodPost, "/api/services/flaky/start", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/services/recent-failures", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if got := strings.TrimSpace(rr.Body.String()); got != "[]" {
+		t.Fatalf("expected no failures, got %s", got)
+	}
+}
+
+func TestRouter_Processes(t *testing.T) {
+	provider := &fakeProvider{
+		processes: map[string][]models.Process{
+			"web": {{PID: 10, Command: "/bin/web"}, {PID: 11, Command: "/bin/web --worker"}},
+		},
+	}
+	router := NewRouter(provider, nil, Options{})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/services/web/processes?scope=system", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var processes []models.Process
+	if err := json.NewDecoder(rr.Body).Decode(&processes); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(processes) != 2 || processes[1].PID != 11 {
+		t.Fatalf("unexpected processes: %+v", processes)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/services/web/processes", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected status %d, got %d", http.StatusMethodNotAllowed, rr.Code)
+	}
+}
+
+func TestRouter_CreateService_RejectsUnknownType(t *testing.T) {
+	router := NewRouter(&fakeProvider{}, nil, Options{})
+
+	body := `{"name":"web","program":"/bin/web","type":"daemonish"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/services", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusBadRequest, rr.Code, rr.Body.String())
+	}
+	if !strings.Contains(rr.Body.String(), "daemonish") {
+		t.Fatalf("expected error to name the type, got %s", rr.Body.String())
+	}
+}
+
+func TestRouter_CreateService_RejectsInvalidConfig(t *testing.T) {
+	bodies := map[string]string{
+		"relative program":  `{"name":"web","program":"bin/web"}`,
+		"traversal name":    `{"name":"../../web","program":"/bin/web"}`,
+		"bad env key":       `{"name":"web","program":"/bin/web","environment":{"A B":"1"}}`,
+		"relative work dir": `{"name":"web","program":"/bin/web","workingDirectory":"srv"}`,
+	}
+
+	for name, body := range bodies {
+		t.Run(name, func(t *testing.T) {
+			router := NewRouter(&fakeProvider{}, nil, Options{})
+			req := httptest.NewRequest(http.MethodPost, "/api/services", strings.NewReader(body))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusBadRequest {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusBadRequest, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestRouter_RejectsTraversalNames(t *testing.T) {
+	cases := []struct {
+		method string
+		target string
+	}{
+		{method: http.MethodDelete, target: "/api/services/..%2f..%2fetc%2fpasswd"},
+		{method: http.MethodDelete, target: "/api/services/%2e%2e"},
+		{method: http.MethodGet, target: "/api/services/..%5c..%5cetc"},
+		{method: http.MethodPost, target: "/api/services/%2e%2e%2fweb/start"},
+		{method: http.MethodPost, target: "/api/services/--help/stop"},
+		{method: http.MethodDelete, target: "/api/timers/.backup"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.target, func(t *testing.T) {
+			provider := &fakeProvider{}
+			router := NewRouter(provider, nil, Options{})
+
+			req := httptest.NewRequest(tc.method, tc.target, nil)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusBadRequest {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusBadRequest, rr.Code, rr.Body.String())
+			}
+			if len(provider.getCalls) != 0 || len(provider.startCalls) != 0 || len(provider.timerDeletes) != 0 {
+				t.Fatal("expected the provider not to be called")
+			}
+		})
+	}
+}
+
+func TestRouter_RejectsTraversalNamesInBodies(t *testing.T) {
+	cases := []struct {
+		target string
+		body   string
+	}{
+		{target: "/api/services/status", body: `{"scope":"user","names":["web","../../etc"]}`},
+		{target: "/api/services/rolling-restart", body: `{"scope":"user","names":["web\\x2f.."]}`},
+		{target: "/api/timers?scope=user", body: `{"name":"../backup","program":"/bin/backup","onCalendar":"daily"}`},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.target, func(t *testing.T) {
+			provider := &fakeProvider{}
+			router := NewRouter(provider, nil, Options{})
+
+			req := httptest.NewRequest(http.MethodPost, tc.target, strings.NewReader(tc.body))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusBadRequest {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusBadRequest, rr.Code, rr.Body.String())
+			}
+			if len(provider.stateCalls) != 0 || len(provider.restartCalls) != 0 || len(provider.timerConfigs) != 0 {
+				t.Fatal("expected the provider not to be called")
+			}
+		})
+	}
+}
+
+func TestRouter_ServiceExists(t *testing.T) {
+	provider := &fakeProvider{
+		userServices: []models.Service{{Name: "web", Scope: models.ScopeUser}},
+	}
+	router := NewRouter(provider, nil, Options{})
+
+	cases := []struct {
+		target string
+		want   string
+	}{
+		{target: "/api/services/web/exists?scope=user", want: `{"exists":true}`},
+		{target: "/api/services/web/exists?scope=system", want: `{"exists":false}`},
+		{target: "/api/services/db/exists?scope=user", want: `{"exists":false}`},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected status %d, got %d", tc.target, http.StatusOK, rr.Code)
+		}
+		if got := strings.TrimSpace(rr.Body.String()); got != tc.want {
+			t.Fatalf("%s: expected %s, got %s", tc.target, tc.want, got)
+		}
+	}
+	if len(provider.listCalls) != 0 {
+		t.Fatalf("expected no service listing, got %d calls", len(provider.listCalls))
+	}
+}
+
+func TestRouter_ProviderErrorStatus(t *testing.T) {
+	notFound := fmt.Errorf("service %w: web", platform.ErrNotFound)
+	exists := fmt.Errorf("service web %w", platform.ErrAlreadyExists)
+
+	cases := []struct {
+		name     string
+		provider *fakeProvider
+		method   string
+		target   string
+		body     string
+		status   int
+	}{
+		{name: "create existing", provider: &fakeProvider{createErr: exists}, method: http.MethodPost, target: "/api/services", body: `{"name":"web","program":"/bin/web"}`, status: http.StatusConflict},
+		{name: "create failed", provider: &fakeProvider{createErr: errors.New("disk full")}, method: http.MethodPost, target: "/api/services", body: `{"name":"web","program":"/bin/web"}`, status: http.StatusInternalServerError},
+		{name: "get missing", provider: &fakeProvider{getErr: notFound}, method: http.MethodGet, target: "/api/services/web", status: http.StatusNotFound},
+		{name: "get failed", provider: &fakeProvider{getErr: errors.New("systemctl show failed")}, method: http.MethodGet, target: "/api/services/web", status: http.StatusInternalServerError},
+		{name: "delete missing", provider: &fakeProvider{deleteErr: notFound}, method: http.MethodDelete, target: "/api/services/web", status: http.StatusNotFound},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			router := NewRouter(tc.provider, nil, Options{})
+			req := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tc.status {
+				t.Fatalf("expected status %d, got %d: %s", tc.status, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestRouter_Actions_ReportChanged(t *testing.T) {
+	provider := &fakeProvider{
+		statuses: map[string]string{"web": models.StatusRunning, "db": models.StatusStopped},
+		enabled:  map[string]bool{"web": true},
+	}
+	router := NewRouter(provider, nil, Options{})
+
+	cases := []struct {
+		path    string
+		status  string
+		changed bool
+	}{
+		{path: "/api/services/web/start", status: "started", changed: false},
+		{path: "/api/services/db/start", status: "started", changed: true},
+		{path: "/api/services/db/stop", status: "stopped", changed: false},
+		{path: "/api/services/web/enable", status: "enabled", changed: false},
+		{path: "/api/services/db/enable", status: "enabled", changed: true},
+		{path: "/api/services/db/disable", status: "disabled", changed: false},
+		{path: "/api/services/web/restart", status: "restarted", changed: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tc.path, nil)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+			}
+			var body struct {
+				Status  string `json:"status"`
+				Changed bool   `json:"changed"`
+			}
+			if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if body.Status != tc.status || body.Changed != tc.changed {
+				t.Fatalf("expected {%s changed:%v}, got %+v", tc.status, tc.changed, body)
+			}
+		})
+	}
+
+	// Only the service that wasn't running should have been started
+	if len(provider.startCalls) != 1 || provider.startCalls[0].name != "db" {
+		t.Fatalf("expected a single start of db, got %+v", provider.startCalls)
+	}
+}
+
+func TestRouter_ServiceStatuses(t *testing.T) {
+	provider := &fakeProvider{
+		statuses: map[string]string{"web": models.StatusRunning, "db": models.StatusStopped, "cache": models.StatusRunning},
+		enabled:  map[string]bool{"web": true},
+	}
+	router := NewRouter(provider, nil, Options{})
+
+	body := `{"scope":"system","names":["web","db","ghost"]}`
+	req := httptest.NewRequest(http.MethodPost, "/api/services/status", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var states map[string]models.ServiceState
+	if err := json.NewDecoder(rr.Body).Decode(&states); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	if len(states) != 3 {
+		t.Fatalf("expected only the 3 requested names, got %+v", states)
+	}
+	if _, ok := states["cache"]; ok {
+		t.Fatalf("unrequested service returned: %+v", states)
+	}
+	if s := states["web"]; s.Status != models.StatusRunning || !s.Enabled || s.NotFound {
+		t.Fatalf("unexpected state for web: %+v", s)
+	}
+	if s := states["ghost"]; !s.NotFound {
+		t.Fatalf("expected ghost to be marked not found: %+v", s)
+	}
+	if len(provider.listCalls) != 0 {
+		t.Fatalf("expected no full listing, got %d ListServices calls", len(provider.listCalls))
+	}
+}
+
+func TestRouter_ServiceStatuses_Validation(t *testing.T) {
+	router := NewRouter(&fakeProvider{}, nil, Options{})
+
+	cases := map[string]string{
+		"no names":      `{"scope":"user","names":[]}`,
+		"invalid scope": `{"scope":"global","names":["web"]}`,
+		"bad json":      `{`,
+	}
+	for name, body := range cases {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/services/status", strings.NewReader(body))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			if rr.Code != http.StatusBadRequest {
+				t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+			}
+		})
+	}
+}
+
+func TestRouter_ErrorCount(t *testing.T) {
+	provider := &fakeProvider{logCounts: models.LogCounts{Errors: 2, Warnings: 5}}
+	router := NewRouter(provider, nil, Options{})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/services/web/error-count?since=-1h", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var counts models.LogCounts
+	if err := json.NewDecoder(rr.Body).Decode(&counts); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if counts.Errors != 2 || counts.Warnings != 5 || counts.Window != "1h0m0s" {
+		t.Fatalf("unexpected counts: %+v", counts)
+	}
+	if age := time.Since(provider.logSince); age < time.Hour || age > time.Hour+time.Minute {
+		t.Fatalf("expected window to start an hour ago, got %s", age)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/services/web/error-count", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if !strings.Contains(rr.Body.String(), `"window":"boot"`) || !provider.logSince.IsZero() {
+		t.Fatalf("expected since-boot window, got %s", rr.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/services/web/error-count?since=yesterday", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d for invalid since, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestRouter_ResetFailed(t *testing.T) {
+	cases := []struct {
+		name     string
+		resetErr error
+		want     int
+	}{
+		{name: "reset", want: http.StatusOK},
+		{name: "not supported", resetErr: fmt.Errorf("reset-failed: %w", platform.ErrNotSupported), want: http.StatusNotImplemented},
+		{name: "failure", resetErr: errors.New("systemctl reset-failed failed"), want: http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			provider := &fakeProvider{resetErr: tc.resetErr}
+			router := NewRouter(provider, nil, Options{})
+
+			req := httptest.NewRequest(http.MethodPost, "/api/services/web/reset-failed?scope=system", nil)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tc.want {
+				t.Fatalf("expected status %d, got %d: %s", tc.want, rr.Code, rr.Body.String())
+			}
+			if len(provider.resetCalls) != 1 || provider.resetCalls[0] != (serviceCall{name: "web", scope: models.ScopeSystem}) {
+				t.Fatalf("unexpected reset calls: %+v", provider.resetCalls)
+			}
+		})
+	}
+}
+
+func TestRouter_PermissionErrorRemediation(t *testing.T) {
+	message := "systemctl start failed: Failed to start nginx.service: Interactive authentication required."
+	provider := &fakeProvider{startErr: map[string]error{
+		"nginx": &platform.CommandError{Message: message, ExitCode: 4, Err: errors.New("exit status 4")},
+	}}
+	router := NewRouter(provider, nil, Options{})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/services/nginx/start?scope=system", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d, got %d", http.StatusForbidden, rr.Code)
+	}
+	var body map[string]interface{}
+	if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body["error"] != insufficientPrivileges || body["detail"] != message {
+		t.Fatalf("expected privileges error with the command's message as detail, got %v", body)
+	}
+	if body["remediation"] != platform.RemediationPolkit {
+		t.Fatalf("expected polkit remediation, got %v", body)
+	}
+}
+
+func TestRouter_PermissionErrorRemediation_UserScope(t *testing.T) {
+	err := fmt.Errorf("failed to write unit file: %w", &fs.PathError{Op: "open", Path: "/home/alice/.config/systemd/user/web.service", Err: fs.ErrPermission})
+	provider := &fakeProvider{startErr: map[string]error{"web": err}}
+	router := NewRouter(provider, nil, Options{})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/services/web/start?scope=user", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d, got %d", http.StatusForbidden, rr.Code)
+	}
+	var body map[string]interface{}
+	if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	// sudo doesn't help with a user service, so it isn't suggested
+	if body["error"] != insufficientUserPrivileges || body["remediation"] != platform.RemediationUser {
+		t.Fatalf("expected the user-scope error and remediation, got %v", body)
+	}
+}
+
+func TestRouter_ReadOnly(t *testing.T) {
+	provider := &fakeProvider{userServices: []models.Service{{Name: "web", Status: "running"}}}
+	router := NewRouter(provider, nil, Options{ReadOnly: true})
+
+	for _, tc := range []struct {
+		method, target, body string
+		want                 int
+	}{
+		{http.MethodGet, "/api/services", "", http.StatusOK},
+		{http.MethodGet, "/api/services/web", "", http.StatusOK},
+		{http.MethodOptions, "/api/services/web/start", "", http.StatusNoContent},
+		{http.MethodPost, "/api/services/status", `{"names": ["web"]}`, http.StatusOK},
+		{http.MethodPost, "/api/services/web/start", "", http.StatusForbidden},
+		{http.MethodPost, "/api/services/web/restart", "", http.StatusForbidden},
+		{http.MethodDelete, "/api/services/web", "", http.StatusForbidden},
+		{http.MethodPost, "/api/services", `{"name": "new", "program": "/bin/true"}`, http.StatusForbidden},
+		{http.MethodPost, "/api/run", `{"program": "/bin/true"}`, http.StatusForbidden},
+		{http.MethodDelete, "/api/timers/backup", "", http.StatusForbidden},
+	} {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body)))
+		if rr.Code != tc.want {
+			t.Errorf("%s %s: expected status %d, got %d: %s", tc.method, tc.target, tc.want, rr.Code, rr.Body.String())
+		}
+	}
+	if len(provider.startCalls) != 0 || len(provider.restartCalls) != 0 || len(provider.runConfigs) != 0 {
+		t.Fatal("expected no changes to reach the provider")
+	}
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/capabilities", nil))
+	if !strings.Contains(rr.Body.String(), `"readOnly":true`) {
+		t.Fatalf("expected readOnly in capabilities, got %s", rr.Body.String())
+	}
+}
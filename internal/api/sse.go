@@ -0,0 +1,158 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"autorun/internal/logger"
+	"autorun/internal/models"
+)
+
+// HandleLogEvents streams logs as server-sent events, for clients behind
+// proxies that block WebSockets. It takes the same query parameters as
+// HandleLogStream. Each log line (or models.LogEntry with ?format=json) is
+// a data-only event; status messages are connected, retrying and error
+// events.
+func (ls *LogStreamer) HandleLogEvents(w http.ResponseWriter, r *http.Request, serviceName string) {
+	params, err := parseLogStreamParams(r)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		errorResponse(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	streamCtx, done, ok := ls.track(r.Context())
+	if !ok {
+		errorResponse(w, http.StatusServiceUnavailable, "server is shutting down")
+		return
+	}
+	defer done()
+
+	logger.DebugContext(r.Context(), "log event stream requested", "service", serviceName, "scope", params.scope, "format", params.format, "grep", params.filter.grep, "level", params.filter.level)
+
+	// The stream outlives the server's write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // stop nginx holding events back
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	// The context is cancelled when the client disconnects or the server
+	// shuts down, which also stops the provider's stream. There is no close
+	// frame, so a shutdown is announced with an error event.
+	ctx := streamCtx
+	defer func() {
+		if ls.shutdown.Err() != nil {
+			writeEvent(w, "error", "server shutting down")
+			flusher.Flush()
+		}
+	}()
+	logger.InfoContext(ctx, "log event stream connected", "service", serviceName, "scope", params.scope)
+	ls.metrics.addLogStream(1)
+	defer ls.metrics.addLogStream(-1)
+
+	notify := func(kind, msg string) {
+		writeEvent(w, kind, msg)
+		flusher.Flush()
+	}
+
+	if params.format == streamFormatJSON {
+		start := func(ctx context.Context) (<-chan models.LogEntry, error) {
+			return ls.streamLogEntries(ctx, serviceName, params.scope, params.opts)
+		}
+		logCh, err := startStream(ctx, ls, serviceName, notify, start)
+		if err != nil {
+			logger.ErrorContext(ctx, "failed to start log stream", "service", serviceName, "scope", params.scope, "error", err)
+			notify("error", err.Error())
+			return
+		}
+		notify("connected", "Connected to log stream for "+serviceName)
+		pumpEvents(ctx, w, flusher, serviceName, ls.pingInterval, logCh, func(entry models.LogEntry) error {
+			if !params.filter.matchEntry(entry) {
+				return nil
+			}
+			data, err := json.Marshal(entry)
+			if err != nil {
+				return nil
+			}
+			return writeEvent(w, "", string(data))
+		})
+		return
+	}
+
+	start := func(ctx context.Context) (<-chan string, error) {
+		return ls.streamLogs(ctx, serviceName, params.scope, params.opts)
+	}
+	logCh, err := startStream(ctx, ls, serviceName, notify, start)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to start log stream", "service", serviceName, "scope", params.scope, "error", err)
+		notify("error", err.Error())
+		return
+	}
+	notify("connected", "Connected to log stream for "+serviceName)
+	pumpEvents(ctx, w, flusher, serviceName, ls.pingInterval, logCh, func(line string) error {
+		if !params.filter.matchLine(line) {
+			return nil
+		}
+		return writeEvent(w, "", line)
+	})
+}
+
+// pumpEvents writes messages from ch as events until the stream ends, the
+// client goes away or a write fails. A comment line is sent every ping
+// interval so idle streams aren't closed by proxies.
+func pumpEvents[T any](ctx context.Context, w io.Writer, flusher http.Flusher, serviceName string, pingInterval time.Duration, ch <-chan T, write func(T) error) {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			logger.DebugContext(ctx, "log event stream ended", "service", serviceName, "reason", "context cancelled")
+			return
+		case <-ticker.C:
+			if _, err := io.WriteString(w, ": ping\n\n"); err != nil {
+				logger.DebugContext(ctx, "log event ping failed", "service", serviceName, "error", err)
+				return
+			}
+			flusher.Flush()
+		case msg, ok := <-ch:
+			if !ok {
+				logger.DebugContext(ctx, "log event stream ended", "service", serviceName, "reason", "channel closed")
+				return
+			}
+			if err := write(msg); err != nil {
+				logger.DebugContext(ctx, "log event write failed", "service", serviceName, "error", err)
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// writeEvent writes one server-sent event. An empty kind sends a default
+// message event. Each line of data gets its own data: field so a stray
+// newline can't end the event early.
+func writeEvent(w io.Writer, kind, data string) error {
+	var b strings.Builder
+	if kind != "" {
+		fmt.Fprintf(&b, "event: %s\n", kind)
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(data, "\r", ""), "\n") {
+		fmt.Fprintf(&b, "data: %s\n", line)
+	}
+	b.WriteString("\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
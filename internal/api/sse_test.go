@@ -0,0 +1,136 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogEvents_StreamsLines(t *testing.T) {
+	provider := &fakeProvider{
+		streamErrs:  []error{errors.New("journalctl: not ready")},
+		streamLines: []string{"GET /health 200", "connection ERROR: reset"},
+	}
+	server := httptest.NewServer(NewRouter(provider, nil, Options{StreamRetries: DefaultStreamRetries, StreamRetryBackoff: time.Millisecond}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/services/demo/logs/stream?grep=error")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+	if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Fatalf("expected text/event-stream, got %q", got)
+	}
+
+	// The fake's channel closes after its lines, which ends the response
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	events := strings.Split(strings.TrimSpace(string(body)), "\n\n")
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %q", events)
+	}
+	if !strings.HasPrefix(events[0], "event: retrying\ndata: Log stream failed to start") {
+		t.Fatalf("expected retry event, got %q", events[0])
+	}
+	if events[1] != "event: connected\ndata: Connected to log stream for demo" {
+		t.Fatalf("expected connected event, got %q", events[1])
+	}
+	if events[2] != "data: connection ERROR: reset" {
+		t.Fatalf("expected the matching log line, got %q", events[2])
+	}
+}
+
+func TestLogEvents_InvalidParams(t *testing.T) {
+	server := httptest.NewServer(NewRouter(&fakeProvider{}, nil, Options{}))
+	defer server.Close()
+
+	for _, query := range []string{"format=xml", "level=loud", "history=-1"} {
+		resp, err := http.Get(server.URL + "/api/services/demo/logs/stream?" + query)
+		if err != nil {
+			t.Fatalf("GET: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("%q: expected 400, got %d", query, resp.StatusCode)
+		}
+	}
+}
+
+func TestLogEvents_DisconnectCancelsStream(t *testing.T) {
+	provider := &fakeProvider{streamLines: []string{"hello"}, streamDone: make(chan struct{})}
+	server := httptest.NewServer(NewRouter(provider, nil, Options{}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/services/demo/logs/stream", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// Wait for the log line so the stream is known to be running
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() && scanner.Text() != "data: hello" {
+	}
+	cancel()
+
+	select {
+	case <-provider.streamDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream was not cancelled when the client went away")
+	}
+}
+
+func TestWriteEvent_SplitsLines(t *testing.T) {
+	var b strings.Builder
+	writeEvent(&b, "error", "first\r\nsecond")
+	if want := "event: error\ndata: first\ndata: second\n\n"; b.String() != want {
+		t.Fatalf("expected %q, got %q", want, b.String())
+	}
+}
+
+func TestLogEvents_ShutdownEndsStream(t *testing.T) {
+	provider := &fakeProvider{streamLines: []string{"hello"}, streamDone: make(chan struct{})}
+	router := NewRouter(provider, nil, Options{})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/services/demo/logs/stream")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() && scanner.Text() != "data: hello" {
+	}
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	if err := router.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	// The rest of the response is the shutdown event
+	rest, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if !strings.Contains(string(rest), "event: error\ndata: server shutting down\n\n") {
+		t.Fatalf("expected a shutdown event, got %q", rest)
+	}
+	select {
+	case <-provider.streamDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("provider stream was not cancelled on shutdown")
+	}
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache-Control values for frontend files. Fingerprinted assets never change
+// under the same name, so browsers may keep them for a year; everything else
+// is revalidated so an upgraded binary's UI is picked up immediately. Embedded
+// files have no modification time, so revalidation relies on an ETag.
+const (
+	cacheImmutable  = "public, max-age=31536000, immutable"
+	cacheRevalidate = "no-cache"
+)
+
+// hashedAsset matches fingerprinted file names such as app.3f9a1c2b.js
+var hashedAsset = regexp.MustCompile(`\.[0-9a-f]{8,}\.[A-Za-z0-9]+$`)
+
+// staticHandler serves the embedded frontend. Paths that don't name a file
+// fall back to index.html so client-side routes such as /services/foo load
+// the app. Unknown /api/ paths and missing assets still 404.
+type staticHandler struct {
+	fsys       fs.FS
+	fileServer http.Handler
+
+	// etags caches each file's ETag, keyed by name
+	etags sync.Map
+}
+
+func newStaticHandler(fsys fs.FS) *staticHandler {
+	return &staticHandler{
+		fsys:       fsys,
+		fileServer: http.FileServer(http.FS(fsys)),
+	}
+}
+
+func (h *staticHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == "/api" || strings.HasPrefix(req.URL.Path, "/api/") {
+		http.NotFound(w, req)
+		return
+	}
+
+	name := strings.TrimPrefix(path.Clean("/"+req.URL.Path), "/")
+	if name == "" || name == "index.html" {
+		h.serveIndex(w, req)
+		return
+	}
+
+	info, err := fs.Stat(h.fsys, name)
+	if err != nil || info.IsDir() {
+		// A missing file with an extension is a broken asset reference, not
+		// a client-side route
+		if err != nil && path.Ext(name) != "" {
+			http.NotFound(w, req)
+			return
+		}
+		h.serveIndex(w, req)
+		return
+	}
+
+	w.Header().Set("Cache-Control", cacheControl(name))
+	h.setETag(w, name)
+	h.fileServer.ServeHTTP(w, req)
+}
+
+// serveIndex serves index.html directly; http.FileServer would redirect
+// /index.html to / and can't serve it for other paths
+func (h *staticHandler) serveIndex(w http.ResponseWriter, req *http.Request) {
+	data, err := fs.ReadFile(h.fsys, "index.html")
+	if err != nil {
+		http.NotFound(w, req)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", cacheRevalidate)
+	h.setETag(w, "index.html")
+	http.ServeContent(w, req, "index.html", time.Time{}, bytes.NewReader(data))
+}
+
+// setETag sets an ETag derived from a file's contents, which
+// http.ServeContent matches against If-None-Match to answer 304
+func (h *staticHandler) setETag(w http.ResponseWriter, name string) {
+	if etag, ok := h.etags.Load(name); ok {
+		w.Header().Set("ETag", etag.(string))
+		return
+	}
+	data, err := fs.ReadFile(h.fsys, name)
+	if err != nil {
+		return
+	}
+	sum := sha256.Sum256(data)
+	etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+	h.etags.Store(name, etag)
+	w.Header().Set("ETag", etag)
+}
+
+// cacheControl returns the Cache-Control value for a frontend file
+func cacheControl(name string) string {
+	if hashedAsset.MatchString(path.Base(name)) {
+		return cacheImmutable
+	}
+	return cacheRevalidate
+}
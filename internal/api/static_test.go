@@ -0,0 +1,104 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func newStaticTestRouter() *Router {
+	frontend := fstest.MapFS{
+		"index.html":             {Data: []byte("<!doctype html><title>autorun</title>")},
+		"app.js":                 {Data: []byte("console.log('app')")},
+		"style.css":              {Data: []byte("body {}")},
+		"assets/app.3f9a1c2b.js": {Data: []byte("console.log('hashed')")},
+	}
+	return NewRouter(&fakeProvider{}, frontend, Options{})
+}
+
+func TestStatic_DeepLinkServesIndex(t *testing.T) {
+	router := newStaticTestRouter()
+
+	for _, path := range []string{"/", "/index.html", "/services/foo", "/services/foo/logs", "/assets"} {
+		t.Run(path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+			}
+			if !strings.Contains(rr.Body.String(), "<title>autorun</title>") {
+				t.Fatalf("expected index.html, got %q", rr.Body.String())
+			}
+			if got := rr.Header().Get("Cache-Control"); got != "no-cache" {
+				t.Fatalf("expected no-cache for index.html, got %q", got)
+			}
+		})
+	}
+}
+
+func TestStatic_NoFallback(t *testing.T) {
+	router := newStaticTestRouter()
+
+	for _, path := range []string{"/api/unknown", "/api", "/missing.js"} {
+		t.Run(path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusNotFound {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusNotFound, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestStatic_AssetCacheHeaders(t *testing.T) {
+	router := newStaticTestRouter()
+
+	cases := map[string]string{
+		"/app.js":                 "no-cache",
+		"/style.css":              "no-cache",
+		"/assets/app.3f9a1c2b.js": "public, max-age=31536000, immutable",
+	}
+	for path, want := range cases {
+		t.Run(path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+			}
+			if got := rr.Header().Get("Cache-Control"); got != want {
+				t.Fatalf("expected Cache-Control %q, got %q", want, got)
+			}
+		})
+	}
+}
+
+func TestStatic_RevalidatesWithETag(t *testing.T) {
+	router := newStaticTestRouter()
+
+	for _, path := range []string{"/", "/services/foo", "/app.js"} {
+		t.Run(path, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+			etag := rr.Header().Get("ETag")
+			if etag == "" {
+				t.Fatal("expected an ETag")
+			}
+
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.Header.Set("If-None-Match", etag)
+			rr = httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			if rr.Code != http.StatusNotModified {
+				t.Fatalf("expected status %d, got %d", http.StatusNotModified, rr.Code)
+			}
+		})
+	}
+}
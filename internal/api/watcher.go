@@ -0,0 +1,213 @@
+package api
+
+import (
+	"context"
+	"maps"
+	"sort"
+	"sync"
+	"time"
+
+	"autorun/internal/logger"
+	"autorun/internal/models"
+	"autorun/internal/platform"
+)
+
+// DefaultWatchInterval is how often the status watcher polls the provider
+const DefaultWatchInterval = 2 * time.Second
+
+// minWatchInterval is the lowest accepted poll interval. Every poll shells
+// out once per scope, so very short intervals mostly burn CPU.
+var minWatchInterval = 500 * time.Millisecond
+
+// Status event types
+const (
+	eventChanged = "changed" // service appeared or its status/enabled state changed
+	eventRemoved = "removed" // service is no longer listed
+)
+
+// statusEvent reports a change in a service's state
+type statusEvent struct {
+	Type    string         `json:"type"`
+	Service models.Service `json:"service"`
+}
+
+// statusWatcher polls the provider for service state and pushes changes to
+// subscribers. Polling only runs while at least one client is subscribed.
+type statusWatcher struct {
+	provider platform.ServiceProvider
+	interval time.Duration
+
+	mu          sync.Mutex
+	subscribers map[chan []statusEvent]struct{}
+	stop        chan struct{} // closed to stop the poll loop; nil while paused
+
+	// last holds each scope's services from its last successful poll, and
+	// failing the scopes whose last poll failed
+	last    map[models.Scope]map[serviceKey]models.Service
+	failing map[models.Scope]bool
+}
+
+func newStatusWatcher(provider platform.ServiceProvider, interval time.Duration) *statusWatcher {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	if interval < minWatchInterval {
+		logger.Warn("watch interval below minimum, using minimum", "requested", interval, "minimum", minWatchInterval)
+		interval = minWatchInterval
+	}
+	return &statusWatcher{
+		provider:    provider,
+		interval:    interval,
+		subscribers: make(map[chan []statusEvent]struct{}),
+		failing:     make(map[models.Scope]bool),
+	}
+}
+
+// subscribe registers a client for change events, starting the poll loop if
+// it is the first. The returned function unsubscribes.
+func (w *statusWatcher) subscribe() (<-chan []statusEvent, func()) {
+	ch := make(chan []statusEvent, 16)
+
+	w.mu.Lock()
+	w.subscribers[ch] = struct{}{}
+	if w.stop == nil {
+		w.stop = make(chan struct{})
+		w.last = nil
+		go w.run(w.stop)
+		logger.Debug("status watcher started", "interval", w.interval)
+	}
+	w.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			w.mu.Lock()
+			defer w.mu.Unlock()
+			delete(w.subscribers, ch)
+			if len(w.subscribers) == 0 && w.stop != nil {
+				close(w.stop)
+				w.stop = nil
+				logger.Debug("status watcher paused", "reason", "no subscribers")
+			}
+		})
+	}
+}
+
+// run polls every interval until stop is closed
+func (w *statusWatcher) run(stop chan struct{}) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	// Cancel an in-flight poll as soon as the last subscriber leaves
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stop
+		cancel()
+	}()
+
+	w.poll(ctx, stop)
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			w.poll(ctx, stop)
+		}
+	}
+}
+
+// poll lists services in both scopes and broadcasts any differences from
+// the previous poll. A scope's first successful poll only records a
+// baseline, and a scope that fails to list keeps its last state, so its
+// services aren't reported as removed while the other scope is still
+// watched.
+func (w *statusWatcher) poll(ctx context.Context, stop chan struct{}) {
+	listed := make(map[models.Scope]map[serviceKey]models.Service)
+	listErrs := make(map[models.Scope]error)
+	for _, scope := range []models.Scope{models.ScopeSystem, models.ScopeUser} {
+		services, err := w.provider.ListServices(ctx, scope)
+		if err != nil {
+			listErrs[scope] = err
+			continue
+		}
+		current := make(map[serviceKey]models.Service, len(services))
+		for _, svc := range services {
+			current[serviceKey{name: svc.Name, scope: svc.Scope}] = svc
+		}
+		listed[scope] = current
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// A poll that finished after the watcher paused (or restarted) is stale
+	if w.stop != stop {
+		return
+	}
+
+	// Warn when a scope starts failing rather than on every poll
+	for _, scope := range []models.Scope{models.ScopeSystem, models.ScopeUser} {
+		err, failed := listErrs[scope]
+		switch {
+		case failed && !w.failing[scope]:
+			logger.Warn("status watcher failed to list services", "scope", scope, "error", err)
+		case failed:
+			logger.Debug("status watcher failed to list services", "scope", scope, "error", err)
+		case w.failing[scope]:
+			logger.Info("status watcher listing services again", "scope", scope)
+		}
+		w.failing[scope] = failed
+	}
+
+	if w.last == nil {
+		w.last = make(map[models.Scope]map[serviceKey]models.Service)
+	}
+	previous := make(map[serviceKey]models.Service)
+	current := make(map[serviceKey]models.Service)
+	for scope, services := range listed {
+		if last, ok := w.last[scope]; ok {
+			maps.Copy(previous, last)
+			maps.Copy(current, services)
+		}
+		w.last[scope] = services
+	}
+
+	events := diffServices(previous, current)
+	if len(events) == 0 {
+		return
+	}
+	for ch := range w.subscribers {
+		select {
+		case ch <- events:
+		default:
+			logger.Debug("status watcher dropped events for slow subscriber", "count", len(events))
+		}
+	}
+}
+
+// diffServices returns events describing how current differs from previous
+func diffServices(previous, current map[serviceKey]models.Service) []statusEvent {
+	var events []statusEvent
+	for key, svc := range current {
+		old, ok := previous[key]
+		if !ok || old.Status != svc.Status || old.Enabled != svc.Enabled {
+			events = append(events, statusEvent{Type: eventChanged, Service: svc})
+		}
+	}
+	for key, svc := range previous {
+		if _, ok := current[key]; !ok {
+			events = append(events, statusEvent{Type: eventRemoved, Service: svc})
+		}
+	}
+
+	// Map iteration order is random; keep event order stable for clients
+	sort.Slice(events, func(i, j int) bool {
+		a, b := events[i].Service, events[j].Service
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Scope < b.Scope
+	})
+	return events
+}
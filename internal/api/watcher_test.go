@@ -0,0 +1,259 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"autorun/internal/models"
+)
+
+// pollRecorder wraps fakeProvider to record when ListServices is called from
+// the watcher goroutine
+type pollRecorder struct {
+	*fakeProvider
+
+	mu    sync.Mutex
+	polls []time.Time
+}
+
+func (p *pollRecorder) ListServices(ctx context.Context, scope models.Scope) ([]models.Service, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if scope == models.ScopeSystem {
+		p.polls = append(p.polls, time.Now())
+	}
+	return nil, nil
+}
+
+func (p *pollRecorder) pollTimes() []time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]time.Time(nil), p.polls...)
+}
+
+func withMinWatchInterval(t *testing.T, d time.Duration) {
+	t.Helper()
+	prev := minWatchInterval
+	minWatchInterval = d
+	t.Cleanup(func() { minWatchInterval = prev })
+}
+
+func TestNewStatusWatcher_Interval(t *testing.T) {
+	cases := []struct {
+		name     string
+		interval time.Duration
+		want     time.Duration
+	}{
+		{name: "default", interval: 0, want: DefaultWatchInterval},
+		{name: "below floor", interval: time.Millisecond, want: minWatchInterval},
+		{name: "custom", interval: 5 * time.Second, want: 5 * time.Second},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := newStatusWatcher(&fakeProvider{}, tc.interval)
+			if w.interval != tc.want {
+				t.Fatalf("expected interval %s, got %s", tc.want, w.interval)
+			}
+		})
+	}
+}
+
+func TestStatusWatcher_RespectsInterval(t *testing.T) {
+	withMinWatchInterval(t, time.Millisecond)
+	provider := &pollRecorder{fakeProvider: &fakeProvider{}}
+	interval := 20 * time.Millisecond
+	w := newStatusWatcher(provider, interval)
+
+	_, unsubscribe := w.subscribe()
+	time.Sleep(10 * interval)
+	unsubscribe()
+
+	polls := provider.pollTimes()
+	if len(polls) < 3 {
+		t.Fatalf("expected several polls, got %d", len(polls))
+	}
+	// Ticker slop is allowed, but polls must not come faster than the interval
+	for i := 1; i < len(polls); i++ {
+		if gap := polls[i].Sub(polls[i-1]); gap < interval/2 {
+			t.Fatalf("poll %d came %s after the previous one, interval is %s", i, gap, interval)
+		}
+	}
+	if limit := 10 + 2; len(polls) > limit {
+		t.Fatalf("expected at most %d polls, got %d", limit, len(polls))
+	}
+}
+
+func TestStatusWatcher_PausesWithoutSubscribers(t *testing.T) {
+	withMinWatchInterval(t, time.Millisecond)
+	provider := &pollRecorder{fakeProvider: &fakeProvider{}}
+	interval := 5 * time.Millisecond
+	w := newStatusWatcher(provider, interval)
+
+	time.Sleep(10 * interval)
+	if n := len(provider.pollTimes()); n != 0 {
+		t.Fatalf("expected no polls before any subscriber, got %d", n)
+	}
+
+	_, unsub1 := w.subscribe()
+	_, unsub2 := w.subscribe()
+	time.Sleep(5 * interval)
+	unsub1()
+	time.Sleep(5 * interval)
+	if n := len(provider.pollTimes()); n == 0 {
+		t.Fatalf("expected polling while a subscriber remains")
+	}
+
+	unsub2()
+	// Let any in-flight poll finish before sampling
+	time.Sleep(2 * interval)
+	stopped := len(provider.pollTimes())
+	time.Sleep(10 * interval)
+	if n := len(provider.pollTimes()); n != stopped {
+		t.Fatalf("expected polling to stop with zero subscribers, got %d more polls", n-stopped)
+	}
+}
+
+func TestStatusWatcher_BroadcastsChanges(t *testing.T) {
+	provider := &fakeProvider{
+		userServices: []models.Service{
+			{Name: "a", Status: models.StatusStopped, Scope: models.ScopeUser},
+		},
+	}
+	w := newStatusWatcher(provider, 0)
+	events := make(chan []statusEvent, 1)
+	stop := make(chan struct{})
+	w.subscribers[events] = struct{}{}
+	w.stop = stop
+
+	w.poll(t.Context(), stop) // baseline
+	select {
+	case batch := <-events:
+		t.Fatalf("expected no events for baseline poll, got %v", batch)
+	default:
+	}
+
+	provider.userServices[0].Status = models.StatusRunning
+	w.poll(t.Context(), stop)
+	select {
+	case batch := <-events:
+		if len(batch) != 1 || batch[0].Type != eventChanged || batch[0].Service.Status != models.StatusRunning {
+			t.Fatalf("unexpected events %+v", batch)
+		}
+	default:
+		t.Fatalf("expected a change event")
+	}
+}
+
+func TestStatusWatcher_FailingScopeDoesNotBlockOthers(t *testing.T) {
+	provider := &fakeProvider{
+		systemServices: []models.Service{
+			{Name: "sshd", Status: models.StatusRunning, Scope: models.ScopeSystem},
+		},
+		userServices: []models.Service{
+			{Name: "a", Status: models.StatusStopped, Scope: models.ScopeUser},
+		},
+	}
+	w := newStatusWatcher(provider, 0)
+	events := make(chan []statusEvent, 1)
+	stop := make(chan struct{})
+	w.subscribers[events] = struct{}{}
+	w.stop = stop
+
+	w.poll(t.Context(), stop) // baseline
+
+	// System listing breaks; user changes are still reported and system
+	// services aren't reported as removed
+	provider.listErr = map[models.Scope]error{models.ScopeSystem: errors.New("dbus timeout")}
+	provider.userServices[0].Status = models.StatusRunning
+	w.poll(t.Context(), stop)
+	select {
+	case batch := <-events:
+		if len(batch) != 1 || batch[0].Service.Name != "a" || batch[0].Type != eventChanged {
+			t.Fatalf("expected only the user change, got %+v", batch)
+		}
+	default:
+		t.Fatal("expected a change event despite the failing system scope")
+	}
+
+	// Once it recovers, only real changes since its last good poll show up
+	provider.listErr = nil
+	provider.systemServices[0].Status = models.StatusFailed
+	w.poll(t.Context(), stop)
+	select {
+	case batch := <-events:
+		if len(batch) != 1 || batch[0].Service.Name != "sshd" || batch[0].Service.Status != models.StatusFailed {
+			t.Fatalf("expected the sshd change, got %+v", batch)
+		}
+	default:
+		t.Fatal("expected a change event after the system scope recovered")
+	}
+}
+
+func TestDiffServices(t *testing.T) {
+	key := func(name string) serviceKey { return serviceKey{name: name, scope: models.ScopeUser} }
+	svc := func(name, status string, enabled bool) models.Service {
+		return models.Service{Name: name, Status: status, Enabled: enabled, Scope: models.ScopeUser}
+	}
+
+	previous := map[serviceKey]models.Service{
+		key("same"):     svc("same", models.StatusRunning, true),
+		key("status"):   svc("status", models.StatusRunning, true),
+		key("disabled"): svc("disabled", models.StatusStopped, true),
+		key("gone"):     svc("gone", models.StatusStopped, false),
+	}
+	current := map[serviceKey]models.Service{
+		key("same"):     svc("same", models.StatusRunning, true),
+		key("status"):   svc("status", models.StatusFailed, true),
+		key("disabled"): svc("disabled", models.StatusStopped, false),
+		key("new"):      svc("new", models.StatusRunning, true),
+	}
+
+	events := diffServices(previous, current)
+	want := []struct{ typ, name string }{
+		{eventChanged, "disabled"},
+		{eventRemoved, "gone"},
+		{eventChanged, "new"},
+		{eventChanged, "status"},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d events, got %+v", len(want), events)
+	}
+	for i, w := range want {
+		if events[i].Type != w.typ || events[i].Service.Name != w.name {
+			t.Fatalf("event %d: expected %s %s, got %s %s", i, w.typ, w.name, events[i].Type, events[i].Service.Name)
+		}
+	}
+}
+
+func TestStatusEvents_OutlivesWriteTimeout(t *testing.T) {
+	server := httptest.NewUnstartedServer(NewRouter(&fakeProvider{}, nil, Options{StreamPingInterval: 100 * time.Millisecond}))
+	server.Config.WriteTimeout = 300 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/services/events", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// Pings keep arriving well past the server's write timeout
+	start := time.Now()
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if scanner.Text() == ": ping" && time.Since(start) > 3*server.Config.WriteTimeout {
+			return
+		}
+	}
+	t.Fatalf("stream ended after %s: %v", time.Since(start), scanner.Err())
+}
@@ -2,7 +2,12 @@ package api
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -20,76 +25,431 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
+// DefaultStreamRetries is how many times a log stream that fails to start
+// is retried unless configured otherwise
+const DefaultStreamRetries = 3
+
+// Defaults for the delay before the first log stream retry and for how
+// long one attempt to start a stream may take
+const (
+	defaultStreamBackoff      = 500 * time.Millisecond
+	defaultStreamStartTimeout = 30 * time.Second
+)
+
+// maxLogHistory caps ?history= so a client can't make the server replay an
+// entire journal
+const maxLogHistory = 10000
+
+// defaultPingInterval is how often log stream clients are pinged
+const defaultPingInterval = 30 * time.Second
+
 // LogStreamer handles WebSocket connections for log streaming
 type LogStreamer struct {
 	provider platform.ServiceProvider
+
+	// retries is how many more times a failed StreamLogs call is attempted;
+	// backoff is the delay before the first retry, doubling each time.
+	retries int
+	backoff time.Duration
+
+	// startTimeout bounds each attempt, so a provider stuck opening an ssh
+	// session counts as a failed start and is retried
+	startTimeout time.Duration
+
+	// pingInterval is how often the client is pinged; it is dropped if no
+	// pong arrives within two intervals
+	pingInterval time.Duration
+
+	// lines and entries share provider streams between clients watching
+	// the same service
+	lines   *logHub[string]
+	entries *logHub[models.LogEntry]
+
+	metrics *Metrics
+
+	// shutdown is cancelled by Shutdown, ending every stream; active
+	// counts the streams still running
+	mu             sync.Mutex
+	closing        bool
+	shutdown       context.Context
+	cancelShutdown context.CancelFunc
+	active         sync.WaitGroup
 }
 
 // NewLogStreamer creates a new log streamer
-func NewLogStreamer(provider platform.ServiceProvider) *LogStreamer {
-	return &LogStreamer{provider: provider}
+func NewLogStreamer(provider platform.ServiceProvider, opts Options) *LogStreamer {
+	ls := &LogStreamer{
+		provider:     provider,
+		retries:      opts.StreamRetries,
+		backoff:      opts.StreamRetryBackoff,
+		pingInterval: opts.StreamPingInterval,
+		startTimeout: opts.StreamStartTimeout,
+		lines:        newLogHub[string](),
+		entries:      newLogHub[models.LogEntry](),
+		metrics:      opts.Metrics,
+	}
+	ls.shutdown, ls.cancelShutdown = context.WithCancel(context.Background())
+	if ls.retries < 0 {
+		ls.retries = 0
+	}
+	if ls.backoff <= 0 {
+		ls.backoff = defaultStreamBackoff
+	}
+	if ls.pingInterval <= 0 {
+		ls.pingInterval = defaultPingInterval
+	}
+	if ls.startTimeout <= 0 {
+		ls.startTimeout = defaultStreamStartTimeout
+	}
+	return ls
+}
+
+// Log stream message formats selected with ?format=
+const (
+	streamFormatText = "text"
+	streamFormatJSON = "json"
+)
+
+// streamControl is a status message sent in the JSON stream format. Log
+// entries never carry a type field, so clients can tell the two apart.
+type streamControl struct {
+	Type    string `json:"type"` // connected, retrying or error
+	Message string `json:"message"`
+}
+
+// logFilter drops log messages before they are written to the socket. The
+// level is also passed to the provider, which pushes it down where the
+// platform's log tool supports that.
+type logFilter struct {
+	grep  string // lowercased substring; empty matches everything
+	level string
+}
+
+// matchLine reports whether a plain log line contains the grep substring,
+// ignoring case. The level was already applied by the provider.
+func (f logFilter) matchLine(line string) bool {
+	return f.grep == "" || strings.Contains(strings.ToLower(line), f.grep)
+}
+
+// matchEntry reports whether a log entry passes both the level and the grep
+// substring, which is matched against the message
+func (f logFilter) matchEntry(entry models.LogEntry) bool {
+	return models.LogLevelAtLeast(entry.Level, f.level) && f.matchLine(entry.Message)
+}
+
+// logStreamParams are the query parameters shared by the WebSocket and
+// server-sent event log streams
+type logStreamParams struct {
+	scope  models.Scope
+	format string
+	filter logFilter
+	opts   models.LogStreamOptions
 }
 
-// HandleLogStream handles WebSocket connections for streaming logs
+// parseLogStreamParams reads ?scope=, ?format=, ?grep=, ?level= and
+// ?history= from r
+func parseLogStreamParams(r *http.Request) (logStreamParams, error) {
+	scope, err := parseScope(r)
+	if err != nil {
+		return logStreamParams{}, err
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = streamFormatText
+	}
+	if format != streamFormatText && format != streamFormatJSON {
+		return logStreamParams{}, fmt.Errorf("invalid format %q (expected text or json)", format)
+	}
+
+	filter := logFilter{
+		grep:  strings.ToLower(r.URL.Query().Get("grep")),
+		level: r.URL.Query().Get("level"),
+	}
+	if !models.ValidLogLevel(filter.level) {
+		return logStreamParams{}, fmt.Errorf("invalid level %q (expected one of %s)", filter.level, strings.Join(models.LogLevels, ", "))
+	}
+	history := models.DefaultLogHistory
+	if v := r.URL.Query().Get("history"); v != "" {
+		history, err = strconv.Atoi(v)
+		if err != nil || history < 0 || history > maxLogHistory {
+			return logStreamParams{}, fmt.Errorf("invalid history %q (expected 0 to %d)", v, maxLogHistory)
+		}
+	}
+	return logStreamParams{
+		scope:  scope,
+		format: format,
+		filter: filter,
+		opts:   models.LogStreamOptions{Level: filter.level, History: history},
+	}, nil
+}
+
+// HandleLogStream handles WebSocket connections for streaming logs. By
+// default each message is a plain log line; with ?format=json each message
+// is a models.LogEntry and status messages are streamControl objects.
+// ?grep= and ?level= drop messages that don't match, and ?history= sets how
+// many recent messages are sent before following.
 func (ls *LogStreamer) HandleLogStream(w http.ResponseWriter, r *http.Request, serviceName string) {
-	scope := models.ScopeUser
-	if r.URL.Query().Get("scope") == "system" {
-		scope = models.ScopeSystem
+	params, err := parseLogStreamParams(r)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	scope, format, filter, opts := params.scope, params.format, params.filter, params.opts
+
+	streamCtx, done, ok := ls.track(r.Context())
+	if !ok {
+		errorResponse(w, http.StatusServiceUnavailable, "server is shutting down")
+		return
 	}
+	defer done()
 
-	logger.Debug("websocket log stream requested", "service", serviceName, "scope", scope)
+	logger.DebugContext(r.Context(), "websocket log stream requested", "service", serviceName, "scope", scope, "format", format, "grep", filter.grep, "level", filter.level)
 
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		logger.Error("websocket upgrade failed", "service", serviceName, "error", err)
+		logger.ErrorContext(r.Context(), "websocket upgrade failed", "service", serviceName, "error", err)
 		return
 	}
 	defer conn.Close()
+	defer func() {
+		if ls.shutdown.Err() != nil {
+			msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+			conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+		}
+	}()
 
-	logger.Info("websocket connected", "service", serviceName, "scope", scope)
+	logger.InfoContext(r.Context(), "websocket connected", "service", serviceName, "scope", scope)
+	ls.metrics.addLogStream(1)
+	defer ls.metrics.addLogStream(-1)
 
 	// Create a context that cancels when the connection closes
-	ctx, cancel := context.WithCancel(r.Context())
+	ctx, cancel := context.WithCancel(streamCtx)
 	defer cancel()
 
-	// Handle client disconnect
+	// Handle client disconnect. A client that crashed without closing the
+	// connection stops answering pings, so the read deadline expires.
+	pongWait := 2 * ls.pingInterval
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
 	go func() {
 		for {
 			if _, _, err := conn.ReadMessage(); err != nil {
-				logger.Debug("websocket client disconnected", "service", serviceName)
+				logger.DebugContext(r.Context(), "websocket client disconnected", "service", serviceName, "error", err)
 				cancel()
+				conn.Close()
 				return
 			}
 		}
 	}()
+	go ls.keepAlive(ctx, conn, serviceName)
 
-	// Start log streaming
-	logCh, err := ls.provider.StreamLogs(ctx, serviceName, scope)
+	if format == streamFormatJSON {
+		notify := func(kind, msg string) { conn.WriteJSON(streamControl{Type: kind, Message: msg}) }
+		start := func(ctx context.Context) (<-chan models.LogEntry, error) {
+			return ls.streamLogEntries(ctx, serviceName, scope, opts)
+		}
+		logCh, err := startStream(ctx, ls, serviceName, notify, start)
+		if err != nil {
+			logger.ErrorContext(r.Context(), "failed to start log stream", "service", serviceName, "scope", scope, "error", err)
+			notify("error", err.Error())
+			return
+		}
+		notify("connected", "Connected to log stream for "+serviceName)
+		pumpStream(ctx, conn, serviceName, logCh, func(entry models.LogEntry) error {
+			if !filter.matchEntry(entry) {
+				return nil
+			}
+			return conn.WriteJSON(entry)
+		})
+		return
+	}
+
+	notify := func(kind, msg string) {
+		switch kind {
+		case "error":
+			msg = "Error: " + msg
+		default:
+			msg = "--- " + msg + " ---"
+		}
+		conn.WriteMessage(websocket.TextMessage, []byte(msg))
+	}
+	start := func(ctx context.Context) (<-chan string, error) {
+		return ls.streamLogs(ctx, serviceName, scope, opts)
+	}
+	logCh, err := startStream(ctx, ls, serviceName, notify, start)
 	if err != nil {
-		logger.Error("failed to start log stream", "service", serviceName, "scope", scope, "error", err)
-		conn.WriteMessage(websocket.TextMessage, []byte("Error: "+err.Error()))
+		logger.ErrorContext(r.Context(), "failed to start log stream", "service", serviceName, "scope", scope, "error", err)
+		notify("error", err.Error())
 		return
 	}
+	notify("connected", "Connected to log stream for "+serviceName)
+	pumpStream(ctx, conn, serviceName, logCh, func(line string) error {
+		if !filter.matchLine(line) {
+			return nil
+		}
+		return conn.WriteMessage(websocket.TextMessage, []byte(line))
+	})
+}
+
+// track registers a stream so that Shutdown can end it and wait for it.
+// The returned context is also cancelled on shutdown, and done must be
+// called once the stream has finished. ok is false once shutdown has begun.
+func (ls *LogStreamer) track(parent context.Context) (ctx context.Context, done func(), ok bool) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if ls.closing {
+		return nil, nil, false
+	}
+	ls.active.Add(1)
+	ctx, cancel := context.WithCancel(parent)
+	stop := context.AfterFunc(ls.shutdown, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+		ls.active.Done()
+	}, true
+}
 
-	// Send an initial message
-	conn.WriteMessage(websocket.TextMessage, []byte("--- Connected to log stream for "+serviceName+" ---"))
+// Shutdown ends every log stream, sending WebSocket clients a close frame,
+// and waits for them to finish or for ctx to expire. Cancelling the streams
+// stops their journalctl or log stream processes. Streams requested after
+// Shutdown get a 503.
+func (ls *LogStreamer) Shutdown(ctx context.Context) error {
+	ls.mu.Lock()
+	ls.closing = true
+	ls.mu.Unlock()
+	ls.cancelShutdown()
 
-	// Stream logs to the WebSocket
+	finished := make(chan struct{})
+	go func() {
+		ls.active.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// streamLogs subscribes to the shared plain-text stream for a service
+func (ls *LogStreamer) streamLogs(ctx context.Context, serviceName string, scope models.Scope, opts models.LogStreamOptions) (<-chan string, error) {
+	key := logSourceKey{name: serviceName, scope: scope, opts: opts}
+	return ls.lines.subscribe(ctx, key, func(ctx context.Context) (<-chan string, error) {
+		return ls.provider.StreamLogs(ctx, serviceName, scope, opts)
+	})
+}
+
+// streamLogEntries subscribes to the shared structured stream for a service
+func (ls *LogStreamer) streamLogEntries(ctx context.Context, serviceName string, scope models.Scope, opts models.LogStreamOptions) (<-chan models.LogEntry, error) {
+	key := logSourceKey{name: serviceName, scope: scope, opts: opts}
+	return ls.entries.subscribe(ctx, key, func(ctx context.Context) (<-chan models.LogEntry, error) {
+		return ls.provider.StreamLogEntries(ctx, serviceName, scope, opts)
+	})
+}
+
+// keepAlive pings the client until ctx is cancelled. WriteControl may be
+// called concurrently with the stream's writes.
+func (ls *LogStreamer) keepAlive(ctx context.Context, conn *websocket.Conn, serviceName string) {
+	ticker := time.NewTicker(ls.pingInterval)
+	defer ticker.Stop()
 	for {
 		select {
 		case <-ctx.Done():
-			logger.Debug("websocket stream ended", "service", serviceName, "reason", "context cancelled")
 			return
-		case line, ok := <-logCh:
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second)); err != nil {
+				logger.DebugContext(ctx, "websocket ping failed", "service", serviceName, "error", err)
+				return
+			}
+		}
+	}
+}
+
+// pumpStream writes messages from ch to the WebSocket until the stream ends,
+// the client goes away or a write fails
+func pumpStream[T any](ctx context.Context, conn *websocket.Conn, serviceName string, ch <-chan T, write func(T) error) {
+	for {
+		select {
+		case <-ctx.Done():
+			logger.DebugContext(ctx, "websocket stream ended", "service", serviceName, "reason", "context cancelled")
+			return
+		case msg, ok := <-ch:
 			if !ok {
-				logger.Debug("websocket stream ended", "service", serviceName, "reason", "channel closed")
+				logger.DebugContext(ctx, "websocket stream ended", "service", serviceName, "reason", "channel closed")
 				return
 			}
 			conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-			if err := conn.WriteMessage(websocket.TextMessage, []byte(line)); err != nil {
-				logger.Debug("websocket write failed", "service", serviceName, "error", err)
+			if err := write(msg); err != nil {
+				logger.DebugContext(ctx, "websocket write failed", "service", serviceName, "error", err)
 				return
 			}
 		}
 	}
 }
+
+// startStream calls start, retrying with exponential backoff when the
+// stream fails or times out starting (e.g. journalctl briefly unavailable at
+// boot). Each retry is announced to the client through notify. Streams the
+// platform can't provide at all fail straight away.
+func startStream[T any](ctx context.Context, ls *LogStreamer, serviceName string, notify func(kind, msg string), start func(context.Context) (<-chan T, error)) (<-chan T, error) {
+	backoff := ls.backoff
+	for attempt := 0; ; attempt++ {
+		logCh, err := startAttempt(ctx, ls.startTimeout, start)
+		if err == nil {
+			return logCh, nil
+		}
+		if attempt >= ls.retries || errors.Is(err, platform.ErrNotSupported) {
+			return nil, err
+		}
+
+		logger.WarnContext(ctx, "log stream failed to start, retrying", "service", serviceName, "attempt", attempt+1, "backoff", backoff, "error", err)
+		notify("retrying", fmt.Sprintf("Log stream failed to start (%v), retrying in %s (%d/%d)", err, backoff, attempt+1, ls.retries))
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// startAttempt calls start once, giving up after timeout. The subscription
+// is made with a context of its own, which is cancelled when the attempt
+// times out so that a stream starting late is dropped again.
+func startAttempt[T any](ctx context.Context, timeout time.Duration, start func(context.Context) (<-chan T, error)) (<-chan T, error) {
+	attemptCtx, cancel := context.WithCancel(ctx)
+	type result struct {
+		ch  <-chan T
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		ch, err := start(attemptCtx)
+		done <- result{ch, err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		if r.err != nil {
+			cancel()
+			return nil, r.err
+		}
+		// The subscription lasts as long as ctx
+		context.AfterFunc(ctx, cancel)
+		return r.ch, nil
+	case <-timer.C:
+		cancel()
+		return nil, fmt.Errorf("log stream %w: not started within %s", platform.ErrTimeout, timeout)
+	case <-ctx.Done():
+		cancel()
+		return nil, ctx.Err()
+	}
+}
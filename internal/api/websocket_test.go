@@ -0,0 +1,445 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"autorun/internal/models"
+	"autorun/internal/platform"
+)
+
+func TestLogStream_RetriesFailedStart(t *testing.T) {
+	provider := &fakeProvider{
+		streamErrs:  []error{errors.New("journalctl: not ready")},
+		streamLines: []string{"hello"},
+	}
+	server := httptest.NewServer(NewRouter(provider, nil, Options{StreamRetries: DefaultStreamRetries, StreamRetryBackoff: time.Millisecond}))
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/services/demo/logs"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	var messages []string
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			break
+		}
+		messages = append(messages, string(msg))
+	}
+
+	if provider.streamCalls != 2 {
+		t.Fatalf("expected 2 StreamLogs calls, got %d", provider.streamCalls)
+	}
+	if len(messages) != 3 {
+		t.Fatalf("expected 3 messages, got %q", messages)
+	}
+	if !strings.Contains(messages[0], "retrying") || !strings.Contains(messages[0], "(1/3)") {
+		t.Fatalf("expected retry notice, got %q", messages[0])
+	}
+	if !strings.HasPrefix(messages[1], "--- Connected") {
+		t.Fatalf("expected connected message, got %q", messages[1])
+	}
+	if messages[2] != "hello" {
+		t.Fatalf("expected log line, got %q", messages[2])
+	}
+}
+
+func TestLogStream_GivesUpAfterRetries(t *testing.T) {
+	provider := &fakeProvider{
+		streamErrs: []error{errors.New("a"), errors.New("b"), errors.New("c")},
+	}
+	server := httptest.NewServer(NewRouter(provider, nil, Options{StreamRetries: 2, StreamRetryBackoff: time.Millisecond}))
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/services/demo/logs"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	var last string
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			break
+		}
+		last = string(msg)
+	}
+
+	if provider.streamCalls != 3 {
+		t.Fatalf("expected 3 StreamLogs calls, got %d", provider.streamCalls)
+	}
+	if last != "Error: c" {
+		t.Fatalf("expected final error message, got %q", last)
+	}
+}
+
+func TestLogStream_ZeroRetriesDisablesRetrying(t *testing.T) {
+	provider := &fakeProvider{
+		streamErrs:  []error{errors.New("journalctl: not ready")},
+		streamLines: []string{"hello"},
+	}
+	server := httptest.NewServer(NewRouter(provider, nil, Options{StreamRetries: 0, StreamRetryBackoff: time.Millisecond}))
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/services/demo/logs"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	var messages []string
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			break
+		}
+		messages = append(messages, string(msg))
+	}
+
+	if provider.streamCalls != 1 {
+		t.Fatalf("expected 1 StreamLogs call, got %d", provider.streamCalls)
+	}
+	if len(messages) != 1 || messages[0] != "Error: journalctl: not ready" {
+		t.Fatalf("expected only the error, got %q", messages)
+	}
+}
+
+func TestStartAttempt_TimesOut(t *testing.T) {
+	release := make(chan struct{})
+	subCtx := make(chan context.Context, 1)
+	start := func(ctx context.Context) (<-chan string, error) {
+		<-release
+		subCtx <- ctx
+		return make(chan string), nil
+	}
+
+	_, err := startAttempt(t.Context(), 10*time.Millisecond, start)
+	if !errors.Is(err, platform.ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+
+	// A stream that starts after the attempt gave up is dropped again
+	close(release)
+	select {
+	case ctx := <-subCtx:
+		if ctx.Err() == nil {
+			t.Fatal("expected the late subscription's context to be cancelled")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the late start")
+	}
+}
+
+func TestLogStream_JSONFormat(t *testing.T) {
+	ts := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	provider := &fakeProvider{
+		streamErrs: []error{errors.New("journalctl: not ready")},
+		streamEntries: []models.LogEntry{
+			{Time: ts, Level: "error", Message: "boom", Raw: `{"MESSAGE":"boom"}`},
+		},
+	}
+	server := httptest.NewServer(NewRouter(provider, nil, Options{StreamRetries: DefaultStreamRetries, StreamRetryBackoff: time.Millisecond}))
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/services/demo/logs?format=json"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	var messages []map[string]any
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var msg map[string]any
+		if err := conn.ReadJSON(&msg); err != nil {
+			break
+		}
+		messages = append(messages, msg)
+	}
+
+	if len(messages) != 3 {
+		t.Fatalf("expected 3 messages, got %v", messages)
+	}
+	if messages[0]["type"] != "retrying" {
+		t.Fatalf("expected retrying control message, got %v", messages[0])
+	}
+	if messages[1]["type"] != "connected" {
+		t.Fatalf("expected connected control message, got %v", messages[1])
+	}
+	entry := messages[2]
+	if _, ok := entry["type"]; ok {
+		t.Fatalf("log entry should not have a type, got %v", entry)
+	}
+	if entry["ts"] != "2025-03-01T12:00:00Z" || entry["level"] != "error" || entry["message"] != "boom" || entry["raw"] != `{"MESSAGE":"boom"}` {
+		t.Fatalf("unexpected log entry %v", entry)
+	}
+}
+
+func TestLogStream_InvalidFormat(t *testing.T) {
+	server := httptest.NewServer(NewRouter(&fakeProvider{}, nil, Options{}))
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/services/demo/logs?format=xml"
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	if err == nil {
+		t.Fatal("expected dial to fail")
+	}
+	if resp == nil || resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %v", resp)
+	}
+}
+
+func TestLogStream_Filters(t *testing.T) {
+	provider := &fakeProvider{
+		streamLines: []string{"GET /health 200", "connection ERROR: reset", "error-free shutdown"},
+		streamEntries: []models.LogEntry{
+			{Level: "error", Message: "disk full"},
+			{Level: "info", Message: "disk check ok"},
+			{Level: "critical", Message: "Disk failed"},
+		},
+	}
+	server := httptest.NewServer(NewRouter(provider, nil, Options{}))
+	defer server.Close()
+	base := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/services/demo/logs"
+
+	read := func(query string) []string {
+		t.Helper()
+		conn, _, err := websocket.DefaultDialer.Dial(base+query, nil)
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		defer conn.Close()
+		var messages []string
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		for {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				return messages
+			}
+			messages = append(messages, string(msg))
+		}
+	}
+
+	lines := read("?grep=error")
+	want := []string{"connection ERROR: reset", "error-free shutdown"}
+	if len(lines) != 3 || !slices.Equal(lines[1:], want) {
+		t.Fatalf("expected connected banner then %q, got %q", want, lines)
+	}
+
+	entries := read("?format=json&level=error&grep=DISK")
+	if provider.streamOpts.Level != "error" {
+		t.Fatalf("expected level passed to provider, got %+v", provider.streamOpts)
+	}
+	if len(entries) != 3 || !strings.Contains(entries[1], "disk full") || !strings.Contains(entries[2], "Disk failed") {
+		t.Fatalf("expected connected message and the error and critical entries, got %q", entries)
+	}
+}
+
+func TestLogStream_InvalidLevel(t *testing.T) {
+	server := httptest.NewServer(NewRouter(&fakeProvider{}, nil, Options{}))
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/services/demo/logs?level=loud"
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	if err == nil {
+		t.Fatal("expected dial to fail")
+	}
+	if resp == nil || resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %v", resp)
+	}
+}
+
+func TestLogStream_DropsClientThatStopsAnsweringPings(t *testing.T) {
+	provider := &fakeProvider{streamDone: make(chan struct{})}
+	server := httptest.NewServer(NewRouter(provider, nil, Options{StreamPingInterval: 20 * time.Millisecond}))
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/services/demo/logs"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	// Never reading means pings go unanswered
+	select {
+	case <-provider.streamDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream was not cancelled for an unresponsive client")
+	}
+}
+
+func TestLogStream_KeepsClientThatAnswersPings(t *testing.T) {
+	provider := &fakeProvider{streamDone: make(chan struct{})}
+	server := httptest.NewServer(NewRouter(provider, nil, Options{StreamPingInterval: 20 * time.Millisecond}))
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/services/demo/logs"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	// Reading answers pings with pongs; the read itself times out since no
+	// log lines arrive
+	conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+
+	select {
+	case <-provider.streamDone:
+		t.Fatal("stream was cancelled for a responsive client")
+	default:
+	}
+}
+
+func TestLogStream_History(t *testing.T) {
+	provider := &fakeProvider{}
+	server := httptest.NewServer(NewRouter(provider, nil, Options{}))
+	defer server.Close()
+	base := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/services/demo/logs"
+
+	cases := []struct {
+		query string
+		want  int
+	}{
+		{query: "", want: models.DefaultLogHistory},
+		{query: "?history=200", want: 200},
+		{query: "?history=0", want: 0},
+	}
+	for _, tc := range cases {
+		conn, _, err := websocket.DefaultDialer.Dial(base+tc.query, nil)
+		if err != nil {
+			t.Fatalf("dial %q: %v", tc.query, err)
+		}
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				break
+			}
+		}
+		conn.Close()
+		if provider.streamOpts.History != tc.want {
+			t.Fatalf("%q: expected history %d, got %d", tc.query, tc.want, provider.streamOpts.History)
+		}
+	}
+
+	for _, query := range []string{"?history=-1", "?history=lots", "?history=1000000"} {
+		_, resp, err := websocket.DefaultDialer.Dial(base+query, nil)
+		if err == nil || resp == nil || resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("%q: expected 400, got %v", query, resp)
+		}
+	}
+}
+
+func TestLogStream_DoesNotRetryUnsupported(t *testing.T) {
+	provider := &fakeProvider{
+		streamErrs: []error{fmt.Errorf("logs are unavailable: journalctl is not installed: %w", platform.ErrNotSupported)},
+	}
+	server := httptest.NewServer(NewRouter(provider, nil, Options{StreamRetries: DefaultStreamRetries, StreamRetryBackoff: time.Millisecond}))
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/services/demo/logs"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, msg, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if provider.streamCalls != 1 {
+		t.Fatalf("expected 1 StreamLogs call, got %d", provider.streamCalls)
+	}
+	if !strings.HasPrefix(string(msg), "Error: logs are unavailable") {
+		t.Fatalf("expected the unsupported error, got %q", msg)
+	}
+}
+
+func TestLogStream_ShutdownClosesStreams(t *testing.T) {
+	provider := &fakeProvider{streamLines: []string{"hello"}, streamDone: make(chan struct{})}
+	router := NewRouter(provider, nil, Options{})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/services/demo/logs"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	// Read up to the log line so the stream is known to be running
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if string(msg) == "hello" {
+			break
+		}
+	}
+
+	// The client keeps reading so it can see the close frame
+	closed := make(chan error, 1)
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				closed <- err
+				return
+			}
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	if err := router.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	select {
+	case <-provider.streamDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("provider stream was not cancelled on shutdown")
+	}
+
+	err = <-closed
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseGoingAway || closeErr.Text != "server shutting down" {
+		t.Fatalf("expected a going-away close frame, got %v", err)
+	}
+
+	// New streams are refused once shutdown has begun
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 after shutdown, got %v", resp)
+	}
+}
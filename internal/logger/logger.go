@@ -1,20 +1,67 @@
 package logger
 
 import (
+	"context"
+	"fmt"
+	"io"
 	"log/slog"
 	"os"
+	"path/filepath"
 	"strings"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// log defaults to slog's default logger so packages can log before Init runs
+// (e.g. in tests).
+var log = slog.Default()
+
+// file is the rotating log file Init last opened, if any
+var file *lumberjack.Logger
+
+// DefaultMaxSizeMB is the size a log file grows to before it is rotated
+const DefaultMaxSizeMB = 100
+
+// DefaultMaxBackups and DefaultMaxAgeDays are how many rotated log files
+// are kept and for how long, unless configured otherwise
+const (
+	DefaultMaxBackups = 5
+	DefaultMaxAgeDays = 30
 )
 
-var log *slog.Logger
+// Config controls how the global logger writes
+type Config struct {
+	// Verbose enables debug logging, as does LOG_LEVEL=debug
+	Verbose bool
+
+	// Format is "text" or "json". Empty falls back to the LOG_FORMAT env
+	// var, then to text.
+	Format string
+
+	// Output receives the log lines; nil means stderr
+	Output io.Writer
+
+	// File, if set, receives the log lines instead of Output. Its directory
+	// is created if missing, and it is rotated once it reaches MaxSizeMB
+	// (DefaultMaxSizeMB if zero).
+	File      string
+	MaxSizeMB int
+
+	// MaxBackups and MaxAgeDays limit the rotated files kept beside File:
+	// older ones are deleted once there are more than MaxBackups or they
+	// are more than MaxAgeDays old. Zero keeps them all.
+	MaxBackups int
+	MaxAgeDays int
+}
 
-// Init initializes the global logger with the appropriate level.
-// If verbose is true or LOG_LEVEL env var is "debug", debug logging is enabled.
-func Init(verbose bool) {
+// Init initializes the global logger from cfg. It fails for an unknown
+// format or a log file that can't be written, leaving the previous logger
+// in place.
+func Init(cfg Config) error {
 	level := slog.LevelInfo
 
 	// Check for verbose flag or LOG_LEVEL environment variable
-	if verbose || strings.EqualFold(os.Getenv("LOG_LEVEL"), "debug") {
+	if cfg.Verbose || strings.EqualFold(os.Getenv("LOG_LEVEL"), "debug") {
 		level = slog.LevelDebug
 	}
 
@@ -22,9 +69,75 @@ func Init(verbose bool) {
 		Level: level,
 	}
 
-	handler := slog.NewTextHandler(os.Stderr, opts)
+	format := cfg.Format
+	if format == "" {
+		format = os.Getenv("LOG_FORMAT")
+	}
+	format = strings.ToLower(format)
+	if format != "" && format != "text" && format != "json" {
+		return fmt.Errorf("unknown log format %q: use text or json", format)
+	}
+
+	output := cfg.Output
+	if output == nil {
+		output = os.Stderr
+	}
+	var rotating *lumberjack.Logger
+	if cfg.File != "" {
+		var err error
+		if rotating, err = openFile(cfg); err != nil {
+			return err
+		}
+		output = rotating
+	}
+
+	var handler slog.Handler
+	if format == "json" {
+		handler = slog.NewJSONHandler(output, opts)
+	} else {
+		handler = slog.NewTextHandler(output, opts)
+	}
 	log = slog.New(handler)
 	slog.SetDefault(log)
+
+	if file != nil {
+		file.Close()
+	}
+	file = rotating
+	return nil
+}
+
+// openFile prepares the rotating log file cfg.File, creating its directory.
+// The file is opened once up front so a bad path fails at startup rather
+// than on the first log line.
+func openFile(cfg Config) (*lumberjack.Logger, error) {
+	path, maxSizeMB := cfg.File, cfg.MaxSizeMB
+	if maxSizeMB < 0 {
+		return nil, fmt.Errorf("invalid log file size %d MB", maxSizeMB)
+	}
+	if maxSizeMB == 0 {
+		maxSizeMB = DefaultMaxSizeMB
+	}
+	if cfg.MaxBackups < 0 {
+		return nil, fmt.Errorf("invalid log file backup count %d", cfg.MaxBackups)
+	}
+	if cfg.MaxAgeDays < 0 {
+		return nil, fmt.Errorf("invalid log file age %d days", cfg.MaxAgeDays)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+	f.Close()
+	return &lumberjack.Logger{
+		Filename:   path,
+		MaxSize:    maxSizeMB,
+		MaxBackups: cfg.MaxBackups,
+		MaxAge:     cfg.MaxAgeDays,
+	}, nil
 }
 
 // Debug logs a debug message with optional key-value pairs.
@@ -46,3 +159,46 @@ func Warn(msg string, args ...any) {
 func Error(msg string, args ...any) {
 	log.Error(msg, args...)
 }
+
+// requestIDKey is the context key WithRequestID stores the ID under
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying a request ID, which the
+// Context logging functions add to every line
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID stored in ctx, or "" if there is none
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// withRequestID appends ctx's request ID to args, if it has one
+func withRequestID(ctx context.Context, args []any) []any {
+	if id := RequestID(ctx); id != "" {
+		return append(args, "requestID", id)
+	}
+	return args
+}
+
+// DebugContext logs a debug message, tagged with ctx's request ID.
+func DebugContext(ctx context.Context, msg string, args ...any) {
+	log.DebugContext(ctx, msg, withRequestID(ctx, args)...)
+}
+
+// InfoContext logs an info message, tagged with ctx's request ID.
+func InfoContext(ctx context.Context, msg string, args ...any) {
+	log.InfoContext(ctx, msg, withRequestID(ctx, args)...)
+}
+
+// WarnContext logs a warning message, tagged with ctx's request ID.
+func WarnContext(ctx context.Context, msg string, args ...any) {
+	log.WarnContext(ctx, msg, withRequestID(ctx, args)...)
+}
+
+// ErrorContext logs an error message, tagged with ctx's request ID.
+func ErrorContext(ctx context.Context, msg string, args ...any) {
+	log.ErrorContext(ctx, msg, withRequestID(ctx, args)...)
+}
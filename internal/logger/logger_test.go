@@ -0,0 +1,93 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInit_Format(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "")
+	t.Setenv("LOG_FORMAT", "")
+	defer Init(Config{})
+
+	var buf bytes.Buffer
+	if err := Init(Config{Format: "json", Output: &buf}); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	Info("service started", "name", "web")
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("expected a JSON line, got %q: %v", buf.String(), err)
+	}
+	if line["msg"] != "service started" || line["name"] != "web" {
+		t.Fatalf("unexpected JSON line %v", line)
+	}
+
+	// LOG_FORMAT applies when no format is given
+	buf.Reset()
+	t.Setenv("LOG_FORMAT", "text")
+	if err := Init(Config{Output: &buf}); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	Info("service started", "name", "web")
+	if !strings.Contains(buf.String(), `msg="service started" name=web`) {
+		t.Fatalf("expected a text line, got %q", buf.String())
+	}
+
+	if err := Init(Config{Format: "xml"}); err == nil {
+		t.Fatal("expected an error for an unknown format")
+	}
+}
+
+func TestInit_File(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "")
+	t.Setenv("LOG_FORMAT", "")
+	defer Init(Config{})
+
+	path := filepath.Join(t.TempDir(), "logs", "autorun.log")
+	if err := Init(Config{File: path}); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	if file.MaxSize != DefaultMaxSizeMB {
+		t.Fatalf("expected the default size limit, got %d MB", file.MaxSize)
+	}
+	Info("service started", "name", "web")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("expected the log file to be created: %v", err)
+	}
+	if !strings.Contains(string(data), `msg="service started" name=web`) {
+		t.Fatalf("expected the log line in the file, got %q", data)
+	}
+
+	// A file under a regular file can't be created
+	if err := Init(Config{File: filepath.Join(path, "nested.log")}); err == nil {
+		t.Fatal("expected an error for an unwritable log file")
+	}
+}
+
+func TestInit_FileRetention(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "")
+	t.Setenv("LOG_FORMAT", "")
+	defer Init(Config{})
+
+	path := filepath.Join(t.TempDir(), "autorun.log")
+	if err := Init(Config{File: path, MaxBackups: 3, MaxAgeDays: 7}); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	if file.MaxBackups != 3 || file.MaxAge != 7 {
+		t.Fatalf("expected 3 backups for 7 days, got %d for %d", file.MaxBackups, file.MaxAge)
+	}
+
+	if err := Init(Config{File: path, MaxBackups: -1}); err == nil {
+		t.Fatal("expected an error for a negative backup count")
+	}
+	if err := Init(Config{File: path, MaxAgeDays: -1}); err == nil {
+		t.Fatal("expected an error for a negative age")
+	}
+}
@@ -1,5 +1,15 @@
 package models
 
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
 // Scope represents whether a service is system-level or user-level
 type Scope string
 
@@ -12,30 +22,355 @@ const (
 type Service struct {
 	Name        string `json:"name"`
 	DisplayName string `json:"displayName"`
-	Status      string `json:"status"` // running, stopped, failed, unknown
+	Status      string `json:"status"` // one of the Status* constants
 	Enabled     bool   `json:"enabled"`
 	Scope       Scope  `json:"scope"`
 	Description string `json:"description,omitempty"`
+	Type        string `json:"type"` // one of the Type* constants
+
+	// EnableState is the unit file state Enabled is derived from, such as
+	// enabled-runtime, static or indirect (systemd; see `systemctl
+	// is-enabled`). launchd sets it to unknown for a loaded job without a
+	// plist, whose Enabled is then false.
+	EnableState string `json:"enableState,omitempty"`
+
+	// Documentation lists the unit's documentation URLs (systemd only)
+	Documentation []string `json:"documentation,omitempty"`
+
+	// RunAs is the account the service runs as. Only filled in by
+	// GetService.
+	RunAs string `json:"runAs,omitempty"`
+
+	// LastExitClean and NeverRan refine a stopped status (launchd only):
+	// the job last exited with status 0, or it has never run.
+	LastExitClean bool `json:"lastExitClean,omitempty"`
+	NeverRan      bool `json:"neverRan,omitempty"`
+
+	// ExitCode and FailureReason explain a failed or abnormally stopped
+	// service: its main process's last exit status, and why it failed
+	// (e.g. systemd's "exit-code" or "timeout" result). Both are empty for
+	// healthy services.
+	ExitCode      int    `json:"exitCode,omitempty"`
+	FailureReason string `json:"failureReason,omitempty"`
+}
+
+// ServiceState is the current state of a single service, as reported by a
+// bulk status query
+type ServiceState struct {
+	Status      string `json:"status"`
+	Enabled     bool   `json:"enabled"`
+	EnableState string `json:"enableState,omitempty"` // as in Service
+	PID         int    `json:"pid,omitempty"`
+	NotFound    bool   `json:"notFound,omitempty"` // no such service in the scope
+}
+
+// LogCounts summarizes a service's recent warning and error log entries
+type LogCounts struct {
+	Errors   int    `json:"errors"`
+	Warnings int    `json:"warnings"`
+	Window   string `json:"window"` // "boot" or a duration such as "1h0m0s"
+}
+
+// Capabilities reports which optional features a platform's service manager
+// supports, so clients can hide controls that would only fail
+type Capabilities struct {
+	Mask         bool `json:"mask"`         // units can be masked to block all starts
+	Reload       bool `json:"reload"`       // services can reload their configuration in place
+	Timers       bool `json:"timers"`       // scheduled jobs (POST /api/timers, schedule on create)
+	Dependencies bool `json:"dependencies"` // after, requires and wants on create
+	Hooks        bool `json:"hooks"`        // execStartPre and execStopPost on create
+	ResetFailed  bool `json:"resetFailed"`  // POST /api/services/{name}/reset-failed
+	Logs         bool `json:"logs"`         // the platform's log tool is installed, so logs can be streamed
+
+	// ReadOnly is set by the API rather than the provider: the server
+	// refuses every change, so clients should hide their controls
+	ReadOnly bool `json:"readOnly"`
 }
 
-// Status constants
+// LogEntry is a single structured log message streamed for a service
+type LogEntry struct {
+	Time    time.Time `json:"ts"`
+	Level   string    `json:"level"` // critical, error, warning, notice, info or debug
+	Message string    `json:"message"`
+	Raw     string    `json:"raw"` // the line as the platform's log tool printed it
+}
+
+// LogLevels are the values of LogEntry.Level, most severe first
+var LogLevels = []string{"critical", "error", "warning", "notice", "info", "debug"}
+
+// ValidLogLevel reports whether level is empty or one of LogLevels
+func ValidLogLevel(level string) bool {
+	return level == "" || slices.Contains(LogLevels, level)
+}
+
+// LogLevelAtLeast reports whether level is at least as severe as min. Every
+// level passes an empty min; unknown levels never pass a non-empty one.
+func LogLevelAtLeast(level, min string) bool {
+	if min == "" {
+		return true
+	}
+	i := slices.Index(LogLevels, level)
+	return i >= 0 && i <= slices.Index(LogLevels, min)
+}
+
+// LogStreamOptions narrows a log stream
+type LogStreamOptions struct {
+	Level   string // drop entries less severe than this; empty keeps everything
+	History int    // recent messages to send before following; 0 sends none
+}
+
+// DefaultLogHistory is how many recent messages a log stream starts with
+// when the client doesn't ask for a number
+const DefaultLogHistory = 100
+
+// Process is a process belonging to a service
+type Process struct {
+	PID     int    `json:"pid"`
+	Command string `json:"command"`
+}
+
+// Status constants reported in Service.Status. starting and stopping are
+// transitional: the service manager is partway through bringing the
+// service up (or reloading it) or down, and it will settle on another
+// status shortly.
+const (
+	StatusRunning  = "running"
+	StatusStopped  = "stopped"
+	StatusFailed   = "failed"
+	StatusStarting = "starting"
+	StatusStopping = "stopping"
+	StatusUnknown  = "unknown"
+)
+
+// Type constants reported in Service.Type. systemd reports the unit type;
+// launchd reports agent or daemon by plist location, or timer for jobs with
+// a schedule. TypeService is the default when the kind is unknown.
 const (
-	StatusRunning = "running"
-	StatusStopped = "stopped"
-	StatusFailed  = "failed"
-	StatusUnknown = "unknown"
+	TypeService = "service"
+	TypeTimer   = "timer"
+	TypeSocket  = "socket"
+	TypeAgent   = "agent"
+	TypeDaemon  = "daemon"
 )
 
 // ServiceConfig holds the configuration for creating a new service
 type ServiceConfig struct {
-	Name             string            `json:"name"`             // Service name/label (required)
-	Description      string            `json:"description"`      // Human-readable description
-	Program          string            `json:"program"`          // Executable path (required)
-	Arguments        []string          `json:"arguments"`        // Command line arguments
-	WorkingDirectory string            `json:"workingDirectory"` // Working directory for the service
-	Environment      map[string]string `json:"environment"`      // Environment variables
-	RunAtLoad        bool              `json:"runAtLoad"`        // Start service when loaded/enabled
-	KeepAlive        bool              `json:"keepAlive"`        // Restart if it exits
-	StandardOutPath  string            `json:"standardOutPath"`  // Path for stdout log
-	StandardErrorPath string           `json:"standardErrorPath"` // Path for stderr log
+	Name              string            `json:"name"`              // Service name/label (required)
+	Description       string            `json:"description"`       // Human-readable description
+	Program           string            `json:"program"`           // Executable path (required)
+	Arguments         []string          `json:"arguments"`         // Command line arguments
+	WorkingDirectory  string            `json:"workingDirectory"`  // Working directory for the service
+	Environment       map[string]string `json:"environment"`       // Environment variables
+	RunAtLoad         bool              `json:"runAtLoad"`         // Start service when loaded/enabled
+	KeepAlive         bool              `json:"keepAlive"`         // Restart if it exits
+	StandardOutPath   string            `json:"standardOutPath"`   // Path for stdout log
+	StandardErrorPath string            `json:"standardErrorPath"` // Path for stderr log
+	Type              string            `json:"type,omitempty"`    // systemd service type (default simple; ignored by launchd)
+	User              string            `json:"user,omitempty"`    // Account to run as (system scope only)
+	Group             string            `json:"group,omitempty"`   // Group to run as (system scope only)
+
+	// EnvironmentFile is the absolute path of a KEY=VALUE file loaded into
+	// the environment. launchd has no equivalent, so its values are copied
+	// into the plist when the service is created.
+	EnvironmentFile string `json:"environmentFile,omitempty"`
+
+	// After, Requires and Wants are systemd unit dependencies. After is
+	// added to the default network.target ordering, which NoDefaultAfter
+	// leaves out. launchd has no unit dependencies and rejects them.
+	After          []string `json:"after,omitempty"`
+	NoDefaultAfter bool     `json:"noDefaultAfter,omitempty"`
+	Requires       []string `json:"requires,omitempty"`
+	Wants          []string `json:"wants,omitempty"`
+
+	// WantedBy is the systemd [Install] target that pulls the service in
+	// when enabled. Empty selects default.target for user services and
+	// multi-user.target for system services. Ignored by launchd.
+	WantedBy string `json:"wantedBy,omitempty"`
+
+	// RestartPolicy is one of RestartPolicies; empty means "always" when
+	// KeepAlive is set and no restart otherwise. RestartSec is the delay
+	// before restarting, in seconds.
+	RestartPolicy string `json:"restartPolicy,omitempty"`
+	RestartSec    int    `json:"restartSec,omitempty"`
+
+	// ExecStartPre and ExecStopPost are commands run before the service
+	// starts and after it stops, e.g. `mkdir -p /run/app`. Quotes group
+	// words as in ExecStart. systemd only; launchd rejects them.
+	ExecStartPre []string `json:"execStartPre,omitempty"`
+	ExecStopPost []string `json:"execStopPost,omitempty"`
+
+	// Schedule runs the service periodically instead of keeping it running.
+	// It is an OnCalendar= expression such as "daily" or "*-*-* 02:00:00";
+	// systemd pairs a oneshot service with a .timer, launchd writes a
+	// StartCalendarInterval. launchd also accepts a period in seconds
+	// (StartInterval) or a five-field cron spec.
+	Schedule string `json:"schedule,omitempty"`
+}
+
+// ServiceTypes are the accepted values of ServiceConfig.Type, matching
+// systemd's Type= setting
+var ServiceTypes = []string{"simple", "exec", "forking", "oneshot", "dbus", "notify", "notify-reload", "idle"}
+
+// RestartPolicies are the accepted values of ServiceConfig.RestartPolicy
+var RestartPolicies = []string{"no", "on-failure", "always"}
+
+// DefaultServiceType is used when ServiceConfig.Type is empty
+const DefaultServiceType = "simple"
+
+// serviceNamePattern is the charset allowed in service names: enough for
+// systemd unit names (including template instances like getty@tty1) and
+// reverse-DNS launchd labels, with no path separators
+var serviceNamePattern = regexp.MustCompile(`^[A-Za-z0-9_@:.-]+$`)
+
+// envKeyPattern matches a portable environment variable name
+var envKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// maxServiceNameLength is systemd's limit on unit names
+const maxServiceNameLength = 255
+
+// ValidateServiceName checks that name can safely be used as a unit name or
+// launchd label, and so as a file name in a unit or LaunchAgents directory
+func ValidateServiceName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("service name is required")
+	case len(name) > maxServiceNameLength:
+		return fmt.Errorf("service name is longer than %d characters", maxServiceNameLength)
+	case !serviceNamePattern.MatchString(name):
+		return fmt.Errorf("invalid service name %q (allowed: letters, digits and _ @ : . -)", name)
+	case strings.Contains(name, ".."), strings.HasPrefix(name, "."):
+		return fmt.Errorf("invalid service name %q", name)
+	case strings.HasPrefix(name, "-"):
+		// Would be taken for an option by systemctl and launchctl
+		return fmt.Errorf("invalid service name %q", name)
+	}
+	return nil
+}
+
+// Validate checks a service configuration before any file is written: the
+// name must be safe to use in a path, paths must be absolute since neither
+// systemd nor launchd resolves them against a known directory, dependencies
+// must be single unit names, and no value may contain a line break or NUL,
+// which would let it add directives to a unit file.
+func (c ServiceConfig) Validate() error {
+	if err := ValidateServiceName(c.Name); err != nil {
+		return err
+	}
+	if field := c.fieldWithControlChars(); field != "" {
+		return fmt.Errorf("%s must not contain line breaks or NUL characters", field)
+	}
+	if c.Program == "" {
+		return fmt.Errorf("program path is required")
+	}
+	if !filepath.IsAbs(c.Program) {
+		return fmt.Errorf("program path %q must be absolute", c.Program)
+	}
+	if c.WorkingDirectory != "" && !filepath.IsAbs(c.WorkingDirectory) {
+		return fmt.Errorf("working directory %q must be absolute", c.WorkingDirectory)
+	}
+	for key := range c.Environment {
+		if !envKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid environment variable name %q", key)
+		}
+	}
+	// Dependencies are joined with spaces, so an empty entry or one with a
+	// space would change the list systemd reads back
+	for _, dep := range slices.Concat(c.After, c.Requires, c.Wants) {
+		if err := ValidateServiceName(dep); err != nil {
+			return fmt.Errorf("invalid dependency %q: expected a unit name such as network.target", dep)
+		}
+	}
+	return nil
+}
+
+// fieldWithControlChars returns the name of the first field holding a line
+// break or NUL, or "" if there is none
+func (c ServiceConfig) fieldWithControlChars() string {
+	fields := []struct {
+		name   string
+		values []string
+	}{
+		{"description", []string{c.Description}},
+		{"program", []string{c.Program}},
+		{"arguments", c.Arguments},
+		{"workingDirectory", []string{c.WorkingDirectory}},
+		{"environment", slices.Collect(maps.Values(c.Environment))},
+		{"standardOutPath", []string{c.StandardOutPath}},
+		{"standardErrorPath", []string{c.StandardErrorPath}},
+		{"type", []string{c.Type}},
+		{"user", []string{c.User}},
+		{"group", []string{c.Group}},
+		{"environmentFile", []string{c.EnvironmentFile}},
+		{"after", c.After},
+		{"requires", c.Requires},
+		{"wants", c.Wants},
+		{"wantedBy", []string{c.WantedBy}},
+		{"restartPolicy", []string{c.RestartPolicy}},
+		{"execStartPre", c.ExecStartPre},
+		{"execStopPost", c.ExecStopPost},
+		{"schedule", []string{c.Schedule}},
+	}
+	for _, field := range fields {
+		for _, value := range field.values {
+			if HasControlChars(value) {
+				return field.name
+			}
+		}
+	}
+	return ""
+}
+
+// HasControlChars reports whether s contains a line break or NUL, which
+// can't be written safely into a unit file or init script line
+func HasControlChars(s string) bool {
+	return strings.ContainsAny(s, "\r\n\x00")
+}
+
+// ValidServiceType reports whether t is empty or one of ServiceTypes
+func ValidServiceType(t string) bool {
+	return t == "" || slices.Contains(ServiceTypes, t)
+}
+
+// ValidRestartPolicy reports whether p is empty or one of RestartPolicies
+func ValidRestartPolicy(p string) bool {
+	return p == "" || slices.Contains(RestartPolicies, p)
+}
+
+// TimerConfig holds the configuration for creating a scheduled job
+type TimerConfig struct {
+	Name       string   `json:"name"`       // Job name (required)
+	Program    string   `json:"program"`    // Executable path (required)
+	Arguments  []string `json:"arguments"`  // Command line arguments
+	OnCalendar string   `json:"onCalendar"` // systemd calendar expression, e.g. "daily" or "*-*-* 02:00:00" (required)
+	Persistent bool     `json:"persistent"` // Run a missed job after downtime (systemd only)
+}
+
+// Validate checks a timer configuration before any file is written. As for
+// services, no value may contain a line break or NUL: a newline in
+// OnCalendar would add directives to the .timer unit.
+func (c TimerConfig) Validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("timer name is required")
+	}
+	if err := ValidateServiceName(c.Name); err != nil {
+		return err
+	}
+	if c.Program == "" {
+		return fmt.Errorf("program path is required")
+	}
+	if !filepath.IsAbs(c.Program) {
+		return fmt.Errorf("program path %q must be absolute", c.Program)
+	}
+	if c.OnCalendar == "" {
+		return fmt.Errorf("onCalendar is required")
+	}
+	if HasControlChars(c.OnCalendar) {
+		return fmt.Errorf("onCalendar must be a single line")
+	}
+	for _, value := range append([]string{c.Program}, c.Arguments...) {
+		if HasControlChars(value) {
+			return fmt.Errorf("program and arguments must not contain line breaks or NUL characters")
+		}
+	}
+	return nil
 }
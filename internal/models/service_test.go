@@ -0,0 +1,82 @@
+package models
+
+import "testing"
+
+func TestServiceConfigValidate(t *testing.T) {
+	valid := ServiceConfig{
+		Name:             "com.example.web",
+		Program:          "/usr/local/bin/web",
+		WorkingDirectory: "/srv/web",
+		Environment:      map[string]string{"PORT": "8080", "_DEBUG": "1"},
+	}
+
+	cases := []struct {
+		name    string
+		modify  func(c *ServiceConfig)
+		wantErr bool
+	}{
+		{name: "valid", modify: func(c *ServiceConfig) {}},
+		{name: "template instance", modify: func(c *ServiceConfig) { c.Name = "getty@tty1" }},
+		{name: "missing name", modify: func(c *ServiceConfig) { c.Name = "" }, wantErr: true},
+		{name: "slash in name", modify: func(c *ServiceConfig) { c.Name = "web/../../etc" }, wantErr: true},
+		{name: "backslash in name", modify: func(c *ServiceConfig) { c.Name = `web\x2f` }, wantErr: true},
+		{name: "dot dot", modify: func(c *ServiceConfig) { c.Name = ".." }, wantErr: true},
+		{name: "hidden", modify: func(c *ServiceConfig) { c.Name = ".web" }, wantErr: true},
+		{name: "space", modify: func(c *ServiceConfig) { c.Name = "my web" }, wantErr: true},
+		{name: "missing program", modify: func(c *ServiceConfig) { c.Program = "" }, wantErr: true},
+		{name: "relative program", modify: func(c *ServiceConfig) { c.Program = "bin/web" }, wantErr: true},
+		{name: "relative working directory", modify: func(c *ServiceConfig) { c.WorkingDirectory = "web" }, wantErr: true},
+		{name: "bad env key", modify: func(c *ServiceConfig) { c.Environment = map[string]string{"1PORT": "80"} }, wantErr: true},
+		{name: "env key with dash", modify: func(c *ServiceConfig) { c.Environment = map[string]string{"MY-VAR": "x"} }, wantErr: true},
+		{name: "newline in description", modify: func(c *ServiceConfig) { c.Description = "web\nExecStartPre=/bin/sh -c id" }, wantErr: true},
+		{name: "carriage return in user", modify: func(c *ServiceConfig) { c.User = "www-data\rGroup=root" }, wantErr: true},
+		{name: "newline in env value", modify: func(c *ServiceConfig) { c.Environment = map[string]string{"PORT": "80\nUser=root"} }, wantErr: true},
+		{name: "NUL in argument", modify: func(c *ServiceConfig) { c.Arguments = []string{"a\x00b"} }, wantErr: true},
+		{name: "dependencies", modify: func(c *ServiceConfig) {
+			c.After = []string{"network-online.target", "db.service"}
+			c.Requires = []string{"getty@tty1.service"}
+			c.Wants = []string{"redis"}
+		}},
+		{name: "empty dependency", modify: func(c *ServiceConfig) { c.Requires = []string{""} }, wantErr: true},
+		{name: "space in dependency", modify: func(c *ServiceConfig) { c.After = []string{"foo bar"} }, wantErr: true},
+		{name: "path as dependency", modify: func(c *ServiceConfig) { c.Wants = []string{"../evil.service"} }, wantErr: true},
+		{name: "newline in dependency", modify: func(c *ServiceConfig) { c.After = []string{"a.service\nExecStartPre=/bin/true"} }, wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := valid
+			tc.modify(&config)
+			err := config.Validate()
+			if tc.wantErr && err == nil {
+				t.Fatal("expected error")
+			}
+			if !tc.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestTimerConfigValidate(t *testing.T) {
+	valid := TimerConfig{Name: "backup", Program: "/usr/local/bin/backup", OnCalendar: "daily"}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for name, modify := range map[string]func(c *TimerConfig){
+		"missing name":        func(c *TimerConfig) { c.Name = "" },
+		"missing calendar":    func(c *TimerConfig) { c.OnCalendar = "" },
+		"relative program":    func(c *TimerConfig) { c.Program = "bin/backup" },
+		"newline in calendar": func(c *TimerConfig) { c.OnCalendar = "daily\nPersistent=true" },
+		"newline in argument": func(c *TimerConfig) { c.Arguments = []string{"a\nb"} },
+	} {
+		t.Run(name, func(t *testing.T) {
+			config := valid
+			modify(&config)
+			if err := config.Validate(); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package platform
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// calendarKeys is the order StartCalendarInterval keys are written in
+var calendarKeys = []string{"Month", "Day", "Weekday", "Hour", "Minute"}
+
+// calendarShorthands maps systemd calendar shorthands to their launchd
+// StartCalendarInterval equivalents
+var calendarShorthands = map[string]map[string]int{
+	"minutely": {},
+	"hourly":   {"Minute": 0},
+	"daily":    {"Hour": 0, "Minute": 0},
+	"weekly":   {"Weekday": 1, "Hour": 0, "Minute": 0},
+	"monthly":  {"Day": 1, "Hour": 0, "Minute": 0},
+	"yearly":   {"Month": 1, "Day": 1, "Hour": 0, "Minute": 0},
+	"annually": {"Month": 1, "Day": 1, "Hour": 0, "Minute": 0},
+}
+
+// weekdays maps systemd weekday names to launchd Weekday numbers (0 = Sunday)
+var weekdays = map[string]int{
+	"sun": 0, "sunday": 0,
+	"mon": 1, "monday": 1,
+	"tue": 2, "tuesday": 2,
+	"wed": 3, "wednesday": 3,
+	"thu": 4, "thursday": 4,
+	"fri": 5, "friday": 5,
+	"sat": 6, "saturday": 6,
+}
+
+// parseCalendarInterval translates a systemd OnCalendar expression into a
+// launchd StartCalendarInterval dictionary. It supports the shorthands
+// (daily, weekly, ...) and the form "[Weekday] [*-MM-DD] HH:MM[:00]" where
+// each date and time component may be "*". Ranges, lists and repetitions
+// have no launchd equivalent and are rejected.
+func parseCalendarInterval(onCalendar string) (map[string]int, error) {
+	expr := strings.ToLower(strings.TrimSpace(onCalendar))
+	if interval, ok := calendarShorthands[expr]; ok {
+		result := make(map[string]int, len(interval))
+		for k, v := range interval {
+			result[k] = v
+		}
+		return result, nil
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty calendar expression")
+	}
+
+	interval := make(map[string]int)
+
+	if day, ok := weekdays[fields[0]]; ok {
+		interval["Weekday"] = day
+		fields = fields[1:]
+	}
+
+	if len(fields) > 0 && strings.Contains(fields[0], "-") {
+		parts := strings.Split(fields[0], "-")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("unsupported calendar date: %s", fields[0])
+		}
+		if parts[0] != "*" {
+			return nil, fmt.Errorf("specific years are not supported: %s", fields[0])
+		}
+		if err := setCalendarField(interval, "Month", parts[1], 1, 12); err != nil {
+			return nil, err
+		}
+		if err := setCalendarField(interval, "Day", parts[2], 1, 31); err != nil {
+			return nil, err
+		}
+		fields = fields[1:]
+	}
+
+	if len(fields) != 1 {
+		return nil, fmt.Errorf("unsupported calendar expression: %s", onCalendar)
+	}
+
+	parts := strings.Split(fields[0], ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return nil, fmt.Errorf("unsupported calendar time: %s", fields[0])
+	}
+	if len(parts) == 3 && parts[2] != "00" && parts[2] != "0" {
+		return nil, fmt.Errorf("launchd schedules cannot specify seconds: %s", fields[0])
+	}
+	if err := setCalendarField(interval, "Hour", parts[0], 0, 23); err != nil {
+		return nil, err
+	}
+	if err := setCalendarField(interval, "Minute", parts[1], 0, 59); err != nil {
+		return nil, err
+	}
+
+	return interval, nil
+}
+
+// setCalendarField parses value into interval[key] unless it is "*"
+func setCalendarField(interval map[string]int, key, value string, lo, hi int) error {
+	if value == "*" {
+		return nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < lo || n > hi {
+		return fmt.Errorf("invalid %s in calendar expression: %s", strings.ToLower(key), value)
+	}
+	interval[key] = n
+	return nil
+}
+
+// launchdSchedule is a parsed ServiceConfig.Schedule: either a fixed
+// StartInterval in seconds or a StartCalendarInterval dictionary
+type launchdSchedule struct {
+	Interval int
+	Calendar map[string]int
+}
+
+// cronKeys are the StartCalendarInterval keys for the five cron fields, in
+// order: minute, hour, day of month, month, day of week
+var cronKeys = []struct {
+	key    string
+	lo, hi int
+}{
+	{"Minute", 0, 59},
+	{"Hour", 0, 23},
+	{"Day", 1, 31},
+	{"Month", 1, 12},
+	{"Weekday", 0, 7},
+}
+
+// parseLaunchdSchedule translates a schedule into launchd terms. A bare
+// integer is a period in seconds (StartInterval); five fields are a cron
+// spec such as "30 2 * * 1"; anything else is read as a systemd OnCalendar
+// expression so the same schedule works on both platforms.
+func parseLaunchdSchedule(schedule string) (launchdSchedule, error) {
+	expr := strings.TrimSpace(schedule)
+	if n, err := strconv.Atoi(expr); err == nil {
+		if n <= 0 {
+			return launchdSchedule{}, fmt.Errorf("schedule interval must be a positive number of seconds: %s", schedule)
+		}
+		return launchdSchedule{Interval: n}, nil
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) == len(cronKeys) {
+		interval := make(map[string]int)
+		for i, field := range fields {
+			k := cronKeys[i]
+			if err := setCalendarField(interval, k.key, field, k.lo, k.hi); err != nil {
+				return launchdSchedule{}, fmt.Errorf("unsupported cron schedule %q: %w", schedule, err)
+			}
+		}
+		return launchdSchedule{Calendar: interval}, nil
+	}
+
+	interval, err := parseCalendarInterval(expr)
+	if err != nil {
+		return launchdSchedule{}, err
+	}
+	return launchdSchedule{Calendar: interval}, nil
+}
+
+// writeCalendarInterval writes a StartCalendarInterval dictionary
+func writeCalendarInterval(sb *strings.Builder, interval map[string]int) {
+	sb.WriteString(`	<key>StartCalendarInterval</key>
+	<dict>
+`)
+	for _, key := range calendarKeys {
+		value, ok := interval[key]
+		if !ok {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("\t\t<key>%s</key>\n\t\t<integer>%d</integer>\n", key, value))
+	}
+	sb.WriteString(`	</dict>
+`)
+}
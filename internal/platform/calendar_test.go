@@ -0,0 +1,84 @@
+package platform
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestParseCalendarInterval(t *testing.T) {
+	cases := []struct {
+		name    string
+		expr    string
+		want    map[string]int
+		wantErr bool
+	}{
+		{name: "daily", expr: "daily", want: map[string]int{"Hour": 0, "Minute": 0}},
+		{name: "weekly", expr: "Weekly", want: map[string]int{"Weekday": 1, "Hour": 0, "Minute": 0}},
+		{name: "every day at time", expr: "*-*-* 02:30:00", want: map[string]int{"Hour": 2, "Minute": 30}},
+		{name: "time only", expr: "14:05", want: map[string]int{"Hour": 14, "Minute": 5}},
+		{name: "weekday and time", expr: "Fri 18:00", want: map[string]int{"Weekday": 5, "Hour": 18, "Minute": 0}},
+		{name: "day of month", expr: "*-*-15 09:00", want: map[string]int{"Day": 15, "Hour": 9, "Minute": 0}},
+		{name: "every hour on the quarter", expr: "*-*-* *:15", want: map[string]int{"Minute": 15}},
+		{name: "specific year", expr: "2025-01-01 00:00", wantErr: true},
+		{name: "seconds", expr: "*-*-* 02:00:30", wantErr: true},
+		{name: "out of range", expr: "25:00", wantErr: true},
+		{name: "list", expr: "Mon,Fri 10:00", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseCalendarInterval(tc.expr)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !maps.Equal(got, tc.want) {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParseLaunchdSchedule(t *testing.T) {
+	cases := []struct {
+		name         string
+		schedule     string
+		wantInterval int
+		wantCalendar map[string]int
+		wantErr      bool
+	}{
+		{name: "seconds", schedule: "300", wantInterval: 300},
+		{name: "cron", schedule: "30 2 * * 1", wantCalendar: map[string]int{"Minute": 30, "Hour": 2, "Weekday": 1}},
+		{name: "cron monthly", schedule: "0 0 1 * *", wantCalendar: map[string]int{"Minute": 0, "Hour": 0, "Day": 1}},
+		{name: "calendar", schedule: "*-*-* 02:00:00", wantCalendar: map[string]int{"Hour": 2, "Minute": 0}},
+		{name: "shorthand", schedule: "daily", wantCalendar: map[string]int{"Hour": 0, "Minute": 0}},
+		{name: "zero seconds", schedule: "0", wantErr: true},
+		{name: "negative seconds", schedule: "-5", wantErr: true},
+		{name: "cron step", schedule: "*/5 * * * *", wantErr: true},
+		{name: "cron out of range", schedule: "60 * * * *", wantErr: true},
+		{name: "garbage", schedule: "sometimes", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseLaunchdSchedule(tc.schedule)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.Interval != tc.wantInterval || !maps.Equal(got.Calendar, tc.wantCalendar) {
+				t.Fatalf("expected interval %d calendar %v, got %+v", tc.wantInterval, tc.wantCalendar, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package platform
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"autorun/internal/logger"
+	"autorun/internal/models"
+)
+
+// The Windows Event Log is read with wevtutil, which is present on every
+// Windows install and renders messages from their provider's resources.
+// Nothing here needs Windows APIs, so the parsing is tested on any platform.
+
+// eventLogChannel is the log services write their events to
+const eventLogChannel = "Application"
+
+// eventPollInterval is how often a followed event log is queried for new
+// events; wevtutil can't subscribe
+const eventPollInterval = 2 * time.Second
+
+// eventLevels maps LogEntry levels to Windows event levels. Level 0
+// (LogAlways) is counted as info. Windows has no notice level.
+var eventLevels = map[string][]int{
+	"critical": {1},
+	"error":    {2},
+	"warning":  {3},
+	"info":     {0, 4},
+	"debug":    {5},
+}
+
+// eventLevelName maps a Windows event level to a LogEntry level
+func eventLevelName(level int) string {
+	for name, levels := range eventLevels {
+		if slices.Contains(levels, level) {
+			return name
+		}
+	}
+	return "info"
+}
+
+// eventLevelCondition returns the XPath condition selecting events at
+// least as severe as min, or "" for no filter
+func eventLevelCondition(min string) string {
+	if min == "" {
+		return ""
+	}
+	var terms []string
+	for _, name := range models.LogLevels {
+		if !models.LogLevelAtLeast(name, min) {
+			continue
+		}
+		for _, level := range eventLevels[name] {
+			terms = append(terms, "Level="+strconv.Itoa(level))
+		}
+	}
+	slices.Sort(terms)
+	return "(" + strings.Join(terms, " or ") + ")"
+}
+
+// eventQuery builds an XPath query for events logged by source, narrowed by
+// the given conditions
+func eventQuery(source string, conditions ...string) string {
+	terms := []string{"Provider[@Name='" + source + "']"}
+	for _, c := range conditions {
+		if c != "" {
+			terms = append(terms, c)
+		}
+	}
+	return "*[System[" + strings.Join(terms, " and ") + "]]"
+}
+
+// eventQueryArgs returns the wevtutil arguments running query against the
+// Application log. extra selects direction and count, e.g. /rd:true /c:10.
+func eventQueryArgs(query string, extra ...string) []string {
+	args := []string{"qe", eventLogChannel, "/q:" + query, "/f:RenderedXml", "/e:Events"}
+	return append(args, extra...)
+}
+
+// windowsEvent is the part of an event's rendered XML autorun reads
+type windowsEvent struct {
+	System struct {
+		Level       int    `xml:"Level"`
+		RecordID    uint64 `xml:"EventRecordID"`
+		TimeCreated struct {
+			SystemTime string `xml:"SystemTime,attr"`
+		} `xml:"TimeCreated"`
+	} `xml:"System"`
+	Data    []string `xml:"EventData>Data"`
+	Message string   `xml:"RenderingInfo>Message"`
+}
+
+// parseEvents parses `wevtutil qe /f:RenderedXml /e:Events` output into
+// entries and their record IDs, in output order
+func parseEvents(output []byte) ([]models.LogEntry, []uint64, error) {
+	var doc struct {
+		Events []windowsEvent `xml:"Event"`
+	}
+	if len(strings.TrimSpace(string(output))) == 0 {
+		return nil, nil, nil
+	}
+	if err := xml.Unmarshal(output, &doc); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse wevtutil output: %w", err)
+	}
+
+	entries := make([]models.LogEntry, 0, len(doc.Events))
+	ids := make([]uint64, 0, len(doc.Events))
+	for _, event := range doc.Events {
+		entry := models.LogEntry{Level: eventLevelName(event.System.Level)}
+		if t, err := time.Parse(time.RFC3339Nano, event.System.TimeCreated.SystemTime); err == nil {
+			entry.Time = t.UTC()
+		}
+		// Events from a source without a message file have no rendered
+		// message, only their insertion strings
+		message := event.Message
+		if message == "" {
+			message = strings.Join(event.Data, " ")
+		}
+		entry.Message = strings.Join(strings.Fields(message), " ")
+		entry.Raw = formatEventLine(entry)
+		entries = append(entries, entry)
+		ids = append(ids, event.System.RecordID)
+	}
+	return entries, ids, nil
+}
+
+// formatEventLine renders an entry as a single text log line
+func formatEventLine(entry models.LogEntry) string {
+	return fmt.Sprintf("%s %s %s", entry.Time.Local().Format("2006-01-02 15:04:05.000"), entry.Level, entry.Message)
+}
+
+// eventLogCounts counts warning- and error-level events logged by source
+// within window
+func eventLogCounts(ctx context.Context, runner CommandRunner, timeouts Timeouts, source string, window time.Duration) (models.LogCounts, error) {
+	query := eventQuery(source, eventLevelCondition("warning"),
+		fmt.Sprintf("TimeCreated[timediff(@SystemTime) <= %d]", window.Milliseconds()))
+	output, err := runCommand(ctx, runner, timeouts, OpList, "wevtutil", eventQueryArgs(query)...)
+	if err != nil {
+		return models.LogCounts{}, newCommandError(err, "wevtutil failed: "+strings.TrimSpace(commandOutput(output, err)))
+	}
+	entries, _, err := parseEvents(output)
+	if err != nil {
+		return models.LogCounts{}, err
+	}
+
+	var counts models.LogCounts
+	for _, entry := range entries {
+		switch entry.Level {
+		case "critical", "error":
+			counts.Errors++
+		case "warning":
+			counts.Warnings++
+		}
+	}
+	return counts, nil
+}
+
+// followEventLog sends the last opts.History events logged by source and
+// then polls for new ones every interval until emit returns false, then
+// calls done. The history query runs before returning so a broken wevtutil
+// is reported to the caller.
+func followEventLog(ctx context.Context, runner CommandRunner, timeouts Timeouts, source string, opts models.LogStreamOptions, interval time.Duration, done func(), emit func(models.LogEntry) bool) error {
+	level := eventLevelCondition(opts.Level)
+	query := func(extra ...string) ([]models.LogEntry, []uint64, error) {
+		return fetchEvents(ctx, runner, timeouts, eventQueryArgs(eventQuery(source, level), extra...))
+	}
+
+	// Newest first, so the history is reversed before sending
+	count := max(opts.History, 1)
+	history, ids, err := query("/rd:true", "/c:"+strconv.Itoa(count))
+	if err != nil {
+		return err
+	}
+	var last uint64
+	if len(ids) > 0 {
+		last = ids[0]
+	}
+	if opts.History == 0 {
+		history = nil
+	}
+	slices.Reverse(history)
+
+	go func() {
+		defer done()
+		for _, entry := range history {
+			if !emit(entry) {
+				return
+			}
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			entries, ids, err := fetchEvents(ctx, runner, timeouts,
+				eventQueryArgs(eventQuery(source, level, "EventRecordID>"+strconv.FormatUint(last, 10))))
+			if err != nil {
+				logger.Warn("event log poll failed", "source", source, "error", err)
+				continue
+			}
+			for i, entry := range entries {
+				last = max(last, ids[i])
+				if !emit(entry) {
+					return
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// fetchEvents runs a wevtutil query and parses the events it prints
+func fetchEvents(ctx context.Context, runner CommandRunner, timeouts Timeouts, args []string) ([]models.LogEntry, []uint64, error) {
+	output, err := runCommand(ctx, runner, timeouts, OpList, "wevtutil", args...)
+	if err != nil {
+		return nil, nil, newCommandError(err, "wevtutil failed: "+strings.TrimSpace(commandOutput(output, err)))
+	}
+	return parseEvents(output)
+}
@@ -0,0 +1,119 @@
+package platform
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"autorun/internal/models"
+)
+
+// Two events as printed by `wevtutil qe /f:RenderedXml`: an error with a
+// rendered multi-line message, and an event from a source without a
+// message file
+const (
+	sampleErrorEvent = `<Event xmlns='http://schemas.microsoft.com/win/2004/08/events/event'><System><Provider Name='web'/><EventID>1</EventID><Level>2</Level><TimeCreated SystemTime='2025-03-01T04:00:00.2500000Z'/><EventRecordID>12</EventRecordID></System><EventData><Data>ignored</Data></EventData><RenderingInfo Culture='en-US'><Message>Listener failed:
+port in use</Message><Level>Error</Level></RenderingInfo></Event>`
+	sampleInfoEvent = `<Event xmlns='http://schemas.microsoft.com/win/2004/08/events/event'><System><Provider Name='web'/><EventID>2</EventID><Level>0</Level><TimeCreated SystemTime='2025-03-01T04:00:01.0000000Z'/><EventRecordID>13</EventRecordID></System><EventData><Data>started</Data><Data>ok</Data></EventData></Event>`
+)
+
+var sampleEvents = "<Events>\n" + sampleErrorEvent + "\n" + sampleInfoEvent + "\n</Events>"
+
+func TestParseEvents(t *testing.T) {
+	entries, ids, err := parseEvents([]byte(sampleEvents))
+	if err != nil {
+		t.Fatalf("parseEvents: %v", err)
+	}
+	if want := []uint64{12, 13}; !slices.Equal(ids, want) {
+		t.Fatalf("expected ids %v, got %v", want, ids)
+	}
+
+	want := []struct {
+		level   string
+		message string
+		time    time.Time
+	}{
+		{level: "error", message: "Listener failed: port in use", time: time.Date(2025, 3, 1, 4, 0, 0, 250_000_000, time.UTC)},
+		{level: "info", message: "started ok", time: time.Date(2025, 3, 1, 4, 0, 1, 0, time.UTC)},
+	}
+	for i, w := range want {
+		got := entries[i]
+		if got.Level != w.level || got.Message != w.message || !got.Time.Equal(w.time) {
+			t.Fatalf("entry %d: expected %s %q at %v, got %+v", i, w.level, w.message, w.time, got)
+		}
+		if !strings.HasSuffix(got.Raw, w.level+" "+w.message) {
+			t.Fatalf("entry %d: unexpected raw line %q", i, got.Raw)
+		}
+	}
+
+	if entries, _, err := parseEvents([]byte("\r\n")); err != nil || len(entries) != 0 {
+		t.Fatalf("expected no events from empty output, got %v, %v", entries, err)
+	}
+}
+
+func TestEventQuery(t *testing.T) {
+	cases := []struct {
+		level string
+		want  string
+	}{
+		{level: "", want: "*[System[Provider[@Name='web']]]"},
+		{level: "warning", want: "*[System[Provider[@Name='web'] and (Level=1 or Level=2 or Level=3)]]"},
+		{level: "info", want: "*[System[Provider[@Name='web'] and (Level=0 or Level=1 or Level=2 or Level=3 or Level=4)]]"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.level, func(t *testing.T) {
+			if got := eventQuery("web", eventLevelCondition(tc.level)); got != tc.want {
+				t.Fatalf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestFollowEventLog(t *testing.T) {
+	newer := `<Events><Event><System><Level>3</Level><TimeCreated SystemTime='2025-03-01T04:00:02Z'/><EventRecordID>14</EventRecordID></System><RenderingInfo><Message>slow</Message></RenderingInfo></Event></Events>`
+	runner := &fakeRunner{handle: func(name string, args []string) ([]byte, error) {
+		query := strings.Join(args, " ")
+		switch {
+		case strings.Contains(query, "/rd:true"):
+			// Newest first
+			return []byte("<Events>" + sampleInfoEvent + sampleErrorEvent + "</Events>"), nil
+		case strings.Contains(query, "EventRecordID>13"):
+			return []byte(newer), nil
+		}
+		return nil, nil
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := make(chan models.LogEntry, 10)
+	err := followEventLog(ctx, runner, nil, "web", models.LogStreamOptions{History: 2}, time.Millisecond, func() { close(ch) }, func(entry models.LogEntry) bool {
+		select {
+		case <-ctx.Done():
+			return false
+		case ch <- entry:
+			return true
+		}
+	})
+	if err != nil {
+		t.Fatalf("followEventLog: %v", err)
+	}
+
+	var messages []string
+	timeout := time.After(5 * time.Second)
+	for len(messages) < 3 {
+		select {
+		case entry := <-ch:
+			messages = append(messages, entry.Message)
+		case <-timeout:
+			t.Fatalf("timed out, got %v", messages)
+		}
+	}
+	// History is sent oldest first, then events newer than its last one
+	if want := []string{"Listener failed: port in use", "started ok", "slow"}; !slices.Equal(messages, want) {
+		t.Fatalf("expected %v, got %v", want, messages)
+	}
+	cancel()
+	waitClosed(t, ch, 5*time.Second)
+}
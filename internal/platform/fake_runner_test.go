@@ -0,0 +1,54 @@
+package platform
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type fakeRunner struct {
+	mu    sync.Mutex
+	calls [][]string
+
+	// deadlines records each call's context deadline (zero if none)
+	deadlines []time.Time
+
+	// handle returns the canned result for a command. A nil handle makes
+	// every command succeed with empty output.
+	handle func(name string, args []string) ([]byte, error)
+}
+
+func (r *fakeRunner) Run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	r.mu.Lock()
+	r.calls = append(r.calls, append([]string{name}, args...))
+	deadline, _ := ctx.Deadline()
+	r.deadlines = append(r.deadlines, deadline)
+	r.mu.Unlock()
+
+	if r.handle == nil {
+		return nil, nil
+	}
+	return r.handle(name, args)
+}
+
+// commands returns each recorded call joined into a single string
+func (r *fakeRunner) commands() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	out := make([]string, 0, len(r.calls))
+	for _, call := range r.calls {
+		out = append(out, strings.Join(call, " "))
+	}
+	return out
+}
+
+// fakeExitError mimics *exec.ExitError for a command that exited non-zero
+type fakeExitError struct {
+	code int
+}
+
+func (e *fakeExitError) Error() string { return "exit status " + strconv.Itoa(e.code) }
+func (e *fakeExitError) ExitCode() int { return e.code }
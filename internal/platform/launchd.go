@@ -2,27 +2,93 @@ package platform
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"maps"
 	"os"
 	"os/exec"
 	"os/user"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"autorun/internal/logger"
 	"autorun/internal/models"
 )
 
+// defaultStopSignal is sent by `launchctl kill` when no signal is configured
+const defaultStopSignal = "SIGTERM"
+
+// stopPollInterval is how often a signalled process is checked for exit
+// while waiting to escalate to SIGKILL
+const stopPollInterval = 250 * time.Millisecond
+
+// stopSignals lists the signals accepted for the configurable stop signal
+var stopSignals = map[string]bool{
+	"SIGTERM": true,
+	"SIGINT":  true,
+	"SIGHUP":  true,
+	"SIGQUIT": true,
+	"SIGKILL": true,
+	"SIGUSR1": true,
+	"SIGUSR2": true,
+}
+
+// normalizeSignal validates a signal name, accepting it with or without the
+// SIG prefix, and returns the canonical SIG-prefixed form.
+func normalizeSignal(signal string) (string, error) {
+	if signal == "" {
+		return defaultStopSignal, nil
+	}
+	name := strings.ToUpper(signal)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	if !stopSignals[name] {
+		return "", fmt.Errorf("unsupported stop signal: %s", signal)
+	}
+	return name, nil
+}
+
 // LaunchdProvider implements ServiceProvider for macOS launchd
 type LaunchdProvider struct {
 	userHome string
 	uid      string
+	runner   CommandRunner
+	timeouts Timeouts
+
+	// stopSignal and stopTimeout control how Stop signals a service when it
+	// falls back to `launchctl kill`; see StopWithSignal.
+	stopSignal   string
+	stopTimeout  time.Duration
+	pollInterval time.Duration
+
+	// logCommand builds the `log` command for log streams; nil runs the
+	// real one. Tests substitute a stand-in process.
+	logCommand func(ctx context.Context, args ...string) *exec.Cmd
+
+	// files and follow reach the host launchd runs on; nil means this one.
+	// The SSH provider sets them for a remote Mac.
+	files  hostFiles
+	follow followFunc
+
+	// noLogTool is set when `log` wasn't found, so logs are refused
+	noLogTool bool
 }
 
 // NewLaunchdProvider creates a new launchd provider
-func NewLaunchdProvider() (*LaunchdProvider, error) {
+func NewLaunchdProvider(opts Options) (*LaunchdProvider, error) {
+	stopSignal, err := normalizeSignal(opts.StopSignal)
+	if err != nil {
+		return nil, err
+	}
+
+	runner := opts.runner()
 	u, err := user.Current()
 	if err != nil {
 		logger.Error("failed to get current user", "error", err)
@@ -37,8 +103,7 @@ func NewLaunchdProvider() (*LaunchdProvider, error) {
 	// by checking the owner of /dev/console
 	if uid == "0" {
 		logger.Debug("running as root, detecting console user")
-		cmd := exec.Command("stat", "-f", "%u", "/dev/console")
-		if output, err := cmd.Output(); err == nil {
+		if output, err := runCommand(context.Background(), runner, opts.Timeouts, OpStatus, "stat", "-f", "%u", "/dev/console"); err == nil {
 			consoleUID := strings.TrimSpace(string(output))
 			if consoleUID != "" && consoleUID != "0" {
 				uid = consoleUID
@@ -53,8 +118,14 @@ func NewLaunchdProvider() (*LaunchdProvider, error) {
 	}
 
 	return &LaunchdProvider{
-		userHome: userHome,
-		uid:      uid,
+		userHome:     userHome,
+		uid:          uid,
+		runner:       runner,
+		timeouts:     opts.Timeouts,
+		stopSignal:   stopSignal,
+		stopTimeout:  opts.StopTimeout,
+		pollInterval: stopPollInterval,
+		noLogTool:    toolMissing("log"),
 	}, nil
 }
 
@@ -62,11 +133,33 @@ func (p *LaunchdProvider) Name() string {
 	return "launchd"
 }
 
+// Capabilities reports launchd's feature set. Jobs have no unit dependencies,
+// pre/post hooks, masking, reload or failed state to reset; calendar jobs
+// stand in for timers.
+func (p *LaunchdProvider) Capabilities() models.Capabilities {
+	return models.Capabilities{Timers: true, Logs: !p.noLogTool}
+}
+
+// hostFiles returns the file system plists are read from
+func (p *LaunchdProvider) hostFiles() hostFiles {
+	if p.files != nil {
+		return p.files
+	}
+	return localFiles{}
+}
+
+// run runs a command with args, bounded by the timeout for op
+func (p *LaunchdProvider) run(ctx context.Context, op, name string, args ...string) ([]byte, error) {
+	return runCommand(ctx, p.runner, p.timeouts, op, name, args...)
+}
+
 // launchdEntry represents a parsed line from a launchctl domain services listing
 // (launchctl print <domain>)
 type launchdEntry struct {
-	pid   int    // 0 if not running/unknown
-	label string // service label
+	pid      int    // 0 if not running/unknown
+	label    string // service label
+	exited   bool   // false if launchd shows no last exit status ("-")
+	lastExit int    // last exit status, valid when exited
 }
 
 // parseLaunchctlPrintServices parses the "services = { ... }" block of
@@ -102,19 +195,37 @@ func parseLaunchctlPrintServices(output string) []launchdEntry {
 			continue
 		}
 
-		entries = append(entries, launchdEntry{
+		entry := launchdEntry{
 			pid:   pid,
 			label: fields[2],
-		})
+		}
+		if status, err := strconv.Atoi(fields[1]); err == nil {
+			entry.exited = true
+			entry.lastExit = status
+		}
+		entries = append(entries, entry)
 	}
 
 	return entries
 }
 
-func (p *LaunchdProvider) listDomainServices(domain string) ([]launchdEntry, error) {
+// launchdState derives a service's status from its domain listing entry.
+// A stopped job is split into one that last exited 0 and one that has no
+// exit status because it was never loaded or never ran.
+func launchdState(entry launchdEntry, loaded bool) (status string, lastExitClean, neverRan bool) {
+	switch {
+	case loaded && entry.pid > 0:
+		return models.StatusRunning, false, false
+	case loaded && entry.exited:
+		return models.StatusStopped, entry.lastExit == 0, false
+	default:
+		return models.StatusStopped, false, true
+	}
+}
+
+func (p *LaunchdProvider) listDomainServices(ctx context.Context, domain string) ([]launchdEntry, error) {
 	logger.Debug("listing domain services", "domain", domain)
-	cmd := exec.Command("launchctl", "print", domain)
-	output, err := cmd.Output()
+	output, err := p.run(ctx, OpList, "launchctl", "print", domain)
 	if err != nil {
 		logger.Error("launchctl print failed", "domain", domain, "error", err)
 		return nil, fmt.Errorf("launchctl print %s failed: %w", domain, err)
@@ -127,9 +238,8 @@ func (p *LaunchdProvider) listDomainServices(domain string) ([]launchdEntry, err
 
 // listDisabledServices returns a map of label -> disabled for the domain.
 // If the command fails, an empty map is returned.
-func (p *LaunchdProvider) listDisabledServices(domain string) map[string]bool {
-	cmd := exec.Command("launchctl", "print-disabled", domain)
-	output, err := cmd.Output()
+func (p *LaunchdProvider) listDisabledServices(ctx context.Context, domain string) map[string]bool {
+	output, err := p.run(ctx, OpList, "launchctl", "print-disabled", domain)
 	if err != nil {
 		return map[string]bool{}
 	}
@@ -184,18 +294,18 @@ func (p *LaunchdProvider) getServiceDirs(scope models.Scope) []string {
 }
 
 // findPlistForLabel searches for a plist file matching the label
-func (p *LaunchdProvider) findPlistForLabel(label string, scope models.Scope) string {
+func (p *LaunchdProvider) findPlistForLabel(ctx context.Context, label string, scope models.Scope) string {
 	dirs := p.getServiceDirs(scope)
 	for _, dir := range dirs {
 		plistPath := filepath.Join(dir, label+".plist")
-		if _, err := os.Stat(plistPath); err == nil {
+		if p.hostFiles().Exists(ctx, plistPath) {
 			return plistPath
 		}
 	}
 	return ""
 }
 
-func (p *LaunchdProvider) ListServices(scope models.Scope) ([]models.Service, error) {
+func (p *LaunchdProvider) ListServices(ctx context.Context, scope models.Scope) ([]models.Service, error) {
 	var domainTarget string
 	switch scope {
 	case models.ScopeUser:
@@ -206,101 +316,363 @@ func (p *LaunchdProvider) ListServices(scope models.Scope) ([]models.Service, er
 		return nil, fmt.Errorf("invalid scope: %s", scope)
 	}
 
-	entries, err := p.listDomainServices(domainTarget)
+	entries, err := p.listDomainServices(ctx, domainTarget)
 	if err != nil {
 		return nil, err
 	}
 
-	// Map of running state by label for this domain.
-	runningByLabel := make(map[string]bool)
+	// Map of loaded jobs by label for this domain.
+	entryByLabel := make(map[string]launchdEntry, len(entries))
 	for _, entry := range entries {
-		if entry.pid > 0 {
-			runningByLabel[entry.label] = true
-		}
+		entryByLabel[entry.label] = entry
 	}
 
 	// Launchd doesn't have a single query that returns "enabled" for every service
 	// the way systemd does. We approximate enabled/disabled using
 	// `launchctl print-disabled <domain>` and fall back to filesystem presence.
-	disabledByLabel := p.listDisabledServices(domainTarget)
+	disabledByLabel := p.listDisabledServices(ctx, domainTarget)
 
 	knownLabels := make(map[string]bool)
+	infoByLabel := make(map[string]launchdJobInfo)
 	dirs := p.getServiceDirs(scope)
 	for _, dir := range dirs {
-		files, err := os.ReadDir(dir)
+		files, err := p.hostFiles().ReadDir(ctx, dir)
 		if err != nil {
 			continue
 		}
 		for _, f := range files {
-			if strings.HasSuffix(f.Name(), ".plist") {
-				label := strings.TrimSuffix(f.Name(), ".plist")
+			if strings.HasSuffix(f, ".plist") {
+				label := strings.TrimSuffix(f, ".plist")
 				knownLabels[label] = true
+				// The first directory wins, as in findPlistForLabel
+				if _, ok := infoByLabel[label]; !ok {
+					infoByLabel[label] = p.jobInfo(ctx, filepath.Join(dir, f))
+				}
 			}
 		}
 	}
 
-	// Only show services that have plist files in known directories
-	services := make([]models.Service, 0, len(knownLabels))
+	// Jobs with a plist in the known directories are listed whether or not
+	// they are loaded, and loaded jobs without one (loaded from elsewhere,
+	// or submitted with `launchctl submit`) are listed too. Labels are
+	// sorted so the result order is stable between calls.
+	labels := make([]string, 0, len(knownLabels)+len(entryByLabel))
 	for label := range knownLabels {
-		status := models.StatusStopped
-		if runningByLabel[label] {
-			status = models.StatusRunning
+		labels = append(labels, label)
+	}
+	for label := range entryByLabel {
+		if !knownLabels[label] {
+			labels = append(labels, label)
+		}
+	}
+	sort.Strings(labels)
+
+	services := make([]models.Service, 0, len(labels))
+	for _, label := range labels {
+		entry, loaded := entryByLabel[label]
+		status, lastExitClean, neverRan := launchdState(entry, loaded)
+
+		info, hasPlist := infoByLabel[label]
+		enabled, enableState := true, ""
+		if !hasPlist {
+			// Without a plist there's no telling whether the job comes back
+			// after a reboot
+			enabled, enableState = false, launchdEnableUnknown
+			info.jobType = p.domainJobType(scope)
 		}
-
-		enabled := knownLabels[label]
 		if disabled, ok := disabledByLabel[label]; ok {
-			enabled = !disabled
+			enabled, enableState = !disabled, ""
 		}
 
 		services = append(services, models.Service{
-			Name:        label,
-			DisplayName: label,
-			Status:      status,
-			Enabled:     enabled,
-			Scope:       scope,
+			Name:          label,
+			DisplayName:   launchdDisplayName(label),
+			Status:        status,
+			Enabled:       enabled,
+			EnableState:   enableState,
+			Scope:         scope,
+			Description:   info.description,
+			LastExitClean: lastExitClean,
+			NeverRan:      neverRan,
+			Type:          info.jobType,
 		})
+
+		// Only jobs that exited non-zero get the extra per-job query
+		if loaded && entry.exited && entry.lastExit != 0 {
+			svc := &services[len(services)-1]
+			svc.ExitCode = entry.lastExit
+			svc.FailureReason = p.failureReason(ctx, p.serviceTarget(label, scope))
+		}
 	}
 
 	return services, nil
 }
 
-func (p *LaunchdProvider) GetService(name string, scope models.Scope) (*models.Service, error) {
-	services, err := p.ListServices(scope)
+// failureReason returns the description launchd gives for a job's last
+// exit, e.g. "EX_CONFIG" or "Killed: 9", or "" if it gives none
+func (p *LaunchdProvider) failureReason(ctx context.Context, serviceTarget string) string {
+	output, err := p.run(ctx, OpStatus, "launchctl", "print", serviceTarget)
 	if err != nil {
-		return nil, err
+		return ""
 	}
+	return parseLaunchctlFailureReason(string(output))
+}
 
-	for _, svc := range services {
-		if svc.Name == name {
-			return &svc, nil
+// parseLaunchctlFailureReason extracts the reason from the "last exit code"
+// line of `launchctl print <target>` output ("last exit code = 78:
+// EX_CONFIG"), preferring the "last terminating signal" line when the job
+// was killed by a signal.
+func parseLaunchctlFailureReason(output string) string {
+	var exitReason, signal string
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), " = ")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "last exit code":
+			if _, reason, ok := strings.Cut(value, ": "); ok {
+				exitReason = strings.TrimSpace(reason)
+			}
+		case "last terminating signal":
+			signal = strings.TrimSpace(value)
 		}
 	}
+	if signal != "" {
+		return signal
+	}
+	return exitReason
+}
+
+// launchdEnableUnknown is the EnableState of a loaded job without a plist
+// in the known directories
+const launchdEnableUnknown = "unknown"
+
+// domainJobType is the type of a job known only from the domain listing:
+// an agent in the user's domain, a daemon in the system domain
+func (p *LaunchdProvider) domainJobType(scope models.Scope) string {
+	if scope == models.ScopeUser {
+		return models.TypeAgent
+	}
+	return models.TypeDaemon
+}
 
-	return nil, fmt.Errorf("service not found: %s", name)
+// launchdJobInfo is what listing reads from each plist
+type launchdJobInfo struct {
+	jobType     string
+	description string
 }
 
-func (p *LaunchdProvider) Start(name string, scope models.Scope) error {
-	logger.Debug("starting service", "name", name, "scope", scope)
+// jobInfo reads a plist, classifies the job it defines and picks up its
+// description. Binary plists get no description, since decoding them
+// means running plutil for every job in the listing.
+func (p *LaunchdProvider) jobInfo(ctx context.Context, plistPath string) launchdJobInfo {
+	data, _ := p.hostFiles().ReadFile(ctx, plistPath)
+	info := launchdJobInfo{jobType: launchdJobType(plistPath, data)}
+	if !bytes.HasPrefix(data, []byte("bplist")) {
+		if plist, err := parseLaunchdPlist(data); err == nil {
+			info.description = plist.Description
+		}
+	}
+	return info
+}
 
-	plistPath := p.findPlistForLabel(name, scope)
-	if plistPath == "" {
-		logger.Error("plist not found", "name", name, "scope", scope)
-		return fmt.Errorf("plist not found for service: %s", name)
+// launchdDisplayName shortens a reverse-DNS label to its last component,
+// e.g. com.example.backup to backup. Labels without a dot, or whose last
+// component is a number (as in application.com.example.app.1234.5678),
+// are returned unchanged.
+func launchdDisplayName(label string) string {
+	i := strings.LastIndex(label, ".")
+	if i < 0 || i == len(label)-1 {
+		return label
+	}
+	last := label[i+1:]
+	if _, err := strconv.Atoi(last); err == nil {
+		return label
+	}
+	return last
+}
+
+// launchdJobType classifies a job by its plist's content: timer if it has a
+// schedule, otherwise agent or daemon by the directory it lives in. The key
+// names appear verbatim in both XML and binary plists, so the raw bytes are
+// searched rather than converting every plist with plutil.
+func launchdJobType(plistPath string, data []byte) string {
+	if bytes.Contains(data, []byte("StartCalendarInterval")) || bytes.Contains(data, []byte("StartInterval")) {
+		return models.TypeTimer
 	}
+	switch filepath.Base(filepath.Dir(plistPath)) {
+	case "LaunchAgents":
+		return models.TypeAgent
+	case "LaunchDaemons":
+		return models.TypeDaemon
+	default:
+		return models.TypeService
+	}
+}
 
+// ServiceStates reads the domain listing once and looks up each label in it.
+// A label that is neither loaded nor has a plist is marked NotFound.
+func (p *LaunchdProvider) ServiceStates(ctx context.Context, names []string, scope models.Scope) (map[string]models.ServiceState, error) {
 	var domainTarget string
-	if scope == models.ScopeUser {
+	switch scope {
+	case models.ScopeUser:
 		domainTarget = fmt.Sprintf("gui/%s", p.uid)
-	} else {
+	case models.ScopeSystem:
 		domainTarget = "system"
+	default:
+		return nil, fmt.Errorf("invalid scope: %s", scope)
+	}
+
+	states := make(map[string]models.ServiceState, len(names))
+	if len(names) == 0 {
+		return states, nil
+	}
+
+	entries, err := p.listDomainServices(ctx, domainTarget)
+	if err != nil {
+		return nil, err
+	}
+	entryByLabel := make(map[string]launchdEntry, len(entries))
+	for _, entry := range entries {
+		entryByLabel[entry.label] = entry
+	}
+	disabledByLabel := p.listDisabledServices(ctx, domainTarget)
+
+	for _, name := range names {
+		entry, loaded := entryByLabel[name]
+		hasPlist := p.findPlistForLabel(ctx, name, scope) != ""
+		if !loaded && !hasPlist {
+			states[name] = models.ServiceState{Status: models.StatusUnknown, NotFound: true}
+			continue
+		}
+
+		status, _, _ := launchdState(entry, loaded)
+		enabled, enableState := hasPlist, ""
+		if !hasPlist {
+			enableState = launchdEnableUnknown
+		}
+		if disabled, ok := disabledByLabel[name]; ok {
+			enabled, enableState = !disabled, ""
+		}
+		states[name] = models.ServiceState{Status: status, Enabled: enabled, EnableState: enableState, PID: entry.pid}
+	}
+	return states, nil
+}
+
+// GetService reads one job with `launchctl print <domain>/<label>` rather
+// than listing the whole domain. A job that isn't loaded is still found if
+// it has a plist, and a loaded job is found without one.
+func (p *LaunchdProvider) GetService(ctx context.Context, name string, scope models.Scope) (*models.Service, error) {
+	var domainTarget string
+	switch scope {
+	case models.ScopeUser:
+		domainTarget = fmt.Sprintf("gui/%s", p.uid)
+	case models.ScopeSystem:
+		domainTarget = "system"
+	default:
+		return nil, fmt.Errorf("invalid scope: %s", scope)
+	}
+
+	plistPath := p.findPlistForLabel(ctx, name, scope)
+	output, err := p.run(ctx, OpStatus, "launchctl", "print", p.serviceTarget(name, scope))
+	loaded := err == nil
+	if plistPath == "" && !loaded {
+		return nil, fmt.Errorf("service %w: %s", ErrNotFound, name)
+	}
+	entry := launchdEntry{label: name}
+	if loaded {
+		entry = parseLaunchctlPrintEntry(name, string(output))
+	}
+	status, lastExitClean, neverRan := launchdState(entry, loaded)
+
+	enabled, enableState := true, ""
+	if plistPath == "" {
+		enabled, enableState = false, launchdEnableUnknown
+	}
+	if disabled, ok := p.listDisabledServices(ctx, domainTarget)[name]; ok {
+		enabled, enableState = !disabled, ""
+	}
+
+	svc := &models.Service{
+		Name:          name,
+		DisplayName:   launchdDisplayName(name),
+		Status:        status,
+		Enabled:       enabled,
+		EnableState:   enableState,
+		Scope:         scope,
+		LastExitClean: lastExitClean,
+		NeverRan:      neverRan,
+		Type:          p.domainJobType(scope),
+		RunAs:         p.runAs(ctx, name, scope),
+	}
+	if plistPath != "" {
+		svc.Type = p.jobInfo(ctx, plistPath).jobType
+		if plist, err := p.readPlist(ctx, plistPath); err == nil {
+			svc.Description = plist.Description
+		}
+	}
+	if loaded && entry.exited && entry.lastExit != 0 {
+		svc.ExitCode = entry.lastExit
+		svc.FailureReason = parseLaunchctlFailureReason(string(output))
+	}
+	return svc, nil
+}
+
+// parseLaunchctlPrintEntry builds a launchdEntry from `launchctl print
+// <domain>/<label>` output, reading the "pid" and "last exit code" lines
+func parseLaunchctlPrintEntry(label, output string) launchdEntry {
+	entry := launchdEntry{label: label, pid: parseLaunchctlPrintPID(output)}
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), " = ")
+		if !ok || key != "last exit code" {
+			continue
+		}
+		code, _, _ := strings.Cut(value, ":")
+		if n, err := strconv.Atoi(strings.TrimSpace(code)); err == nil {
+			entry.exited = true
+			entry.lastExit = n
+		}
 	}
-	serviceTarget := fmt.Sprintf("%s/%s", domainTarget, name)
+	return entry
+}
+
+// runAs returns the account a job runs as: the plist's UserName, else the
+// GUI user for agents (which run in the user's session) and root for
+// daemons
+func (p *LaunchdProvider) runAs(ctx context.Context, name string, scope models.Scope) string {
+	if plistPath := p.findPlistForLabel(ctx, name, scope); plistPath != "" {
+		if plist, err := p.readPlist(ctx, plistPath); err == nil && plist.UserName != "" {
+			return plist.UserName
+		}
+	}
+	if scope == models.ScopeSystem {
+		return "root"
+	}
+	if u, err := user.LookupId(p.uid); err == nil {
+		return u.Username
+	}
+	return p.uid
+}
+
+func (p *LaunchdProvider) Start(ctx context.Context, name string, scope models.Scope) error {
+	logger.Debug("starting service", "name", name, "scope", scope)
+
+	plistPath := p.findPlistForLabel(ctx, name, scope)
+	if plistPath == "" {
+		logger.Error("plist not found", "name", name, "scope", scope)
+		return fmt.Errorf("plist %w for service: %s", ErrNotFound, name)
+	}
+
+	domainTarget := p.domainTarget(scope)
+	serviceTarget := p.serviceTarget(name, scope)
 
 	// Try modern bootstrap first (macOS 10.10+)
 	// bootstrap loads the service into the domain
 	logger.Debug("attempting bootstrap", "domain", domainTarget, "plist", plistPath)
-	cmd := exec.Command("launchctl", "bootstrap", domainTarget, plistPath)
-	bootstrapErr := cmd.Run()
+	_, bootstrapErr := p.run(ctx, OpAction, "launchctl", "bootstrap", domainTarget, plistPath)
 	if bootstrapErr != nil {
 		logger.Debug("bootstrap failed (may already be loaded)", "error", bootstrapErr)
 	}
@@ -308,20 +680,17 @@ func (p *LaunchdProvider) Start(name string, scope models.Scope) error {
 	// If bootstrap succeeded or service already loaded, try to kickstart it
 	// kickstart -k will kill any existing instance and restart
 	logger.Debug("attempting kickstart", "target", serviceTarget)
-	cmd = exec.Command("launchctl", "kickstart", "-k", serviceTarget)
-	if err := cmd.Run(); err != nil {
+	if _, err := p.run(ctx, OpAction, "launchctl", "kickstart", "-k", serviceTarget); err != nil {
 		logger.Debug("kickstart failed", "error", err)
 		// If kickstart fails and bootstrap also failed, try legacy load
 		if bootstrapErr != nil {
 			logger.Debug("attempting legacy load", "plist", plistPath)
-			cmd = exec.Command("launchctl", "load", plistPath)
-			if err := cmd.Run(); err != nil {
+			if output, err := p.run(ctx, OpAction, "launchctl", "load", plistPath); err != nil {
 				logger.Error("all start methods failed", "name", name, "error", err)
-				return fmt.Errorf("failed to start service: %w", err)
+				return newCommandError(err, "failed to start service: "+strings.TrimSpace(commandOutput(output, err)))
 			}
 			// After legacy load, try kickstart again
-			cmd = exec.Command("launchctl", "kickstart", serviceTarget)
-			cmd.Run() // Ignore error, load may have started it
+			p.run(ctx, OpAction, "launchctl", "kickstart", serviceTarget) // Ignore error, load may have started it
 		}
 	}
 
@@ -329,39 +698,44 @@ func (p *LaunchdProvider) Start(name string, scope models.Scope) error {
 	return nil
 }
 
-func (p *LaunchdProvider) Stop(name string, scope models.Scope) error {
+func (p *LaunchdProvider) Stop(ctx context.Context, name string, scope models.Scope) error {
 	logger.Debug("stopping service", "name", name, "scope", scope)
 
-	var domainTarget string
-	if scope == models.ScopeUser {
-		domainTarget = fmt.Sprintf("gui/%s", p.uid)
-	} else {
-		domainTarget = "system"
-	}
-	serviceTarget := fmt.Sprintf("%s/%s", domainTarget, name)
+	serviceTarget := p.serviceTarget(name, scope)
 
 	// Try modern bootout first (opposite of bootstrap)
-	plistPath := p.findPlistForLabel(name, scope)
+	plistPath := p.findPlistForLabel(ctx, name, scope)
 	if plistPath != "" {
 		logger.Debug("attempting bootout", "target", serviceTarget)
-		cmd := exec.Command("launchctl", "bootout", serviceTarget)
-		if err := cmd.Run(); err == nil {
+		if _, err := p.run(ctx, OpAction, "launchctl", "bootout", serviceTarget); err == nil {
 			logger.Debug("service stopped via bootout", "name", name)
 			return nil
 		}
 		logger.Debug("bootout failed, trying alternatives")
 	}
 
-	// Fallback: try kill
-	logger.Debug("attempting kill", "target", serviceTarget)
-	cmd := exec.Command("launchctl", "kill", "SIGTERM", serviceTarget)
-	if err := cmd.Run(); err != nil {
+	// Transient jobs are kept alive, so signalling would only restart them;
+	// removing the job stops it for good
+	if plistPath == "" && strings.HasPrefix(name, transientPrefix) {
+		args, err := p.callerDomainArgs(scope, []string{"remove", name})
+		if err != nil {
+			return err
+		}
+		if output, err := p.run(ctx, OpAction, "launchctl", args...); err != nil {
+			return newCommandError(err, "launchctl remove failed: "+strings.TrimSpace(commandOutput(output, err)))
+		}
+		logger.Debug("transient job removed", "name", name)
+		return nil
+	}
+
+	// Fallback: signal the process
+	if err := p.StopWithSignal(ctx, name, scope, p.stopSignal, p.stopTimeout); err != nil {
 		logger.Debug("kill failed", "error", err)
 		// Final fallback: legacy unload
 		if plistPath != "" {
 			logger.Debug("attempting legacy unload", "plist", plistPath)
-			cmd = exec.Command("launchctl", "unload", plistPath)
-			return cmd.Run()
+			_, err := p.run(ctx, OpAction, "launchctl", "unload", plistPath)
+			return err
 		}
 		logger.Error("all stop methods failed", "name", name, "error", err)
 		return fmt.Errorf("failed to stop service: %w", err)
@@ -370,86 +744,266 @@ func (p *LaunchdProvider) Stop(name string, scope models.Scope) error {
 	return nil
 }
 
-func (p *LaunchdProvider) Restart(name string, scope models.Scope) error {
-	if err := p.Stop(name, scope); err != nil {
-		// Ignore stop errors, service might not be running
+// StopWithSignal sends signal to a service via `launchctl kill`. If
+// killTimeout is positive and the process is still running once it elapses,
+// the service is sent SIGKILL.
+func (p *LaunchdProvider) StopWithSignal(ctx context.Context, name string, scope models.Scope, signal string, killTimeout time.Duration) error {
+	signal, err := normalizeSignal(signal)
+	if err != nil {
+		return err
 	}
-	return p.Start(name, scope)
-}
 
-func (p *LaunchdProvider) Enable(name string, scope models.Scope) error {
-	plistPath := p.findPlistForLabel(name, scope)
-	if plistPath == "" {
-		return fmt.Errorf("plist not found for service: %s", name)
+	serviceTarget := p.serviceTarget(name, scope)
+
+	logger.Debug("attempting kill", "target", serviceTarget, "signal", signal)
+	if _, err := p.run(ctx, OpAction, "launchctl", "kill", signal, serviceTarget); err != nil {
+		return fmt.Errorf("launchctl kill %s failed: %w", signal, err)
+	}
+
+	if killTimeout <= 0 || signal == "SIGKILL" {
+		return nil
+	}
+
+	deadline := time.Now().Add(killTimeout)
+	for time.Now().Before(deadline) {
+		if running, err := p.running(ctx, serviceTarget); err != nil || !running {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(p.pollInterval):
+		}
+	}
+	if running, err := p.running(ctx, serviceTarget); err != nil || !running {
+		return err
 	}
 
-	cmd := exec.Command("launchctl", "load", "-w", plistPath)
-	return cmd.Run()
+	logger.Warn("service did not exit after stop signal, sending SIGKILL", "target", serviceTarget, "signal", signal, "timeout", killTimeout)
+	if _, err := p.run(ctx, OpAction, "launchctl", "kill", "SIGKILL", serviceTarget); err != nil {
+		return fmt.Errorf("launchctl kill SIGKILL failed: %w", err)
+	}
+	return nil
 }
 
-func (p *LaunchdProvider) Disable(name string, scope models.Scope) error {
-	plistPath := p.findPlistForLabel(name, scope)
-	if plistPath == "" {
-		return fmt.Errorf("plist not found for service: %s", name)
+// serviceTarget returns the launchctl service target (<domain>/<label>)
+func (p *LaunchdProvider) serviceTarget(name string, scope models.Scope) string {
+	return p.domainTarget(scope) + "/" + name
+}
+
+// domainTarget returns the launchctl domain for scope: gui/<uid> or system
+func (p *LaunchdProvider) domainTarget(scope models.Scope) string {
+	if scope == models.ScopeUser {
+		return "gui/" + p.uid
 	}
+	return "system"
+}
 
-	cmd := exec.Command("launchctl", "unload", "-w", plistPath)
-	return cmd.Run()
+// processPID returns the PID of a loaded service, or 0 if it isn't running
+func (p *LaunchdProvider) processPID(ctx context.Context, serviceTarget string) int {
+	output, err := p.run(ctx, OpStatus, "launchctl", "print", serviceTarget)
+	if err != nil {
+		return 0
+	}
+	return parseLaunchctlPrintPID(string(output))
 }
 
-// getProcessNameForService extracts the program/process name from a plist file
-// Returns the basename of the executable, or falls back to the last component of the service label
-func (p *LaunchdProvider) getProcessNameForService(name string, scope models.Scope) string {
-	plistPath := p.findPlistForLabel(name, scope)
-	if plistPath == "" {
-		// Fallback: use last component of service label
-		parts := strings.Split(name, ".")
-		return parts[len(parts)-1]
+// running reports whether a loaded service has a process. launchctl print
+// fails once ctx has ended, which would look like the job exiting, so
+// ctx's error is returned instead.
+func (p *LaunchdProvider) running(ctx context.Context, serviceTarget string) (bool, error) {
+	pid := p.processPID(ctx, serviceTarget)
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return pid != 0, nil
+}
+
+// Processes returns the job's main process and all of its descendants
+func (p *LaunchdProvider) Processes(ctx context.Context, name string, scope models.Scope) ([]models.Process, error) {
+	pid := p.processPID(ctx, p.serviceTarget(name, scope))
+	if pid == 0 {
+		return []models.Process{}, nil
 	}
 
-	// Try to read the plist and extract Program or ProgramArguments
-	// Use plutil to convert to xml and parse
-	cmd := exec.Command("plutil", "-convert", "xml1", "-o", "-", plistPath)
-	output, err := cmd.Output()
+	output, err := p.run(ctx, OpStatus, "ps", "-ax", "-o", "pid=,ppid=,command=")
 	if err != nil {
-		parts := strings.Split(name, ".")
-		return parts[len(parts)-1]
+		return nil, newCommandError(err, "ps failed: "+strings.TrimSpace(commandOutput(output, err)))
 	}
+	return processTree(string(output), pid), nil
+}
 
-	content := string(output)
+// processTree parses `ps -o pid=,ppid=,command=` output and returns root
+// followed by its descendants.
+func processTree(output string, root int) []models.Process {
+	type psEntry struct {
+		ppid    int
+		command string
+	}
+	entries := make(map[int]psEntry)
+	var order []int
 
-	// Look for <key>Program</key> or <key>ProgramArguments</key>
-	// Simple string parsing to find the program path
-	var programPath string
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 {
+			continue
+		}
+		pid, err1 := strconv.Atoi(fields[0])
+		ppid, err2 := strconv.Atoi(fields[1])
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		entries[pid] = psEntry{ppid: ppid, command: strings.Join(fields[2:], " ")}
+		order = append(order, pid)
+	}
 
-	// Check for Program key first
-	if idx := strings.Index(content, "<key>Program</key>"); idx != -1 {
-		// Find the next <string> element
-		rest := content[idx:]
-		if start := strings.Index(rest, "<string>"); start != -1 {
-			rest = rest[start+8:]
-			if end := strings.Index(rest, "</string>"); end != -1 {
-				programPath = rest[:end]
+	rootEntry, ok := entries[root]
+	if !ok {
+		return []models.Process{}
+	}
+	processes := []models.Process{{PID: root, Command: rootEntry.command}}
+	inTree := map[int]bool{root: true}
+
+	// A child may be listed before its parent, so sweep until nothing is added
+	for changed := true; changed; {
+		changed = false
+		for _, pid := range order {
+			entry := entries[pid]
+			if inTree[pid] || !inTree[entry.ppid] {
+				continue
 			}
+			inTree[pid] = true
+			processes = append(processes, models.Process{PID: pid, Command: entry.command})
+			changed = true
 		}
 	}
+	return processes
+}
 
-	// If no Program, try ProgramArguments (first element is the executable)
-	if programPath == "" {
-		if idx := strings.Index(content, "<key>ProgramArguments</key>"); idx != -1 {
-			rest := content[idx:]
-			if start := strings.Index(rest, "<string>"); start != -1 {
-				rest = rest[start+8:]
-				if end := strings.Index(rest, "</string>"); end != -1 {
-					programPath = rest[:end]
-				}
-			}
+// parseLaunchctlPrintPID extracts the "pid = N" line from `launchctl print
+// <service-target>` output. It returns 0 if no pid is present.
+func parseLaunchctlPrintPID(output string) int {
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), " = ")
+		if !ok || key != "pid" {
+			continue
+		}
+		if pid, err := strconv.Atoi(value); err == nil {
+			return pid
 		}
 	}
+	return 0
+}
+
+func (p *LaunchdProvider) Restart(ctx context.Context, name string, scope models.Scope) error {
+	if err := p.Stop(ctx, name, scope); err != nil {
+		// Ignore stop errors, service might not be running
+	}
+	return p.Start(ctx, name, scope)
+}
+
+// Enable clears the job's disabled override with `launchctl enable` (the
+// state print-disabled reports) and bootstraps it, as `load -w` did. macOS
+// releases without the enable subcommand fall back to `load -w`.
+func (p *LaunchdProvider) Enable(ctx context.Context, name string, scope models.Scope) error {
+	plistPath := p.findPlistForLabel(ctx, name, scope)
+	if plistPath == "" {
+		return fmt.Errorf("plist %w for service: %s", ErrNotFound, name)
+	}
+
+	serviceTarget := p.serviceTarget(name, scope)
+	output, err := p.run(ctx, OpAction, "launchctl", "enable", serviceTarget)
+	if err != nil && unknownSubcommand(commandOutput(output, err)) {
+		logger.Debug("launchctl enable unavailable, using load -w", "plist", plistPath)
+		output, err = p.run(ctx, OpAction, "launchctl", "load", "-w", plistPath)
+		if err != nil {
+			return newCommandError(err, "launchctl load -w failed: "+strings.TrimSpace(commandOutput(output, err)))
+		}
+		return nil
+	}
+	if err != nil {
+		return newCommandError(err, "launchctl enable failed: "+strings.TrimSpace(commandOutput(output, err)))
+	}
+
+	if _, err := p.run(ctx, OpAction, "launchctl", "bootstrap", p.domainTarget(scope), plistPath); err != nil {
+		logger.Debug("bootstrap after enable failed (may already be loaded)", "target", serviceTarget, "error", err)
+	}
+	return nil
+}
+
+// Disable sets the job's disabled override with `launchctl disable` and
+// boots it out, as `unload -w` did. macOS releases without the disable
+// subcommand fall back to `unload -w`.
+func (p *LaunchdProvider) Disable(ctx context.Context, name string, scope models.Scope) error {
+	plistPath := p.findPlistForLabel(ctx, name, scope)
+	if plistPath == "" {
+		return fmt.Errorf("plist %w for service: %s", ErrNotFound, name)
+	}
+
+	serviceTarget := p.serviceTarget(name, scope)
+	output, err := p.run(ctx, OpAction, "launchctl", "disable", serviceTarget)
+	if err != nil && unknownSubcommand(commandOutput(output, err)) {
+		logger.Debug("launchctl disable unavailable, using unload -w", "plist", plistPath)
+		output, err = p.run(ctx, OpAction, "launchctl", "unload", "-w", plistPath)
+		if err != nil {
+			return newCommandError(err, "launchctl unload -w failed: "+strings.TrimSpace(commandOutput(output, err)))
+		}
+		return nil
+	}
+	if err != nil {
+		return newCommandError(err, "launchctl disable failed: "+strings.TrimSpace(commandOutput(output, err)))
+	}
+
+	if _, err := p.run(ctx, OpAction, "launchctl", "bootout", serviceTarget); err != nil {
+		logger.Debug("bootout after disable failed (may not be loaded)", "target", serviceTarget, "error", err)
+	}
+	return nil
+}
+
+// unknownSubcommand reports whether launchctl rejected its subcommand, as
+// macOS before 10.10 does for enable, disable, bootstrap and bootout
+func unknownSubcommand(output string) bool {
+	output = strings.ToLower(output)
+	return strings.Contains(output, "unrecognized subcommand") || strings.Contains(output, "unknown subcommand")
+}
+
+// ResetFailed is not supported: launchd keeps no failed state to clear
+func (p *LaunchdProvider) ResetFailed(ctx context.Context, name string, scope models.Scope) error {
+	return fmt.Errorf("reset-failed: %w", ErrNotSupported)
+}
+
+// readPlist loads and decodes the plist at path. plutil normalizes binary
+// and XML plists to XML before decoding; if it is missing or fails, an XML
+// plist is read and decoded directly.
+func (p *LaunchdProvider) readPlist(ctx context.Context, path string) (*launchdPlist, error) {
+	output, err := p.run(ctx, OpStatus, "plutil", "-convert", "xml1", "-o", "-", path)
+	if err == nil {
+		return parseLaunchdPlist(output)
+	}
+	logger.Debug("plutil failed, reading plist directly", "path", path, "error", err)
+
+	data, readErr := p.hostFiles().ReadFile(ctx, path)
+	if readErr != nil {
+		return nil, readErr
+	}
+	if bytes.HasPrefix(data, []byte("bplist")) {
+		return nil, fmt.Errorf("binary plist %s requires plutil: %w", path, err)
+	}
+	return parseLaunchdPlist(data)
+}
 
-	if programPath != "" {
-		// Return just the basename
-		return filepath.Base(programPath)
+// getProcessNameForService extracts the program/process name from a plist file
+// Returns the basename of the executable, or falls back to the last component of the service label
+func (p *LaunchdProvider) getProcessNameForService(ctx context.Context, name string, scope models.Scope) string {
+	if plistPath := p.findPlistForLabel(ctx, name, scope); plistPath != "" {
+		plist, err := p.readPlist(ctx, plistPath)
+		if err != nil {
+			logger.Debug("failed to read plist", "path", plistPath, "error", err)
+		} else if program := plist.executable(); program != "" {
+			return filepath.Base(program)
+		}
 	}
 
 	// Fallback: use last component of service label
@@ -457,55 +1011,240 @@ func (p *LaunchdProvider) getProcessNameForService(name string, scope models.Sco
 	return parts[len(parts)-1]
 }
 
-func (p *LaunchdProvider) StreamLogs(ctx context.Context, name string, scope models.Scope) (<-chan string, error) {
-	ch := make(chan string, 100)
-
+// logPredicate builds a unified log predicate matching a job's entries
+func (p *LaunchdProvider) logPredicate(ctx context.Context, name string, scope models.Scope) string {
 	// Get the program name from the plist to use in log filtering
-	processName := p.getProcessNameForService(name, scope)
+	processName := p.getProcessNameForService(ctx, name, scope)
 
-	// Use log stream with predicate to filter by process name
 	// We use CONTAINS for more flexible matching since process names may vary
-	predicate := fmt.Sprintf("process == '%s' OR process CONTAINS '%s' OR subsystem CONTAINS '%s'",
+	return fmt.Sprintf("process == '%s' OR process CONTAINS '%s' OR subsystem CONTAINS '%s'",
 		processName, processName, name)
-	cmd := exec.CommandContext(ctx, "log", "stream",
-		"--predicate", predicate,
-		"--style", "compact")
+}
+
+// LogCounts counts error- and fault-level unified log entries for a job.
+// The unified log has no warning level, so Warnings is always zero.
+func (p *LaunchdProvider) LogCounts(ctx context.Context, name string, scope models.Scope, since time.Time) (models.LogCounts, error) {
+	if p.noLogTool {
+		return models.LogCounts{}, logsUnavailable("log")
+	}
+	last := "boot"
+	if !since.IsZero() {
+		last = fmt.Sprintf("%ds", int(time.Since(since).Seconds()))
+	}
 
-	stdout, err := cmd.StdoutPipe()
+	output, err := p.run(ctx, OpList, "log", "show", "--style", "ndjson", "--last", last,
+		"--predicate", "("+p.logPredicate(ctx, name, scope)+") AND messageType >= error")
 	if err != nil {
-		return nil, fmt.Errorf("failed to create stdout pipe: %w", err)
+		return models.LogCounts{}, newCommandError(err, "log show failed: "+strings.TrimSpace(commandOutput(output, err)))
 	}
+	return countUnifiedLogErrors(string(output)), nil
+}
 
-	if err := cmd.Start(); err != nil {
-		return nil, fmt.Errorf("failed to start log stream: %w", err)
+// countUnifiedLogErrors tallies `log show --style ndjson` entries whose
+// messageType is Error or Fault
+func countUnifiedLogErrors(output string) models.LogCounts {
+	var counts models.LogCounts
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry struct {
+			MessageType string `json:"messageType"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if entry.MessageType == "Error" || entry.MessageType == "Fault" {
+			counts.Errors++
+		}
 	}
+	return counts
+}
 
-	go func() {
-		defer close(ch)
-		defer cmd.Wait()
+// StreamLogs follows a job's unified log. log stream can't filter by a
+// minimum level the way journalctl -p does, so opts.Level is applied to the
+// compact output as it is read.
+func (p *LaunchdProvider) StreamLogs(ctx context.Context, name string, scope models.Scope, opts models.LogStreamOptions) (<-chan string, error) {
+	if p.noLogTool {
+		return nil, logsUnavailable("log")
+	}
+	ch := make(chan string, 100)
 
-		scanner := bufio.NewScanner(stdout)
-		for scanner.Scan() {
-			select {
-			case <-ctx.Done():
-				return
-			case ch <- scanner.Text():
+	// Use log stream with predicate to filter by process name
+	args := []string{"stream", "--predicate", p.logPredicate(ctx, name, scope), "--style", "compact"}
+	history := p.logHistory(ctx, name, scope, "compact", opts.History)
+	err := p.followLog(ctx, args, history, func() { close(ch) }, func(line string) bool {
+		if opts.Level != "" {
+			level, ok := compactLogLevel(line)
+			if !ok || !models.LogLevelAtLeast(level, opts.Level) {
+				return true
 			}
 		}
-	}()
+		select {
+		case <-ctx.Done():
+			return false
+		case ch <- line:
+			return true
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
 
+// StreamLogEntries follows a job's unified log and parses each message.
+// ndjson prints one JSON object per line, unlike the json style which wraps
+// the whole stream in an array.
+func (p *LaunchdProvider) StreamLogEntries(ctx context.Context, name string, scope models.Scope, opts models.LogStreamOptions) (<-chan models.LogEntry, error) {
+	if p.noLogTool {
+		return nil, logsUnavailable("log")
+	}
+	ch := make(chan models.LogEntry, 100)
+
+	args := []string{"stream", "--predicate", p.logPredicate(ctx, name, scope), "--style", "ndjson"}
+	history := p.logHistory(ctx, name, scope, "ndjson", opts.History)
+	err := p.followLog(ctx, args, history, func() { close(ch) }, func(line string) bool {
+		entry, ok := parseUnifiedLogEntry(line)
+		if !ok || !models.LogLevelAtLeast(entry.Level, opts.Level) {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case ch <- entry:
+			return true
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
 	return ch, nil
 }
 
+// followLog starts `log` with args and follows its output with
+// followCommand
+func (p *LaunchdProvider) followLog(ctx context.Context, args []string, history *logHistory, done func(), emit func(line string) bool) error {
+	if p.follow != nil {
+		return p.follow(ctx, history, done, emit, "log", args...)
+	}
+	var cmd *exec.Cmd
+	if p.logCommand != nil {
+		cmd = p.logCommand(ctx, args...)
+	} else {
+		cmd = exec.CommandContext(ctx, "log", args...)
+	}
+	return followCommand(cmd, history, done, emit)
+}
+
+// compactLogLevel reads the level from a line of `log stream --style
+// compact`, e.g. "2025-03-01 04:00:00.250 E  demo[123:456] message". The
+// header, banner and continuation lines of multi-line messages have none.
+func compactLogLevel(line string) (string, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 {
+		return "", false
+	}
+	if _, err := time.Parse("2006-01-02", fields[0]); err != nil {
+		return "", false
+	}
+	switch fields[2] {
+	case "F":
+		return "critical", true
+	case "E":
+		return "error", true
+	case "Db":
+		return "debug", true
+	case "I", "Df":
+		return "info", true
+	}
+	return "", false
+}
+
+// unifiedLogTime is the timestamp layout of `log stream --style ndjson`
+const unifiedLogTime = "2006-01-02 15:04:05.000000-0700"
+
+// parseUnifiedLogEntry parses one line of `log stream --style ndjson`. The
+// stream opens with a "Filtering the log data" banner, which is skipped.
+func parseUnifiedLogEntry(line string) (models.LogEntry, bool) {
+	var fields struct {
+		Timestamp    string `json:"timestamp"`
+		MessageType  string `json:"messageType"`
+		EventMessage string `json:"eventMessage"`
+	}
+	if err := json.Unmarshal([]byte(line), &fields); err != nil {
+		return models.LogEntry{}, false
+	}
+
+	entry := models.LogEntry{Message: fields.EventMessage, Raw: line}
+	if t, err := time.Parse(unifiedLogTime, fields.Timestamp); err == nil {
+		entry.Time = t.UTC()
+	}
+	switch fields.MessageType {
+	case "Fault":
+		entry.Level = "critical"
+	case "Error":
+		entry.Level = "error"
+	case "Debug":
+		entry.Level = "debug"
+	default:
+		entry.Level = "info"
+	}
+	return entry, true
+}
+
+// ServiceExists reports whether a plist for the label is in any of the
+// scope's agent or daemon directories
+func (p *LaunchdProvider) ServiceExists(ctx context.Context, name string, scope models.Scope) (bool, error) {
+	return p.findPlistForLabel(ctx, name, scope) != "", nil
+}
+
 // CreateService creates a new launchd service with the given configuration
-func (p *LaunchdProvider) CreateService(config models.ServiceConfig, scope models.Scope) error {
+func (p *LaunchdProvider) CreateService(ctx context.Context, config models.ServiceConfig, scope models.Scope) error {
 	logger.Debug("creating service", "name", config.Name, "program", config.Program, "scope", scope)
 
-	if config.Name == "" {
-		return fmt.Errorf("service name is required")
+	if err := config.Validate(); err != nil {
+		return err
 	}
-	if config.Program == "" {
-		return fmt.Errorf("program path is required")
+	if err := validateRunAs(config, scope); err != nil {
+		return err
+	}
+	if err := validateRestart(config); err != nil {
+		return err
+	}
+	if len(config.ExecStartPre) > 0 || len(config.ExecStopPost) > 0 {
+		return notSupported("execStartPre and execStopPost are not supported by launchd")
+	}
+	if len(config.After) > 0 || len(config.Requires) > 0 || len(config.Wants) > 0 {
+		return notSupported("after, requires and wants are not supported by launchd, which has no unit dependencies")
+	}
+	if err := validateSchedule(config); err != nil {
+		return err
+	}
+	if config.Schedule != "" {
+		if _, err := parseLaunchdSchedule(config.Schedule); err != nil {
+			return err
+		}
+	}
+	if err := validateEnvironmentFile(config); err != nil {
+		return err
+	}
+
+	// launchd has no EnvironmentFile equivalent, so copy the file's values
+	// into the plist now. Later edits to the file are not picked up.
+	if config.EnvironmentFile != "" {
+		fileEnv, err := readEnvironmentFile(config.EnvironmentFile)
+		if err != nil {
+			return err
+		}
+		env := make(map[string]string, len(fileEnv)+len(config.Environment))
+		for k, v := range fileEnv {
+			env[k] = v
+		}
+		// Inline variables take precedence, as with systemd
+		for k, v := range config.Environment {
+			env[k] = v
+		}
+		config.Environment = env
 	}
 
 	// Determine the target directory
@@ -531,7 +1270,7 @@ func (p *LaunchdProvider) CreateService(config models.ServiceConfig, scope model
 	plistPath := filepath.Join(targetDir, config.Name+".plist")
 	if _, err := os.Stat(plistPath); err == nil {
 		logger.Warn("service already exists", "name", config.Name, "path", plistPath)
-		return fmt.Errorf("service %s already exists", config.Name)
+		return fmt.Errorf("service %s %w", config.Name, ErrAlreadyExists)
 	}
 
 	// Generate the plist content
@@ -544,16 +1283,63 @@ func (p *LaunchdProvider) CreateService(config models.ServiceConfig, scope model
 		return fmt.Errorf("failed to write plist file: %w", err)
 	}
 
+	// Load a scheduled job so launchd starts tracking its schedule; it
+	// first runs when the schedule fires
+	if config.Schedule != "" {
+		domainTarget := "system"
+		if scope == models.ScopeUser {
+			domainTarget = fmt.Sprintf("gui/%s", p.uid)
+		}
+		if _, err := p.run(ctx, OpAction, "launchctl", "bootstrap", domainTarget, plistPath); err != nil {
+			logger.Error("failed to load scheduled service", "name", config.Name, "error", err)
+			return fmt.Errorf("failed to load scheduled service: %w", err)
+		}
+		logger.Debug("scheduled service created", "name", config.Name)
+		return nil
+	}
+
 	// Load the service if RunAtLoad is set
 	if config.RunAtLoad {
 		logger.Debug("starting service after creation", "name", config.Name)
-		return p.Start(config.Name, scope)
+		return p.Start(ctx, config.Name, scope)
 	}
 
 	logger.Debug("service created", "name", config.Name)
 	return nil
 }
 
+// readEnvironmentFile parses a systemd-style environment file: KEY=VALUE
+// lines, with blank lines and lines starting with # or ; ignored, and
+// optional single or double quotes around the value.
+func readEnvironmentFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read environment file: %w", err)
+	}
+	return parseEnvironmentFile(string(data))
+}
+
+func parseEnvironmentFile(content string) (map[string]string, error) {
+	env := make(map[string]string)
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(strings.TrimPrefix(key, "export "))
+		if !ok || key == "" {
+			return nil, fmt.Errorf("environment file line %d: expected KEY=VALUE", i+1)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		env[key] = value
+	}
+	return env, nil
+}
+
 // generatePlist creates the XML plist content for a service configuration
 func (p *LaunchdProvider) generatePlist(config models.ServiceConfig) string {
 	var sb strings.Builder
@@ -567,31 +1353,17 @@ func (p *LaunchdProvider) generatePlist(config models.ServiceConfig) string {
 	sb.WriteString(escapeXML(config.Name))
 	sb.WriteString(`</string>
 `)
-
-	// Program and arguments
-	if len(config.Arguments) > 0 {
-		sb.WriteString(`	<key>ProgramArguments</key>
-	<array>
-		<string>`)
-		sb.WriteString(escapeXML(config.Program))
-		sb.WriteString(`</string>
-`)
-		for _, arg := range config.Arguments {
-			sb.WriteString(`		<string>`)
-			sb.WriteString(escapeXML(arg))
-			sb.WriteString(`</string>
-`)
-		}
-		sb.WriteString(`	</array>
-`)
-	} else {
-		sb.WriteString(`	<key>Program</key>
+	if config.Description != "" {
+		sb.WriteString(`	<key>Comment</key>
 	<string>`)
-		sb.WriteString(escapeXML(config.Program))
+		sb.WriteString(escapeXML(config.Description))
 		sb.WriteString(`</string>
 `)
 	}
 
+	// Program and arguments
+	writePlistProgram(&sb, config.Program, config.Arguments)
+
 	// Working directory
 	if config.WorkingDirectory != "" {
 		sb.WriteString(`	<key>WorkingDirectory</key>
@@ -601,6 +1373,22 @@ func (p *LaunchdProvider) generatePlist(config models.ServiceConfig) string {
 `)
 	}
 
+	// Account to run as (only honored for LaunchDaemons)
+	if config.User != "" {
+		sb.WriteString(`	<key>UserName</key>
+	<string>`)
+		sb.WriteString(escapeXML(config.User))
+		sb.WriteString(`</string>
+`)
+	}
+	if config.Group != "" {
+		sb.WriteString(`	<key>GroupName</key>
+	<string>`)
+		sb.WriteString(escapeXML(config.Group))
+		sb.WriteString(`</string>
+`)
+	}
+
 	// Environment variables
 	if len(config.Environment) > 0 {
 		sb.WriteString(`	<key>EnvironmentVariables</key>
@@ -619,23 +1407,45 @@ func (p *LaunchdProvider) generatePlist(config models.ServiceConfig) string {
 `)
 	}
 
-	// RunAtLoad
-	sb.WriteString(`	<key>RunAtLoad</key>
-	<`)
-	if config.RunAtLoad {
-		sb.WriteString("true")
+	// A schedule (validated by CreateService) replaces RunAtLoad
+	if schedule, err := parseLaunchdSchedule(config.Schedule); config.Schedule != "" && err == nil {
+		if schedule.Interval > 0 {
+			sb.WriteString(fmt.Sprintf("\t<key>StartInterval</key>\n\t<integer>%d</integer>\n", schedule.Interval))
+		} else {
+			writeCalendarInterval(&sb, schedule.Calendar)
+		}
 	} else {
-		sb.WriteString("false")
-	}
-	sb.WriteString(`/>
+		// RunAtLoad
+		sb.WriteString(`	<key>RunAtLoad</key>
+	<`)
+		if config.RunAtLoad {
+			sb.WriteString("true")
+		} else {
+			sb.WriteString("false")
+		}
+		sb.WriteString(`/>
 `)
+	}
 
-	// KeepAlive
-	if config.KeepAlive {
+	// KeepAlive: launchd has no restart delay per se, so RestartSec maps
+	// to ThrottleInterval (the minimum time between launches)
+	policy, _ := restartPolicy(config)
+	switch policy {
+	case "always":
 		sb.WriteString(`	<key>KeepAlive</key>
 	<true/>
+`)
+	case "on-failure":
+		sb.WriteString(`	<key>KeepAlive</key>
+	<dict>
+		<key>SuccessfulExit</key>
+		<false/>
+	</dict>
 `)
 	}
+	if policy != "" && policy != "no" && config.RestartSec > 0 {
+		sb.WriteString(fmt.Sprintf("\t<key>ThrottleInterval</key>\n\t<integer>%d</integer>\n", config.RestartSec))
+	}
 
 	// Standard output path
 	if config.StandardOutPath != "" {
@@ -662,6 +1472,33 @@ func (p *LaunchdProvider) generatePlist(config models.ServiceConfig) string {
 	return sb.String()
 }
 
+// writePlistProgram writes the Program key, or ProgramArguments when there
+// are arguments
+func writePlistProgram(sb *strings.Builder, program string, arguments []string) {
+	if len(arguments) > 0 {
+		sb.WriteString(`	<key>ProgramArguments</key>
+	<array>
+		<string>`)
+		sb.WriteString(escapeXML(program))
+		sb.WriteString(`</string>
+`)
+		for _, arg := range arguments {
+			sb.WriteString(`		<string>`)
+			sb.WriteString(escapeXML(arg))
+			sb.WriteString(`</string>
+`)
+		}
+		sb.WriteString(`	</array>
+`)
+	} else {
+		sb.WriteString(`	<key>Program</key>
+	<string>`)
+		sb.WriteString(escapeXML(program))
+		sb.WriteString(`</string>
+`)
+	}
+}
+
 // escapeXML escapes special characters for XML
 func escapeXML(s string) string {
 	s = strings.ReplaceAll(s, "&", "&amp;")
@@ -673,22 +1510,22 @@ func escapeXML(s string) string {
 }
 
 // DeleteService removes a launchd service
-func (p *LaunchdProvider) DeleteService(name string, scope models.Scope) error {
+func (p *LaunchdProvider) DeleteService(ctx context.Context, name string, scope models.Scope) error {
 	logger.Debug("deleting service", "name", name, "scope", scope)
 
-	plistPath := p.findPlistForLabel(name, scope)
+	plistPath := p.findPlistForLabel(ctx, name, scope)
 	if plistPath == "" {
 		logger.Error("service not found for deletion", "name", name, "scope", scope)
-		return fmt.Errorf("service not found: %s", name)
+		return fmt.Errorf("service %w: %s", ErrNotFound, name)
 	}
 
 	// Stop the service first (ignore errors if not running)
 	logger.Debug("stopping service before deletion", "name", name)
-	_ = p.Stop(name, scope)
+	_ = p.Stop(ctx, name, scope)
 
 	// Disable the service
 	logger.Debug("disabling service before deletion", "name", name)
-	_ = p.Disable(name, scope)
+	_ = p.Disable(ctx, name, scope)
 
 	// Delete the plist file
 	logger.Debug("removing plist file", "path", plistPath)
@@ -700,3 +1537,165 @@ func (p *LaunchdProvider) DeleteService(name string, scope models.Scope) error {
 	logger.Debug("service deleted", "name", name)
 	return nil
 }
+
+// RunTransient submits config's program with `launchctl submit`. Submitted
+// jobs have no plist, are restarted by launchd whenever they exit until
+// stopped, and are gone after a reboot or logout.
+func (p *LaunchdProvider) RunTransient(ctx context.Context, config models.ServiceConfig, scope models.Scope) (string, error) {
+	if config.Program == "" {
+		return "", fmt.Errorf("program path is required")
+	}
+	label := transientName()
+	args, err := launchctlSubmitArgs(label, config)
+	if err != nil {
+		return "", err
+	}
+	args, err = p.callerDomainArgs(scope, args)
+	if err != nil {
+		return "", err
+	}
+
+	logger.Debug("submitting transient job", "label", label, "args", args)
+	if output, err := p.run(ctx, OpAction, "launchctl", args...); err != nil {
+		return "", newCommandError(err, "launchctl submit failed: "+strings.TrimSpace(commandOutput(output, err)))
+	}
+	return label, nil
+}
+
+// submitWrapper runs a submitted job's command and then removes the job.
+// launchd keeps submitted jobs alive, restarting them whenever they exit,
+// so without it a one-off run would repeat until stopped. The label is
+// passed as $0 and the command as the remaining arguments.
+const submitWrapper = `"$@"; status=$?; /bin/launchctl remove "$0"; exit $status`
+
+// launchctlSubmitArgs builds the `launchctl submit` arguments for a transient
+// job. submit only takes a label, a command and output paths, so the
+// environment is set by running the program through env(1), and the
+// command is run through submitWrapper so that the job ends with it.
+func launchctlSubmitArgs(label string, config models.ServiceConfig) ([]string, error) {
+	switch {
+	case config.WorkingDirectory != "":
+		return nil, notSupported("workingDirectory is not supported by launchctl submit")
+	case config.User != "" || config.Group != "":
+		return nil, notSupported("user and group are not supported by launchctl submit")
+	case config.EnvironmentFile != "":
+		return nil, notSupported("environmentFile is not supported by launchctl submit")
+	}
+
+	args := []string{"submit", "-l", label}
+	if config.StandardOutPath != "" {
+		args = append(args, "-o", config.StandardOutPath)
+	}
+	if config.StandardErrorPath != "" {
+		args = append(args, "-e", config.StandardErrorPath)
+	}
+	args = append(args, "--", "/bin/sh", "-c", submitWrapper, label)
+	if len(config.Environment) > 0 {
+		args = append(args, "/usr/bin/env")
+		for _, key := range slices.Sorted(maps.Keys(config.Environment)) {
+			args = append(args, key+"="+config.Environment[key])
+		}
+	}
+	args = append(args, config.Program)
+	return append(args, config.Arguments...), nil
+}
+
+// callerDomainArgs adapts launchctl subcommands that act on the caller's own
+// domain (submit, remove) to scope. When running as root, user-scope
+// commands are run in the console user's session via asuser; system scope
+// needs root.
+func (p *LaunchdProvider) callerDomainArgs(scope models.Scope, args []string) ([]string, error) {
+	root := os.Geteuid() == 0
+	switch {
+	case scope == models.ScopeUser && root && p.uid != "0":
+		return append([]string{"asuser", p.uid, "launchctl"}, args...), nil
+	case scope == models.ScopeSystem && !root:
+		return nil, fmt.Errorf("running a command in the system domain requires root: %w", ErrPermission)
+	}
+	return args, nil
+}
+
+// CreateTimer creates an agent/daemon that launchd runs on a calendar
+// schedule via StartCalendarInterval
+func (p *LaunchdProvider) CreateTimer(ctx context.Context, config models.TimerConfig, scope models.Scope) ([]string, error) {
+	logger.Debug("creating launchd timer", "name", config.Name, "onCalendar", config.OnCalendar, "scope", scope)
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	interval, err := parseCalendarInterval(config.OnCalendar)
+	if err != nil {
+		return nil, err
+	}
+
+	var targetDir string
+	switch scope {
+	case models.ScopeUser:
+		targetDir = filepath.Join(p.userHome, "Library", "LaunchAgents")
+	case models.ScopeSystem:
+		targetDir = "/Library/LaunchDaemons"
+	default:
+		return nil, fmt.Errorf("invalid scope: %s", scope)
+	}
+
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		logger.Error("failed to create directory", "dir", targetDir, "error", err)
+		return nil, fmt.Errorf("failed to create directory %s: %w", targetDir, err)
+	}
+
+	plistPath := filepath.Join(targetDir, config.Name+".plist")
+	if _, err := os.Stat(plistPath); err == nil {
+		logger.Warn("service already exists", "name", config.Name, "path", plistPath)
+		return nil, fmt.Errorf("service %s %w", config.Name, ErrAlreadyExists)
+	}
+
+	logger.Debug("writing plist", "path", plistPath)
+	if err := os.WriteFile(plistPath, []byte(generateTimerPlist(config, interval)), 0644); err != nil {
+		logger.Error("failed to write plist", "path", plistPath, "error", err)
+		return nil, fmt.Errorf("failed to write plist file: %w", err)
+	}
+
+	// Load the job so launchd starts tracking its schedule
+	domainTarget := "system"
+	if scope == models.ScopeUser {
+		domainTarget = fmt.Sprintf("gui/%s", p.uid)
+	}
+	if _, err := p.run(ctx, OpAction, "launchctl", "bootstrap", domainTarget, plistPath); err != nil {
+		logger.Error("failed to load timer", "name", config.Name, "error", err)
+		return nil, fmt.Errorf("failed to load timer: %w", err)
+	}
+
+	logger.Debug("timer created", "name", config.Name)
+	return []string{config.Name}, nil
+}
+
+// generateTimerPlist creates the plist for a job run on a calendar schedule
+func generateTimerPlist(config models.TimerConfig, interval map[string]int) string {
+	var sb strings.Builder
+
+	sb.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>`)
+	sb.WriteString(escapeXML(config.Name))
+	sb.WriteString(`</string>
+`)
+
+	writePlistProgram(&sb, config.Program, config.Arguments)
+
+	writeCalendarInterval(&sb, interval)
+	sb.WriteString(`	<key>RunAtLoad</key>
+	<false/>
+</dict>
+</plist>
+`)
+
+	return sb.String()
+}
+
+// DeleteTimer removes a scheduled job; on launchd it is a single plist
+func (p *LaunchdProvider) DeleteTimer(ctx context.Context, name string, scope models.Scope) error {
+	return p.DeleteService(ctx, name, scope)
+}
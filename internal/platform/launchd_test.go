@@ -0,0 +1,1021 @@
+package platform
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"autorun/internal/models"
+)
+
+func newTestLaunchdProvider(t *testing.T, runner *fakeRunner) *LaunchdProvider {
+	t.Helper()
+	return &LaunchdProvider{
+		userHome:     t.TempDir(),
+		uid:          "501",
+		runner:       runner,
+		stopSignal:   defaultStopSignal,
+		pollInterval: time.Millisecond,
+	}
+}
+
+func TestLaunchdListServices_SortedByLabel(t *testing.T) {
+	p := newTestLaunchdProvider(t, &fakeRunner{})
+	dir := filepath.Join(p.userHome, "Library", "LaunchAgents")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, label := range []string{"test.sorted.zeta", "test.sorted.alpha", "test.sorted.mid"} {
+		if err := os.WriteFile(filepath.Join(dir, label+".plist"), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []string{"test.sorted.alpha", "test.sorted.mid", "test.sorted.zeta"}
+	for i := 0; i < 5; i++ {
+		services, err := p.ListServices(t.Context(), models.ScopeUser)
+		if err != nil {
+			t.Fatalf("ListServices: %v", err)
+		}
+		var got []string
+		for _, svc := range services {
+			// Ignore agents installed on the host running the test
+			if strings.HasPrefix(svc.Name, "test.sorted.") {
+				got = append(got, svc.Name)
+			}
+		}
+		if !slices.Equal(got, want) {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+}
+
+const testDomainPrint = `gui/501 = {
+	type = login
+	handle = 501
+
+	services = {
+		     412      -     test.state.running
+		       0      0     test.state.clean
+		       0     78     test.state.failed
+		       0      -     test.state.loaded
+	}
+
+	unmanaged processes = {
+	}
+}
+`
+
+func TestLaunchdListServices_StoppedStates(t *testing.T) {
+	runner := &fakeRunner{handle: func(name string, args []string) ([]byte, error) {
+		if name == "launchctl" && len(args) == 2 && args[0] == "print" {
+			return []byte(testDomainPrint), nil
+		}
+		return nil, nil
+	}}
+	p := newTestLaunchdProvider(t, runner)
+	dir := filepath.Join(p.userHome, "Library", "LaunchAgents")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	labels := []string{"test.state.running", "test.state.clean", "test.state.failed", "test.state.loaded", "test.state.unloaded"}
+	for _, label := range labels {
+		if err := os.WriteFile(filepath.Join(dir, label+".plist"), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	services, err := p.ListServices(t.Context(), models.ScopeUser)
+	if err != nil {
+		t.Fatalf("ListServices: %v", err)
+	}
+	byName := make(map[string]models.Service)
+	for _, svc := range services {
+		byName[svc.Name] = svc
+	}
+
+	cases := []struct {
+		label         string
+		status        string
+		lastExitClean bool
+		neverRan      bool
+	}{
+		{label: "test.state.running", status: models.StatusRunning},
+		{label: "test.state.clean", status: models.StatusStopped, lastExitClean: true},
+		{label: "test.state.failed", status: models.StatusStopped},
+		{label: "test.state.loaded", status: models.StatusStopped, neverRan: true},
+		{label: "test.state.unloaded", status: models.StatusStopped, neverRan: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.label, func(t *testing.T) {
+			svc, ok := byName[tc.label]
+			if !ok {
+				t.Fatalf("service %s not listed", tc.label)
+			}
+			if svc.Status != tc.status || svc.LastExitClean != tc.lastExitClean || svc.NeverRan != tc.neverRan {
+				t.Fatalf("expected status=%s lastExitClean=%v neverRan=%v, got status=%s lastExitClean=%v neverRan=%v",
+					tc.status, tc.lastExitClean, tc.neverRan, svc.Status, svc.LastExitClean, svc.NeverRan)
+			}
+		})
+	}
+}
+
+func TestLaunchdListServices_LoadedWithoutPlist(t *testing.T) {
+	runner := &fakeRunner{handle: func(name string, args []string) ([]byte, error) {
+		if name == "launchctl" && len(args) == 2 && args[0] == "print" {
+			return []byte(testDomainPrint), nil
+		}
+		return nil, nil
+	}}
+	p := newTestLaunchdProvider(t, runner)
+	dir := filepath.Join(p.userHome, "Library", "LaunchAgents")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "test.state.clean.plist"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	services, err := p.ListServices(t.Context(), models.ScopeUser)
+	if err != nil {
+		t.Fatalf("ListServices: %v", err)
+	}
+	byName := make(map[string]models.Service)
+	var names []string
+	for _, svc := range services {
+		byName[svc.Name] = svc
+		names = append(names, svc.Name)
+	}
+	if !slices.IsSorted(names) {
+		t.Fatalf("expected services sorted by label, got %v", names)
+	}
+
+	running, ok := byName["test.state.running"]
+	if !ok {
+		t.Fatalf("expected a loaded job without a plist to be listed, got %v", names)
+	}
+	if running.Status != models.StatusRunning || running.Enabled || running.EnableState != "unknown" || running.Type != models.TypeAgent {
+		t.Fatalf("unexpected service: %+v", running)
+	}
+	if clean := byName["test.state.clean"]; !clean.Enabled || clean.EnableState != "" {
+		t.Fatalf("expected a job with a plist to stay enabled, got %+v", clean)
+	}
+}
+
+func TestParseLaunchctlPrintServices(t *testing.T) {
+	want := []launchdEntry{
+		{pid: 412, label: "test.state.running"},
+		{label: "test.state.clean", exited: true},
+		{label: "test.state.failed", exited: true, lastExit: 78},
+		{label: "test.state.loaded"},
+	}
+	if got := parseLaunchctlPrintServices(testDomainPrint); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+	if got := parseLaunchctlPrintServices("gui/501 = {\n\ttype = login\n}\n"); len(got) != 0 {
+		t.Fatalf("expected no entries without a services block, got %+v", got)
+	}
+}
+
+func TestLaunchdListDisabledServices(t *testing.T) {
+	runner := &fakeRunner{handle: func(name string, args []string) ([]byte, error) {
+		return []byte("disabled services = {\n\t\"com.example.off\" => disabled\n\t\"com.example.on\" => enabled\n}\n"), nil
+	}}
+	p := newTestLaunchdProvider(t, runner)
+
+	want := map[string]bool{"com.example.off": true, "com.example.on": false}
+	if got := p.listDisabledServices(t.Context(), "gui/501"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	if cmds := runner.commands(); !slices.Equal(cmds, []string{"launchctl print-disabled gui/501"}) {
+		t.Fatalf("unexpected commands: %v", cmds)
+	}
+
+	p = newTestLaunchdProvider(t, &fakeRunner{handle: func(name string, args []string) ([]byte, error) {
+		return nil, &fakeExitError{code: 113}
+	}})
+	if got := p.listDisabledServices(t.Context(), "gui/501"); len(got) != 0 {
+		t.Fatalf("expected an empty map when launchctl fails, got %v", got)
+	}
+}
+
+func TestNormalizeSignal(t *testing.T) {
+	cases := []struct {
+		name    string
+		signal  string
+		want    string
+		wantErr bool
+	}{
+		{name: "empty defaults to SIGTERM", signal: "", want: "SIGTERM"},
+		{name: "prefixed", signal: "SIGINT", want: "SIGINT"},
+		{name: "unprefixed", signal: "hup", want: "SIGHUP"},
+		{name: "unknown", signal: "SIGBOGUS", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := normalizeSignal(tc.signal)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Fatalf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParseLaunchctlPrintPID(t *testing.T) {
+	output := "gui/501/com.example.demo = {\n\tactive count = 1\n\tpid = 4242\n\tstate = running\n}\n"
+	if got := parseLaunchctlPrintPID(output); got != 4242 {
+		t.Fatalf("expected pid 4242, got %d", got)
+	}
+	if got := parseLaunchctlPrintPID("gui/501/com.example.demo = {\n\tstate = not running\n}\n"); got != 0 {
+		t.Fatalf("expected pid 0, got %d", got)
+	}
+}
+
+func TestLaunchdStop_SendsConfiguredSignal(t *testing.T) {
+	runner := &fakeRunner{}
+	p := newTestLaunchdProvider(t, runner)
+	p.stopSignal = "SIGINT"
+
+	if err := p.Stop(t.Context(), "com.example.demo", models.ScopeUser); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "launchctl kill SIGINT gui/501/com.example.demo"
+	if !slices.Contains(runner.commands(), want) {
+		t.Fatalf("expected %q in commands, got %v", want, runner.commands())
+	}
+}
+
+func TestLaunchdStopWithSignal_EscalatesAfterTimeout(t *testing.T) {
+	runner := &fakeRunner{
+		handle: func(name string, args []string) ([]byte, error) {
+			if args[0] == "print" {
+				return []byte("\tpid = 4242\n"), nil
+			}
+			return nil, nil
+		},
+	}
+	p := newTestLaunchdProvider(t, runner)
+
+	if err := p.StopWithSignal(t.Context(), "com.example.demo", models.ScopeSystem, "SIGTERM", 10*time.Millisecond); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cmds := runner.commands()
+	if cmds[0] != "launchctl kill SIGTERM system/com.example.demo" {
+		t.Fatalf("expected SIGTERM first, got %q", cmds[0])
+	}
+	if last := cmds[len(cmds)-1]; last != "launchctl kill SIGKILL system/com.example.demo" {
+		t.Fatalf("expected SIGKILL escalation last, got %q", last)
+	}
+}
+
+func TestLaunchdStopWithSignal_NoEscalationWhenProcessExits(t *testing.T) {
+	runner := &fakeRunner{}
+	p := newTestLaunchdProvider(t, runner)
+
+	if err := p.StopWithSignal(t.Context(), "com.example.demo", models.ScopeSystem, "SIGTERM", time.Second); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if slices.Contains(runner.commands(), "launchctl kill SIGKILL system/com.example.demo") {
+		t.Fatalf("expected no SIGKILL, got %v", runner.commands())
+	}
+}
+
+func TestLaunchdStopWithSignal_CancelledWhileWaiting(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	runner := &fakeRunner{
+		handle: func(name string, args []string) ([]byte, error) {
+			if args[0] == "print" {
+				// The request goes away while launchctl print runs
+				cancel()
+				return nil, context.Canceled
+			}
+			return nil, nil
+		},
+	}
+	p := newTestLaunchdProvider(t, runner)
+
+	err := p.StopWithSignal(ctx, "com.example.demo", models.ScopeSystem, "SIGTERM", time.Second)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled rather than a clean stop, got %v", err)
+	}
+}
+
+func TestLaunchdStopWithSignal_CancelledBetweenPolls(t *testing.T) {
+	runner := &fakeRunner{
+		handle: func(name string, args []string) ([]byte, error) {
+			if args[0] == "print" {
+				return []byte("\tpid = 4242\n"), nil
+			}
+			return nil, nil
+		},
+	}
+	p := newTestLaunchdProvider(t, runner)
+	p.pollInterval = time.Hour
+
+	ctx, cancel := context.WithTimeout(t.Context(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := p.StopWithSignal(ctx, "com.example.demo", models.ScopeSystem, "SIGTERM", 2*time.Hour)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the request's deadline error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("kept waiting %s after the request ended", elapsed)
+	}
+	if slices.Contains(runner.commands(), "launchctl kill SIGKILL system/com.example.demo") {
+		t.Fatal("expected no SIGKILL after the request ended")
+	}
+}
+
+func TestGenerateTimerPlist(t *testing.T) {
+	plist := generateTimerPlist(models.TimerConfig{
+		Name:    "com.example.backup",
+		Program: "/usr/local/bin/backup",
+	}, map[string]int{"Hour": 2, "Minute": 30})
+
+	want := "\t<key>StartCalendarInterval</key>\n\t<dict>\n" +
+		"\t\t<key>Hour</key>\n\t\t<integer>2</integer>\n" +
+		"\t\t<key>Minute</key>\n\t\t<integer>30</integer>\n" +
+		"\t</dict>\n"
+	if !strings.Contains(plist, want) {
+		t.Fatalf("expected calendar interval block, got:\n%s", plist)
+	}
+	if !strings.Contains(plist, "<key>Program</key>\n\t<string>/usr/local/bin/backup</string>") {
+		t.Fatalf("expected program key, got:\n%s", plist)
+	}
+	if !strings.Contains(plist, "<key>RunAtLoad</key>\n\t<false/>") {
+		t.Fatalf("expected RunAtLoad false, got:\n%s", plist)
+	}
+}
+
+func TestGeneratePlist_UserAndGroup(t *testing.T) {
+	p := newTestLaunchdProvider(t, &fakeRunner{})
+	plist := p.generatePlist(models.ServiceConfig{
+		Name:    "com.example.worker",
+		Program: "/usr/local/bin/worker",
+		User:    "_www",
+		Group:   "staff",
+	})
+
+	root, err := decodePlist([]byte(plist))
+	if err != nil {
+		t.Fatalf("generated plist does not decode: %v\n%s", err, plist)
+	}
+	dict := root.(map[string]any)
+	if dict["UserName"] != "_www" || dict["GroupName"] != "staff" {
+		t.Fatalf("expected UserName/GroupName keys, got:\n%s", plist)
+	}
+}
+
+func TestLaunchdDisplayName(t *testing.T) {
+	for label, want := range map[string]string{
+		"com.example.backup":                  "backup",
+		"homebrew.mxcl.postgresql@16":         "postgresql@16",
+		"backup":                              "backup",
+		"application.com.example.app.123.456": "application.com.example.app.123.456",
+	} {
+		if got := launchdDisplayName(label); got != want {
+			t.Errorf("launchdDisplayName(%q) = %q, expected %q", label, got, want)
+		}
+	}
+}
+
+func TestLaunchdListServices_Description(t *testing.T) {
+	p := newTestLaunchdProvider(t, &fakeRunner{})
+	dir := filepath.Join(p.userHome, "Library", "LaunchAgents")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	plist := p.generatePlist(models.ServiceConfig{
+		Name:        "test.describe.backup",
+		Program:     "/usr/local/bin/backup",
+		Description: "Nightly <backup>",
+	})
+	if err := os.WriteFile(filepath.Join(dir, "test.describe.backup.plist"), []byte(plist), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	services, err := p.ListServices(t.Context(), models.ScopeUser)
+	if err != nil {
+		t.Fatalf("ListServices: %v", err)
+	}
+	i := slices.IndexFunc(services, func(svc models.Service) bool { return svc.Name == "test.describe.backup" })
+	if i < 0 {
+		t.Fatalf("expected the job in %+v", services)
+	}
+	if svc := services[i]; svc.DisplayName != "backup" || svc.Description != "Nightly <backup>" {
+		t.Fatalf("expected display name and description from the plist, got %+v", svc)
+	}
+}
+
+func TestLaunchdReadPlist_FallbackWithoutPlutil(t *testing.T) {
+	runner := &fakeRunner{handle: func(name string, args []string) ([]byte, error) {
+		if name == "plutil" {
+			return nil, errors.New(`exec: "plutil": executable file not found in $PATH`)
+		}
+		return nil, nil
+	}}
+	p := newTestLaunchdProvider(t, runner)
+
+	xmlPath := filepath.Join(t.TempDir(), "com.example.worker.plist")
+	if err := os.WriteFile(xmlPath, []byte(testPlist), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lp, err := p.readPlist(t.Context(), xmlPath)
+	if err != nil {
+		t.Fatalf("readPlist: %v", err)
+	}
+	if lp.Label != "com.example.worker" || lp.executable() != "/usr/local/bin/worker" {
+		t.Fatalf("unexpected plist: %+v", lp)
+	}
+
+	binPath := filepath.Join(t.TempDir(), "binary.plist")
+	if err := os.WriteFile(binPath, []byte("bplist00\x00\x01"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.readPlist(t.Context(), binPath); err == nil || !strings.Contains(err.Error(), "requires plutil") {
+		t.Fatalf("expected binary plist error, got %v", err)
+	}
+}
+
+func TestGeneratePlist_RestartPolicy(t *testing.T) {
+	cases := []struct {
+		name      string
+		config    models.ServiceConfig
+		keepAlive any
+		throttle  any
+	}{
+		{name: "none", config: models.ServiceConfig{}},
+		{name: "keepalive", config: models.ServiceConfig{KeepAlive: true}, keepAlive: true},
+		{name: "always", config: models.ServiceConfig{RestartPolicy: "always", RestartSec: 15}, keepAlive: true, throttle: int64(15)},
+		{name: "on-failure", config: models.ServiceConfig{RestartPolicy: "on-failure"}, keepAlive: map[string]any{"SuccessfulExit": false}},
+		{name: "no", config: models.ServiceConfig{KeepAlive: true, RestartPolicy: "no", RestartSec: 15}},
+	}
+
+	p := newTestLaunchdProvider(t, &fakeRunner{})
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.config.Name = "com.example.app"
+			tc.config.Program = "/bin/app"
+			plist := p.generatePlist(tc.config)
+			root, err := decodePlist([]byte(plist))
+			if err != nil {
+				t.Fatalf("generated plist does not decode: %v\n%s", err, plist)
+			}
+			dict := root.(map[string]any)
+			if !reflect.DeepEqual(dict["KeepAlive"], tc.keepAlive) {
+				t.Fatalf("expected KeepAlive %v, got %v", tc.keepAlive, dict["KeepAlive"])
+			}
+			if !reflect.DeepEqual(dict["ThrottleInterval"], tc.throttle) {
+				t.Fatalf("expected ThrottleInterval %v, got %v", tc.throttle, dict["ThrottleInterval"])
+			}
+		})
+	}
+}
+
+func TestParseEnvironmentFile(t *testing.T) {
+	content := `# secrets
+API_KEY=abc123
+export REGION = eu-west-1
+GREETING="hello world"
+; legacy comment
+SINGLE='quoted'
+EMPTY=
+`
+	env, err := parseEnvironmentFile(content)
+	if err != nil {
+		t.Fatalf("parseEnvironmentFile: %v", err)
+	}
+	want := map[string]string{
+		"API_KEY":  "abc123",
+		"REGION":   "eu-west-1",
+		"GREETING": "hello world",
+		"SINGLE":   "quoted",
+		"EMPTY":    "",
+	}
+	if !reflect.DeepEqual(env, want) {
+		t.Fatalf("want %v\ngot  %v", want, env)
+	}
+
+	if _, err := parseEnvironmentFile("JUSTAKEY\n"); err == nil {
+		t.Fatalf("expected error for line without '='")
+	}
+}
+
+func TestProcessTree(t *testing.T) {
+	output := `    1     0 /sbin/launchd
+  600     1 /usr/local/bin/server --port 80
+  650   601 /usr/bin/worker
+  601   600 /bin/sh -c worker
+  700     1 /usr/bin/unrelated
+`
+	want := []models.Process{
+		{PID: 600, Command: "/usr/local/bin/server --port 80"},
+		{PID: 601, Command: "/bin/sh -c worker"},
+		{PID: 650, Command: "/usr/bin/worker"},
+	}
+	if got := processTree(output, 600); !slices.Equal(got, want) {
+		t.Fatalf("want %+v\ngot  %+v", want, got)
+	}
+	if got := processTree(output, 999); len(got) != 0 {
+		t.Fatalf("expected no processes for unknown root, got %+v", got)
+	}
+}
+
+func TestCountUnifiedLogErrors(t *testing.T) {
+	output := `{"messageType":"Error","eventMessage":"failed to open socket"}
+{"messageType":"Fault","eventMessage":"assertion failed"}
+{"messageType":"Default","eventMessage":"started"}
+{"messageType":"Error","eventMessage":"retrying"}
+`
+	got := countUnifiedLogErrors(output)
+	if got.Errors != 3 || got.Warnings != 0 {
+		t.Fatalf("expected 3 errors and no warnings, got %+v", got)
+	}
+}
+
+func TestParseUnifiedLogEntry(t *testing.T) {
+	line := `{"timestamp":"2025-03-01 04:00:00.250000-0800","messageType":"Fault","eventMessage":"assertion failed"}`
+	got, ok := parseUnifiedLogEntry(line)
+	if !ok {
+		t.Fatal("expected entry to parse")
+	}
+	want := models.LogEntry{
+		Time:    time.Date(2025, 3, 1, 12, 0, 0, 250000000, time.UTC),
+		Level:   "critical",
+		Message: "assertion failed",
+		Raw:     line,
+	}
+	if got != want {
+		t.Fatalf("want %+v, got %+v", want, got)
+	}
+
+	if got, _ := parseUnifiedLogEntry(`{"messageType":"Default","eventMessage":"started"}`); got.Level != "info" {
+		t.Fatalf("expected default messages at info, got %q", got.Level)
+	}
+	if _, ok := parseUnifiedLogEntry("Filtering the log data using \"process == \"demo\"\""); ok {
+		t.Fatal("expected banner line to be skipped")
+	}
+}
+
+func TestCompactLogLevel(t *testing.T) {
+	cases := []struct {
+		line  string
+		level string
+		ok    bool
+	}{
+		{line: "2025-03-01 04:00:00.250 E  demo[123:456] failed to open socket", level: "error", ok: true},
+		{line: "2025-03-01 04:00:00.250 F  demo[123:456] assertion failed", level: "critical", ok: true},
+		{line: "2025-03-01 04:00:00.250 Df demo[123:456] started", level: "info", ok: true},
+		{line: "2025-03-01 04:00:00.250 Db demo[123:456] tick", level: "debug", ok: true},
+		{line: "Timestamp               Ty Process[PID:TID]"},
+		{line: "Filtering the log data using \"process == 'demo'\""},
+		{line: "    continuation of a multi-line message"},
+	}
+
+	for _, tc := range cases {
+		level, ok := compactLogLevel(tc.line)
+		if level != tc.level || ok != tc.ok {
+			t.Errorf("compactLogLevel(%q) = %q, %v; want %q, %v", tc.line, level, ok, tc.level, tc.ok)
+		}
+	}
+}
+
+func TestGeneratePlist_Schedule(t *testing.T) {
+	p := newTestLaunchdProvider(t, &fakeRunner{})
+
+	cases := []struct {
+		name     string
+		schedule string
+		check    func(dict map[string]any) bool
+	}{
+		{name: "interval", schedule: "3600", check: func(dict map[string]any) bool {
+			return dict["StartInterval"] == int64(3600)
+		}},
+		{name: "calendar", schedule: "0 2 * * *", check: func(dict map[string]any) bool {
+			cal, ok := dict["StartCalendarInterval"].(map[string]any)
+			return ok && cal["Hour"] == int64(2) && cal["Minute"] == int64(0)
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			plist := p.generatePlist(models.ServiceConfig{
+				Name:      "com.example.backup",
+				Program:   "/usr/local/bin/backup",
+				RunAtLoad: true,
+				Schedule:  tc.schedule,
+			})
+			root, err := decodePlist([]byte(plist))
+			if err != nil {
+				t.Fatalf("generated plist does not decode: %v\n%s", err, plist)
+			}
+			dict := root.(map[string]any)
+			if !tc.check(dict) {
+				t.Fatalf("unexpected schedule keys in:\n%s", plist)
+			}
+			if _, ok := dict["RunAtLoad"]; ok {
+				t.Fatalf("expected schedule to replace RunAtLoad, got:\n%s", plist)
+			}
+		})
+	}
+}
+
+func TestLaunchdJobType(t *testing.T) {
+	root := t.TempDir()
+	write := func(dir, name, content string) string {
+		t.Helper()
+		path := filepath.Join(root, dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	scheduled := generateTimerPlist(models.TimerConfig{Name: "com.example.backup", Program: "/bin/backup"}, map[string]int{"Hour": 2})
+	cases := map[string]string{
+		write("LaunchAgents", "com.example.agent.plist", "<plist/>"):                  models.TypeAgent,
+		write("LaunchDaemons", "com.example.daemon.plist", "<plist/>"):                models.TypeDaemon,
+		write("LaunchAgents", "com.example.backup.plist", scheduled):                  models.TypeTimer,
+		write("LaunchDaemons", "com.example.every.plist", "<key>StartInterval</key>"): models.TypeTimer,
+		write("Elsewhere", "com.example.other.plist", "<plist/>"):                     models.TypeService,
+	}
+	for path, want := range cases {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := launchdJobType(path, data); got != want {
+				t.Fatalf("expected %s, got %s", want, got)
+			}
+		})
+	}
+}
+
+func TestParseLaunchctlFailureReason(t *testing.T) {
+	cases := []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{name: "exit code", output: "\tstate = not running\n\tlast exit code = 78: EX_CONFIG\n", want: "EX_CONFIG"},
+		{name: "signal", output: "\tlast exit code = 9\n\tlast terminating signal = Killed: 9\n", want: "Killed: 9"},
+		{name: "bare code", output: "\tlast exit code = 1\n", want: ""},
+		{name: "never exited", output: "\tlast exit code = (never exited)\n", want: ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := parseLaunchctlFailureReason(tc.output); got != tc.want {
+				t.Fatalf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestLaunchdListServices_FailureDetails(t *testing.T) {
+	runner := &fakeRunner{handle: func(name string, args []string) ([]byte, error) {
+		if name == "launchctl" && len(args) == 2 && args[0] == "print" {
+			switch args[1] {
+			case "gui/501":
+				return []byte(testDomainPrint), nil
+			case "gui/501/test.state.failed":
+				return []byte("\tlast exit code = 78: EX_CONFIG\n"), nil
+			}
+		}
+		return nil, nil
+	}}
+	p := newTestLaunchdProvider(t, runner)
+	dir := filepath.Join(p.userHome, "Library", "LaunchAgents")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, label := range []string{"test.state.failed", "test.state.clean"} {
+		if err := os.WriteFile(filepath.Join(dir, label+".plist"), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	services, err := p.ListServices(t.Context(), models.ScopeUser)
+	if err != nil {
+		t.Fatalf("ListServices: %v", err)
+	}
+	for _, svc := range services {
+		switch svc.Name {
+		case "test.state.failed":
+			if svc.ExitCode != 78 || svc.FailureReason != "EX_CONFIG" {
+				t.Fatalf("unexpected failure details: %+v", svc)
+			}
+		case "test.state.clean":
+			if svc.ExitCode != 0 || svc.FailureReason != "" {
+				t.Fatalf("expected no failure details for a clean exit, got %+v", svc)
+			}
+		}
+	}
+	for _, cmd := range runner.commands() {
+		if strings.Contains(cmd, "test.state.clean") {
+			t.Fatalf("expected no per-job query for a clean exit, got %q", cmd)
+		}
+	}
+}
+
+func TestLaunchdRunAs(t *testing.T) {
+	current, err := user.Current()
+	if err != nil {
+		t.Skipf("no current user: %v", err)
+	}
+	p := newTestLaunchdProvider(t, &fakeRunner{handle: func(name string, args []string) ([]byte, error) {
+		return nil, errors.New("plutil not available")
+	}})
+	p.uid = current.Uid
+
+	dir := filepath.Join(p.userHome, "Library", "LaunchAgents")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	agent := p.generatePlist(models.ServiceConfig{Name: "test.runas.agent", Program: "/bin/agent"})
+	if err := os.WriteFile(filepath.Join(dir, "test.runas.agent.plist"), []byte(agent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	named := p.generatePlist(models.ServiceConfig{Name: "test.runas.named", Program: "/bin/agent", User: "_www"})
+	if err := os.WriteFile(filepath.Join(dir, "test.runas.named.plist"), []byte(named), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := p.runAs(t.Context(), "test.runas.agent", models.ScopeUser); got != current.Username {
+		t.Fatalf("expected user agent to run as %q, got %q", current.Username, got)
+	}
+	if got := p.runAs(t.Context(), "test.runas.named", models.ScopeUser); got != "_www" {
+		t.Fatalf("expected UserName from plist, got %q", got)
+	}
+	if got := p.runAs(t.Context(), "test.runas.missing", models.ScopeSystem); got != "root" {
+		t.Fatalf("expected daemons to default to root, got %q", got)
+	}
+}
+
+func TestLaunchdGetService(t *testing.T) {
+	runner := &fakeRunner{handle: func(name string, args []string) ([]byte, error) {
+		if name == "launchctl" && len(args) == 2 && args[0] == "print" && args[1] == "gui/501/test.get.failed" {
+			return []byte("gui/501/test.get.failed = {\n\tstate = not running\n\tlast exit code = 78: EX_CONFIG\n}\n"), nil
+		}
+		if name == "launchctl" && len(args) == 2 && args[0] == "print-disabled" {
+			return []byte("disabled services = {\n\t\"test.get.failed\" => disabled\n}\n"), nil
+		}
+		return nil, errors.New("unexpected command")
+	}}
+	p := newTestLaunchdProvider(t, runner)
+	dir := filepath.Join(p.userHome, "Library", "LaunchAgents")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, label := range []string{"test.get.failed", "test.get.unloaded"} {
+		if err := os.WriteFile(filepath.Join(dir, label+".plist"), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	svc, err := p.GetService(t.Context(), "test.get.failed", models.ScopeUser)
+	if err != nil {
+		t.Fatalf("GetService: %v", err)
+	}
+	if svc.Status != models.StatusStopped || svc.Enabled || svc.ExitCode != 78 || svc.FailureReason != "EX_CONFIG" || svc.Type != models.TypeAgent {
+		t.Fatalf("unexpected service: %+v", svc)
+	}
+
+	svc, err = p.GetService(t.Context(), "test.get.unloaded", models.ScopeUser)
+	if err != nil {
+		t.Fatalf("GetService: %v", err)
+	}
+	if svc.Status != models.StatusStopped || !svc.NeverRan {
+		t.Fatalf("expected an unloaded job to be stopped and never run, got %+v", svc)
+	}
+
+	if _, err := p.GetService(t.Context(), "test.get.missing", models.ScopeUser); err == nil {
+		t.Fatal("expected an error for a job without a plist")
+	}
+
+	for _, cmd := range runner.commands() {
+		if cmd == "launchctl print gui/501" {
+			t.Fatalf("expected no domain listing, got %q", cmd)
+		}
+	}
+}
+
+// shellLogCommand replaces `log` with a shell script for stream tests
+func shellLogCommand(t *testing.T, script string) func(ctx context.Context, args ...string) *exec.Cmd {
+	t.Helper()
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	return func(ctx context.Context, args ...string) *exec.Cmd {
+		return exec.CommandContext(ctx, "sh", "-c", script)
+	}
+}
+
+// waitClosed drains ch and fails if it isn't closed within timeout
+func waitClosed[T any](t *testing.T, ch <-chan T, timeout time.Duration) {
+	t.Helper()
+	deadline := time.After(timeout)
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				return
+			}
+		case <-deadline:
+			t.Fatal("log stream did not end after its context was cancelled")
+		}
+	}
+}
+
+func TestLaunchdStreamLogs_StopsWhenConsumerGoesAway(t *testing.T) {
+	p := newTestLaunchdProvider(t, &fakeRunner{})
+	p.logCommand = shellLogCommand(t, "while :; do echo line; done")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := p.StreamLogs(ctx, "com.example.demo", models.ScopeUser, models.LogStreamOptions{})
+	if err != nil {
+		t.Fatalf("StreamLogs: %v", err)
+	}
+
+	// Let the buffer fill so the reader is blocked sending, as it is when a
+	// client stops reading
+	<-ch
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	waitClosed(t, ch, 5*time.Second)
+}
+
+func TestLaunchdStreamLogs_KillsIdleProcess(t *testing.T) {
+	p := newTestLaunchdProvider(t, &fakeRunner{})
+	p.logCommand = shellLogCommand(t, "echo $$; exec sleep 60")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := p.StreamLogs(ctx, "com.example.demo", models.ScopeUser, models.LogStreamOptions{})
+	if err != nil {
+		t.Fatalf("StreamLogs: %v", err)
+	}
+
+	pid, err := strconv.Atoi(<-ch)
+	if err != nil {
+		t.Fatalf("expected pid line: %v", err)
+	}
+	cancel()
+	waitClosed(t, ch, 5*time.Second)
+
+	// The channel only closes after the process is reaped
+	proc, err := os.FindProcess(pid)
+	if err == nil {
+		err = proc.Signal(syscall.Signal(0))
+	}
+	if err == nil {
+		t.Fatalf("expected log process %d to be gone", pid)
+	}
+}
+
+func TestLaunchctlSubmitArgs(t *testing.T) {
+	config := models.ServiceConfig{
+		Program:         "/usr/bin/rsync",
+		Arguments:       []string{"-a", "src", "dst"},
+		Environment:     map[string]string{"B": "2", "A": "1"},
+		StandardOutPath: "/tmp/sync.log",
+	}
+	got, err := launchctlSubmitArgs("run-1234abcd", config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"submit", "-l", "run-1234abcd", "-o", "/tmp/sync.log", "--",
+		"/bin/sh", "-c", submitWrapper, "run-1234abcd",
+		"/usr/bin/env", "A=1", "B=2", "/usr/bin/rsync", "-a", "src", "dst"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+
+	if _, err := launchctlSubmitArgs("run-1234abcd", models.ServiceConfig{Program: "/bin/true", WorkingDirectory: "/srv"}); err == nil {
+		t.Fatal("expected workingDirectory to be rejected")
+	}
+}
+
+func TestLaunchdServiceExists(t *testing.T) {
+	p := newTestLaunchdProvider(t, &fakeRunner{})
+	dir := filepath.Join(p.userHome, "Library", "LaunchAgents")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "com.example.web.plist"), []byte("<plist/>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for label, want := range map[string]bool{"com.example.web": true, "com.example.db": false} {
+		got, err := p.ServiceExists(t.Context(), label, models.ScopeUser)
+		if err != nil {
+			t.Fatalf("ServiceExists(%s): %v", label, err)
+		}
+		if got != want {
+			t.Fatalf("ServiceExists(%s) = %v, want %v", label, got, want)
+		}
+	}
+}
+
+func TestLaunchdEnableDisable(t *testing.T) {
+	cases := []struct {
+		name   string
+		legacy bool
+		want   []string
+	}{
+		{name: "modern", want: []string{
+			"launchctl enable gui/501/test.toggle",
+			"launchctl bootstrap gui/501 PLIST",
+			"launchctl disable gui/501/test.toggle",
+			"launchctl bootout gui/501/test.toggle",
+		}},
+		{name: "legacy", legacy: true, want: []string{
+			"launchctl enable gui/501/test.toggle",
+			"launchctl load -w PLIST",
+			"launchctl disable gui/501/test.toggle",
+			"launchctl unload -w PLIST",
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			runner := &fakeRunner{handle: func(name string, args []string) ([]byte, error) {
+				if tc.legacy && (args[0] == "enable" || args[0] == "disable") {
+					return []byte("Unrecognized subcommand: " + args[0] + "\n"), &fakeExitError{code: 1}
+				}
+				return nil, nil
+			}}
+			p := newTestLaunchdProvider(t, runner)
+			dir := filepath.Join(p.userHome, "Library", "LaunchAgents")
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			plistPath := filepath.Join(dir, "test.toggle.plist")
+			if err := os.WriteFile(plistPath, nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := p.Enable(t.Context(), "test.toggle", models.ScopeUser); err != nil {
+				t.Fatalf("Enable: %v", err)
+			}
+			if err := p.Disable(t.Context(), "test.toggle", models.ScopeUser); err != nil {
+				t.Fatalf("Disable: %v", err)
+			}
+
+			var want []string
+			for _, cmd := range tc.want {
+				want = append(want, strings.Replace(cmd, "PLIST", plistPath, 1))
+			}
+			if cmds := runner.commands(); !slices.Equal(cmds, want) {
+				t.Fatalf("expected %v, got %v", want, cmds)
+			}
+		})
+	}
+}
+
+func TestLaunchdEnable_ReportsFailure(t *testing.T) {
+	runner := &fakeRunner{handle: func(name string, args []string) ([]byte, error) {
+		return []byte("Not privileged to set domain overrides.\n"), &fakeExitError{code: 1}
+	}}
+	p := newTestLaunchdProvider(t, runner)
+	dir := filepath.Join(p.userHome, "Library", "LaunchAgents")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "test.toggle.plist"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := p.Enable(t.Context(), "test.toggle", models.ScopeUser)
+	if err == nil || !strings.Contains(err.Error(), "Not privileged") {
+		t.Fatalf("expected the launchctl error, got %v", err)
+	}
+	if cmds := runner.commands(); len(cmds) != 1 {
+		t.Fatalf("expected no fallback to load -w, got %v", cmds)
+	}
+}
@@ -0,0 +1,139 @@
+package platform
+
+import (
+	"bufio"
+	"context"
+	"strings"
+	"time"
+
+	"autorun/internal/logger"
+	"autorun/internal/models"
+)
+
+// logHistoryWindow is how far back `log show` looks for history. The unified
+// log can't be asked for the last N messages, so the window is read and
+// trimmed.
+const logHistoryWindow = "1h"
+
+// logHistory seeds a launchd log stream with recent messages from `log show`.
+// The stream is started before the history is read so nothing logged in
+// between is lost; duplicate then drops stream lines the history already
+// covered.
+type logHistory struct {
+	// fetch returns the output of `log show` for the job
+	fetch func() ([]byte, error)
+
+	// lineTime returns the timestamp that starts a message; lines without one
+	// (headers, continuations of multi-line messages) return false
+	lineTime func(line string) (time.Time, bool)
+
+	// n is how many messages to keep
+	n int
+
+	// last is the timestamp of the final history message and seen holds the
+	// lines stamped with it, since several messages can share a timestamp
+	last time.Time
+	seen map[string]bool
+
+	// skipping is whether the current message is a duplicate, so its
+	// continuation lines are dropped too; caughtUp is set at the first stream
+	// message newer than the history
+	skipping bool
+	caughtUp bool
+}
+
+// load runs fetch and returns the lines of the last n messages. Failures are
+// logged and yield no history; the stream is still followed.
+func (h *logHistory) load() []string {
+	output, err := h.fetch()
+	if err != nil {
+		logger.Warn("failed to read log history", "error", err)
+		return nil
+	}
+
+	var lines []string
+	var starts []int // index in lines of each message's first line
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		// log show ends with a statistics footer below a rule of dashes
+		if strings.HasPrefix(line, "----") {
+			break
+		}
+		t, ok := h.lineTime(line)
+		if ok {
+			starts = append(starts, len(lines))
+			if !t.Equal(h.last) {
+				h.last = t
+				h.seen = map[string]bool{}
+			}
+			h.seen[line] = true
+		} else if len(starts) == 0 {
+			// Header lines before the first message
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	if len(starts) > h.n {
+		lines = lines[starts[len(starts)-h.n]:]
+	}
+	return lines
+}
+
+// duplicate reports whether a stream line was already sent as history
+func (h *logHistory) duplicate(line string) bool {
+	if h.caughtUp {
+		return false
+	}
+	t, ok := h.lineTime(line)
+	if !ok {
+		return h.skipping
+	}
+	switch {
+	case t.Before(h.last), t.Equal(h.last) && h.seen[line]:
+		h.skipping = true
+	default:
+		h.caughtUp = true
+		h.skipping = false
+	}
+	return h.skipping
+}
+
+// compactLineTime reads the timestamp that starts a message in the compact
+// style, e.g. "2025-03-01 04:00:00.250 E  demo[123:456] message"
+func compactLineTime(line string) (time.Time, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation("2006-01-02 15:04:05.000", fields[0]+" "+fields[1], time.Local)
+	return t, err == nil
+}
+
+// ndjsonLineTime reads the timestamp of an ndjson log message
+func ndjsonLineTime(line string) (time.Time, bool) {
+	entry, ok := parseUnifiedLogEntry(line)
+	return entry.Time, ok && !entry.Time.IsZero()
+}
+
+// logHistory returns a history seeder for a job's log stream in the given
+// style, or nil if no history was requested
+func (p *LaunchdProvider) logHistory(ctx context.Context, name string, scope models.Scope, style string, n int) *logHistory {
+	if n <= 0 {
+		return nil
+	}
+	lineTime := compactLineTime
+	if style == "ndjson" {
+		lineTime = ndjsonLineTime
+	}
+	return &logHistory{
+		fetch: func() ([]byte, error) {
+			return p.run(ctx, OpList, "log", "show", "--last", logHistoryWindow,
+				"--predicate", p.logPredicate(ctx, name, scope), "--style", style)
+		},
+		lineTime: lineTime,
+		n:        n,
+	}
+}
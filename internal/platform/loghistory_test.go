@@ -0,0 +1,109 @@
+package platform
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"autorun/internal/models"
+)
+
+const compactHistory = `Timestamp               Ty Process[PID:TID]
+2025-03-01 04:00:00.100 Df demo[1:2] one
+2025-03-01 04:00:00.150 E  demo[1:2] two
+    second line of two
+2025-03-01 04:00:00.200 Df demo[1:2] three
+2025-03-01 04:00:00.200 Df demo[1:2] four
+--------------------------------------------------------------------------------------------------------------------
+Log      - Default:          3, Info:                0, Debug:             0, Error:          1, Fault:          0
+`
+
+func TestLogHistory_Load(t *testing.T) {
+	h := &logHistory{
+		fetch:    func() ([]byte, error) { return []byte(compactHistory), nil },
+		lineTime: compactLineTime,
+		n:        3,
+	}
+	got := h.load()
+	want := []string{
+		"2025-03-01 04:00:00.150 E  demo[1:2] two",
+		"    second line of two",
+		"2025-03-01 04:00:00.200 Df demo[1:2] three",
+		"2025-03-01 04:00:00.200 Df demo[1:2] four",
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+}
+
+func TestLogHistory_Duplicate(t *testing.T) {
+	h := &logHistory{
+		fetch:    func() ([]byte, error) { return []byte(compactHistory), nil },
+		lineTime: compactLineTime,
+		n:        10,
+	}
+	h.load()
+
+	cases := []struct {
+		line string
+		dup  bool
+	}{
+		{line: "Filtering the log data using \"process == 'demo'\"", dup: false},
+		{line: "2025-03-01 04:00:00.150 E  demo[1:2] two", dup: true},
+		{line: "    second line of two", dup: true},
+		{line: "2025-03-01 04:00:00.200 Df demo[1:2] four", dup: true},
+		{line: "2025-03-01 04:00:00.200 Df demo[1:2] five", dup: false},
+		{line: "    second line of five", dup: false},
+		{line: "2025-03-01 04:00:00.100 Df demo[1:2] out of order", dup: false},
+	}
+	for _, tc := range cases {
+		if got := h.duplicate(tc.line); got != tc.dup {
+			t.Errorf("duplicate(%q) = %v, want %v", tc.line, got, tc.dup)
+		}
+	}
+}
+
+func TestLaunchdStreamLogs_History(t *testing.T) {
+	runner := &fakeRunner{handle: func(name string, args []string) ([]byte, error) {
+		return []byte(compactHistory), nil
+	}}
+	p := newTestLaunchdProvider(t, runner)
+	p.logCommand = shellLogCommand(t, `printf '%s\n' \
+		'2025-03-01 04:00:00.200 Df demo[1:2] three' \
+		'2025-03-01 04:00:00.200 Df demo[1:2] four' \
+		'2025-03-01 04:00:01.000 Df demo[1:2] five'`)
+
+	ch, err := p.StreamLogs(context.Background(), "com.example.demo", models.ScopeUser, models.LogStreamOptions{History: 2})
+	if err != nil {
+		t.Fatalf("StreamLogs: %v", err)
+	}
+	var got []string
+	timeout := time.After(5 * time.Second)
+	for done := false; !done; {
+		select {
+		case line, ok := <-ch:
+			if !ok {
+				done = true
+				break
+			}
+			got = append(got, line)
+		case <-timeout:
+			t.Fatal("stream did not end")
+		}
+	}
+
+	want := []string{
+		"2025-03-01 04:00:00.200 Df demo[1:2] three",
+		"2025-03-01 04:00:00.200 Df demo[1:2] four",
+		"2025-03-01 04:00:01.000 Df demo[1:2] five",
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+	cmds := runner.commands()
+	if len(cmds) != 1 || !strings.HasPrefix(cmds[0], "log show --last 1h --predicate ") || !strings.HasSuffix(cmds[0], "--style compact") {
+		t.Fatalf("expected a single log show call, got %q", cmds)
+	}
+}
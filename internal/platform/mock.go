@@ -0,0 +1,406 @@
+package platform
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"autorun/internal/models"
+)
+
+// MockProvider implements ServiceProvider in memory, for working on the UI
+// and for integration tests on hosts autorun can't or shouldn't manage. It
+// starts with a handful of demo services, honors every action on them, and
+// streams synthetic log lines.
+type MockProvider struct {
+	mu       sync.Mutex
+	services map[models.Scope]map[string]*mockService
+	nextPID  int
+
+	// logInterval is how often a followed log gets a new line
+	logInterval time.Duration
+}
+
+// mockService is a service held by MockProvider
+type mockService struct {
+	service models.Service
+	command string
+	pid     int
+}
+
+// mockLogInterval is how often MockProvider's log streams emit a line
+const mockLogInterval = time.Second
+
+// mockLogMessages are cycled through by the synthetic log streams, with
+// the level each is logged at
+var mockLogMessages = []struct {
+	level   string
+	message string
+}{
+	{"info", "handled request GET / in 3ms"},
+	{"info", "handled request GET /health in 1ms"},
+	{"debug", "connection pool: 4 idle, 2 in use"},
+	{"info", "handled request POST /api/items in 12ms"},
+	{"warning", "slow request GET /api/report took 1.8s"},
+	{"info", "handled request GET /static/app.js in 2ms"},
+	{"notice", "configuration checked, no changes"},
+	{"error", "upstream timed out after 5s, retrying"},
+}
+
+// NewMockProvider creates a provider seeded with demo services
+func NewMockProvider() *MockProvider {
+	p := &MockProvider{
+		services:    map[models.Scope]map[string]*mockService{models.ScopeSystem: {}, models.ScopeUser: {}},
+		nextPID:     1000,
+		logInterval: mockLogInterval,
+	}
+	seed := []struct {
+		scope   models.Scope
+		service models.Service
+		command string
+	}{
+		{models.ScopeSystem, models.Service{Name: "nginx", Description: "A high performance web server", Status: models.StatusRunning, Enabled: true}, "/usr/sbin/nginx -g daemon off;"},
+		{models.ScopeSystem, models.Service{Name: "postgresql", Description: "PostgreSQL database server", Status: models.StatusRunning, Enabled: true}, "/usr/lib/postgresql/16/bin/postgres -D /var/lib/postgresql/16/main"},
+		{models.ScopeSystem, models.Service{Name: "redis", Description: "Advanced key-value store", Status: models.StatusStopped}, "/usr/bin/redis-server /etc/redis/redis.conf"},
+		{models.ScopeSystem, models.Service{Name: "worker", Description: "Background job worker", Status: models.StatusFailed, Enabled: true, ExitCode: 1, FailureReason: "exit-code"}, "/opt/app/bin/worker"},
+		{models.ScopeSystem, models.Service{Name: "backup", Description: "Nightly backup", Status: models.StatusStopped, Enabled: true, Type: models.TypeTimer}, "/usr/local/bin/backup.sh"},
+		{models.ScopeUser, models.Service{Name: "syncthing", Description: "File synchronization", Status: models.StatusRunning, Enabled: true}, "/usr/bin/syncthing serve --no-browser"},
+		{models.ScopeUser, models.Service{Name: "dev-server", Description: "Local development server", Status: models.StatusStopped}, "/home/dev/app/bin/serve --port 3000"},
+	}
+	for _, s := range seed {
+		p.add(s.scope, s.service, s.command)
+	}
+	return p
+}
+
+// add stores a service, filling in the fields every service shares. The
+// caller holds p.mu or is the constructor.
+func (p *MockProvider) add(scope models.Scope, service models.Service, command string) {
+	service.Scope = scope
+	if service.DisplayName == "" {
+		service.DisplayName = service.Name
+	}
+	if service.Type == "" {
+		service.Type = models.TypeService
+	}
+	svc := &mockService{service: service, command: command}
+	if service.Status == models.StatusRunning {
+		svc.pid = p.newPID()
+	}
+	p.services[scope][service.Name] = svc
+}
+
+// newPID returns a made-up process ID. The caller holds p.mu.
+func (p *MockProvider) newPID() int {
+	p.nextPID++
+	return p.nextPID
+}
+
+// lookup returns a service by name. The caller holds p.mu.
+func (p *MockProvider) lookup(name string, scope models.Scope) (*mockService, error) {
+	svc, ok := p.services[scope][name]
+	if !ok {
+		return nil, fmt.Errorf("service %w: %s", ErrNotFound, name)
+	}
+	return svc, nil
+}
+
+// update runs fn on a service under the lock
+func (p *MockProvider) update(name string, scope models.Scope, fn func(*mockService)) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	svc, err := p.lookup(name, scope)
+	if err != nil {
+		return err
+	}
+	fn(svc)
+	return nil
+}
+
+func (p *MockProvider) Name() string {
+	return "mock"
+}
+
+// Capabilities reports the optional features the mock accepts. It has no
+// masking or reload to act on.
+func (p *MockProvider) Capabilities() models.Capabilities {
+	return models.Capabilities{Timers: true, Dependencies: true, Hooks: true, ResetFailed: true, Logs: true}
+}
+
+func (p *MockProvider) ListServices(ctx context.Context, scope models.Scope) ([]models.Service, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	services := make([]models.Service, 0, len(p.services[scope]))
+	for _, name := range slices.Sorted(maps.Keys(p.services[scope])) {
+		services = append(services, p.services[scope][name].service)
+	}
+	return services, nil
+}
+
+func (p *MockProvider) GetService(ctx context.Context, name string, scope models.Scope) (*models.Service, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	svc, err := p.lookup(name, scope)
+	if err != nil {
+		return nil, err
+	}
+	service := svc.service
+	if scope == models.ScopeSystem {
+		service.RunAs = "root"
+	}
+	return &service, nil
+}
+
+func (p *MockProvider) ServiceStates(ctx context.Context, names []string, scope models.Scope) (map[string]models.ServiceState, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	states := make(map[string]models.ServiceState, len(names))
+	for _, name := range names {
+		svc, ok := p.services[scope][name]
+		if !ok {
+			states[name] = models.ServiceState{Status: models.StatusUnknown, NotFound: true}
+			continue
+		}
+		states[name] = models.ServiceState{Status: svc.service.Status, Enabled: svc.service.Enabled, PID: svc.pid}
+	}
+	return states, nil
+}
+
+func (p *MockProvider) Start(ctx context.Context, name string, scope models.Scope) error {
+	return p.update(name, scope, func(svc *mockService) {
+		if svc.service.Status != models.StatusRunning {
+			p.run(svc)
+		}
+	})
+}
+
+// run marks a service running under a new process. The caller holds p.mu.
+func (p *MockProvider) run(svc *mockService) {
+	svc.service.Status = models.StatusRunning
+	svc.service.ExitCode = 0
+	svc.service.FailureReason = ""
+	svc.pid = p.newPID()
+}
+
+func (p *MockProvider) Stop(ctx context.Context, name string, scope models.Scope) error {
+	return p.update(name, scope, func(svc *mockService) {
+		svc.service.Status = models.StatusStopped
+		svc.pid = 0
+	})
+}
+
+func (p *MockProvider) Restart(ctx context.Context, name string, scope models.Scope) error {
+	return p.update(name, scope, p.run)
+}
+
+func (p *MockProvider) Enable(ctx context.Context, name string, scope models.Scope) error {
+	return p.update(name, scope, func(svc *mockService) { svc.service.Enabled = true })
+}
+
+func (p *MockProvider) Disable(ctx context.Context, name string, scope models.Scope) error {
+	return p.update(name, scope, func(svc *mockService) { svc.service.Enabled = false })
+}
+
+func (p *MockProvider) ResetFailed(ctx context.Context, name string, scope models.Scope) error {
+	return p.update(name, scope, func(svc *mockService) {
+		if svc.service.Status == models.StatusFailed {
+			svc.service.Status = models.StatusStopped
+			svc.service.ExitCode = 0
+			svc.service.FailureReason = ""
+		}
+	})
+}
+
+func (p *MockProvider) Processes(ctx context.Context, name string, scope models.Scope) ([]models.Process, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	svc, err := p.lookup(name, scope)
+	if err != nil {
+		return nil, err
+	}
+	if svc.pid == 0 {
+		return []models.Process{}, nil
+	}
+	return []models.Process{{PID: svc.pid, Command: svc.command}}, nil
+}
+
+// LogCounts reports one error for failed services and nothing otherwise
+func (p *MockProvider) LogCounts(ctx context.Context, name string, scope models.Scope, since time.Time) (models.LogCounts, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	svc, err := p.lookup(name, scope)
+	if err != nil {
+		return models.LogCounts{}, err
+	}
+	if svc.service.Status == models.StatusFailed {
+		return models.LogCounts{Errors: 1}, nil
+	}
+	return models.LogCounts{}, nil
+}
+
+func (p *MockProvider) StreamLogs(ctx context.Context, name string, scope models.Scope, opts models.LogStreamOptions) (<-chan string, error) {
+	ch := make(chan string, 100)
+	err := p.followLog(ctx, name, scope, opts, func() { close(ch) }, func(entry models.LogEntry) bool {
+		select {
+		case <-ctx.Done():
+			return false
+		case ch <- entry.Raw:
+			return true
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
+
+func (p *MockProvider) StreamLogEntries(ctx context.Context, name string, scope models.Scope, opts models.LogStreamOptions) (<-chan models.LogEntry, error) {
+	ch := make(chan models.LogEntry, 100)
+	err := p.followLog(ctx, name, scope, opts, func() { close(ch) }, func(entry models.LogEntry) bool {
+		select {
+		case <-ctx.Done():
+			return false
+		case ch <- entry:
+			return true
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
+
+// followLog sends opts.History synthetic entries backdated one interval
+// apart, then a new one every interval until emit returns false, then calls
+// done. Entries below opts.Level are skipped.
+func (p *MockProvider) followLog(ctx context.Context, name string, scope models.Scope, opts models.LogStreamOptions, done func(), emit func(models.LogEntry) bool) error {
+	p.mu.Lock()
+	_, err := p.lookup(name, scope)
+	p.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		defer done()
+		seq := 0
+		next := func(t time.Time) models.LogEntry {
+			msg := mockLogMessages[seq%len(mockLogMessages)]
+			seq++
+			entry := models.LogEntry{Time: t.UTC(), Level: msg.level, Message: msg.message}
+			entry.Raw = fmt.Sprintf("%s %s[%s]: %s", t.Format("Jan 02 15:04:05"), name, msg.level, msg.message)
+			return entry
+		}
+
+		now := time.Now()
+		for i := opts.History; i > 0; i-- {
+			entry := next(now.Add(-time.Duration(i) * p.logInterval))
+			if models.LogLevelAtLeast(entry.Level, opts.Level) && !emit(entry) {
+				return
+			}
+		}
+
+		ticker := time.NewTicker(p.logInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case t := <-ticker.C:
+				entry := next(t)
+				if models.LogLevelAtLeast(entry.Level, opts.Level) && !emit(entry) {
+					return
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func (p *MockProvider) ServiceExists(ctx context.Context, name string, scope models.Scope) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, ok := p.services[scope][name]
+	return ok, nil
+}
+
+// commandLine joins a program and its arguments for display
+func commandLine(program string, args []string) string {
+	return strings.Join(append([]string{program}, args...), " ")
+}
+
+func (p *MockProvider) CreateService(ctx context.Context, config models.ServiceConfig, scope models.Scope) error {
+	if err := validateRestart(config); err != nil {
+		return err
+	}
+	if !models.ValidServiceType(config.Type) {
+		return fmt.Errorf("unknown service type %q", config.Type)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.services[scope][config.Name]; ok {
+		return fmt.Errorf("service %s %w", config.Name, ErrAlreadyExists)
+	}
+	service := models.Service{Name: config.Name, Description: config.Description, Status: models.StatusStopped, Enabled: config.RunAtLoad}
+	if config.Schedule != "" {
+		service.Type = models.TypeTimer
+	} else if config.RunAtLoad {
+		service.Status = models.StatusRunning
+	}
+	p.add(scope, service, commandLine(config.Program, config.Arguments))
+	return nil
+}
+
+func (p *MockProvider) DeleteService(ctx context.Context, name string, scope models.Scope) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, err := p.lookup(name, scope); err != nil {
+		return err
+	}
+	delete(p.services[scope], name)
+	return nil
+}
+
+// RunTransient adds a running, disabled service under a generated name
+func (p *MockProvider) RunTransient(ctx context.Context, config models.ServiceConfig, scope models.Scope) (string, error) {
+	name := transientName()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	service := models.Service{Name: name, Description: config.Description, Status: models.StatusRunning}
+	p.add(scope, service, commandLine(config.Program, config.Arguments))
+	return name, nil
+}
+
+// CreateTimer adds an enabled timer service; the schedule isn't acted on
+func (p *MockProvider) CreateTimer(ctx context.Context, config models.TimerConfig, scope models.Scope) ([]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.services[scope][config.Name]; ok {
+		return nil, fmt.Errorf("service %s %w", config.Name, ErrAlreadyExists)
+	}
+	service := models.Service{
+		Name:        config.Name,
+		Description: "Runs " + config.OnCalendar,
+		Status:      models.StatusStopped,
+		Enabled:     true,
+		Type:        models.TypeTimer,
+	}
+	p.add(scope, service, commandLine(config.Program, config.Arguments))
+	return []string{config.Name}, nil
+}
+
+func (p *MockProvider) DeleteTimer(ctx context.Context, name string, scope models.Scope) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	svc, ok := p.services[scope][name]
+	if !ok || svc.service.Type != models.TypeTimer {
+		return fmt.Errorf("timer %w: %s", ErrNotFound, name)
+	}
+	delete(p.services[scope], name)
+	return nil
+}
@@ -0,0 +1,81 @@
+package platform
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"autorun/internal/models"
+)
+
+func TestMockProvider_Lifecycle(t *testing.T) {
+	p := NewMockProvider()
+	config := models.ServiceConfig{Name: "demo", Program: "/usr/bin/demo", Arguments: []string{"-v"}}
+
+	if err := p.CreateService(t.Context(), config, models.ScopeUser); err != nil {
+		t.Fatalf("CreateService: %v", err)
+	}
+	if err := p.CreateService(t.Context(), config, models.ScopeUser); !errors.Is(err, ErrAlreadyExists) {
+		t.Fatalf("expected ErrAlreadyExists, got %v", err)
+	}
+	if err := p.Start(t.Context(), "demo", models.ScopeUser); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	svc, err := p.GetService(t.Context(), "demo", models.ScopeUser)
+	if err != nil || svc.Status != models.StatusRunning {
+		t.Fatalf("expected demo running, got %+v, %v", svc, err)
+	}
+	procs, err := p.Processes(t.Context(), "demo", models.ScopeUser)
+	if err != nil || len(procs) != 1 || procs[0].Command != "/usr/bin/demo -v" {
+		t.Fatalf("unexpected processes %+v, %v", procs, err)
+	}
+
+	if err := p.Stop(t.Context(), "demo", models.ScopeUser); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	states, _ := p.ServiceStates(t.Context(), []string{"demo", "missing"}, models.ScopeUser)
+	if states["demo"].Status != models.StatusStopped || !states["missing"].NotFound {
+		t.Fatalf("unexpected states %+v", states)
+	}
+
+	if err := p.DeleteService(t.Context(), "demo", models.ScopeUser); err != nil {
+		t.Fatalf("DeleteService: %v", err)
+	}
+	if err := p.Start(t.Context(), "demo", models.ScopeUser); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound after delete, got %v", err)
+	}
+	if exists, _ := p.ServiceExists(t.Context(), "nginx", models.ScopeSystem); !exists {
+		t.Fatal("expected the seeded nginx service")
+	}
+}
+
+func TestMockProvider_StreamLogEntries(t *testing.T) {
+	p := NewMockProvider()
+	p.logInterval = time.Millisecond
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	ch, err := p.StreamLogEntries(ctx, "nginx", models.ScopeSystem, models.LogStreamOptions{Level: "warning", History: len(mockLogMessages)})
+	if err != nil {
+		t.Fatalf("StreamLogEntries: %v", err)
+	}
+
+	// The history holds one warning and one error; following adds more
+	for range 4 {
+		select {
+		case entry := <-ch:
+			if !models.LogLevelAtLeast(entry.Level, "warning") {
+				t.Fatalf("expected warning or worse, got %+v", entry)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for log entries")
+		}
+	}
+	cancel()
+	waitClosed(t, ch, 5*time.Second)
+
+	if _, err := p.StreamLogs(t.Context(), "missing", models.ScopeSystem, models.LogStreamOptions{}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
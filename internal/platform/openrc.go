@@ -0,0 +1,763 @@
+package platform
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"autorun/internal/logger"
+	"autorun/internal/models"
+)
+
+// openrcRunlevel is the runlevel Enable adds services to
+const openrcRunlevel = "default"
+
+// OpenRCProvider implements ServiceProvider for Linux systems running OpenRC
+// (Alpine, Gentoo). OpenRC only manages system services, so the user scope
+// is always empty.
+type OpenRCProvider struct {
+	runner   CommandRunner
+	timeouts Timeouts
+
+	// initDir and confDir hold init scripts and their settings; cgroupRoot
+	// and procRoot are where service processes are looked up. Tests point
+	// them at temporary directories.
+	initDir    string
+	confDir    string
+	cgroupRoot string
+	procRoot   string
+
+	// tailCommand builds the `tail` command for log streams; nil runs the
+	// real one. Tests substitute a stand-in process.
+	tailCommand commandFunc
+}
+
+// NewOpenRCProvider creates a new OpenRC provider
+func NewOpenRCProvider(opts Options) (*OpenRCProvider, error) {
+	return &OpenRCProvider{
+		runner:     opts.runner(),
+		timeouts:   opts.Timeouts,
+		initDir:    "/etc/init.d",
+		confDir:    "/etc/conf.d",
+		cgroupRoot: "/sys/fs/cgroup",
+		procRoot:   "/proc",
+	}, nil
+}
+
+func (p *OpenRCProvider) Name() string {
+	return "openrc"
+}
+
+// Capabilities reports OpenRC's feature set. Dependencies map to depend(),
+// hooks to start_pre() and stop_post(), and `rc-service zap` resets a
+// crashed service; there are no timers, masks or API-driven reloads. Logs
+// are tailed from files when a service writes one.
+func (p *OpenRCProvider) Capabilities() models.Capabilities {
+	return models.Capabilities{
+		Dependencies: true,
+		Hooks:        true,
+		ResetFailed:  true,
+		Logs:         true,
+	}
+}
+
+// systemOnly rejects the user scope on init systems that have no
+// equivalent for it
+func systemOnly(platform string, scope models.Scope) error {
+	if scope != models.ScopeSystem {
+		return notSupported("%s only manages system services", platform)
+	}
+	return nil
+}
+
+// rc runs an OpenRC command, bounded by the timeout for op
+func (p *OpenRCProvider) rc(ctx context.Context, op, name string, args ...string) ([]byte, error) {
+	return runCommand(ctx, p.runner, p.timeouts, op, name, args...)
+}
+
+// serviceList returns the state of every init script, keyed by name, from
+// `rc-status --servicelist`
+func (p *OpenRCProvider) serviceList(ctx context.Context) (map[string]string, []string, error) {
+	output, err := p.rc(ctx, OpList, "rc-status", "--servicelist", "--nocolor")
+	if err != nil {
+		return nil, nil, newCommandError(err, "rc-status failed: "+strings.TrimSpace(commandOutput(output, err)))
+	}
+	states, names := parseRCStatus(string(output))
+	return states, names, nil
+}
+
+// parseRCStatus parses `rc-status --servicelist` output, e.g.
+//
+//	sshd                                                    [  started  ]
+//	nginx                                                   [  started 01:02:03 (0) ]
+//	crond                                                   [  stopped  ]
+//
+// into each service's state word and the names in listed order
+func parseRCStatus(output string) (map[string]string, []string) {
+	states := make(map[string]string)
+	var names []string
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		open := strings.LastIndex(line, "[")
+		if open < 0 || !strings.HasSuffix(strings.TrimSpace(line), "]") {
+			continue
+		}
+		name := strings.TrimSpace(line[:open])
+		state := strings.Fields(strings.Trim(strings.TrimSpace(line[open:]), "[]"))
+		if name == "" || strings.ContainsAny(name, " \t") || len(state) == 0 {
+			continue
+		}
+		if _, ok := states[name]; !ok {
+			names = append(names, name)
+		}
+		states[name] = state[0]
+	}
+	return states, names
+}
+
+// runlevels returns the runlevels each service is added to, from
+// `rc-update show`
+func (p *OpenRCProvider) runlevels(ctx context.Context) (map[string][]string, error) {
+	output, err := p.rc(ctx, OpList, "rc-update", "show", "--nocolor")
+	if err != nil {
+		return nil, newCommandError(err, "rc-update show failed: "+strings.TrimSpace(commandOutput(output, err)))
+	}
+	return parseRCUpdateShow(string(output)), nil
+}
+
+// parseRCUpdateShow parses `rc-update show` output, e.g.
+//
+//	    sshd | default
+//	    udev | sysinit
+//	hostname | boot
+func parseRCUpdateShow(output string) map[string][]string {
+	levels := make(map[string][]string)
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		name, rest, ok := strings.Cut(scanner.Text(), "|")
+		if !ok {
+			continue
+		}
+		name = strings.TrimSpace(name)
+		if fields := strings.Fields(rest); name != "" && len(fields) > 0 {
+			levels[name] = fields
+		}
+	}
+	return levels
+}
+
+// openrcStatus maps an OpenRC service state to a service status
+func openrcStatus(state string) string {
+	switch state {
+	case "started":
+		return models.StatusRunning
+	case "stopped":
+		return models.StatusStopped
+	case "crashed", "failed":
+		return models.StatusFailed
+	default:
+		// starting, stopping, inactive, scheduled, ...
+		return models.StatusUnknown
+	}
+}
+
+func (p *OpenRCProvider) ListServices(ctx context.Context, scope models.Scope) ([]models.Service, error) {
+	if scope != models.ScopeSystem {
+		return []models.Service{}, nil
+	}
+
+	states, names, err := p.serviceList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	levels, err := p.runlevels(ctx)
+	if err != nil {
+		logger.Warn("failed to read runlevels", "error", err)
+	}
+
+	services := make([]models.Service, 0, len(names))
+	for _, name := range names {
+		services = append(services, models.Service{
+			Name:        name,
+			DisplayName: name,
+			Status:      openrcStatus(states[name]),
+			Enabled:     len(levels[name]) > 0,
+			Scope:       scope,
+			Description: p.scriptSetting(name, "description"),
+			Type:        models.TypeService,
+		})
+	}
+	return services, nil
+}
+
+func (p *OpenRCProvider) GetService(ctx context.Context, name string, scope models.Scope) (*models.Service, error) {
+	if err := systemOnly("OpenRC", scope); err != nil {
+		return nil, err
+	}
+
+	output, err := p.rc(ctx, OpStatus, "rc-service", name, "status")
+	text := commandOutput(output, err)
+	if err != nil && notFoundMessage(text) {
+		return nil, fmt.Errorf("service %w: %s", ErrNotFound, name)
+	}
+	// status exits non-zero for stopped and crashed services but still
+	// prints their state
+	state, ok := parseRCServiceStatus(text)
+	if !ok {
+		return nil, newCommandError(err, "rc-service status failed: "+strings.TrimSpace(text))
+	}
+
+	levels, err := p.runlevels(ctx)
+	if err != nil {
+		logger.Warn("failed to read runlevels", "error", err)
+	}
+
+	svc := &models.Service{
+		Name:        name,
+		DisplayName: name,
+		Status:      openrcStatus(state),
+		Enabled:     len(levels[name]) > 0,
+		Scope:       scope,
+		Description: p.scriptSetting(name, "description"),
+		Type:        models.TypeService,
+		RunAs:       "root",
+	}
+	if u := p.scriptSetting(name, "command_user"); u != "" {
+		svc.RunAs, _, _ = strings.Cut(u, ":")
+	}
+	return svc, nil
+}
+
+// parseRCServiceStatus reads the state from `rc-service <name> status`
+// output, e.g. " * status: started"
+func parseRCServiceStatus(output string) (string, bool) {
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		_, state, ok := strings.Cut(scanner.Text(), "status:")
+		if fields := strings.Fields(state); ok && len(fields) > 0 {
+			return fields[0], true
+		}
+	}
+	return "", false
+}
+
+// ServiceStates reads every service's state with a single `rc-status`
+func (p *OpenRCProvider) ServiceStates(ctx context.Context, names []string, scope models.Scope) (map[string]models.ServiceState, error) {
+	states := make(map[string]models.ServiceState, len(names))
+	if len(names) == 0 {
+		return states, nil
+	}
+	if scope != models.ScopeSystem {
+		for _, name := range names {
+			states[name] = models.ServiceState{Status: models.StatusUnknown, NotFound: true}
+		}
+		return states, nil
+	}
+
+	current, _, err := p.serviceList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	levels, err := p.runlevels(ctx)
+	if err != nil {
+		logger.Warn("failed to read runlevels", "error", err)
+	}
+
+	for _, name := range names {
+		state, ok := current[name]
+		if !ok {
+			states[name] = models.ServiceState{Status: models.StatusUnknown, NotFound: true}
+			continue
+		}
+		states[name] = models.ServiceState{
+			Status:  openrcStatus(state),
+			Enabled: len(levels[name]) > 0,
+		}
+	}
+	return states, nil
+}
+
+// runAction runs an rc-service or rc-update command that changes a service
+func (p *OpenRCProvider) runAction(ctx context.Context, scope models.Scope, command string, args ...string) error {
+	if err := systemOnly("OpenRC", scope); err != nil {
+		return err
+	}
+
+	logger.Debug("executing "+command, "args", args)
+	if output, err := p.rc(ctx, OpAction, command, args...); err != nil {
+		text := strings.TrimSpace(commandOutput(output, err))
+		logger.Error(command+" failed", "args", args, "error", err, "output", text)
+		if text == "" {
+			text = err.Error()
+		}
+		return newCommandError(err, fmt.Sprintf("%s %s failed: %s", command, strings.Join(args, " "), text))
+	}
+	return nil
+}
+
+func (p *OpenRCProvider) Start(ctx context.Context, name string, scope models.Scope) error {
+	return p.runAction(ctx, scope, "rc-service", name, "start")
+}
+
+func (p *OpenRCProvider) Stop(ctx context.Context, name string, scope models.Scope) error {
+	return p.runAction(ctx, scope, "rc-service", name, "stop")
+}
+
+func (p *OpenRCProvider) Restart(ctx context.Context, name string, scope models.Scope) error {
+	return p.runAction(ctx, scope, "rc-service", name, "restart")
+}
+
+// Enable adds the service to the default runlevel
+func (p *OpenRCProvider) Enable(ctx context.Context, name string, scope models.Scope) error {
+	return p.runAction(ctx, scope, "rc-update", "add", name, openrcRunlevel)
+}
+
+// Disable removes the service from every runlevel it was added to
+func (p *OpenRCProvider) Disable(ctx context.Context, name string, scope models.Scope) error {
+	return p.runAction(ctx, scope, "rc-update", "--all", "delete", name)
+}
+
+// ResetFailed runs `rc-service zap`, which resets a crashed service's state
+// to stopped so it can be started again
+func (p *OpenRCProvider) ResetFailed(ctx context.Context, name string, scope models.Scope) error {
+	return p.runAction(ctx, scope, "rc-service", name, "zap")
+}
+
+// Processes lists the processes in the service's cgroup, which openrc-run
+// creates as openrc.<name> on both the unified and the hybrid hierarchy
+func (p *OpenRCProvider) Processes(ctx context.Context, name string, scope models.Scope) ([]models.Process, error) {
+	if err := systemOnly("OpenRC", scope); err != nil {
+		return nil, err
+	}
+	if exists, err := fileExists(filepath.Join(p.initDir, name)); err != nil {
+		return nil, err
+	} else if !exists {
+		return nil, fmt.Errorf("service %w: %s", ErrNotFound, name)
+	}
+
+	processes := []models.Process{}
+	for _, dir := range []string{"openrc." + name, filepath.Join("unified", "openrc."+name)} {
+		data, err := os.ReadFile(filepath.Join(p.cgroupRoot, dir, "cgroup.procs"))
+		if err != nil {
+			continue
+		}
+		for _, field := range strings.Fields(string(data)) {
+			pid, err := strconv.Atoi(field)
+			if err != nil {
+				continue
+			}
+			processes = append(processes, models.Process{PID: pid, Command: p.processCommand(pid)})
+		}
+		break
+	}
+	return processes, nil
+}
+
+// processCommand returns a process's command line from /proc. busybox ps,
+// as found on Alpine, can't select processes by PID.
+func (p *OpenRCProvider) processCommand(pid int) string {
+	data, err := os.ReadFile(filepath.Join(p.procRoot, strconv.Itoa(pid), "cmdline"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(strings.ReplaceAll(string(data), "\x00", " "))
+}
+
+// LogCounts is not supported: OpenRC services log to plain files that carry
+// no levels
+func (p *OpenRCProvider) LogCounts(ctx context.Context, name string, scope models.Scope, since time.Time) (models.LogCounts, error) {
+	return models.LogCounts{}, notSupported("OpenRC log files carry no log levels")
+}
+
+// scriptAssignment matches a top-level shell assignment such as
+// output_log="/var/log/web.log"
+var scriptAssignment = regexp.MustCompile(`^\s*([A-Za-z_][A-Za-z0-9_]*)=(.*)$`)
+
+// scriptSetting returns the value a service's conf.d file or init script
+// assigns to key, with conf.d taking precedence as it does for openrc-run.
+// Only literal values are understood; anything that needs the shell to
+// expand it is ignored.
+func (p *OpenRCProvider) scriptSetting(name, key string) string {
+	for _, path := range []string{filepath.Join(p.confDir, name), filepath.Join(p.initDir, name)} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if value, ok := parseScriptSetting(string(data), key); ok {
+			return value
+		}
+	}
+	return ""
+}
+
+// parseScriptSetting finds the last literal assignment to key in a shell
+// script. Quoted values are unquoted; values that expand variables or run
+// commands are skipped.
+func parseScriptSetting(script, key string) (string, bool) {
+	var value string
+	found := false
+
+	scanner := bufio.NewScanner(strings.NewReader(script))
+	for scanner.Scan() {
+		m := scriptAssignment.FindStringSubmatch(scanner.Text())
+		if m == nil || m[1] != key {
+			continue
+		}
+		raw := strings.TrimSpace(m[2])
+		switch {
+		case len(raw) >= 2 && raw[0] == '\'' && raw[len(raw)-1] == '\'':
+			value, found = raw[1:len(raw)-1], true
+		case len(raw) >= 2 && raw[0] == '"' && raw[len(raw)-1] == '"':
+			inner := raw[1 : len(raw)-1]
+			if !strings.ContainsAny(inner, "$`\\") {
+				value, found = inner, true
+			}
+		case !strings.ContainsAny(raw, "$`\\'\" \t;&|"):
+			value, found = raw, true
+		}
+	}
+	return value, found
+}
+
+// logFiles returns the log files of a service: the output_log and
+// error_log its init script sets, else /var/log/<name>.log if it exists
+func (p *OpenRCProvider) logFiles(name string) []string {
+	var files []string
+	for _, key := range []string{"output_log", "error_log"} {
+		if path := p.scriptSetting(name, key); filepath.IsAbs(path) && !slices.Contains(files, path) {
+			files = append(files, path)
+		}
+	}
+	if len(files) == 0 {
+		path := filepath.Join("/var/log", name+".log")
+		if exists, _ := fileExists(path); exists {
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+// streamFiles returns the log files to follow for a service
+func (p *OpenRCProvider) streamFiles(name string, scope models.Scope) ([]string, error) {
+	if err := systemOnly("OpenRC", scope); err != nil {
+		return nil, err
+	}
+	files := p.logFiles(name)
+	if len(files) == 0 {
+		return nil, notSupported("no log file found for %s: set output_log in %s", name, filepath.Join(p.confDir, name))
+	}
+	return files, nil
+}
+
+func (p *OpenRCProvider) StreamLogs(ctx context.Context, name string, scope models.Scope, opts models.LogStreamOptions) (<-chan string, error) {
+	files, err := p.streamFiles(name, scope)
+	if err != nil {
+		return nil, err
+	}
+	return tailLines(ctx, p.tailCommand, files, opts)
+}
+
+func (p *OpenRCProvider) StreamLogEntries(ctx context.Context, name string, scope models.Scope, opts models.LogStreamOptions) (<-chan models.LogEntry, error) {
+	files, err := p.streamFiles(name, scope)
+	if err != nil {
+		return nil, err
+	}
+	return tailEntries(ctx, p.tailCommand, files, opts)
+}
+
+// ServiceExists reports whether an init script named name exists
+func (p *OpenRCProvider) ServiceExists(ctx context.Context, name string, scope models.Scope) (bool, error) {
+	if scope != models.ScopeSystem {
+		return false, nil
+	}
+	return fileExists(filepath.Join(p.initDir, name))
+}
+
+// openrcTypes are the service types an init script can express: simple and
+// exec run in the background under start-stop-daemon, forking daemonizes
+// itself
+var openrcTypes = []string{"", "simple", "exec", "forking"}
+
+// CreateService writes an openrc-run init script to /etc/init.d
+func (p *OpenRCProvider) CreateService(ctx context.Context, config models.ServiceConfig, scope models.Scope) error {
+	logger.Debug("creating openrc service", "name", config.Name, "program", config.Program, "scope", scope)
+
+	if err := systemOnly("OpenRC", scope); err != nil {
+		return err
+	}
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	if !models.ValidServiceType(config.Type) {
+		return fmt.Errorf("unknown service type %q (expected one of %s)", config.Type, strings.Join(models.ServiceTypes, ", "))
+	}
+	if !slices.Contains(openrcTypes, config.Type) {
+		return notSupported("service type %s is not supported by OpenRC", config.Type)
+	}
+	if config.Schedule != "" {
+		return notSupported("schedules are not supported by OpenRC; use cron")
+	}
+	if err := validateRunAs(config, scope); err != nil {
+		return err
+	}
+	if err := validateRestart(config); err != nil {
+		return err
+	}
+	if policy, _ := restartPolicy(config); policy != "" && policy != "no" && config.Type == "forking" {
+		return notSupported("forking services cannot be restarted by supervise-daemon")
+	}
+	if err := validateEnvironmentFile(config); err != nil {
+		return err
+	}
+	// openrc-run passes these through eval, so they must not need quoting
+	for _, path := range []string{config.Program, config.WorkingDirectory, config.StandardOutPath, config.StandardErrorPath} {
+		if path != "" && !shellSafeWord.MatchString(path) {
+			return fmt.Errorf("path %q cannot be used in an OpenRC init script (allowed: letters, digits and _ @ %% + = : , . / -)", path)
+		}
+	}
+	for _, dep := range slices.Concat(config.After, config.Requires, config.Wants) {
+		if _, err := openrcDependency(dep); err != nil {
+			return err
+		}
+	}
+	for _, hook := range slices.Concat(config.ExecStartPre, config.ExecStopPost) {
+		if _, err := shellHookLine(hook); err != nil {
+			return fmt.Errorf("invalid hook command %q: %w", hook, err)
+		}
+	}
+
+	scriptPath := filepath.Join(p.initDir, config.Name)
+	if exists, err := fileExists(scriptPath); err != nil {
+		return err
+	} else if exists {
+		logger.Warn("service already exists", "name", config.Name, "path", scriptPath)
+		return fmt.Errorf("service %s %w", config.Name, ErrAlreadyExists)
+	}
+
+	logger.Debug("writing init script", "path", scriptPath)
+	if err := os.WriteFile(scriptPath, []byte(generateInitScript(config)), 0755); err != nil {
+		logger.Error("failed to write init script", "path", scriptPath, "error", err)
+		return fmt.Errorf("failed to write init script: %w", err)
+	}
+
+	if config.RunAtLoad {
+		logger.Debug("enabling and starting service", "name", config.Name)
+		if err := p.Enable(ctx, config.Name, scope); err != nil {
+			logger.Error("failed to enable service", "name", config.Name, "error", err)
+			return fmt.Errorf("failed to enable service: %w", err)
+		}
+		if err := p.Start(ctx, config.Name, scope); err != nil {
+			logger.Error("failed to start service", "name", config.Name, "error", err)
+			return fmt.Errorf("failed to start service: %w", err)
+		}
+	}
+
+	logger.Debug("service created successfully", "name", config.Name)
+	return nil
+}
+
+// shellSafeWord matches words that mean the same to the shell unquoted
+var shellSafeWord = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// shellWord quotes word for the shell unless it is safe as it is
+func shellWord(word string) string {
+	if shellSafeWord.MatchString(word) {
+		return word
+	}
+	return "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+}
+
+// shellCommandLine joins words into a shell command line
+func shellCommandLine(words []string) string {
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		quoted[i] = shellWord(word)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// shellHookLine converts a hook command such as `mkdir -p "/run/my app"`
+// into a shell command line, splitting words the same way as for systemd
+func shellHookLine(command string) (string, error) {
+	words, err := splitUnitWords(command)
+	if err != nil {
+		return "", err
+	}
+	if len(words) == 0 {
+		return "", fmt.Errorf("empty command")
+	}
+	return shellCommandLine(words), nil
+}
+
+// openrcDependency converts a systemd-style dependency into an OpenRC
+// service name: web.service becomes web and network.target becomes net.
+// Other unit types have no counterpart.
+func openrcDependency(dep string) (string, error) {
+	if dep == "network.target" || dep == "network-online.target" {
+		return "net", nil
+	}
+	name := strings.TrimSuffix(dep, ".service")
+	if err := models.ValidateServiceName(name); err != nil || slices.ContainsFunc(unitSuffixes, func(suffix string) bool {
+		return strings.HasSuffix(name, suffix)
+	}) {
+		return "", fmt.Errorf("invalid dependency %q: expected an OpenRC service name", dep)
+	}
+	return name, nil
+}
+
+// generateInitScript creates the openrc-run script for a service
+// configuration. command_args is evaluated by openrc-run, so its words are
+// quoted once more inside the assignment.
+func generateInitScript(config models.ServiceConfig) string {
+	var sb strings.Builder
+	sb.WriteString("#!/sbin/openrc-run\n\n")
+
+	description := config.Description
+	if description == "" {
+		description = config.Name + " service"
+	}
+	sb.WriteString(fmt.Sprintf("description=%s\n", shellWord(description)))
+	sb.WriteString(fmt.Sprintf("command=%s\n", config.Program))
+	if len(config.Arguments) > 0 {
+		sb.WriteString(fmt.Sprintf("command_args=%s\n", shellWord(shellCommandLine(config.Arguments))))
+	}
+
+	policy, sec := restartPolicy(config)
+	switch {
+	case policy != "" && policy != "no":
+		// supervise-daemon respawns on every exit, so on-failure behaves
+		// like always
+		sb.WriteString("supervisor=supervise-daemon\n")
+		sb.WriteString("respawn_max=0\n")
+		if sec > 0 {
+			sb.WriteString(fmt.Sprintf("respawn_delay=%d\n", sec))
+		}
+	case config.Type != "forking":
+		sb.WriteString("command_background=true\n")
+		sb.WriteString("pidfile=\"/run/${RC_SVCNAME}.pid\"\n")
+	}
+
+	if config.WorkingDirectory != "" {
+		sb.WriteString(fmt.Sprintf("directory=%s\n", config.WorkingDirectory))
+	}
+	if config.User != "" || config.Group != "" {
+		account := config.User
+		if account == "" {
+			account = "root"
+		}
+		if config.Group != "" {
+			account += ":" + config.Group
+		}
+		sb.WriteString(fmt.Sprintf("command_user=%s\n", shellWord(account)))
+	}
+	if config.StandardOutPath != "" {
+		sb.WriteString(fmt.Sprintf("output_log=%s\n", config.StandardOutPath))
+	}
+	if config.StandardErrorPath != "" {
+		sb.WriteString(fmt.Sprintf("error_log=%s\n", config.StandardErrorPath))
+	}
+
+	// The script is sourced by openrc-run, so exported variables reach the
+	// command. EnvironmentFile is sourced too, which needs its values to be
+	// valid shell.
+	if config.EnvironmentFile != "" || len(config.Environment) > 0 {
+		sb.WriteString("\n")
+	}
+	if config.EnvironmentFile != "" {
+		sb.WriteString(fmt.Sprintf("set -a\n. %s\nset +a\n", shellWord(config.EnvironmentFile)))
+	}
+	for _, key := range slices.Sorted(maps.Keys(config.Environment)) {
+		sb.WriteString(fmt.Sprintf("export %s=%s\n", key, shellWord(config.Environment[key])))
+	}
+
+	sb.WriteString("\ndepend() {\n")
+	writeDepend(&sb, "need", config.Requires)
+	writeDepend(&sb, "use", config.Wants)
+	writeDepend(&sb, "after", serviceAfter(config))
+	sb.WriteString("}\n")
+
+	writeHookFunction(&sb, "start_pre", config.ExecStartPre)
+	writeHookFunction(&sb, "stop_post", config.ExecStopPost)
+
+	return sb.String()
+}
+
+// writeDepend writes one depend() line for deps (validated by CreateService)
+func writeDepend(sb *strings.Builder, keyword string, deps []string) {
+	var names []string
+	for _, dep := range deps {
+		if name, err := openrcDependency(dep); err == nil && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	if len(names) > 0 {
+		sb.WriteString(fmt.Sprintf("\t%s %s\n", keyword, strings.Join(names, " ")))
+	}
+}
+
+// writeHookFunction writes an openrc-run hook function running commands in
+// order and failing at the first that fails, as systemd does
+func writeHookFunction(sb *strings.Builder, function string, commands []string) {
+	if len(commands) == 0 {
+		return
+	}
+	sb.WriteString(fmt.Sprintf("\n%s() {\n", function))
+	for _, command := range commands {
+		if line, err := shellHookLine(command); err == nil {
+			sb.WriteString(fmt.Sprintf("\t%s || return 1\n", line))
+		}
+	}
+	sb.WriteString("}\n")
+}
+
+// DeleteService stops and disables a service and removes its init script
+func (p *OpenRCProvider) DeleteService(ctx context.Context, name string, scope models.Scope) error {
+	logger.Debug("deleting openrc service", "name", name, "scope", scope)
+	if err := systemOnly("OpenRC", scope); err != nil {
+		return err
+	}
+
+	scriptPath := filepath.Join(p.initDir, name)
+	if exists, err := fileExists(scriptPath); err != nil {
+		return err
+	} else if !exists {
+		logger.Error("service not found for deletion", "name", name, "path", scriptPath)
+		return fmt.Errorf("service %w: %s", ErrNotFound, name)
+	}
+
+	// Stop and disable first (ignore errors if not running or not enabled)
+	_ = p.Stop(ctx, name, scope)
+	_ = p.Disable(ctx, name, scope)
+
+	logger.Debug("removing init script", "path", scriptPath)
+	if err := os.Remove(scriptPath); err != nil {
+		logger.Error("failed to delete init script", "path", scriptPath, "error", err)
+		return fmt.Errorf("failed to delete init script: %w", err)
+	}
+	return nil
+}
+
+// RunTransient is not supported: every OpenRC service needs an init script
+func (p *OpenRCProvider) RunTransient(ctx context.Context, config models.ServiceConfig, scope models.Scope) (string, error) {
+	return "", notSupported("transient services are not supported by OpenRC")
+}
+
+// CreateTimer is not supported: OpenRC has no scheduler
+func (p *OpenRCProvider) CreateTimer(ctx context.Context, config models.TimerConfig, scope models.Scope) ([]string, error) {
+	return nil, notSupported("timers are not supported by OpenRC; use cron")
+}
+
+// DeleteTimer is not supported: OpenRC has no scheduler
+func (p *OpenRCProvider) DeleteTimer(ctx context.Context, name string, scope models.Scope) error {
+	return notSupported("timers are not supported by OpenRC; use cron")
+}
@@ -0,0 +1,367 @@
+package platform
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"autorun/internal/models"
+)
+
+func newTestOpenRCProvider(t *testing.T, runner *fakeRunner) *OpenRCProvider {
+	t.Helper()
+	root := t.TempDir()
+	p := &OpenRCProvider{
+		runner:     runner,
+		initDir:    filepath.Join(root, "init.d"),
+		confDir:    filepath.Join(root, "conf.d"),
+		cgroupRoot: filepath.Join(root, "cgroup"),
+		procRoot:   filepath.Join(root, "proc"),
+	}
+	for _, dir := range []string{p.initDir, p.confDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return p
+}
+
+func TestParseRCStatus(t *testing.T) {
+	output := ` sshd                                                    [  started  ]
+ nginx                                                   [  started 01:02:03 (0) ]
+ crond                                                   [  stopped  ]
+ web                                                     [  crashed  ]
+`
+	states, names := parseRCStatus(output)
+
+	if want := []string{"sshd", "nginx", "crond", "web"}; !slices.Equal(names, want) {
+		t.Fatalf("expected names %v, got %v", want, names)
+	}
+	want := map[string]string{"sshd": "started", "nginx": "started", "crond": "stopped", "web": "crashed"}
+	if !reflect.DeepEqual(states, want) {
+		t.Fatalf("expected states %v, got %v", want, states)
+	}
+}
+
+func TestParseRCUpdateShow(t *testing.T) {
+	output := `                sshd |      default
+                udev | sysinit
+            hostname | boot
+`
+	want := map[string][]string{"sshd": {"default"}, "udev": {"sysinit"}, "hostname": {"boot"}}
+	if got := parseRCUpdateShow(output); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestParseScriptSetting(t *testing.T) {
+	script := `#!/sbin/openrc-run
+description="Web server"
+command=/usr/bin/web
+output_log='/var/log/web.log'
+error_log="/var/log/${RC_SVCNAME}.err"
+`
+	cases := []struct {
+		key    string
+		want   string
+		wantOK bool
+	}{
+		{key: "description", want: "Web server", wantOK: true},
+		{key: "command", want: "/usr/bin/web", wantOK: true},
+		{key: "output_log", want: "/var/log/web.log", wantOK: true},
+		{key: "error_log"},
+		{key: "pidfile"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.key, func(t *testing.T) {
+			got, ok := parseScriptSetting(script, tc.key)
+			if got != tc.want || ok != tc.wantOK {
+				t.Fatalf("expected %q, %v, got %q, %v", tc.want, tc.wantOK, got, ok)
+			}
+		})
+	}
+}
+
+func TestGenerateInitScript(t *testing.T) {
+	script := generateInitScript(models.ServiceConfig{
+		Name:             "web",
+		Description:      "Web server",
+		Program:          "/usr/bin/web",
+		Arguments:        []string{"--listen", ":8080", "it's here"},
+		WorkingDirectory: "/srv/web",
+		Environment:      map[string]string{"B": "two words", "A": "1"},
+		User:             "www",
+		StandardOutPath:  "/var/log/web.log",
+		Requires:         []string{"postgresql.service"},
+		ExecStartPre:     []string{`mkdir -p "/run/my app"`},
+	})
+
+	for _, want := range []string{
+		"#!/sbin/openrc-run\n",
+		"description='Web server'\n",
+		"command=/usr/bin/web\n",
+		`command_args='--listen :8080 '\''it'\''\'\'''\''s here'\'''` + "\n",
+		"command_background=true\n",
+		"directory=/srv/web\n",
+		"command_user=www\n",
+		"output_log=/var/log/web.log\n",
+		"export A=1\nexport B='two words'\n",
+		"\tneed postgresql\n",
+		"\tafter net\n",
+		"start_pre() {\n\tmkdir -p '/run/my app' || return 1\n}\n",
+	} {
+		if !strings.Contains(script, want) {
+			t.Fatalf("expected script to contain %q, got:\n%s", want, script)
+		}
+	}
+}
+
+func TestGenerateInitScript_After(t *testing.T) {
+	script := generateInitScript(models.ServiceConfig{Name: "web", Program: "/usr/bin/web", After: []string{"postgresql.service"}})
+	if !strings.Contains(script, "\tafter net postgresql\n") {
+		t.Fatalf("expected the configured ordering after net, got:\n%s", script)
+	}
+
+	script = generateInitScript(models.ServiceConfig{Name: "web", Program: "/usr/bin/web", After: []string{"postgresql.service"}, NoDefaultAfter: true})
+	if !strings.Contains(script, "\tafter postgresql\n") {
+		t.Fatalf("expected only the configured ordering, got:\n%s", script)
+	}
+}
+
+func TestGenerateInitScript_RestartUsesSuperviseDaemon(t *testing.T) {
+	script := generateInitScript(models.ServiceConfig{Name: "web", Program: "/usr/bin/web", KeepAlive: true})
+
+	for _, want := range []string{"supervisor=supervise-daemon\n", "respawn_delay=5\n"} {
+		if !strings.Contains(script, want) {
+			t.Fatalf("expected script to contain %q, got:\n%s", want, script)
+		}
+	}
+	if strings.Contains(script, "command_background") {
+		t.Fatalf("expected supervised script not to background the command, got:\n%s", script)
+	}
+}
+
+func TestOpenRCCreateService_Validation(t *testing.T) {
+	cases := []struct {
+		name    string
+		config  models.ServiceConfig
+		scope   models.Scope
+		wantErr string
+		unsup   bool
+	}{
+		{name: "user scope", config: models.ServiceConfig{Name: "web", Program: "/usr/bin/web"}, scope: models.ScopeUser, unsup: true},
+		{name: "schedule", config: models.ServiceConfig{Name: "web", Program: "/usr/bin/web", Schedule: "daily"}, unsup: true},
+		{name: "notify type", config: models.ServiceConfig{Name: "web", Program: "/usr/bin/web", Type: "notify"}, unsup: true},
+		{name: "unsafe path", config: models.ServiceConfig{Name: "web", Program: "/opt/my app/web"}, wantErr: "cannot be used in an OpenRC init script"},
+		{name: "target dependency", config: models.ServiceConfig{Name: "web", Program: "/usr/bin/web", After: []string{"graphical.target"}}, wantErr: "invalid dependency"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := newTestOpenRCProvider(t, &fakeRunner{})
+			scope := tc.scope
+			if scope == "" {
+				scope = models.ScopeSystem
+			}
+
+			err := p.CreateService(t.Context(), tc.config, scope)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if tc.unsup && !errors.Is(err, ErrNotSupported) {
+				t.Fatalf("expected ErrNotSupported, got %v", err)
+			}
+			if tc.wantErr != "" && !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+			}
+			if entries, _ := os.ReadDir(p.initDir); len(entries) != 0 {
+				t.Fatalf("expected no init script to be written, found %d", len(entries))
+			}
+		})
+	}
+}
+
+func TestOpenRCCreateService_WritesScriptAndStarts(t *testing.T) {
+	runner := &fakeRunner{}
+	p := newTestOpenRCProvider(t, runner)
+
+	config := models.ServiceConfig{Name: "web", Program: "/usr/bin/web", RunAtLoad: true}
+	if err := p.CreateService(t.Context(), config, models.ScopeSystem); err != nil {
+		t.Fatalf("CreateService: %v", err)
+	}
+
+	info, err := os.Stat(filepath.Join(p.initDir, "web"))
+	if err != nil {
+		t.Fatalf("expected init script: %v", err)
+	}
+	if info.Mode().Perm()&0100 == 0 {
+		t.Fatalf("expected init script to be executable, mode %v", info.Mode())
+	}
+	want := []string{"rc-update add web default", "rc-service web start"}
+	if got := runner.commands(); !slices.Equal(got, want) {
+		t.Fatalf("expected commands %v, got %v", want, got)
+	}
+
+	if err := p.CreateService(t.Context(), config, models.ScopeSystem); !errors.Is(err, ErrAlreadyExists) {
+		t.Fatalf("expected ErrAlreadyExists, got %v", err)
+	}
+}
+
+func TestOpenRCActions(t *testing.T) {
+	cases := []struct {
+		name string
+		run  func(p *OpenRCProvider) error
+		want string
+	}{
+		{name: "start", run: func(p *OpenRCProvider) error { return p.Start(t.Context(), "web", models.ScopeSystem) }, want: "rc-service web start"},
+		{name: "stop", run: func(p *OpenRCProvider) error { return p.Stop(t.Context(), "web", models.ScopeSystem) }, want: "rc-service web stop"},
+		{name: "restart", run: func(p *OpenRCProvider) error { return p.Restart(t.Context(), "web", models.ScopeSystem) }, want: "rc-service web restart"},
+		{name: "enable", run: func(p *OpenRCProvider) error { return p.Enable(t.Context(), "web", models.ScopeSystem) }, want: "rc-update add web default"},
+		{name: "disable", run: func(p *OpenRCProvider) error { return p.Disable(t.Context(), "web", models.ScopeSystem) }, want: "rc-update --all delete web"},
+		{name: "reset-failed", run: func(p *OpenRCProvider) error { return p.ResetFailed(t.Context(), "web", models.ScopeSystem) }, want: "rc-service web zap"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			runner := &fakeRunner{}
+			if err := tc.run(newTestOpenRCProvider(t, runner)); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := runner.commands(); !slices.Equal(got, []string{tc.want}) {
+				t.Fatalf("expected %q, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestOpenRCActions_UserScopeNotSupported(t *testing.T) {
+	runner := &fakeRunner{}
+	p := newTestOpenRCProvider(t, runner)
+
+	if err := p.Start(t.Context(), "web", models.ScopeUser); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("expected ErrNotSupported, got %v", err)
+	}
+	services, err := p.ListServices(t.Context(), models.ScopeUser)
+	if err != nil || len(services) != 0 {
+		t.Fatalf("expected no user services, got %v, %v", services, err)
+	}
+	if len(runner.commands()) != 0 {
+		t.Fatalf("expected no commands, got %v", runner.commands())
+	}
+}
+
+func TestOpenRCGetService(t *testing.T) {
+	runner := &fakeRunner{handle: func(name string, args []string) ([]byte, error) {
+		switch {
+		case name == "rc-service" && args[0] == "web":
+			return []byte(" * status: crashed\n"), &fakeExitError{code: 32}
+		case name == "rc-service":
+			return nil, newCommandError(&fakeExitError{code: 1}, "rc-service: service `"+args[0]+"' does not exist")
+		case name == "rc-update":
+			return []byte("                 web | default\n"), nil
+		}
+		return nil, nil
+	}}
+	p := newTestOpenRCProvider(t, runner)
+	if err := os.WriteFile(filepath.Join(p.initDir, "web"), []byte("description=\"Web server\"\ncommand_user=\"www:www\"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	svc, err := p.GetService(t.Context(), "web", models.ScopeSystem)
+	if err != nil {
+		t.Fatalf("GetService: %v", err)
+	}
+	if svc.Status != models.StatusFailed || !svc.Enabled || svc.Description != "Web server" || svc.RunAs != "www" {
+		t.Fatalf("unexpected service %+v", svc)
+	}
+
+	if _, err := p.GetService(t.Context(), "missing", models.ScopeSystem); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestOpenRCProcesses(t *testing.T) {
+	p := newTestOpenRCProvider(t, &fakeRunner{})
+	if err := os.WriteFile(filepath.Join(p.initDir, "web"), nil, 0755); err != nil {
+		t.Fatal(err)
+	}
+	cgroup := filepath.Join(p.cgroupRoot, "openrc.web")
+	proc := filepath.Join(p.procRoot, "42")
+	for _, dir := range []string{cgroup, proc} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(cgroup, "cgroup.procs"), []byte("42\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(proc, "cmdline"), []byte("/usr/bin/web\x00--listen\x00:8080\x00"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	processes, err := p.Processes(t.Context(), "web", models.ScopeSystem)
+	if err != nil {
+		t.Fatalf("Processes: %v", err)
+	}
+	want := []models.Process{{PID: 42, Command: "/usr/bin/web --listen :8080"}}
+	if !reflect.DeepEqual(processes, want) {
+		t.Fatalf("expected %v, got %v", want, processes)
+	}
+
+	if _, err := p.Processes(t.Context(), "missing", models.ScopeSystem); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestOpenRCStreamLogs(t *testing.T) {
+	p := newTestOpenRCProvider(t, &fakeRunner{})
+	if err := os.WriteFile(filepath.Join(p.confDir, "web"), []byte("output_log=/var/log/web.log\nerror_log=/var/log/web.err\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var gotArgs []string
+	shell := shellLogCommand(t, "echo one; echo two")
+	p.tailCommand = func(ctx context.Context, args ...string) *exec.Cmd {
+		gotArgs = args
+		return shell(ctx, args...)
+	}
+
+	ch, err := p.StreamLogs(context.Background(), "web", models.ScopeSystem, models.LogStreamOptions{History: 10})
+	if err != nil {
+		t.Fatalf("StreamLogs: %v", err)
+	}
+	var lines []string
+	timeout := time.After(5 * time.Second)
+	for done := false; !done; {
+		select {
+		case line, ok := <-ch:
+			if !ok {
+				done = true
+				break
+			}
+			lines = append(lines, line)
+		case <-timeout:
+			t.Fatal("log stream did not end")
+		}
+	}
+
+	if want := []string{"one", "two"}; !slices.Equal(lines, want) {
+		t.Fatalf("expected lines %v, got %v", want, lines)
+	}
+	if want := []string{"-q", "-F", "-n", "10", "/var/log/web.log", "/var/log/web.err"}; !slices.Equal(gotArgs, want) {
+		t.Fatalf("expected tail args %v, got %v", want, gotArgs)
+	}
+}
+
+func TestOpenRCStreamLogs_NoLogFile(t *testing.T) {
+	p := newTestOpenRCProvider(t, &fakeRunner{})
+
+	_, err := p.StreamLogs(context.Background(), "autorun-no-such-service", models.ScopeSystem, models.LogStreamOptions{})
+	if !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("expected ErrNotSupported, got %v", err)
+	}
+}
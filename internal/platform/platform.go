@@ -1,70 +1,448 @@
 package platform
 
 import (
+	"bufio"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
+	"time"
 
 	"autorun/internal/logger"
 	"autorun/internal/models"
 )
 
+// ErrNotSupported is returned for operations the platform has no
+// equivalent for
+var ErrNotSupported = errors.New("not supported on this platform")
+
+// ErrNotFound is wrapped by errors for a service, timer or plist that
+// doesn't exist, e.g. "service not found: web"
+var ErrNotFound = errors.New("not found")
+
+// ErrAlreadyExists is wrapped by errors for creating a service or timer
+// whose name is taken, e.g. "service web already exists"
+var ErrAlreadyExists = errors.New("already exists")
+
+// ErrTimeout is wrapped by errors for a command that didn't finish within
+// its operation's timeout, e.g. a systemctl call stuck waiting on polkit
+var ErrTimeout = errors.New("timed out")
+
+// ErrPermission matches failures caused by missing privileges: file system
+// EACCES/EPERM errors, and commands refused by polkit or for not running as
+// root (see CommandError.Is). It is fs.ErrPermission so errors from the os
+// package match without being wrapped.
+var ErrPermission = fs.ErrPermission
+
+// unsupportedError describes something the platform can't do in its own
+// words while still matching ErrNotSupported
+type unsupportedError struct{ msg string }
+
+func (e unsupportedError) Error() string        { return e.msg }
+func (e unsupportedError) Is(target error) bool { return target == ErrNotSupported }
+
+// notSupported returns a formatted error that matches ErrNotSupported
+func notSupported(format string, args ...any) error {
+	return unsupportedError{msg: fmt.Sprintf(format, args...)}
+}
+
 // ServiceProvider defines the interface for platform-specific service management
 type ServiceProvider interface {
 	// Name returns the platform name (e.g., "systemd", "launchd")
 	Name() string
 
+	// Capabilities reports which optional features the platform supports
+	Capabilities() models.Capabilities
+
 	// ListServices returns all services for the given scope
-	ListServices(scope models.Scope) ([]models.Service, error)
+	ListServices(ctx context.Context, scope models.Scope) ([]models.Service, error)
 
 	// GetService returns details for a specific service
-	GetService(name string, scope models.Scope) (*models.Service, error)
+	GetService(ctx context.Context, name string, scope models.Scope) (*models.Service, error)
+
+	// ServiceStates returns the current state of each named service without
+	// listing every service. Unknown names are marked NotFound.
+	ServiceStates(ctx context.Context, names []string, scope models.Scope) (map[string]models.ServiceState, error)
 
 	// Start starts a service
-	Start(name string, scope models.Scope) error
+	Start(ctx context.Context, name string, scope models.Scope) error
 
 	// Stop stops a service
-	Stop(name string, scope models.Scope) error
+	Stop(ctx context.Context, name string, scope models.Scope) error
 
 	// Restart restarts a service
-	Restart(name string, scope models.Scope) error
+	Restart(ctx context.Context, name string, scope models.Scope) error
 
 	// Enable enables a service to start at boot
-	Enable(name string, scope models.Scope) error
+	Enable(ctx context.Context, name string, scope models.Scope) error
 
 	// Disable disables a service from starting at boot
-	Disable(name string, scope models.Scope) error
+	Disable(ctx context.Context, name string, scope models.Scope) error
+
+	// ResetFailed clears a service's failed state and start rate limit
+	// counter. Platforms without the concept return ErrNotSupported.
+	ResetFailed(ctx context.Context, name string, scope models.Scope) error
+
+	// Processes returns the processes belonging to a running service,
+	// including forked children
+	Processes(ctx context.Context, name string, scope models.Scope) ([]models.Process, error)
 
-	// StreamLogs returns a channel that streams log lines for a service
-	StreamLogs(ctx context.Context, name string, scope models.Scope) (<-chan string, error)
+	// LogCounts counts warning- and error-level log entries for a service
+	// since the given time, or since boot if since is zero. Window is left
+	// for the caller to fill in.
+	LogCounts(ctx context.Context, name string, scope models.Scope, since time.Time) (models.LogCounts, error)
+
+	// StreamLogs returns a channel that streams log lines for a service.
+	// Lines whose level can't be determined are dropped when opts.Level is
+	// set.
+	StreamLogs(ctx context.Context, name string, scope models.Scope, opts models.LogStreamOptions) (<-chan string, error)
+
+	// StreamLogEntries is like StreamLogs but parses each message into a
+	// timestamp, level and message
+	StreamLogEntries(ctx context.Context, name string, scope models.Scope, opts models.LogStreamOptions) (<-chan models.LogEntry, error)
+
+	// ServiceExists reports whether CreateService would find a service of
+	// that name already defined, without listing every service
+	ServiceExists(ctx context.Context, name string, scope models.Scope) (bool, error)
 
 	// CreateService creates a new service with the given configuration
-	CreateService(config models.ServiceConfig, scope models.Scope) error
+	CreateService(ctx context.Context, config models.ServiceConfig, scope models.Scope) error
 
 	// DeleteService removes a service
-	DeleteService(name string, scope models.Scope) error
+	DeleteService(ctx context.Context, name string, scope models.Scope) error
+
+	// RunTransient runs config's program under the service manager without
+	// writing a unit file or plist and returns the generated unit name (or
+	// label). Transient runs are gone after a reboot.
+	RunTransient(ctx context.Context, config models.ServiceConfig, scope models.Scope) (string, error)
+
+	// CreateTimer creates a scheduled job and returns the names of the units
+	// (or labels) that were created for it
+	CreateTimer(ctx context.Context, config models.TimerConfig, scope models.Scope) ([]string, error)
+
+	// DeleteTimer removes a scheduled job created by CreateTimer
+	DeleteTimer(ctx context.Context, name string, scope models.Scope) error
+}
+
+// Options configures provider behavior that cannot be detected from the host
+type Options struct {
+	// StopSignal is the signal sent to launchd services that are stopped via
+	// `launchctl kill`. Defaults to SIGTERM.
+	StopSignal string
+
+	// StopTimeout, when positive, escalates to SIGKILL if a launchd service is
+	// still running this long after StopSignal was sent.
+	StopTimeout time.Duration
+
+	// Timeouts bounds how long each kind of provider command may run.
+	// Operations not present fall back to DefaultTimeouts.
+	Timeouts Timeouts
+
+	// Runner executes the providers' commands. Nil runs them on the local
+	// host; tests pass a runner returning canned output.
+	Runner CommandRunner
+
+	// Remote is the [user@]host[:port] NewSSHProvider connects to, and
+	// RemoteKey an optional private key file to authenticate with
+	Remote    string
+	RemoteKey string
+}
+
+// runner returns opts.Runner, or a runner for local commands if unset
+func (opts Options) runner() CommandRunner {
+	if opts.Runner != nil {
+		return opts.Runner
+	}
+	return execRunner{}
 }
 
 // Detect detects the current platform and returns the appropriate ServiceProvider
-func Detect() (ServiceProvider, error) {
+func Detect(opts Options) (ServiceProvider, error) {
 	logger.Debug("detecting platform", "os", runtime.GOOS)
 
 	switch runtime.GOOS {
 	case "darwin":
 		logger.Debug("detected macOS, using launchd")
-		return NewLaunchdProvider()
+		return NewLaunchdProvider(opts)
 	case "linux":
 		// Check if systemd is available
 		systemdPath := "/run/systemd/system"
+		var systemdErr error
 		if _, err := os.Stat(systemdPath); err == nil {
 			logger.Debug("detected Linux with systemd", "path", systemdPath)
-			return NewSystemdProvider()
+			p, err := NewSystemdProvider(opts)
+			if !errors.Is(err, ErrSystemdNotRunning) {
+				return p, err
+			}
+			// The directory can outlive systemd, e.g. bind-mounted into a
+			// container, so look for another init system before giving up
+			logger.Warn("systemd is installed but not running, trying other init systems", "path", systemdPath)
+			systemdErr = err
+		}
+		// Alpine and Gentoo run OpenRC instead
+		if openrcAvailable() {
+			logger.Debug("detected Linux with OpenRC")
+			return NewOpenRCProvider(opts)
 		}
-		logger.Error("systemd not detected", "path", systemdPath)
-		return nil, fmt.Errorf("systemd not detected on this Linux system")
+		// Older systems and minimal containers fall back to plain init scripts
+		if info, err := os.Stat("/etc/init.d"); err == nil && info.IsDir() {
+			logger.Debug("detected Linux with SysV init scripts")
+			return NewSysVProvider(opts)
+		}
+		if systemdErr != nil {
+			return nil, systemdErr
+		}
+		logger.Error("no supported init system detected", "path", systemdPath)
+		return nil, fmt.Errorf("no supported init system (systemd, OpenRC or SysV init) detected on this Linux system")
+	case "windows":
+		logger.Debug("detected Windows, using the service control manager")
+		return newWindowsProvider(opts)
 	default:
 		logger.Error("unsupported platform", "os", runtime.GOOS)
 		return nil, fmt.Errorf("unsupported platform: %s", runtime.GOOS)
 	}
 }
+
+// openrcAvailable reports whether the host boots with OpenRC
+func openrcAvailable() bool {
+	if _, err := os.Stat("/sbin/openrc"); err == nil {
+		return true
+	}
+	_, err := exec.LookPath("rc-service")
+	return err == nil
+}
+
+// toolMissing reports whether the log tool name is not on the PATH. Minimal
+// containers often lack journalctl, so providers check once at startup and
+// refuse log streams instead of failing to start the tool every time.
+func toolMissing(name string) bool {
+	if _, err := exec.LookPath(name); err != nil {
+		logger.Warn("log tool not found, logs will be unavailable", "tool", name)
+		return true
+	}
+	return false
+}
+
+// logsUnavailable is returned by log streams when the log tool is missing
+func logsUnavailable(tool string) error {
+	return notSupported("logs are unavailable: %s is not installed", tool)
+}
+
+// transientPrefix starts the names of transient runs, telling them apart
+// from services created with CreateService
+const transientPrefix = "run-"
+
+// transientName returns a new random name for a transient run
+func transientName() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return transientPrefix + hex.EncodeToString(b)
+}
+
+// hostFiles reads files on the host a provider manages. Providers that
+// read service definitions hold one so the SSH provider can point them at
+// the remote host; nil means the local file system.
+type hostFiles interface {
+	ReadFile(ctx context.Context, path string) ([]byte, error)
+	// ReadDir returns the names of the entries in dir
+	ReadDir(ctx context.Context, dir string) ([]string, error)
+	// Exists reports whether path exists, treating errors as absent
+	Exists(ctx context.Context, path string) bool
+}
+
+// localFiles implements hostFiles with the os package
+type localFiles struct{}
+
+func (localFiles) ReadFile(ctx context.Context, path string) ([]byte, error) {
+	return os.ReadFile(path)
+}
+
+func (localFiles) ReadDir(ctx context.Context, dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = entry.Name()
+	}
+	return names, nil
+}
+
+func (localFiles) Exists(ctx context.Context, path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// fileExists reports whether path exists. Errors other than the file being
+// missing, such as permission denied on a parent directory, are returned.
+func fileExists(path string) (bool, error) {
+	_, err := os.Stat(path)
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, fs.ErrNotExist):
+		return false, nil
+	}
+	return false, err
+}
+
+// validateRunAs checks the User and Group of a service configuration: they
+// are only honored for system services, and must name existing accounts.
+func validateRunAs(config models.ServiceConfig, scope models.Scope) error {
+	if config.User == "" && config.Group == "" {
+		return nil
+	}
+	if scope != models.ScopeSystem {
+		return fmt.Errorf("user and group can only be set for system services")
+	}
+	if config.User != "" {
+		if _, err := user.Lookup(config.User); err != nil {
+			return fmt.Errorf("unknown user %q: %w", config.User, err)
+		}
+	}
+	if config.Group != "" {
+		if _, err := user.LookupGroup(config.Group); err != nil {
+			return fmt.Errorf("unknown group %q: %w", config.Group, err)
+		}
+	}
+	return nil
+}
+
+// validateRestart checks the restart settings of a service configuration
+func validateRestart(config models.ServiceConfig) error {
+	if !models.ValidRestartPolicy(config.RestartPolicy) {
+		return fmt.Errorf("unknown restart policy %q (expected one of %s)", config.RestartPolicy, strings.Join(models.RestartPolicies, ", "))
+	}
+	if config.RestartSec < 0 {
+		return fmt.Errorf("restartSec must not be negative")
+	}
+	return nil
+}
+
+// defaultRestartSec is the restart delay for KeepAlive without a policy
+const defaultRestartSec = 5
+
+// restartPolicy returns the effective restart policy and delay in seconds
+// of a service configuration. KeepAlive without an explicit policy keeps the
+// historical behavior of restarting always after defaultRestartSec.
+func restartPolicy(config models.ServiceConfig) (string, int) {
+	if config.RestartPolicy == "" && config.KeepAlive {
+		sec := config.RestartSec
+		if sec == 0 {
+			sec = defaultRestartSec
+		}
+		return "always", sec
+	}
+	return config.RestartPolicy, config.RestartSec
+}
+
+// validateEnvironmentFile checks that an EnvironmentFile path is absolute
+func validateEnvironmentFile(config models.ServiceConfig) error {
+	if config.EnvironmentFile != "" && !filepath.IsAbs(config.EnvironmentFile) {
+		return fmt.Errorf("environmentFile must be an absolute path: %s", config.EnvironmentFile)
+	}
+	return nil
+}
+
+// validateSchedule checks that a scheduled service is a oneshot job that
+// does not also ask to be kept alive
+func validateSchedule(config models.ServiceConfig) error {
+	if config.Schedule == "" {
+		return nil
+	}
+	if strings.ContainsAny(config.Schedule, "\n\r") {
+		return fmt.Errorf("schedule must be a single line")
+	}
+	if config.Type != "" && config.Type != "oneshot" {
+		return fmt.Errorf("scheduled services must be type oneshot, got %q", config.Type)
+	}
+	if policy, _ := restartPolicy(config); policy != "" && policy != "no" {
+		return fmt.Errorf("scheduled services cannot use restart policy %q", policy)
+	}
+	return nil
+}
+
+// processOwner returns the user name owning a process, or "" if it can't be
+// determined
+func processOwner(ctx context.Context, runner CommandRunner, timeouts Timeouts, pid int) string {
+	output, err := runCommand(ctx, runner, timeouts, OpStatus, "ps", "-o", "user=", "-p", strconv.Itoa(pid))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// followFunc runs a log-following command somewhere other than the local
+// host and follows its output the way followCommand does. Providers hold
+// one for the SSH provider to set; nil runs commands locally.
+type followFunc func(ctx context.Context, history *logHistory, done func(), emit func(line string) bool, name string, args ...string) error
+
+// logStreamWaitDelay bounds how long reaping a killed log process may wait
+// for its output to close
+const logStreamWaitDelay = time.Second
+
+// followCommand starts a log-following cmd (`log stream`, `tail -F`) and
+// passes each output line to emit until the output ends or emit returns
+// false, then calls done. If history is non-nil its lines are emitted first.
+// emit must return false once cmd's context is cancelled rather than block.
+// The process is killed and reaped before done is called, so a consumer that
+// goes away can't leak it.
+func followCommand(cmd *exec.Cmd, history *logHistory, done func(), emit func(line string) bool) error {
+	cmd.WaitDelay = logStreamWaitDelay
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start log stream: %w", err)
+	}
+
+	go func() {
+		defer done()
+		defer func() {
+			// The context only kills the process once it is cancelled; if
+			// the loop stopped for another reason (e.g. an oversized line),
+			// the process would otherwise block writing to a pipe nobody
+			// reads and Wait would never return
+			cmd.Process.Kill()
+			cmd.Wait()
+		}()
+
+		if history != nil {
+			for _, line := range history.load() {
+				if !emit(line) {
+					return
+				}
+			}
+		}
+
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if history != nil && history.duplicate(line) {
+				continue
+			}
+			if !emit(line) {
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			logger.Warn("log stream read failed", "command", cmd.Args, "error", err)
+		}
+	}()
+
+	return nil
+}
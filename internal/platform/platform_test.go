@@ -0,0 +1,63 @@
+package platform
+
+import (
+	"os/user"
+	"strings"
+	"testing"
+
+	"autorun/internal/models"
+)
+
+func TestValidateRunAs(t *testing.T) {
+	current, err := user.Current()
+	if err != nil {
+		t.Skipf("current user unknown: %v", err)
+	}
+	group, err := user.LookupGroupId(current.Gid)
+	if err != nil {
+		t.Skipf("current group unknown: %v", err)
+	}
+
+	cases := []struct {
+		name    string
+		config  models.ServiceConfig
+		scope   models.Scope
+		wantErr string
+	}{
+		{name: "unset", config: models.ServiceConfig{}, scope: models.ScopeUser},
+		{name: "existing", config: models.ServiceConfig{User: current.Username, Group: group.Name}, scope: models.ScopeSystem},
+		{name: "user scope", config: models.ServiceConfig{User: current.Username}, scope: models.ScopeUser, wantErr: "only be set for system services"},
+		{name: "unknown user", config: models.ServiceConfig{User: "autorun-no-such-user"}, scope: models.ScopeSystem, wantErr: `unknown user "autorun-no-such-user"`},
+		{name: "unknown group", config: models.ServiceConfig{Group: "autorun-no-such-group"}, scope: models.ScopeSystem, wantErr: `unknown group "autorun-no-such-group"`},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateRunAs(tc.config, tc.scope)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateEnvironmentFile(t *testing.T) {
+	cases := map[string]bool{
+		"":              true,
+		"/etc/app/env":  true,
+		"etc/app/env":   false,
+		"./secrets.env": false,
+	}
+	for path, ok := range cases {
+		err := validateEnvironmentFile(models.ServiceConfig{EnvironmentFile: path})
+		if (err == nil) != ok {
+			t.Fatalf("path %q: expected ok=%v, got %v", path, ok, err)
+		}
+	}
+}
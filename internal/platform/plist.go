@@ -0,0 +1,215 @@
+package platform
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// launchdPlist holds the launchd job keys autorun reads back from a plist
+type launchdPlist struct {
+	Label                string
+	Program              string
+	ProgramArguments     []string
+	EnvironmentVariables map[string]string
+	UserName             string
+	// Description is the legacy ServiceDescription key, or else Comment.
+	// launchd ignores both, but they are the usual places to describe a job.
+	Description string
+	// KeepAlive is true for `<true/>` and for a conditions dictionary, which
+	// asks launchd to keep the job alive under some circumstances.
+	KeepAlive bool
+}
+
+// executable returns the job's program path: Program if set, otherwise the
+// first element of ProgramArguments.
+func (lp *launchdPlist) executable() string {
+	if lp.Program != "" {
+		return lp.Program
+	}
+	if len(lp.ProgramArguments) > 0 {
+		return lp.ProgramArguments[0]
+	}
+	return ""
+}
+
+// parseLaunchdPlist decodes an XML plist (e.g. `plutil -convert xml1` output)
+// into the launchd keys we care about. Unknown keys are ignored.
+func parseLaunchdPlist(data []byte) (*launchdPlist, error) {
+	root, err := decodePlist(data)
+	if err != nil {
+		return nil, err
+	}
+	dict, ok := root.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("plist root is not a dictionary")
+	}
+
+	lp := &launchdPlist{}
+	lp.Label, _ = dict["Label"].(string)
+	lp.Program, _ = dict["Program"].(string)
+	lp.UserName, _ = dict["UserName"].(string)
+	lp.Description, _ = dict["ServiceDescription"].(string)
+	if lp.Description == "" {
+		lp.Description, _ = dict["Comment"].(string)
+	}
+	if args, ok := dict["ProgramArguments"].([]any); ok {
+		for _, arg := range args {
+			if s, ok := arg.(string); ok {
+				lp.ProgramArguments = append(lp.ProgramArguments, s)
+			}
+		}
+	}
+	if env, ok := dict["EnvironmentVariables"].(map[string]any); ok {
+		lp.EnvironmentVariables = make(map[string]string, len(env))
+		for k, v := range env {
+			if s, ok := v.(string); ok {
+				lp.EnvironmentVariables[k] = s
+			}
+		}
+	}
+	switch v := dict["KeepAlive"].(type) {
+	case bool:
+		lp.KeepAlive = v
+	case map[string]any:
+		lp.KeepAlive = len(v) > 0
+	}
+	return lp, nil
+}
+
+// decodePlist decodes an XML property list into Go values: dictionaries
+// become map[string]any, arrays []any, integers int64, reals float64,
+// booleans bool, and strings, dates and data string.
+func decodePlist(data []byte) (any, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	start, ok, err := nextElement(dec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid plist: %w", err)
+	}
+	if !ok || start.Name.Local != "plist" {
+		return nil, fmt.Errorf("invalid plist: missing <plist> element")
+	}
+
+	valueStart, ok, err := nextElement(dec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid plist: %w", err)
+	}
+	if !ok {
+		return nil, fmt.Errorf("invalid plist: empty <plist>")
+	}
+	return decodePlistValue(dec, valueStart)
+}
+
+// decodePlistValue decodes the value element opened by start
+func decodePlistValue(dec *xml.Decoder, start xml.StartElement) (any, error) {
+	switch start.Name.Local {
+	case "dict":
+		dict := make(map[string]any)
+		for {
+			keyStart, ok, err := nextElement(dec)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return dict, nil
+			}
+			if keyStart.Name.Local != "key" {
+				return nil, fmt.Errorf("expected <key> in dict, got <%s>", keyStart.Name.Local)
+			}
+			var key string
+			if err := dec.DecodeElement(&key, &keyStart); err != nil {
+				return nil, err
+			}
+
+			valueStart, ok, err := nextElement(dec)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return nil, fmt.Errorf("missing value for key %q", key)
+			}
+			value, err := decodePlistValue(dec, valueStart)
+			if err != nil {
+				return nil, err
+			}
+			dict[key] = value
+		}
+
+	case "array":
+		array := []any{}
+		for {
+			valueStart, ok, err := nextElement(dec)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return array, nil
+			}
+			value, err := decodePlistValue(dec, valueStart)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+
+	case "string", "date":
+		var s string
+		err := dec.DecodeElement(&s, &start)
+		return s, err
+
+	case "data":
+		var s string
+		err := dec.DecodeElement(&s, &start)
+		return strings.Join(strings.Fields(s), ""), err
+
+	case "integer":
+		var s string
+		if err := dec.DecodeElement(&s, &start); err != nil {
+			return nil, err
+		}
+		n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %q", s)
+		}
+		return n, nil
+
+	case "real":
+		var s string
+		if err := dec.DecodeElement(&s, &start); err != nil {
+			return nil, err
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid real %q", s)
+		}
+		return f, nil
+
+	case "true", "false":
+		if err := dec.Skip(); err != nil {
+			return nil, err
+		}
+		return start.Name.Local == "true", nil
+
+	default:
+		return nil, fmt.Errorf("unsupported plist element <%s>", start.Name.Local)
+	}
+}
+
+// nextElement advances to the next start element, skipping character data,
+// comments and directives. It returns ok=false if an end element comes first.
+func nextElement(dec *xml.Decoder) (xml.StartElement, bool, error) {
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return xml.StartElement{}, false, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			return t, true, nil
+		case xml.EndElement:
+			return xml.StartElement{}, false, nil
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package platform
+
+import (
+	"maps"
+	"slices"
+	"testing"
+)
+
+const testPlist = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>KeepAlive</key>
+	<dict>
+		<key>SuccessfulExit</key>
+		<false/>
+	</dict>
+	<key>EnvironmentVariables</key>
+	<dict>
+		<key>PATH</key>
+		<string>/usr/bin:/bin</string>
+		<key>MODE</key>
+		<string>prod</string>
+	</dict>
+	<key>ProgramArguments</key>
+	<array>
+
+		<string>/usr/local/bin/worker</string>   <!-- executable -->
+		<string>--port</string>
+		<string>8080</string>
+	</array>
+	<key>ThrottleInterval</key>
+	<integer>10</integer>
+	<key>Label</key>
+	<string>com.example.worker</string>
+	<key>Comment</key>
+	<string>Queue worker</string>
+</dict>
+</plist>
+`
+
+func TestParseLaunchdPlist(t *testing.T) {
+	lp, err := parseLaunchdPlist([]byte(testPlist))
+	if err != nil {
+		t.Fatalf("parseLaunchdPlist: %v", err)
+	}
+
+	if lp.Label != "com.example.worker" {
+		t.Fatalf("unexpected label %q", lp.Label)
+	}
+	wantArgs := []string{"/usr/local/bin/worker", "--port", "8080"}
+	if !slices.Equal(lp.ProgramArguments, wantArgs) {
+		t.Fatalf("expected arguments %q, got %q", wantArgs, lp.ProgramArguments)
+	}
+	wantEnv := map[string]string{"PATH": "/usr/bin:/bin", "MODE": "prod"}
+	if !maps.Equal(lp.EnvironmentVariables, wantEnv) {
+		t.Fatalf("expected environment %v, got %v", wantEnv, lp.EnvironmentVariables)
+	}
+	if !lp.KeepAlive {
+		t.Fatalf("expected KeepAlive for a conditions dictionary")
+	}
+	if lp.Description != "Queue worker" {
+		t.Fatalf("expected the Comment as description, got %q", lp.Description)
+	}
+}
+
+func TestLaunchdPlistExecutable(t *testing.T) {
+	cases := []struct {
+		name string
+		lp   launchdPlist
+		want string
+	}{
+		{name: "program", lp: launchdPlist{Program: "/bin/a", ProgramArguments: []string{"/bin/b"}}, want: "/bin/a"},
+		{name: "arguments", lp: launchdPlist{ProgramArguments: []string{"/bin/b", "-v"}}, want: "/bin/b"},
+		{name: "none", lp: launchdPlist{}, want: ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.lp.executable(); got != tc.want {
+				t.Fatalf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestDecodePlist_Errors(t *testing.T) {
+	cases := map[string]string{
+		"not a plist":   `<dict></dict>`,
+		"missing value": `<plist><dict><key>Label</key></dict></plist>`,
+		"bad integer":   `<plist><integer>ten</integer></plist>`,
+		"truncated":     `<plist><dict><key>Label</key>`,
+	}
+
+	for name, input := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := decodePlist([]byte(input)); err == nil {
+				t.Fatalf("expected error for %q", input)
+			}
+		})
+	}
+}
+
+func TestParseLaunchdPlist_ProgramKey(t *testing.T) {
+	data := `<?xml version="1.0" encoding="UTF-8"?>
+<plist version="1.0">
+<dict>
+	<key>Program</key>
+	<string>/usr/sbin/daemon</string>
+	<key>Label</key>
+	<string>com.example.daemon</string>
+	<key>KeepAlive</key>
+	<true/>
+	<key>Sockets</key>
+	<dict>
+		<key>Listeners</key>
+		<array>
+			<dict>
+				<key>SockServiceName</key>
+				<string>8080</string>
+			</dict>
+		</array>
+	</dict>
+</dict>
+</plist>`
+
+	lp, err := parseLaunchdPlist([]byte(data))
+	if err != nil {
+		t.Fatalf("parseLaunchdPlist: %v", err)
+	}
+	if lp.Label != "com.example.daemon" || lp.executable() != "/usr/sbin/daemon" || !lp.KeepAlive {
+		t.Fatalf("unexpected plist: %+v", lp)
+	}
+	if lp.ProgramArguments != nil || lp.EnvironmentVariables != nil {
+		t.Fatalf("expected absent keys to stay empty: %+v", lp)
+	}
+}
@@ -0,0 +1,96 @@
+package platform
+
+import (
+	"errors"
+	"io/fs"
+	"regexp"
+	"strings"
+
+	"autorun/internal/models"
+)
+
+// Remediation hints returned by PermissionRemediation
+const (
+	RemediationPolkit = "systemd refused the action because polkit authorization is required. " +
+		"Run autorun with sudo, or allow this user through a polkit rule or an authentication agent in a desktop session."
+	RemediationSudo = "autorun does not have permission for this action. " +
+		"System services can only be managed as root; restart autorun with sudo."
+	RemediationUser = "autorun does not have permission for this action. " +
+		"User services are managed as the user autorun runs as; run it as the service's owner, " +
+		"or check that this user can write its unit or plist directory."
+)
+
+// polkitMessages are substrings systemctl prints when polkit denies an action
+var polkitMessages = []string{
+	"interactive authentication required",
+	"org.freedesktop.policykit",
+	"authorization not available",
+}
+
+// permissionMessages are substrings of generic permission failures (EACCES,
+// EPERM) as printed by systemctl, launchctl, OpenRC and the standard library
+var permissionMessages = []string{
+	"permission denied",
+	"operation not permitted",
+	"access denied",
+	"must be root",
+	"superuser access required", // openrc-run: * web: superuser access required
+}
+
+// notFoundMessages are substrings systemctl, launchctl and rc-service print
+// for a unit, job or init script that doesn't exist
+var notFoundMessages = []string{
+	"could not be found",     // systemctl: Unit web.service could not be found.
+	"could not find service", // launchctl: Could not find service "web" in domain
+	"does not exist",         // rc-service: service `web' does not exist
+}
+
+// unitNotFound matches systemctl's "Unit web.service not found."
+var unitNotFound = regexp.MustCompile(`unit \S+ not found`)
+
+// PermissionRemediation returns a hint for fixing err if it is a permission
+// failure on a service in scope, or "" otherwise. polkit denials from
+// systemd need a different fix than a plain EACCES, so they are told apart
+// by the command's message, and sudo only helps with system services.
+func PermissionRemediation(err error, scope models.Scope) string {
+	if err == nil {
+		return ""
+	}
+	hint := permissionMessage(err.Error())
+	if hint == "" && errors.Is(err, fs.ErrPermission) {
+		hint = RemediationSudo
+	}
+	if hint == RemediationSudo && scope == models.ScopeUser {
+		hint = RemediationUser
+	}
+	return hint
+}
+
+// permissionMessage returns the remediation for a command or os error
+// message that reports a permission failure, or ""
+func permissionMessage(msg string) string {
+	msg = strings.ToLower(msg)
+	for _, s := range polkitMessages {
+		if strings.Contains(msg, s) {
+			return RemediationPolkit
+		}
+	}
+	for _, s := range permissionMessages {
+		if strings.Contains(msg, s) {
+			return RemediationSudo
+		}
+	}
+	return ""
+}
+
+// notFoundMessage reports whether a command's message says the unit or job
+// it acted on doesn't exist
+func notFoundMessage(msg string) bool {
+	msg = strings.ToLower(msg)
+	for _, s := range notFoundMessages {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return unitNotFound.MatchString(msg)
+}
@@ -0,0 +1,114 @@
+package platform
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"testing"
+
+	"autorun/internal/models"
+)
+
+func TestPermissionRemediation(t *testing.T) {
+	cases := []struct {
+		name  string
+		err   error
+		scope models.Scope
+		want  string
+	}{
+		{name: "nil", err: nil, want: ""},
+		{
+			name: "polkit interactive auth",
+			err:  errors.New("systemctl start failed: Failed to start nginx.service: Interactive authentication required.\nSee system logs and 'systemctl status nginx.service' for details."),
+			want: RemediationPolkit,
+		},
+		{
+			name: "polkit access denied",
+			err:  errors.New("systemctl enable failed: Failed to enable unit: Access denied (org.freedesktop.PolicyKit1.Error.NotAuthorized)"),
+			want: RemediationPolkit,
+		},
+		{
+			name: "unit file write",
+			err:  fmt.Errorf("failed to write unit file: %w", &fs.PathError{Op: "open", Path: "/etc/systemd/system/x.service", Err: fs.ErrPermission}),
+			want: RemediationSudo,
+		},
+		{
+			name: "launchctl bootstrap",
+			err:  errors.New("launchctl bootstrap failed: Bootstrap failed: 1: Operation not permitted"),
+			want: RemediationSudo,
+		},
+		{
+			name:  "user unit file write",
+			err:   fmt.Errorf("failed to write unit file: %w", &fs.PathError{Op: "open", Path: "/home/alice/.config/systemd/user/x.service", Err: fs.ErrPermission}),
+			scope: models.ScopeUser,
+			want:  RemediationUser,
+		},
+		{
+			name:  "user launchctl bootstrap",
+			err:   errors.New("launchctl bootstrap failed: Bootstrap failed: 1: Operation not permitted"),
+			scope: models.ScopeUser,
+			want:  RemediationUser,
+		},
+		{
+			name:  "user polkit",
+			err:   errors.New("systemctl start failed: Interactive authentication required."),
+			scope: models.ScopeUser,
+			want:  RemediationPolkit,
+		},
+		{
+			name: "unrelated failure",
+			err:  errors.New("systemctl start failed: Unit nginx.service not found."),
+			want: "",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			scope := tc.scope
+			if scope == "" {
+				scope = models.ScopeSystem
+			}
+			if got := PermissionRemediation(tc.err, scope); got != tc.want {
+				t.Fatalf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestCommandErrorIs(t *testing.T) {
+	cases := []struct {
+		name       string
+		message    string
+		permission bool
+		notFound   bool
+	}{
+		{name: "polkit", message: "systemctl start failed: Interactive authentication required.", permission: true},
+		{name: "launchctl", message: "launchctl bootstrap failed: Bootstrap failed: 1: Operation not permitted", permission: true},
+		{name: "unit not found", message: "systemctl start failed: Failed to start web.service: Unit web.service not found.", notFound: true},
+		{name: "unit could not be found", message: "systemctl show failed: Unit web.service could not be found.", notFound: true},
+		{name: "launchctl service", message: `launchctl kickstart failed: Could not find service "web" in domain for user gui: 501`, notFound: true},
+		{name: "openrc-run", message: "rc-service start failed:  * web: superuser access required", permission: true},
+		{name: "rc-service", message: "rc-service start failed: rc-service: service `web' does not exist", notFound: true},
+		{name: "other", message: "systemctl start failed: Job for web.service failed because the control process exited with error code."},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := fmt.Errorf("wrapped: %w", newCommandError(errors.New("exit status 1"), tc.message))
+			if got := errors.Is(err, ErrPermission); got != tc.permission {
+				t.Errorf("errors.Is(err, ErrPermission) = %v, want %v", got, tc.permission)
+			}
+			if got := errors.Is(err, ErrNotFound); got != tc.notFound {
+				t.Errorf("errors.Is(err, ErrNotFound) = %v, want %v", got, tc.notFound)
+			}
+		})
+	}
+}
+
+func TestNotSupported(t *testing.T) {
+	err := notSupported("%s is not supported by launchd", "execStartPre")
+	if !errors.Is(err, ErrNotSupported) {
+		t.Fatal("expected error to match ErrNotSupported")
+	}
+	if err.Error() != "execStartPre is not supported by launchd" {
+		t.Fatalf("unexpected message %q", err.Error())
+	}
+}
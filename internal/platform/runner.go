@@ -0,0 +1,155 @@
+package platform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// CommandRunner executes external commands on behalf of a provider.
+// Providers shell out through a runner rather than os/exec directly so tests
+// can substitute canned command output.
+type CommandRunner interface {
+	// Run executes name with args and returns its standard output. On a
+	// non-zero exit the error is an *exec.ExitError carrying stderr.
+	Run(ctx context.Context, name string, args ...string) ([]byte, error)
+}
+
+// execRunner runs commands on the local host via os/exec
+type execRunner struct{}
+
+func (execRunner) Run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return exec.CommandContext(ctx, name, args...).Output()
+}
+
+// Operations group provider commands by how long they are expected to take
+const (
+	OpList   = "list"   // enumerate services (list-units, launchctl print <domain>)
+	OpStatus = "status" // query a single service (is-enabled, show, plutil)
+	OpAction = "action" // start/stop/restart/enable/disable
+	OpReload = "reload" // daemon-reload
+)
+
+// Timeouts maps an operation to the maximum time its command may run
+type Timeouts map[string]time.Duration
+
+// DefaultTimeouts are applied to any operation missing from configured Timeouts
+var DefaultTimeouts = Timeouts{
+	OpList:   30 * time.Second,
+	OpStatus: 5 * time.Second,
+	OpAction: 90 * time.Second,
+	OpReload: 60 * time.Second,
+}
+
+// For returns the timeout for op, falling back to DefaultTimeouts
+func (t Timeouts) For(op string) time.Duration {
+	if d, ok := t[op]; ok && d > 0 {
+		return d
+	}
+	return DefaultTimeouts[op]
+}
+
+// ParseTimeouts parses a comma-separated list of op=duration pairs, e.g.
+// "list=1m,status=3s". Unknown operations and invalid durations are errors.
+func ParseTimeouts(s string) (Timeouts, error) {
+	timeouts := Timeouts{}
+	if strings.TrimSpace(s) == "" {
+		return timeouts, nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		op, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid timeout %q: expected op=duration", pair)
+		}
+		if _, known := DefaultTimeouts[op]; !known {
+			return nil, fmt.Errorf("unknown timeout operation: %s", op)
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid timeout for %s: %s", op, value)
+		}
+		timeouts[op] = d
+	}
+	return timeouts, nil
+}
+
+// runCommand runs a command through runner, bounded by the timeout
+// configured for op. A command cut off by the timeout returns an error
+// wrapping ErrTimeout.
+func runCommand(ctx context.Context, runner CommandRunner, timeouts Timeouts, op, name string, args ...string) ([]byte, error) {
+	timeout := timeouts.For(op)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	output, err := runner.Run(ctx, name, args...)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return output, fmt.Errorf("%s %s %w after %s", name, strings.Join(args, " "), ErrTimeout, timeout)
+	}
+	return output, err
+}
+
+// CommandError is returned when a provider command fails. It keeps the
+// command's exit code so API clients can act on it (e.g. for retry logic).
+type CommandError struct {
+	Message  string
+	ExitCode int // -1 if the command did not exit normally
+	Err      error
+}
+
+func (e *CommandError) Error() string { return e.Message }
+func (e *CommandError) Unwrap() error { return e.Err }
+
+// Is matches ErrPermission when the command was refused for lack of
+// privileges and ErrNotFound when it named a unit or job that doesn't exist,
+// judging by the message the command printed
+func (e *CommandError) Is(target error) bool {
+	switch target {
+	case ErrPermission:
+		return permissionMessage(e.Message) != ""
+	case ErrNotFound:
+		return notFoundMessage(e.Message)
+	}
+	return false
+}
+
+// newCommandError describes a failed command with message, capturing the
+// exit code carried by err (e.g. from an *exec.ExitError).
+func newCommandError(err error, message string) *CommandError {
+	code, ok := ExitCode(err)
+	if !ok {
+		code = -1
+	}
+	return &CommandError{Message: message, ExitCode: code, Err: err}
+}
+
+// ExitCode returns the exit code of the failed command behind err, if any
+func ExitCode(err error) (int, bool) {
+	var cmdErr *CommandError
+	if errors.As(err, &cmdErr) {
+		return cmdErr.ExitCode, cmdErr.ExitCode >= 0
+	}
+	var exitErr interface{ ExitCode() int }
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), true
+	}
+	return 0, false
+}
+
+// commandOutput returns the stdout and any captured stderr of a finished
+// command, for use in error messages.
+func commandOutput(output []byte, err error) string {
+	text := string(output)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+		text += string(exitErr.Stderr)
+	}
+	var remoteErr *remoteExitError
+	if errors.As(err, &remoteErr) {
+		text += string(remoteErr.Stderr)
+	}
+	return text
+}
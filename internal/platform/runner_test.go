@@ -0,0 +1,152 @@
+package platform
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"autorun/internal/models"
+)
+
+func TestParseTimeouts(t *testing.T) {
+	cases := []struct {
+		name    string
+		input   string
+		want    Timeouts
+		wantErr bool
+	}{
+		{name: "empty", input: "", want: Timeouts{}},
+		{name: "single", input: "list=1m", want: Timeouts{OpList: time.Minute}},
+		{name: "multiple", input: "list=1m, status=3s", want: Timeouts{OpList: time.Minute, OpStatus: 3 * time.Second}},
+		{name: "unknown op", input: "bogus=1s", wantErr: true},
+		{name: "bad duration", input: "list=soon", wantErr: true},
+		{name: "missing value", input: "list", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseTimeouts(tc.input)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+			for op, d := range tc.want {
+				if got[op] != d {
+					t.Fatalf("expected %s=%s, got %s", op, d, got[op])
+				}
+			}
+		})
+	}
+}
+
+func TestTimeouts_ForFallsBackToDefault(t *testing.T) {
+	timeouts := Timeouts{OpList: time.Minute}
+	if got := timeouts.For(OpList); got != time.Minute {
+		t.Fatalf("expected configured timeout, got %s", got)
+	}
+	if got := timeouts.For(OpStatus); got != DefaultTimeouts[OpStatus] {
+		t.Fatalf("expected default timeout, got %s", got)
+	}
+}
+
+func TestSystemdListUnits_AppliesListTimeout(t *testing.T) {
+	runner := &fakeRunner{
+		handle: func(name string, args []string) ([]byte, error) {
+			return []byte("[]"), nil
+		},
+	}
+	p := &SystemdProvider{runner: runner, timeouts: Timeouts{OpList: 42 * time.Second}}
+
+	start := time.Now()
+	if _, err := p.listUnits(t.Context(), models.ScopeSystem); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(runner.deadlines) != 1 {
+		t.Fatalf("expected 1 command, got %d", len(runner.deadlines))
+	}
+	remaining := runner.deadlines[0].Sub(start)
+	if remaining < 41*time.Second || remaining > 43*time.Second {
+		t.Fatalf("expected ~42s deadline, got %s", remaining)
+	}
+}
+
+func TestSystemdListUnits_HonoursCallerContext(t *testing.T) {
+	runner := &fakeRunner{
+		handle: func(name string, args []string) ([]byte, error) {
+			return []byte("[]"), nil
+		},
+	}
+	p := &SystemdProvider{runner: runner, timeouts: Timeouts{OpList: 42 * time.Second}}
+
+	// A caller deadline sooner than the operation timeout wins
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	if _, err := p.listUnits(ctx, models.ScopeSystem); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if remaining := runner.deadlines[0].Sub(start); remaining > 6*time.Second {
+		t.Fatalf("expected the caller's ~5s deadline, got %s", remaining)
+	}
+
+	// A cancelled request is not reported as a command timeout
+	runner.handle = func(name string, args []string) ([]byte, error) {
+		return nil, context.Canceled
+	}
+	cancel()
+	if _, err := p.listUnits(ctx, models.ScopeSystem); err == nil || errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected a non-timeout error, got %v", err)
+	}
+}
+
+func TestRunCommand_ReportsTimeout(t *testing.T) {
+	runner := &fakeRunner{
+		handle: func(name string, args []string) ([]byte, error) {
+			time.Sleep(20 * time.Millisecond)
+			return nil, context.DeadlineExceeded
+		},
+	}
+
+	_, err := runCommand(context.Background(), runner, Timeouts{OpStatus: time.Millisecond}, OpStatus, "systemctl", "is-enabled", "foo")
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+
+	// Providers wrap command errors; the timeout must still be recognizable
+	p := &SystemdProvider{runner: runner, timeouts: Timeouts{OpAction: time.Millisecond}}
+	if err := p.Start(t.Context(), "web", models.ScopeSystem); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected Start to fail with ErrTimeout, got %v", err)
+	}
+}
+
+func TestSystemdStart_PreservesExitCode(t *testing.T) {
+	runner := &fakeRunner{
+		handle: func(name string, args []string) ([]byte, error) {
+			return nil, &fakeExitError{code: 3}
+		},
+	}
+	p := &SystemdProvider{runner: runner}
+
+	err := p.Start(t.Context(), "demo", models.ScopeSystem)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	code, ok := ExitCode(err)
+	if !ok || code != 3 {
+		t.Fatalf("expected exit code 3, got %d (ok=%v)", code, ok)
+	}
+}
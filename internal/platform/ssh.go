@@ -0,0 +1,410 @@
+package platform
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"autorun/internal/logger"
+	"autorun/internal/models"
+)
+
+// sshDialTimeout bounds connecting and authenticating to the remote host
+const sshDialTimeout = 15 * time.Second
+
+// defaultSSHKeys are tried, in order, when no key is given and no agent is
+// running, as ssh does
+var defaultSSHKeys = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// SSHProvider implements ServiceProvider for a remote host reached over
+// SSH. It drives the remote systemd or launchd with the same providers used
+// locally, running their commands over the connection. Defining services
+// means writing unit files or plists, which those providers do on the local
+// disk, so creating and deleting services and timers isn't supported.
+type SSHProvider struct {
+	ServiceProvider
+}
+
+// NewSSHProvider connects to opts.Remote and picks the provider matching
+// the remote host's init system
+func NewSSHProvider(opts Options) (*SSHProvider, error) {
+	username, addr, err := parseSSHTarget(opts.Remote)
+	if err != nil {
+		return nil, err
+	}
+	auth, err := sshAuthMethods(opts.RemoteKey)
+	if err != nil {
+		return nil, err
+	}
+	hostKeys, err := sshHostKeyCallback()
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Debug("connecting over ssh", "user", username, "addr", addr)
+	client, err := ssh.Dial("tcp", addr, &ssh.ClientConfig{
+		User:            username,
+		Auth:            auth,
+		HostKeyCallback: hostKeys,
+		Timeout:         sshDialTimeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", opts.Remote, err)
+	}
+
+	runner := &sshRunner{client: client}
+	inner, err := newRemoteProvider(runner, runner.follow, remoteFiles{runner: runner, timeouts: opts.Timeouts}, opts)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	logger.Info("managing remote host", "remote", opts.Remote, "platform", inner.Name())
+	return &SSHProvider{ServiceProvider: inner}, nil
+}
+
+// parseSSHTarget splits a [user@]host[:port] target, defaulting to the
+// local user name and port 22
+func parseSSHTarget(target string) (username, addr string, err error) {
+	username, host, ok := strings.Cut(target, "@")
+	if !ok {
+		host = username
+		username = ""
+	}
+	if host == "" || (ok && username == "") {
+		return "", "", fmt.Errorf("invalid remote %q: expected user@host[:port]", target)
+	}
+	if username == "" {
+		u, err := user.Current()
+		if err != nil {
+			return "", "", fmt.Errorf("invalid remote %q: no user given and the current user is unknown: %w", target, err)
+		}
+		username = u.Username
+	}
+
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		// No port; brackets around an IPv6 address are optional then
+		host = net.JoinHostPort(strings.Trim(host, "[]"), "22")
+	}
+	return username, host, nil
+}
+
+// sshAuthMethods authenticates with keyFile if given, otherwise with the
+// running ssh-agent and the default key files in ~/.ssh
+func sshAuthMethods(keyFile string) ([]ssh.AuthMethod, error) {
+	if keyFile != "" {
+		signer, err := readSSHKey(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		return []ssh.AuthMethod{ssh.PublicKeys(signer)}, nil
+	}
+
+	var methods []ssh.AuthMethod
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+		} else {
+			logger.Debug("ssh agent unavailable", "socket", sock, "error", err)
+		}
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		var signers []ssh.Signer
+		for _, name := range defaultSSHKeys {
+			path := filepath.Join(home, ".ssh", name)
+			if _, err := os.Stat(path); err != nil {
+				continue
+			}
+			signer, err := readSSHKey(path)
+			if err != nil {
+				logger.Debug("skipping ssh key", "path", path, "error", err)
+				continue
+			}
+			signers = append(signers, signer)
+		}
+		if len(signers) > 0 {
+			methods = append(methods, ssh.PublicKeys(signers...))
+		}
+	}
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("no ssh credentials: start ssh-agent or pass -remote-key")
+	}
+	return methods, nil
+}
+
+// readSSHKey reads an unencrypted private key. Passphrase-protected keys
+// have to be loaded into ssh-agent instead.
+func readSSHKey(path string) (ssh.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ssh key: %w", err)
+	}
+	signer, err := ssh.ParsePrivateKey(data)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		return nil, fmt.Errorf("ssh key %s is encrypted; add it to ssh-agent instead", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ssh key %s: %w", path, err)
+	}
+	return signer, nil
+}
+
+// sshHostKeyCallback verifies host keys against ~/.ssh/known_hosts. Unknown
+// hosts are refused rather than trusted on first use, so connect once with
+// ssh to record the key.
+func sshHostKeyCallback() (ssh.HostKeyCallback, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate known_hosts: %w", err)
+	}
+	callback, err := knownhosts.New(filepath.Join(home, ".ssh", "known_hosts"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read known_hosts (connect once with ssh to add the host): %w", err)
+	}
+	return callback, nil
+}
+
+// newRemoteProvider asks the remote host which init system it runs and
+// returns that provider, wired to run everything through runner, follow
+// and files
+func newRemoteProvider(runner CommandRunner, follow followFunc, files hostFiles, opts Options) (ServiceProvider, error) {
+	ctx := context.Background()
+	output, err := runCommand(ctx, runner, opts.Timeouts, OpStatus, "uname", "-s")
+	if err != nil {
+		return nil, newCommandError(err, "failed to detect remote platform: "+strings.TrimSpace(commandOutput(output, err)))
+	}
+
+	switch system := strings.TrimSpace(string(output)); system {
+	case "Linux":
+		if _, err := runCommand(ctx, runner, opts.Timeouts, OpStatus, "test", "-d", "/run/systemd/system"); err != nil {
+			return nil, fmt.Errorf("remote host does not run systemd; only systemd and launchd can be managed over SSH")
+		}
+		p := &SystemdProvider{
+			runner:    runner,
+			timeouts:  opts.Timeouts,
+			follow:    follow,
+			noJournal: !remoteHasCommand(ctx, runner, opts.Timeouts, "journalctl"),
+		}
+		if err := p.checkRunning(ctx); err != nil {
+			return nil, err
+		}
+		return p, nil
+	case "Darwin":
+		stopSignal, err := normalizeSignal(opts.StopSignal)
+		if err != nil {
+			return nil, err
+		}
+		uid, err := runCommand(ctx, runner, opts.Timeouts, OpStatus, "id", "-u")
+		if err != nil {
+			return nil, newCommandError(err, "failed to read remote user id: "+strings.TrimSpace(commandOutput(uid, err)))
+		}
+		home, err := runCommand(ctx, runner, opts.Timeouts, OpStatus, "sh", "-c", `printf %s "$HOME"`)
+		if err != nil {
+			return nil, newCommandError(err, "failed to read remote home directory: "+strings.TrimSpace(commandOutput(home, err)))
+		}
+		return &LaunchdProvider{
+			userHome:     string(home),
+			uid:          strings.TrimSpace(string(uid)),
+			runner:       runner,
+			timeouts:     opts.Timeouts,
+			stopSignal:   stopSignal,
+			stopTimeout:  opts.StopTimeout,
+			pollInterval: stopPollInterval,
+			files:        files,
+			follow:       follow,
+			noLogTool:    !remoteHasCommand(ctx, runner, opts.Timeouts, "log"),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported remote platform: %s", system)
+	}
+}
+
+// remoteHasCommand reports whether name is on the remote user's PATH
+func remoteHasCommand(ctx context.Context, runner CommandRunner, timeouts Timeouts, name string) bool {
+	if _, err := runCommand(ctx, runner, timeouts, OpStatus, "sh", "-c", "command -v "+name); err != nil {
+		logger.Warn("log tool not found on remote host, logs will be unavailable", "tool", name)
+		return false
+	}
+	return true
+}
+
+// Capabilities drops the features that only apply to creating services
+func (p *SSHProvider) Capabilities() models.Capabilities {
+	caps := p.ServiceProvider.Capabilities()
+	caps.Timers = false
+	caps.Dependencies = false
+	caps.Hooks = false
+	return caps
+}
+
+func (p *SSHProvider) ServiceExists(ctx context.Context, name string, scope models.Scope) (bool, error) {
+	return false, notSupported("creating services over SSH is not supported")
+}
+
+func (p *SSHProvider) CreateService(ctx context.Context, config models.ServiceConfig, scope models.Scope) error {
+	return notSupported("creating services over SSH is not supported")
+}
+
+func (p *SSHProvider) DeleteService(ctx context.Context, name string, scope models.Scope) error {
+	return notSupported("deleting services over SSH is not supported")
+}
+
+func (p *SSHProvider) RunTransient(ctx context.Context, config models.ServiceConfig, scope models.Scope) (string, error) {
+	return "", notSupported("transient runs over SSH are not supported")
+}
+
+func (p *SSHProvider) CreateTimer(ctx context.Context, config models.TimerConfig, scope models.Scope) ([]string, error) {
+	return nil, notSupported("creating timers over SSH is not supported")
+}
+
+func (p *SSHProvider) DeleteTimer(ctx context.Context, name string, scope models.Scope) error {
+	return notSupported("deleting timers over SSH is not supported")
+}
+
+// remoteExitError is returned by sshRunner for a remote command that
+// exited non-zero. Like *exec.ExitError it carries the captured stderr.
+type remoteExitError struct {
+	code   int
+	Stderr []byte
+}
+
+func (e *remoteExitError) Error() string { return "exit status " + strconv.Itoa(e.code) }
+func (e *remoteExitError) ExitCode() int { return e.code }
+
+// sshRunner implements CommandRunner by running commands in sessions on
+// an SSH connection. The remote login shell parses the command line, so
+// every word is quoted.
+type sshRunner struct {
+	client *ssh.Client
+}
+
+func (r *sshRunner) Run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	session, err := r.client.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ssh session: %w", err)
+	}
+	defer session.Close()
+
+	var stdout, stderr bytes.Buffer
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+
+	// Closing the session on cancellation makes Run return
+	stop := context.AfterFunc(ctx, func() {
+		session.Signal(ssh.SIGKILL)
+		session.Close()
+	})
+	err = session.Run(shellCommandLine(append([]string{name}, args...)))
+	stop()
+
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		return stdout.Bytes(), &remoteExitError{code: exitErr.ExitStatus(), Stderr: stderr.Bytes()}
+	}
+	if err == nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	return stdout.Bytes(), err
+}
+
+// follow is the followFunc for remote log streams: it runs the command in
+// its own session and passes each line to emit like followCommand. The
+// session is closed when ctx is cancelled, which ends the remote command
+// the next time it writes.
+func (r *sshRunner) follow(ctx context.Context, history *logHistory, done func(), emit func(line string) bool, name string, args ...string) error {
+	session, err := r.client.NewSession()
+	if err != nil {
+		return fmt.Errorf("failed to open ssh session: %w", err)
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		session.Close()
+		return fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+	command := shellCommandLine(append([]string{name}, args...))
+	if err := session.Start(command); err != nil {
+		session.Close()
+		return fmt.Errorf("failed to start log stream: %w", err)
+	}
+
+	stop := context.AfterFunc(ctx, func() { session.Close() })
+	go func() {
+		defer done()
+		defer func() {
+			stop()
+			session.Signal(ssh.SIGTERM)
+			session.Close()
+		}()
+
+		if history != nil {
+			for _, line := range history.load() {
+				if !emit(line) {
+					return
+				}
+			}
+		}
+
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if history != nil && history.duplicate(line) {
+				continue
+			}
+			if !emit(line) {
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil && ctx.Err() == nil {
+			logger.Warn("log stream read failed", "command", command, "error", err)
+		}
+	}()
+	return nil
+}
+
+// remoteFiles implements hostFiles by running cat, ls and test on the
+// remote host
+type remoteFiles struct {
+	runner   CommandRunner
+	timeouts Timeouts
+}
+
+func (f remoteFiles) ReadFile(ctx context.Context, path string) ([]byte, error) {
+	output, err := runCommand(ctx, f.runner, f.timeouts, OpStatus, "cat", "--", path)
+	if err != nil {
+		return nil, newCommandError(err, "cat failed: "+strings.TrimSpace(commandOutput(nil, err)))
+	}
+	return output, nil
+}
+
+func (f remoteFiles) ReadDir(ctx context.Context, dir string) ([]string, error) {
+	output, err := runCommand(ctx, f.runner, f.timeouts, OpList, "ls", "-1A", "--", dir)
+	if err != nil {
+		return nil, newCommandError(err, "ls failed: "+strings.TrimSpace(commandOutput(nil, err)))
+	}
+	var names []string
+	for _, name := range strings.Split(string(output), "\n") {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+func (f remoteFiles) Exists(ctx context.Context, path string) bool {
+	_, err := runCommand(ctx, f.runner, f.timeouts, OpStatus, "test", "-e", path)
+	return err == nil
+}
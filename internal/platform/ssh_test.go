@@ -0,0 +1,155 @@
+package platform
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+
+	"autorun/internal/models"
+)
+
+func TestParseSSHTarget(t *testing.T) {
+	cases := []struct {
+		target string
+		user   string
+		addr   string
+	}{
+		{target: "admin@server", user: "admin", addr: "server:22"},
+		{target: "admin@server:2222", user: "admin", addr: "server:2222"},
+		{target: "admin@[::1]:2222", user: "admin", addr: "[::1]:2222"},
+		{target: "admin@::1", user: "admin", addr: "[::1]:22"},
+		{target: "admin@10.0.0.5", user: "admin", addr: "10.0.0.5:22"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.target, func(t *testing.T) {
+			user, addr, err := parseSSHTarget(tc.target)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if user != tc.user || addr != tc.addr {
+				t.Fatalf("expected %s at %s, got %s at %s", tc.user, tc.addr, user, addr)
+			}
+		})
+	}
+
+	for _, target := range []string{"", "admin@", "@server"} {
+		if _, _, err := parseSSHTarget(target); err == nil {
+			t.Fatalf("expected an error for %q", target)
+		}
+	}
+}
+
+func TestNewRemoteProvider(t *testing.T) {
+	cases := []struct {
+		name     string
+		uname    string
+		systemd  bool
+		wantName string
+	}{
+		{name: "systemd", uname: "Linux\n", systemd: true, wantName: "systemd"},
+		{name: "launchd", uname: "Darwin\n", wantName: "launchd"},
+		{name: "linux without systemd", uname: "Linux\n"},
+		{name: "other", uname: "FreeBSD\n"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			runner := &fakeRunner{handle: func(name string, args []string) ([]byte, error) {
+				switch name {
+				case "uname":
+					return []byte(tc.uname), nil
+				case "test":
+					if tc.systemd {
+						return nil, nil
+					}
+					return nil, &fakeExitError{code: 1}
+				case "id":
+					return []byte("501\n"), nil
+				case "sh":
+					return []byte("/Users/admin"), nil
+				}
+				return nil, nil
+			}}
+
+			p, err := newRemoteProvider(runner, nil, remoteFiles{runner: runner}, Options{})
+			if tc.wantName == "" {
+				if err == nil {
+					t.Fatalf("expected an error, got %s", p.Name())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("newRemoteProvider: %v", err)
+			}
+			if p.Name() != tc.wantName {
+				t.Fatalf("expected %s, got %s", tc.wantName, p.Name())
+			}
+			if lp, ok := p.(*LaunchdProvider); ok && (lp.uid != "501" || lp.userHome != "/Users/admin") {
+				t.Fatalf("expected the remote user's uid and home, got %q and %q", lp.uid, lp.userHome)
+			}
+		})
+	}
+}
+
+func TestRemoteFiles(t *testing.T) {
+	runner := &fakeRunner{handle: func(name string, args []string) ([]byte, error) {
+		switch name {
+		case "ls":
+			return []byte("com.example.web.plist\ncom.example.my job.plist\n"), nil
+		case "cat":
+			return []byte("<plist/>"), nil
+		case "test":
+			return nil, &fakeExitError{code: 1}
+		}
+		return nil, nil
+	}}
+	files := remoteFiles{runner: runner}
+
+	names, err := files.ReadDir(t.Context(), "/Library/LaunchDaemons")
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if want := []string{"com.example.web.plist", "com.example.my job.plist"}; !slices.Equal(names, want) {
+		t.Fatalf("expected %v, got %v", want, names)
+	}
+	if data, err := files.ReadFile(t.Context(), "/Library/LaunchDaemons/com.example.web.plist"); err != nil || string(data) != "<plist/>" {
+		t.Fatalf("unexpected ReadFile result %q, %v", data, err)
+	}
+	if files.Exists(t.Context(), "/Library/LaunchDaemons/missing.plist") {
+		t.Fatal("expected missing file to not exist")
+	}
+
+	want := []string{
+		"ls -1A -- /Library/LaunchDaemons",
+		"cat -- /Library/LaunchDaemons/com.example.web.plist",
+		"test -e /Library/LaunchDaemons/missing.plist",
+	}
+	if got := runner.commands(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestSSHProvider_RefusesCreate(t *testing.T) {
+	p := &SSHProvider{ServiceProvider: &SystemdProvider{runner: &fakeRunner{}}}
+
+	if caps := p.Capabilities(); caps.Timers || caps.Hooks || caps.Dependencies || !caps.ResetFailed {
+		t.Fatalf("unexpected capabilities %+v", caps)
+	}
+	config := models.ServiceConfig{Name: "web", Program: "/usr/bin/web"}
+	if err := p.CreateService(t.Context(), config, models.ScopeSystem); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("expected ErrNotSupported, got %v", err)
+	}
+	if _, err := p.CreateTimer(t.Context(), models.TimerConfig{Name: "web"}, models.ScopeSystem); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("expected ErrNotSupported, got %v", err)
+	}
+}
+
+func TestCommandOutput_RemoteStderr(t *testing.T) {
+	err := &remoteExitError{code: 5, Stderr: []byte("Unit web.service not found.\n")}
+	if got := commandOutput(nil, err); got != "Unit web.service not found.\n" {
+		t.Fatalf("expected stderr in output, got %q", got)
+	}
+	if code, ok := ExitCode(err); !ok || code != 5 {
+		t.Fatalf("expected exit code 5, got %d, %v", code, ok)
+	}
+}
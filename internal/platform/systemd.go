@@ -2,14 +2,20 @@ package platform
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
 	"os"
 	"os/exec"
 	"os/user"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"autorun/internal/logger"
 	"autorun/internal/models"
@@ -20,11 +26,34 @@ type SystemdProvider struct {
 	// targetUser is set when running as root to access another user's services
 	// via --machine=<user>@.host
 	targetUser string
+
+	runner   CommandRunner
+	timeouts Timeouts
+
+	// follow runs journalctl for log streams on the SSH provider's remote
+	// host; nil runs it locally
+	follow followFunc
+
+	// noJournal is set when journalctl wasn't found, so logs are refused
+	noJournal bool
 }
 
-// NewSystemdProvider creates a new systemd provider
-func NewSystemdProvider() (*SystemdProvider, error) {
-	p := &SystemdProvider{}
+// ErrSystemdNotRunning is returned when systemctl reports that systemd
+// isn't the init system, as under WSL1 or in most containers
+var ErrSystemdNotRunning = errors.New("systemd is not running as the init system on this host (common under WSL1 and in containers); run autorun where systemd is PID 1, or manage such a host with -remote")
+
+// NewSystemdProvider creates a new systemd provider. It fails with
+// ErrSystemdNotRunning if systemctl reports that systemd is offline.
+func NewSystemdProvider(opts Options) (*SystemdProvider, error) {
+	p := &SystemdProvider{
+		runner:    opts.runner(),
+		timeouts:  opts.Timeouts,
+		noJournal: toolMissing("journalctl"),
+	}
+
+	if err := p.checkRunning(context.Background()); err != nil {
+		return nil, err
+	}
 
 	// If running as root, we need to use --machine=<user>@.host to access
 	// user services via the user's D-Bus session
@@ -43,6 +72,19 @@ func (p *SystemdProvider) Name() string {
 	return "systemd"
 }
 
+// Capabilities reports systemd's feature set. systemd can mask and reload
+// units, but the API has no endpoints for either yet, so both are reported
+// as unavailable. Logs need journalctl, which minimal containers leave out.
+func (p *SystemdProvider) Capabilities() models.Capabilities {
+	return models.Capabilities{
+		Timers:       true,
+		Dependencies: true,
+		Hooks:        true,
+		ResetFailed:  true,
+		Logs:         !p.noJournal,
+	}
+}
+
 // getUserScopeArgs returns the systemctl arguments needed to access user services.
 // When running as root with a target user, uses --machine=<user>@.host --user.
 // Otherwise, just returns --user.
@@ -53,6 +95,99 @@ func (p *SystemdProvider) getUserScopeArgs() []string {
 	return []string{"--user"}
 }
 
+// checkRunning asks systemctl for the system state and returns
+// ErrSystemdNotRunning if systemd is offline. A degraded system (some unit
+// failed) is only logged; other failures are left for the first real call.
+func (p *SystemdProvider) checkRunning(ctx context.Context) error {
+	output, err := p.systemctl(ctx, OpStatus, "is-system-running")
+	if errors.Is(err, ErrSystemdNotRunning) {
+		return err
+	}
+	switch state := strings.TrimSpace(string(output)); state {
+	case "offline":
+		return ErrSystemdNotRunning
+	case "degraded":
+		logger.Warn("systemd reports a degraded system; one or more units have failed")
+	default:
+		if err != nil && state == "" {
+			logger.Debug("systemctl is-system-running failed", "error", err)
+		}
+	}
+	return nil
+}
+
+// systemctl runs systemctl with args, bounded by the timeout for op. When
+// systemctl refuses because systemd isn't the init system, the error is
+// ErrSystemdNotRunning rather than the bare exit status.
+func (p *SystemdProvider) systemctl(ctx context.Context, op string, args ...string) ([]byte, error) {
+	output, err := runCommand(ctx, p.runner, p.timeouts, op, "systemctl", args...)
+	if err != nil && notBootedWithSystemd(commandOutput(output, err)) {
+		return output, ErrSystemdNotRunning
+	}
+	return output, err
+}
+
+// notBootedWithSystemd reports whether systemctl's output says systemd isn't
+// PID 1, e.g. "System has not been booted with systemd as init system (PID 1)"
+func notBootedWithSystemd(output string) bool {
+	return strings.Contains(output, "has not been booted with systemd")
+}
+
+// unitSuffixes are the unit types recognized when deciding whether a name
+// already carries a suffix
+var unitSuffixes = []string{".service", ".timer", ".socket", ".target", ".path", ".mount"}
+
+// unitName returns name with a .service suffix unless it already names a unit
+// type explicitly (e.g. foo.timer).
+func unitName(name string) string {
+	for _, suffix := range unitSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return name
+		}
+	}
+	return name + ".service"
+}
+
+// splitInstance splits an instance of a template unit, such as
+// getty@tty1.service, into its template (getty@.service) and instance
+// (tty1). ok is false for units that aren't instances, including bare
+// templates like getty@.service.
+func splitInstance(unit string) (template, instance string, ok bool) {
+	prefix, rest, found := strings.Cut(unit, "@")
+	if !found {
+		return "", "", false
+	}
+	dot := strings.LastIndex(rest, ".")
+	if dot <= 0 {
+		return "", "", false
+	}
+	return prefix + "@" + rest[dot:], rest[:dot], true
+}
+
+// isTemplate reports whether unit is a template with no instance, such as
+// getty@.service, which systemd can't start or stop by itself
+func isTemplate(unit string) bool {
+	prefix, rest, found := strings.Cut(unit, "@")
+	return found && prefix != "" && strings.HasPrefix(rest, ".")
+}
+
+// unitDir returns the directory unit files for scope are written to
+func unitDir(scope models.Scope) (string, error) {
+	switch scope {
+	case models.ScopeUser:
+		u, err := user.Current()
+		if err != nil {
+			logger.Error("failed to get current user", "error", err)
+			return "", fmt.Errorf("failed to get current user: %w", err)
+		}
+		return filepath.Join(u.HomeDir, ".config", "systemd", "user"), nil
+	case models.ScopeSystem:
+		return "/etc/systemd/system", nil
+	default:
+		return "", fmt.Errorf("invalid scope: %s", scope)
+	}
+}
+
 // systemdUnit represents a unit from systemctl list-units --output=json
 type systemdUnit struct {
 	Unit        string `json:"unit"`
@@ -62,20 +197,20 @@ type systemdUnit struct {
 	Description string `json:"description"`
 }
 
-func (p *SystemdProvider) listUnits(scope models.Scope) ([]systemdUnit, error) {
+func (p *SystemdProvider) listUnits(ctx context.Context, scope models.Scope) ([]systemdUnit, error) {
 	var args []string
 
 	if scope == models.ScopeUser {
 		args = append(args, p.getUserScopeArgs()...)
 	}
-	args = append(args, "list-units", "--type=service", "--all", "--output=json")
+	args = append(args, "list-units", "--type=service,timer,socket", "--all", "--output=json")
 
 	logger.Debug("executing systemctl", "args", args)
-	cmd := exec.Command("systemctl", args...)
-	output, err := cmd.Output()
+	output, err := p.systemctl(ctx, OpList, args...)
 	if err != nil {
 		// Get stderr for more details
-		if exitErr, ok := err.(*exec.ExitError); ok {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
 			logger.Error("systemctl list-units failed", "scope", scope, "error", err, "stderr", string(exitErr.Stderr))
 		} else {
 			logger.Error("systemctl list-units failed", "scope", scope, "error", err)
@@ -83,6 +218,13 @@ func (p *SystemdProvider) listUnits(scope models.Scope) ([]systemdUnit, error) {
 		return nil, fmt.Errorf("systemctl list-units failed: %w", err)
 	}
 
+	// Some systemd versions print nothing at all rather than [] when no
+	// units match
+	if len(bytes.TrimSpace(output)) == 0 {
+		logger.Debug("systemctl list-units printed no output", "scope", scope)
+		return []systemdUnit{}, nil
+	}
+
 	var units []systemdUnit
 	if err := json.Unmarshal(output, &units); err != nil {
 		logger.Error("failed to parse systemctl output", "error", err, "output", string(output[:min(len(output), 200)]))
@@ -93,193 +235,679 @@ func (p *SystemdProvider) listUnits(scope models.Scope) ([]systemdUnit, error) {
 	return units, nil
 }
 
-func (p *SystemdProvider) isEnabled(name string, scope models.Scope) bool {
+// systemdUnitFile represents a unit from systemctl list-unit-files --output=json
+type systemdUnitFile struct {
+	UnitFile string `json:"unit_file"`
+	State    string `json:"state"`
+}
+
+// unitFileStates returns the state (enabled, static, ...) of every unit
+// file in the scope with a single `systemctl list-unit-files`, keyed by
+// unit name
+func (p *SystemdProvider) unitFileStates(ctx context.Context, scope models.Scope) (map[string]string, error) {
+	var args []string
+	if scope == models.ScopeUser {
+		args = append(args, p.getUserScopeArgs()...)
+	}
+	args = append(args, "list-unit-files", "--type=service,timer,socket", "--output=json")
+
+	output, err := p.systemctl(ctx, OpList, args...)
+	if err != nil {
+		return nil, newCommandError(err, "systemctl list-unit-files failed: "+strings.TrimSpace(commandOutput(output, err)))
+	}
+
+	var files []systemdUnitFile
+	if err := json.Unmarshal(output, &files); err != nil {
+		return nil, fmt.Errorf("failed to parse systemctl list-unit-files output: %w", err)
+	}
+
+	states := make(map[string]string, len(files))
+	for _, f := range files {
+		states[filepath.Base(f.UnitFile)] = f.State
+	}
+	return states, nil
+}
+
+// enableState returns the unit's `systemctl is-enabled` state, or "" if it
+// couldn't be read
+func (p *SystemdProvider) enableState(ctx context.Context, name string, scope models.Scope) string {
 	var args []string
 	if scope == models.ScopeUser {
 		args = append(args, p.getUserScopeArgs()...)
 	}
 	args = append(args, "is-enabled", name)
 
-	cmd := exec.Command("systemctl", args...)
-	output, _ := cmd.Output()
-	return strings.TrimSpace(string(output)) == "enabled"
+	output, _ := p.systemctl(ctx, OpStatus, args...)
+	return strings.TrimSpace(string(output))
+}
+
+// unitFileEnabled maps a unit file state to Service.Enabled. enabled and
+// enabled-runtime units start at boot (the latter until the next reboot);
+// static, indirect, generated and alias units are activated through other
+// units or generators and can't be enabled themselves, so they count as
+// enabled too. disabled, masked, linked, transient and bad units don't.
+func unitFileEnabled(state string) bool {
+	switch state {
+	case "enabled", "enabled-runtime", "static", "indirect", "generated", "alias":
+		return true
+	}
+	return false
 }
 
-func (p *SystemdProvider) ListServices(scope models.Scope) ([]models.Service, error) {
-	units, err := p.listUnits(scope)
+func (p *SystemdProvider) ListServices(ctx context.Context, scope models.Scope) ([]models.Service, error) {
+	units, err := p.listUnits(ctx, scope)
 	if err != nil {
 		return nil, err
 	}
 
+	// Units missing from the unit file listing (template instances such as
+	// getty@tty1, transient units) are asked about individually, as is every
+	// unit on older systemd that can't print unit files as JSON
+	stateByUnit, err := p.unitFileStates(ctx, scope)
+	if err != nil {
+		logger.Debug("falling back to per-unit is-enabled", "scope", scope, "error", err)
+	}
+
 	var services []models.Service
 	for _, unit := range units {
-		// Extract service name without .service suffix
-		name := unit.Unit
-		if strings.HasSuffix(name, ".service") {
-			name = strings.TrimSuffix(name, ".service")
-		}
+		// Extract service name without .service suffix. Timers and sockets
+		// keep theirs so they don't collide with the service they activate.
+		name := strings.TrimSuffix(unit.Unit, ".service")
 
-		status := models.StatusUnknown
-		switch unit.Active {
-		case "active":
-			if unit.Sub == "running" {
-				status = models.StatusRunning
-			} else {
-				status = models.StatusStopped
-			}
-		case "inactive":
-			status = models.StatusStopped
-		case "failed":
-			status = models.StatusFailed
+		enableState, ok := stateByUnit[unit.Unit]
+		if !ok {
+			enableState = p.enableState(ctx, unit.Unit, scope)
 		}
 
 		services = append(services, models.Service{
 			Name:        name,
 			DisplayName: name,
-			Status:      status,
-			Enabled:     p.isEnabled(unit.Unit, scope),
+			Status:      unitStatus(unit.Active, unit.Sub),
+			Enabled:     unitFileEnabled(enableState),
+			EnableState: enableState,
 			Scope:       scope,
 			Description: unit.Description,
+			Type:        systemdUnitType(unit.Unit),
 		})
 	}
 
+	p.fillFailureDetails(ctx, services, scope)
 	return services, nil
 }
 
-func (p *SystemdProvider) GetService(name string, scope models.Scope) (*models.Service, error) {
-	services, err := p.ListServices(scope)
+// fillFailureDetails sets ExitCode and FailureReason on failed services.
+// Only failed units are queried, with a single `systemctl show`, so healthy
+// lists cost nothing extra. Errors leave the details empty.
+func (p *SystemdProvider) fillFailureDetails(ctx context.Context, services []models.Service, scope models.Scope) {
+	var failed []int
+	for i, svc := range services {
+		if svc.Status == models.StatusFailed {
+			failed = append(failed, i)
+		}
+	}
+	if len(failed) == 0 {
+		return
+	}
+
+	var args []string
+	if scope == models.ScopeUser {
+		args = append(args, p.getUserScopeArgs()...)
+	}
+	args = append(args, "show", "--property=Id,ExecMainStatus,Result")
+	for _, i := range failed {
+		args = append(args, unitName(services[i].Name))
+	}
+
+	output, err := p.systemctl(ctx, OpStatus, args...)
 	if err != nil {
-		return nil, err
+		logger.Warn("failed to query failure details", "scope", scope, "error", err)
+		return
+	}
+
+	// systemctl prints one block per unit, in argument order
+	blocks := parseShowBlocks(string(output))
+	for n, i := range failed {
+		if n >= len(blocks) {
+			break
+		}
+		services[i].ExitCode, _ = strconv.Atoi(blocks[n]["ExecMainStatus"])
+		if result := blocks[n]["Result"]; result != "success" {
+			services[i].FailureReason = result
+		}
+	}
+}
+
+// systemdUnitType maps a unit name's suffix to a Service.Type
+func systemdUnitType(unit string) string {
+	switch {
+	case strings.HasSuffix(unit, ".timer"):
+		return models.TypeTimer
+	case strings.HasSuffix(unit, ".socket"):
+		return models.TypeSocket
+	default:
+		return models.TypeService
 	}
+}
 
-	for _, svc := range services {
-		if svc.Name == name || svc.Name+".service" == name {
-			return &svc, nil
+// unitStatus maps a unit's active and sub state to a service status
+func unitStatus(active, sub string) string {
+	switch active {
+	case "active":
+		// A timer waiting for its next elapse or a socket listening for
+		// connections is armed, so count it as running
+		if sub == "running" || sub == "waiting" || sub == "listening" {
+			return models.StatusRunning
 		}
+		return models.StatusStopped
+	case "inactive":
+		return models.StatusStopped
+	case "failed":
+		return models.StatusFailed
+	case "activating", "reloading":
+		return models.StatusStarting
+	case "deactivating":
+		return models.StatusStopping
+	default:
+		return models.StatusUnknown
+	}
+}
+
+// ServiceStates queries all named units with a single `systemctl show`
+func (p *SystemdProvider) ServiceStates(ctx context.Context, names []string, scope models.Scope) (map[string]models.ServiceState, error) {
+	states := make(map[string]models.ServiceState, len(names))
+	if len(names) == 0 {
+		return states, nil
+	}
+
+	var args []string
+	if scope == models.ScopeUser {
+		args = append(args, p.getUserScopeArgs()...)
+	}
+	args = append(args, "show", "--property=Id,LoadState,ActiveState,SubState,UnitFileState,MainPID")
+	for _, name := range names {
+		args = append(args, unitName(name))
+	}
+
+	output, err := p.systemctl(ctx, OpStatus, args...)
+	if err != nil {
+		return nil, newCommandError(err, "systemctl show failed: "+strings.TrimSpace(commandOutput(output, err)))
 	}
 
-	return nil, fmt.Errorf("service not found: %s", name)
+	// systemctl prints one block per unit, in argument order
+	blocks := parseShowBlocks(string(output))
+	for i, name := range names {
+		if i >= len(blocks) || blocks[i]["LoadState"] == "not-found" {
+			states[name] = models.ServiceState{Status: models.StatusUnknown, NotFound: true}
+			continue
+		}
+		props := blocks[i]
+		pid, _ := strconv.Atoi(props["MainPID"])
+		states[name] = models.ServiceState{
+			Status:      unitStatus(props["ActiveState"], props["SubState"]),
+			Enabled:     unitFileEnabled(props["UnitFileState"]),
+			EnableState: props["UnitFileState"],
+			PID:         pid,
+		}
+	}
+	return states, nil
+}
+
+// parseShowBlocks splits `systemctl show` output for several units into one
+// property map per unit. Blocks are separated by blank lines.
+func parseShowBlocks(output string) []map[string]string {
+	var blocks []map[string]string
+	var current map[string]string
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			current = nil
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		if current == nil {
+			current = make(map[string]string)
+			blocks = append(blocks, current)
+		}
+		current[key] = value
+	}
+	return blocks
 }
 
-func (p *SystemdProvider) runSystemctl(action, name string, scope models.Scope) error {
+// getServiceProperties are read by GetService in a single `systemctl show`
+const getServiceProperties = "Id,LoadState,ActiveState,SubState,UnitFileState,Description,Documentation,User,MainPID,ExecMainStatus,Result"
+
+// GetService reads one unit with a single `systemctl show` rather than
+// listing every unit and checking each one's enabled state
+func (p *SystemdProvider) GetService(ctx context.Context, name string, scope models.Scope) (*models.Service, error) {
+	unit := unitName(name)
+
 	var args []string
 	if scope == models.ScopeUser {
 		args = append(args, p.getUserScopeArgs()...)
 	}
+	args = append(args, "show", "--property="+getServiceProperties, unit)
 
-	// Ensure .service suffix
-	if !strings.HasSuffix(name, ".service") {
-		name = name + ".service"
+	output, err := p.systemctl(ctx, OpStatus, args...)
+	if err != nil {
+		return nil, newCommandError(err, "systemctl show failed: "+strings.TrimSpace(commandOutput(output, err)))
 	}
 
+	blocks := parseShowBlocks(string(output))
+	if len(blocks) == 0 || blocks[0]["LoadState"] == "not-found" {
+		return nil, fmt.Errorf("service %w: %s", ErrNotFound, name)
+	}
+	props := blocks[0]
+
+	serviceName := strings.TrimSuffix(unit, ".service")
+	svc := &models.Service{
+		Name:          serviceName,
+		DisplayName:   serviceName,
+		Status:        unitStatus(props["ActiveState"], props["SubState"]),
+		Enabled:       unitFileEnabled(props["UnitFileState"]),
+		EnableState:   props["UnitFileState"],
+		Scope:         scope,
+		Description:   props["Description"],
+		Type:          systemdUnitType(unit),
+		Documentation: parseDocumentation(props["Documentation"]),
+		RunAs:         p.runAs(ctx, props, scope),
+	}
+	if svc.Status == models.StatusFailed {
+		svc.ExitCode, _ = strconv.Atoi(props["ExecMainStatus"])
+		if result := props["Result"]; result != "success" {
+			svc.FailureReason = result
+		}
+	}
+	return svc, nil
+}
+
+// Processes lists every process in the unit's control group, as shown in
+// the CGroup tree of `systemctl status`.
+func (p *SystemdProvider) Processes(ctx context.Context, name string, scope models.Scope) ([]models.Process, error) {
+	var args []string
+	if scope == models.ScopeUser {
+		args = append(args, p.getUserScopeArgs()...)
+	}
+	args = append(args, "status", "--full", "--no-pager", "--lines=0", unitName(name))
+
+	output, err := p.systemctl(ctx, OpStatus, args...)
+	if err != nil {
+		// status exits 3 for inactive units but still prints their state
+		if code, ok := ExitCode(err); !ok || code != 3 {
+			return nil, newCommandError(err, "systemctl status failed: "+strings.TrimSpace(commandOutput(output, err)))
+		}
+	}
+	return parseStatusCGroup(string(output)), nil
+}
+
+// parseStatusCGroup extracts processes from the CGroup tree printed by
+// `systemctl status`, e.g.
+//
+//	CGroup: /system.slice/nginx.service
+//	        ├─1201 "nginx: master process /usr/sbin/nginx"
+//	        └─1202 "nginx: worker process"
+//
+// Nested cgroup lines (which carry no PID) are skipped.
+func parseStatusCGroup(output string) []models.Process {
+	processes := []models.Process{}
+	inCGroup := false
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !inCGroup {
+			inCGroup = strings.HasPrefix(line, "CGroup:")
+			continue
+		}
+		if line == "" {
+			break
+		}
+
+		// Strip tree drawing, in both UTF-8 and ASCII forms
+		line = strings.TrimLeft(line, "├└│─|`- ")
+		pidText, command, _ := strings.Cut(line, " ")
+		pid, err := strconv.Atoi(pidText)
+		if err != nil {
+			continue
+		}
+		processes = append(processes, models.Process{PID: pid, Command: strings.TrimSpace(command)})
+	}
+	return processes
+}
+
+// runAs returns the account a unit runs as, from its `systemctl show`
+// properties: User= when set, else the owner of its running main process,
+// else the default for the scope (root for system units, the service
+// manager's user for user units)
+func (p *SystemdProvider) runAs(ctx context.Context, props map[string]string, scope models.Scope) string {
+	if u := props["User"]; u != "" {
+		return u
+	}
+	if pid, _ := strconv.Atoi(props["MainPID"]); pid > 0 {
+		if owner := processOwner(ctx, p.runner, p.timeouts, pid); owner != "" {
+			return owner
+		}
+	}
+
+	if scope == models.ScopeSystem {
+		return "root"
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return ""
+}
+
+// parseDocumentation parses `systemctl show --property=Documentation` output,
+// e.g. "Documentation=man:sshd(8) https://www.openssh.com/", into its URLs.
+func parseDocumentation(output string) []string {
+	value := strings.TrimSpace(output)
+	value = strings.TrimPrefix(value, "Documentation=")
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
+
+func (p *SystemdProvider) runSystemctl(ctx context.Context, action, name string, scope models.Scope) error {
+	var args []string
+	if scope == models.ScopeUser {
+		args = append(args, p.getUserScopeArgs()...)
+	}
+
+	name = unitName(name)
+	// Only enable and disable accept a template (using its DefaultInstance)
+	if isTemplate(name) && action != "enable" && action != "disable" {
+		return fmt.Errorf("%s is a template; name one of its instances instead, e.g. %s", name, strings.Replace(name, "@.", "@<instance>.", 1))
+	}
 	args = append(args, action, name)
 	logger.Debug("executing systemctl", "action", action, "name", name, "args", args)
-	cmd := exec.Command("systemctl", args...)
-	if output, err := cmd.CombinedOutput(); err != nil {
-		logger.Error("systemctl command failed", "action", action, "name", name, "scope", scope, "error", err, "output", string(output))
-		return fmt.Errorf("systemctl %s failed: %s", action, string(output))
+	if output, err := p.systemctl(ctx, OpAction, args...); err != nil {
+		text := commandOutput(output, err)
+		logger.Error("systemctl command failed", "action", action, "name", name, "scope", scope, "error", err, "output", text)
+		if text == "" {
+			text = err.Error()
+		}
+		return newCommandError(err, fmt.Sprintf("systemctl %s failed: %s", action, text))
 	}
 	logger.Debug("systemctl command succeeded", "action", action, "name", name)
 	return nil
 }
 
-func (p *SystemdProvider) Start(name string, scope models.Scope) error {
-	return p.runSystemctl("start", name, scope)
+func (p *SystemdProvider) Start(ctx context.Context, name string, scope models.Scope) error {
+	return p.runSystemctl(ctx, "start", name, scope)
 }
 
-func (p *SystemdProvider) Stop(name string, scope models.Scope) error {
-	return p.runSystemctl("stop", name, scope)
+func (p *SystemdProvider) Stop(ctx context.Context, name string, scope models.Scope) error {
+	return p.runSystemctl(ctx, "stop", name, scope)
 }
 
-func (p *SystemdProvider) Restart(name string, scope models.Scope) error {
-	return p.runSystemctl("restart", name, scope)
+func (p *SystemdProvider) Restart(ctx context.Context, name string, scope models.Scope) error {
+	return p.runSystemctl(ctx, "restart", name, scope)
 }
 
-func (p *SystemdProvider) Enable(name string, scope models.Scope) error {
-	return p.runSystemctl("enable", name, scope)
+func (p *SystemdProvider) Enable(ctx context.Context, name string, scope models.Scope) error {
+	return p.runSystemctl(ctx, "enable", name, scope)
 }
 
-func (p *SystemdProvider) Disable(name string, scope models.Scope) error {
-	return p.runSystemctl("disable", name, scope)
+func (p *SystemdProvider) Disable(ctx context.Context, name string, scope models.Scope) error {
+	return p.runSystemctl(ctx, "disable", name, scope)
 }
 
-func (p *SystemdProvider) StreamLogs(ctx context.Context, name string, scope models.Scope) (<-chan string, error) {
-	ch := make(chan string, 100)
-
-	var args []string
-	args = append(args, "-f", "-n", "100") // Follow, last 100 lines
+// ResetFailed runs `systemctl reset-failed`, which also resets the unit's
+// start rate limit so a crash-looping service can be restarted
+func (p *SystemdProvider) ResetFailed(ctx context.Context, name string, scope models.Scope) error {
+	return p.runSystemctl(ctx, "reset-failed", name, scope)
+}
 
+// journalUnitArgs selects a unit's journal entries for journalctl
+func (p *SystemdProvider) journalUnitArgs(name string, scope models.Scope) []string {
 	if scope == models.ScopeUser {
 		// When running as root with a target user, use --machine to access their journal
 		if p.targetUser != "" {
-			args = append(args, "--machine="+p.targetUser+"@.host", "--user-unit", name+".service")
-		} else {
-			args = append(args, "--user-unit", name+".service")
+			return []string{"--machine=" + p.targetUser + "@.host", "--user-unit", unitName(name)}
 		}
-	} else {
-		args = append(args, "-u", name+".service")
+		return []string{"--user-unit", unitName(name)}
 	}
+	return []string{"-u", unitName(name)}
+}
 
-	logger.Debug("starting journalctl", "args", args)
-	cmd := exec.CommandContext(ctx, "journalctl", args...)
+// LogCounts counts warning- and error-level journal entries for a unit
+// since the given time, or since boot if since is zero
+func (p *SystemdProvider) LogCounts(ctx context.Context, name string, scope models.Scope, since time.Time) (models.LogCounts, error) {
+	if p.noJournal {
+		return models.LogCounts{}, logsUnavailable("journalctl")
+	}
+	args := []string{"--priority=warning", "--output=json", "--output-fields=PRIORITY", "--no-pager", "--quiet"}
+	if since.IsZero() {
+		args = append(args, "--boot")
+	} else {
+		args = append(args, "--since=@"+strconv.FormatInt(since.Unix(), 10))
+	}
+	args = append(args, p.journalUnitArgs(name, scope)...)
 
-	stdout, err := cmd.StdoutPipe()
+	output, err := runCommand(ctx, p.runner, p.timeouts, OpList, "journalctl", args...)
 	if err != nil {
-		logger.Error("failed to create stdout pipe", "error", err)
-		return nil, fmt.Errorf("failed to create stdout pipe: %w", err)
+		return models.LogCounts{}, newCommandError(err, "journalctl failed: "+strings.TrimSpace(commandOutput(output, err)))
 	}
+	return countJournalPriorities(string(output)), nil
+}
 
-	if err := cmd.Start(); err != nil {
-		logger.Error("failed to start journalctl", "name", name, "scope", scope, "error", err)
-		return nil, fmt.Errorf("failed to start journalctl: %w", err)
+// countJournalPriorities tallies `journalctl --output=json` entries by
+// syslog priority: 0-3 (emerg..err) are errors and 4 is a warning.
+func countJournalPriorities(output string) models.LogCounts {
+	var counts models.LogCounts
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry struct {
+			Priority string `json:"PRIORITY"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		priority, err := strconv.Atoi(entry.Priority)
+		if err != nil {
+			continue
+		}
+		switch {
+		case priority <= 3:
+			counts.Errors++
+		case priority == 4:
+			counts.Warnings++
+		}
 	}
+	return counts
+}
 
-	logger.Debug("journalctl started", "name", name, "scope", scope)
+func (p *SystemdProvider) StreamLogs(ctx context.Context, name string, scope models.Scope, opts models.LogStreamOptions) (<-chan string, error) {
+	ch := make(chan string, 100)
 
-	go func() {
-		defer close(ch)
-		defer cmd.Wait()
-
-		scanner := bufio.NewScanner(stdout)
-		for scanner.Scan() {
-			select {
-			case <-ctx.Done():
-				logger.Debug("log stream context cancelled", "name", name)
-				return
-			case ch <- scanner.Text():
-			}
+	args := []string{"-f", "-n", strconv.Itoa(opts.History)} // Follow after the last N lines
+	args = append(args, journalPriorityArgs(opts.Level)...)
+	args = append(args, p.journalUnitArgs(name, scope)...)
+
+	err := p.followJournal(ctx, args, func() { close(ch) }, func(line string) bool {
+		select {
+		case <-ctx.Done():
+			return false
+		case ch <- line:
+			return true
 		}
-		logger.Debug("log stream ended", "name", name)
-	}()
+	})
+	if err != nil {
+		logger.Error("failed to start journalctl", "name", name, "scope", scope, "error", err)
+		return nil, err
+	}
+	logger.Debug("journalctl started", "name", name, "scope", scope)
+	return ch, nil
+}
 
+// StreamLogEntries follows a unit's journal as JSON and parses each entry
+func (p *SystemdProvider) StreamLogEntries(ctx context.Context, name string, scope models.Scope, opts models.LogStreamOptions) (<-chan models.LogEntry, error) {
+	ch := make(chan models.LogEntry, 100)
+
+	args := []string{"-f", "-n", strconv.Itoa(opts.History), "--output=json"}
+	args = append(args, journalPriorityArgs(opts.Level)...)
+	args = append(args, p.journalUnitArgs(name, scope)...)
+
+	err := p.followJournal(ctx, args, func() { close(ch) }, func(line string) bool {
+		entry, ok := parseJournalEntry(line)
+		if !ok {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case ch <- entry:
+			return true
+		}
+	})
+	if err != nil {
+		logger.Error("failed to start journalctl", "name", name, "scope", scope, "error", err)
+		return nil, err
+	}
 	return ch, nil
 }
 
+// followJournal starts `journalctl` with args and follows its output with
+// followCommand, or with p.follow on a remote host
+func (p *SystemdProvider) followJournal(ctx context.Context, args []string, done func(), emit func(line string) bool) error {
+	if p.noJournal {
+		return logsUnavailable("journalctl")
+	}
+	logger.Debug("starting journalctl", "args", args)
+	if p.follow != nil {
+		return p.follow(ctx, nil, done, emit, "journalctl", args...)
+	}
+	return followCommand(exec.CommandContext(ctx, "journalctl", args...), nil, done, emit)
+}
+
+// parseJournalEntry parses one line of `journalctl --output=json`. MESSAGE
+// is usually a string but journald emits an array of bytes for messages
+// that aren't valid UTF-8.
+func parseJournalEntry(line string) (models.LogEntry, bool) {
+	var fields struct {
+		Timestamp string          `json:"__REALTIME_TIMESTAMP"`
+		Priority  string          `json:"PRIORITY"`
+		Message   json.RawMessage `json:"MESSAGE"`
+	}
+	if err := json.Unmarshal([]byte(line), &fields); err != nil {
+		return models.LogEntry{}, false
+	}
+
+	entry := models.LogEntry{Level: "info", Raw: line}
+	if usec, err := strconv.ParseInt(fields.Timestamp, 10, 64); err == nil {
+		entry.Time = time.UnixMicro(usec).UTC()
+	}
+	if priority, err := strconv.Atoi(fields.Priority); err == nil {
+		entry.Level = syslogLevel(priority)
+	}
+
+	var text string
+	var raw []byte
+	switch {
+	case json.Unmarshal(fields.Message, &text) == nil:
+		entry.Message = text
+	case json.Unmarshal(fields.Message, &raw) == nil:
+		entry.Message = string(raw)
+	}
+	return entry, true
+}
+
+// journalPriorityArgs pushes a minimum log level down to journalctl
+func journalPriorityArgs(level string) []string {
+	priorities := map[string]string{
+		"critical": "crit",
+		"error":    "err",
+		"warning":  "warning",
+		"notice":   "notice",
+		"info":     "info",
+		"debug":    "debug",
+	}
+	if priority, ok := priorities[level]; ok {
+		return []string{"-p", priority}
+	}
+	return nil
+}
+
+// syslogLevel names a syslog priority, folding emerg, alert and crit
+// together
+func syslogLevel(priority int) string {
+	switch {
+	case priority <= 2:
+		return "critical"
+	case priority == 3:
+		return "error"
+	case priority == 4:
+		return "warning"
+	case priority == 5:
+		return "notice"
+	case priority == 6:
+		return "info"
+	default:
+		return "debug"
+	}
+}
+
+// ServiceExists reports whether a unit file for name is in the directory
+// CreateService writes to. An instance such as foo@bar also exists when its
+// template foo@.service is there.
+func (p *SystemdProvider) ServiceExists(ctx context.Context, name string, scope models.Scope) (bool, error) {
+	targetDir, err := unitDir(scope)
+	if err != nil {
+		return false, err
+	}
+	unit := unitName(name)
+	if exists, err := fileExists(filepath.Join(targetDir, unit)); exists || err != nil {
+		return exists, err
+	}
+	if template, _, ok := splitInstance(unit); ok {
+		return fileExists(filepath.Join(targetDir, template))
+	}
+	return false, nil
+}
+
 // CreateService creates a new systemd service with the given configuration
-func (p *SystemdProvider) CreateService(config models.ServiceConfig, scope models.Scope) error {
+func (p *SystemdProvider) CreateService(ctx context.Context, config models.ServiceConfig, scope models.Scope) error {
 	logger.Debug("creating systemd service", "name", config.Name, "program", config.Program, "scope", scope)
 
-	if config.Name == "" {
-		return fmt.Errorf("service name is required")
+	if err := config.Validate(); err != nil {
+		return err
 	}
-	if config.Program == "" {
-		return fmt.Errorf("program path is required")
+	if !models.ValidServiceType(config.Type) {
+		return fmt.Errorf("unknown service type %q (expected one of %s)", config.Type, strings.Join(models.ServiceTypes, ", "))
+	}
+	if err := validateRunAs(config, scope); err != nil {
+		return err
+	}
+	if err := validateRestart(config); err != nil {
+		return err
+	}
+	if err := validateEnvironmentFile(config); err != nil {
+		return err
+	}
+	if err := validateWantedBy(config.WantedBy); err != nil {
+		return err
+	}
+	if err := validateSchedule(config); err != nil {
+		return err
+	}
+	for _, hook := range append(append([]string(nil), config.ExecStartPre...), config.ExecStopPost...) {
+		if _, err := execHookLine(hook); err != nil {
+			return fmt.Errorf("invalid hook command %q: %w", hook, err)
+		}
 	}
 
 	// Determine the target directory
-	var targetDir string
-	switch scope {
-	case models.ScopeUser:
-		u, err := user.Current()
-		if err != nil {
-			logger.Error("failed to get current user", "error", err)
-			return fmt.Errorf("failed to get current user: %w", err)
-		}
-		targetDir = filepath.Join(u.HomeDir, ".config", "systemd", "user")
-	case models.ScopeSystem:
-		targetDir = "/etc/systemd/system"
-	default:
-		return fmt.Errorf("invalid scope: %s", scope)
+	targetDir, err := unitDir(scope)
+	if err != nil {
+		return err
 	}
 
 	logger.Debug("target directory", "dir", targetDir)
@@ -290,21 +918,22 @@ func (p *SystemdProvider) CreateService(config models.ServiceConfig, scope model
 		return fmt.Errorf("failed to create directory %s: %w", targetDir, err)
 	}
 
-	// Service name for file
-	serviceName := config.Name
-	if !strings.HasSuffix(serviceName, ".service") {
-		serviceName = serviceName + ".service"
-	}
-
 	// Check if service already exists
-	unitPath := filepath.Join(targetDir, serviceName)
+	unitPath := filepath.Join(targetDir, unitName(config.Name))
 	if _, err := os.Stat(unitPath); err == nil {
 		logger.Warn("service already exists", "name", config.Name, "path", unitPath)
-		return fmt.Errorf("service %s already exists", config.Name)
+		return fmt.Errorf("service %s %w", config.Name, ErrAlreadyExists)
+	}
+	timerPath := filepath.Join(targetDir, timerUnitName(config.Name))
+	if config.Schedule != "" {
+		if _, err := os.Stat(timerPath); err == nil {
+			logger.Warn("timer already exists", "name", config.Name, "path", timerPath)
+			return fmt.Errorf("service %s %w", config.Name, ErrAlreadyExists)
+		}
 	}
 
 	// Generate the unit file content
-	unitContent := p.generateUnitFile(config)
+	unitContent := p.generateUnitFile(config, scope)
 
 	// Write the unit file
 	logger.Debug("writing unit file", "path", unitPath)
@@ -313,22 +942,50 @@ func (p *SystemdProvider) CreateService(config models.ServiceConfig, scope model
 		return fmt.Errorf("failed to write unit file: %w", err)
 	}
 
+	// A scheduled service is activated by a paired timer of the same name
+	if config.Schedule != "" {
+		timerContent := generateTimerUnit(config.Name, config.Schedule, false)
+		logger.Debug("writing timer unit", "path", timerPath)
+		if err := os.WriteFile(timerPath, []byte(timerContent), 0644); err != nil {
+			logger.Error("failed to write unit file", "path", timerPath, "error", err)
+			os.Remove(unitPath)
+			return fmt.Errorf("failed to write unit file: %w", err)
+		}
+	}
+
 	// Reload systemd to pick up the new unit
 	logger.Debug("reloading systemd daemon")
-	if err := p.daemonReload(scope); err != nil {
+	if err := p.daemonReload(ctx, scope); err != nil {
 		logger.Error("daemon reload failed, cleaning up", "error", err)
 		os.Remove(unitPath)
+		if config.Schedule != "" {
+			os.Remove(timerPath)
+		}
 		return fmt.Errorf("failed to reload systemd: %w", err)
 	}
 
+	// The timer, not the oneshot service, is what gets enabled
+	if config.Schedule != "" {
+		timerUnit := timerUnitName(config.Name)
+		logger.Debug("enabling and starting timer", "name", timerUnit)
+		if err := p.runSystemctl(ctx, "enable", timerUnit, scope); err != nil {
+			return fmt.Errorf("failed to enable timer: %w", err)
+		}
+		if err := p.runSystemctl(ctx, "start", timerUnit, scope); err != nil {
+			return fmt.Errorf("failed to start timer: %w", err)
+		}
+		logger.Debug("scheduled service created successfully", "name", config.Name)
+		return nil
+	}
+
 	// Enable and start the service if RunAtLoad is set
 	if config.RunAtLoad {
 		logger.Debug("enabling and starting service", "name", config.Name)
-		if err := p.Enable(config.Name, scope); err != nil {
+		if err := p.Enable(ctx, config.Name, scope); err != nil {
 			logger.Error("failed to enable service", "name", config.Name, "error", err)
 			return fmt.Errorf("failed to enable service: %w", err)
 		}
-		if err := p.Start(config.Name, scope); err != nil {
+		if err := p.Start(ctx, config.Name, scope); err != nil {
 			logger.Error("failed to start service", "name", config.Name, "error", err)
 			return fmt.Errorf("failed to start service: %w", err)
 		}
@@ -338,73 +995,442 @@ func (p *SystemdProvider) CreateService(config models.ServiceConfig, scope model
 	return nil
 }
 
+// execCommandLine joins a program and its arguments into an Exec*= value
+func execCommandLine(program string, arguments []string) string {
+	words := make([]string, 0, len(arguments)+1)
+	words = append(words, quoteExecWord(program))
+	for _, arg := range arguments {
+		words = append(words, quoteExecWord(arg))
+	}
+	return strings.Join(words, " ")
+}
+
+// execHookLine converts a hook command such as `mkdir -p "/run/my app"` into
+// an Exec*= value, escaped the same way as ExecStart.
+func execHookLine(command string) (string, error) {
+	words, err := splitUnitWords(command)
+	if err != nil {
+		return "", err
+	}
+	if len(words) == 0 {
+		return "", fmt.Errorf("empty command")
+	}
+	return execCommandLine(words[0], words[1:]), nil
+}
+
+// hookCommand rebuilds a hook command string from the unescaped words of an
+// Exec*= line, the inverse of execHookLine.
+func hookCommand(words []string) string {
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		if word != "" && !strings.ContainsAny(word, " \t\n\"'\\;") {
+			quoted[i] = word
+		} else {
+			quoted[i] = `"` + execQuoter.Replace(word) + `"`
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+// execQuoter escapes characters that are special inside a quoted word
+var execQuoter = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
+
+// quoteExecWord escapes a single word of an Exec*= line so systemd passes
+// it through verbatim: "%" and "$" are doubled so they are not expanded as
+// specifiers or variables, and words containing whitespace, quotes,
+// backslashes or ";" are double-quoted with C-style escapes.
+func quoteExecWord(word string) string {
+	word = strings.ReplaceAll(word, "%", "%%")
+	word = strings.ReplaceAll(word, "$", "$$")
+	if word != "" && !strings.ContainsAny(word, " \t\n\"'\\;") {
+		return word
+	}
+	return `"` + execQuoter.Replace(word) + `"`
+}
+
+// unescapeExecWord reverses the specifier and variable escaping applied by
+// quoteExecWord to a word already split by splitUnitWords.
+func unescapeExecWord(word string) string {
+	word = strings.ReplaceAll(word, "%%", "%")
+	return strings.ReplaceAll(word, "$$", "$")
+}
+
+// specifierEscaper doubles "%" so systemd does not expand it as a specifier
+// in a setting that accepts them
+var specifierEscaper = strings.NewReplacer("%", "%%")
+
+// specifierUnescaper reverses specifierEscaper when a unit is read back
+var specifierUnescaper = strings.NewReplacer("%%", "%")
+
+// envQuoter escapes an Environment= value for use inside double quotes
+var envQuoter = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
 // generateUnitFile creates the systemd unit file content for a service configuration
-func (p *SystemdProvider) generateUnitFile(config models.ServiceConfig) string {
+func (p *SystemdProvider) generateUnitFile(config models.ServiceConfig, scope models.Scope) string {
 	var sb strings.Builder
 
 	// [Unit] section
 	sb.WriteString("[Unit]\n")
 	if config.Description != "" {
-		sb.WriteString(fmt.Sprintf("Description=%s\n", config.Description))
+		sb.WriteString(fmt.Sprintf("Description=%s\n", specifierEscaper.Replace(config.Description)))
 	} else {
 		sb.WriteString(fmt.Sprintf("Description=%s service\n", config.Name))
 	}
-	sb.WriteString("After=network.target\n")
+	if after := serviceAfter(config); len(after) > 0 {
+		sb.WriteString(fmt.Sprintf("After=%s\n", strings.Join(after, " ")))
+	}
+	if len(config.Requires) > 0 {
+		sb.WriteString(fmt.Sprintf("Requires=%s\n", strings.Join(config.Requires, " ")))
+	}
+	if len(config.Wants) > 0 {
+		sb.WriteString(fmt.Sprintf("Wants=%s\n", strings.Join(config.Wants, " ")))
+	}
 	sb.WriteString("\n")
 
 	// [Service] section
 	sb.WriteString("[Service]\n")
-	sb.WriteString("Type=simple\n")
+	serviceType := config.Type
+	if config.Schedule != "" {
+		serviceType = "oneshot"
+	} else if serviceType == "" {
+		serviceType = models.DefaultServiceType
+	}
+	sb.WriteString(fmt.Sprintf("Type=%s\n", serviceType))
 
 	// ExecStart with program and arguments
-	execStart := config.Program
-	if len(config.Arguments) > 0 {
-		for _, arg := range config.Arguments {
-			// Escape spaces in arguments
-			if strings.Contains(arg, " ") {
-				execStart += fmt.Sprintf(" \"%s\"", arg)
-			} else {
-				execStart += " " + arg
-			}
+	sb.WriteString(fmt.Sprintf("ExecStart=%s\n", execCommandLine(config.Program, config.Arguments)))
+
+	// Setup and cleanup hooks (validated by CreateService)
+	for _, hook := range config.ExecStartPre {
+		if line, err := execHookLine(hook); err == nil {
+			sb.WriteString(fmt.Sprintf("ExecStartPre=%s\n", line))
+		}
+	}
+	for _, hook := range config.ExecStopPost {
+		if line, err := execHookLine(hook); err == nil {
+			sb.WriteString(fmt.Sprintf("ExecStopPost=%s\n", line))
 		}
 	}
-	sb.WriteString(fmt.Sprintf("ExecStart=%s\n", execStart))
 
 	// Working directory
 	if config.WorkingDirectory != "" {
-		sb.WriteString(fmt.Sprintf("WorkingDirectory=%s\n", config.WorkingDirectory))
+		sb.WriteString(fmt.Sprintf("WorkingDirectory=%s\n", specifierEscaper.Replace(config.WorkingDirectory)))
+	}
+
+	// Account to run as (only accepted for system units)
+	if config.User != "" {
+		sb.WriteString(fmt.Sprintf("User=%s\n", config.User))
+	}
+	if config.Group != "" {
+		sb.WriteString(fmt.Sprintf("Group=%s\n", config.Group))
 	}
 
 	// Environment variables
-	for key, value := range config.Environment {
-		sb.WriteString(fmt.Sprintf("Environment=\"%s=%s\"\n", key, value))
+	if config.EnvironmentFile != "" {
+		sb.WriteString(fmt.Sprintf("EnvironmentFile=%s\n", specifierEscaper.Replace(config.EnvironmentFile)))
+	}
+	for _, key := range slices.Sorted(maps.Keys(config.Environment)) {
+		value := specifierEscaper.Replace(config.Environment[key])
+		sb.WriteString(fmt.Sprintf("Environment=\"%s=%s\"\n", key, envQuoter.Replace(value)))
 	}
 
 	// Restart policy
-	if config.KeepAlive {
-		sb.WriteString("Restart=always\n")
-		sb.WriteString("RestartSec=5\n")
+	if policy, sec := restartPolicy(config); policy != "" {
+		sb.WriteString(fmt.Sprintf("Restart=%s\n", policy))
+		if sec > 0 {
+			sb.WriteString(fmt.Sprintf("RestartSec=%d\n", sec))
+		}
 	}
 
 	// Standard output/error
 	if config.StandardOutPath != "" {
-		sb.WriteString(fmt.Sprintf("StandardOutput=file:%s\n", config.StandardOutPath))
+		sb.WriteString(fmt.Sprintf("StandardOutput=file:%s\n", specifierEscaper.Replace(config.StandardOutPath)))
 	}
 	if config.StandardErrorPath != "" {
-		sb.WriteString(fmt.Sprintf("StandardError=file:%s\n", config.StandardErrorPath))
+		sb.WriteString(fmt.Sprintf("StandardError=file:%s\n", specifierEscaper.Replace(config.StandardErrorPath)))
+	}
+
+	// A scheduled service is started by its timer, so it has no [Install]
+	// section of its own
+	if config.Schedule != "" {
+		return sb.String()
 	}
 
 	sb.WriteString("\n")
 
 	// [Install] section
 	sb.WriteString("[Install]\n")
-	sb.WriteString("WantedBy=default.target\n")
+	sb.WriteString(fmt.Sprintf("WantedBy=%s\n", wantedBy(config, scope)))
 
 	return sb.String()
 }
 
+// timerUnitName returns the .timer unit paired with a scheduled service
+func timerUnitName(name string) string {
+	return strings.TrimSuffix(name, ".service") + ".timer"
+}
+
+// defaultAfter orders services after the network unless NoDefaultAfter is set
+const defaultAfter = "network.target"
+
+// serviceAfter returns the ordering dependencies for a service: the default
+// network ordering followed by the configured ones
+func serviceAfter(config models.ServiceConfig) []string {
+	if config.NoDefaultAfter || slices.Contains(config.After, defaultAfter) {
+		return config.After
+	}
+	return append([]string{defaultAfter}, config.After...)
+}
+
+// wantedBy returns the [Install] target for a service: the configured one,
+// or the usual boot target for the scope.
+func wantedBy(config models.ServiceConfig, scope models.Scope) string {
+	if config.WantedBy != "" {
+		return config.WantedBy
+	}
+	if scope == models.ScopeSystem {
+		return "multi-user.target"
+	}
+	return "default.target"
+}
+
+// validateWantedBy checks that a configured WantedBy names a target unit
+func validateWantedBy(target string) error {
+	if target == "" {
+		return nil
+	}
+	base := strings.TrimSuffix(target, ".target")
+	if base == target || base == "" || strings.ContainsAny(target, " \t\n/") {
+		return fmt.Errorf("invalid wantedBy %q: expected a target unit such as multi-user.target", target)
+	}
+	return nil
+}
+
+// parseUnitFile reads a service unit back into a ServiceConfig. It is the
+// inverse of generateUnitFile for the settings autorun manages; other keys
+// are ignored. The unit name is not part of the file, so Name is left empty.
+func parseUnitFile(content string) (models.ServiceConfig, error) {
+	var config models.ServiceConfig
+	var section string
+	var hasExecStart bool
+
+	// Join continuation lines ending in a backslash
+	content = strings.ReplaceAll(content, "\\\n", " ")
+
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = line[1 : len(line)-1]
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return config, fmt.Errorf("invalid unit file line: %q", line)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		switch section + "." + key {
+		case "Unit.Description":
+			config.Description = specifierUnescaper.Replace(value)
+
+		case "Unit.After":
+			config.After = append(config.After, strings.Fields(value)...)
+
+		case "Unit.Requires":
+			config.Requires = append(config.Requires, strings.Fields(value)...)
+
+		case "Unit.Wants":
+			config.Wants = append(config.Wants, strings.Fields(value)...)
+
+		case "Service.ExecStart":
+			words, err := splitUnitWords(value)
+			if err != nil {
+				return config, fmt.Errorf("invalid ExecStart: %w", err)
+			}
+			if len(words) == 0 {
+				return config, fmt.Errorf("invalid ExecStart: empty command")
+			}
+			// Strip special executable prefixes such as "-" (ignore failure)
+			config.Program = unescapeExecWord(strings.TrimLeft(words[0], "-@:+!"))
+			config.Arguments = nil
+			for _, word := range words[1:] {
+				config.Arguments = append(config.Arguments, unescapeExecWord(word))
+			}
+			hasExecStart = true
+
+		case "Service.ExecStartPre", "Service.ExecStopPost":
+			words, err := splitUnitWords(value)
+			if err != nil {
+				return config, fmt.Errorf("invalid %s: %w", key, err)
+			}
+			if len(words) == 0 {
+				continue
+			}
+			words[0] = strings.TrimLeft(words[0], "-@:+!")
+			for i, word := range words {
+				words[i] = unescapeExecWord(word)
+			}
+			if key == "ExecStartPre" {
+				config.ExecStartPre = append(config.ExecStartPre, hookCommand(words))
+			} else {
+				config.ExecStopPost = append(config.ExecStopPost, hookCommand(words))
+			}
+
+		case "Install.WantedBy":
+			config.WantedBy = value
+
+		case "Service.Type":
+			config.Type = value
+
+		case "Service.WorkingDirectory":
+			config.WorkingDirectory = specifierUnescaper.Replace(value)
+
+		case "Service.User":
+			config.User = value
+
+		case "Service.Group":
+			config.Group = value
+
+		case "Service.Environment":
+			words, err := splitUnitWords(value)
+			if err != nil {
+				return config, fmt.Errorf("invalid Environment: %w", err)
+			}
+			for _, word := range words {
+				name, val, ok := strings.Cut(word, "=")
+				if !ok {
+					return config, fmt.Errorf("invalid Environment assignment: %q", word)
+				}
+				if config.Environment == nil {
+					config.Environment = make(map[string]string)
+				}
+				config.Environment[name] = specifierUnescaper.Replace(val)
+			}
+
+		case "Service.EnvironmentFile":
+			config.EnvironmentFile = specifierUnescaper.Replace(value)
+
+		case "Service.Restart":
+			config.RestartPolicy = value
+			config.KeepAlive = value != "" && value != "no"
+
+		case "Service.RestartSec":
+			sec, err := strconv.Atoi(strings.TrimSuffix(value, "s"))
+			if err != nil {
+				return config, fmt.Errorf("unsupported RestartSec: %q", value)
+			}
+			config.RestartSec = sec
+
+		case "Service.StandardOutput":
+			config.StandardOutPath = specifierUnescaper.Replace(outputFilePath(value))
+
+		case "Service.StandardError":
+			config.StandardErrorPath = specifierUnescaper.Replace(outputFilePath(value))
+		}
+	}
+
+	if !hasExecStart {
+		return config, fmt.Errorf("unit file has no ExecStart")
+	}
+
+	// Restart=always with a delay is what KeepAlive alone generates, so it
+	// reads back as KeepAlive, dropping the delay if it is the default
+	if config.RestartPolicy == "always" && config.RestartSec > 0 {
+		config.RestartPolicy = ""
+		if config.RestartSec == defaultRestartSec {
+			config.RestartSec = 0
+		}
+	}
+
+	// The generated default ordering is not a user setting, and a unit
+	// without it opted out
+	if i := slices.Index(config.After, defaultAfter); i >= 0 {
+		config.After = slices.Delete(config.After, i, i+1)
+		if len(config.After) == 0 {
+			config.After = nil
+		}
+	} else {
+		config.NoDefaultAfter = true
+	}
+	return config, nil
+}
+
+// outputFilePath returns the path of a StandardOutput=/StandardError= file
+// target, or "" for other targets such as journal.
+func outputFilePath(value string) string {
+	for _, prefix := range []string{"file:", "append:", "truncate:"} {
+		if path, ok := strings.CutPrefix(value, prefix); ok {
+			return path
+		}
+	}
+	return ""
+}
+
+// splitUnitWords splits a unit file value into words the way systemd does:
+// on whitespace, honoring single and double quotes and backslash escapes.
+func splitUnitWords(value string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+
+	runes := []rune(value)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\\':
+			if i+1 >= len(runes) {
+				return nil, fmt.Errorf("trailing backslash in %q", value)
+			}
+			i++
+			switch runes[i] {
+			case 'n':
+				word.WriteRune('\n')
+			case 't':
+				word.WriteRune('\t')
+			case 's':
+				word.WriteRune(' ')
+			default:
+				word.WriteRune(runes[i])
+			}
+			inWord = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %q", value)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
 // daemonReload runs systemctl daemon-reload
-func (p *SystemdProvider) daemonReload(scope models.Scope) error {
+func (p *SystemdProvider) daemonReload(ctx context.Context, scope models.Scope) error {
 	var args []string
 	if scope == models.ScopeUser {
 		args = append(args, p.getUserScopeArgs()...)
@@ -412,54 +1438,61 @@ func (p *SystemdProvider) daemonReload(scope models.Scope) error {
 	args = append(args, "daemon-reload")
 
 	logger.Debug("executing daemon-reload", "args", args)
-	cmd := exec.Command("systemctl", args...)
-	if output, err := cmd.CombinedOutput(); err != nil {
-		logger.Error("daemon-reload failed", "scope", scope, "error", err, "output", string(output))
-		return fmt.Errorf("daemon-reload failed: %s", string(output))
+	if output, err := p.systemctl(ctx, OpReload, args...); err != nil {
+		text := commandOutput(output, err)
+		logger.Error("daemon-reload failed", "scope", scope, "error", err, "output", text)
+		if text == "" {
+			text = err.Error()
+		}
+		return newCommandError(err, "daemon-reload failed: "+text)
 	}
 	logger.Debug("daemon-reload succeeded", "scope", scope)
 	return nil
 }
 
 // DeleteService removes a systemd service
-func (p *SystemdProvider) DeleteService(name string, scope models.Scope) error {
+func (p *SystemdProvider) DeleteService(ctx context.Context, name string, scope models.Scope) error {
 	logger.Debug("deleting systemd service", "name", name, "scope", scope)
 
 	// Determine the target directory
-	var targetDir string
-	switch scope {
-	case models.ScopeUser:
-		u, err := user.Current()
-		if err != nil {
-			logger.Error("failed to get current user", "error", err)
-			return fmt.Errorf("failed to get current user: %w", err)
-		}
-		targetDir = filepath.Join(u.HomeDir, ".config", "systemd", "user")
-	case models.ScopeSystem:
-		targetDir = "/etc/systemd/system"
-	default:
-		return fmt.Errorf("invalid scope: %s", scope)
-	}
-
-	// Service name for file
-	serviceName := name
-	if !strings.HasSuffix(serviceName, ".service") {
-		serviceName = serviceName + ".service"
+	targetDir, err := unitDir(scope)
+	if err != nil {
+		return err
 	}
 
-	unitPath := filepath.Join(targetDir, serviceName)
+	unitPath := filepath.Join(targetDir, unitName(name))
 	if _, err := os.Stat(unitPath); os.IsNotExist(err) {
+		// Deleting the template would remove every instance, not just this one
+		if template, _, ok := splitInstance(unitName(name)); ok {
+			if exists, _ := fileExists(filepath.Join(targetDir, template)); exists {
+				return fmt.Errorf("%s is an instance of %s and has no unit file of its own; delete the template instead", name, template)
+			}
+		}
 		logger.Error("service not found for deletion", "name", name, "path", unitPath)
-		return fmt.Errorf("service not found: %s", name)
+		return fmt.Errorf("service %w: %s", ErrNotFound, name)
+	}
+
+	// A paired timer is removed first so it can't start the service again
+	timerPath := filepath.Join(targetDir, timerUnitName(name))
+	if strings.HasSuffix(unitName(name), ".service") {
+		if _, err := os.Stat(timerPath); err == nil {
+			logger.Debug("removing paired timer", "name", name, "path", timerPath)
+			_ = p.runSystemctl(ctx, "stop", timerUnitName(name), scope)
+			_ = p.runSystemctl(ctx, "disable", timerUnitName(name), scope)
+			if err := os.Remove(timerPath); err != nil {
+				logger.Error("failed to delete unit file", "path", timerPath, "error", err)
+				return fmt.Errorf("failed to delete timer file: %w", err)
+			}
+		}
 	}
 
 	// Stop the service first (ignore errors if not running)
 	logger.Debug("stopping service before deletion", "name", name)
-	_ = p.Stop(name, scope)
+	_ = p.Stop(ctx, name, scope)
 
 	// Disable the service
 	logger.Debug("disabling service before deletion", "name", name)
-	_ = p.Disable(name, scope)
+	_ = p.Disable(ctx, name, scope)
 
 	// Delete the unit file
 	logger.Debug("removing unit file", "path", unitPath)
@@ -470,7 +1503,7 @@ func (p *SystemdProvider) DeleteService(name string, scope models.Scope) error {
 
 	// Reload systemd
 	logger.Debug("reloading systemd daemon")
-	if err := p.daemonReload(scope); err != nil {
+	if err := p.daemonReload(ctx, scope); err != nil {
 		logger.Error("daemon reload failed", "error", err)
 		return fmt.Errorf("failed to reload systemd: %w", err)
 	}
@@ -478,3 +1511,213 @@ func (p *SystemdProvider) DeleteService(name string, scope models.Scope) error {
 	logger.Debug("service deleted successfully", "name", name)
 	return nil
 }
+
+// RunTransient starts config's program as a transient service with
+// systemd-run. The unit exists only until it stops (or fails and is reset)
+// and never survives a reboot.
+func (p *SystemdProvider) RunTransient(ctx context.Context, config models.ServiceConfig, scope models.Scope) (string, error) {
+	if config.Program == "" {
+		return "", fmt.Errorf("program path is required")
+	}
+	if !models.ValidServiceType(config.Type) {
+		return "", fmt.Errorf("unknown service type %q (expected one of %s)", config.Type, strings.Join(models.ServiceTypes, ", "))
+	}
+	if err := validateRunAs(config, scope); err != nil {
+		return "", err
+	}
+
+	name := transientName()
+	var args []string
+	if scope == models.ScopeUser {
+		args = append(args, p.getUserScopeArgs()...)
+	}
+	args = append(args, systemdRunArgs(name, config)...)
+
+	logger.Debug("starting transient unit", "name", name, "args", args)
+	output, err := runCommand(ctx, p.runner, p.timeouts, OpAction, "systemd-run", args...)
+	if err != nil {
+		return "", newCommandError(err, "systemd-run failed: "+strings.TrimSpace(commandOutput(output, err)))
+	}
+	return name, nil
+}
+
+// systemdRunArgs builds the systemd-run arguments for a transient service
+func systemdRunArgs(name string, config models.ServiceConfig) []string {
+	args := []string{"--unit=" + unitName(name)}
+	if config.Description != "" {
+		args = append(args, "--description="+config.Description)
+	}
+	if config.Type != "" {
+		args = append(args, "--service-type="+config.Type)
+	}
+	if config.WorkingDirectory != "" {
+		args = append(args, "--working-directory="+config.WorkingDirectory)
+	}
+	if config.User != "" {
+		args = append(args, "--uid="+config.User)
+	}
+	if config.Group != "" {
+		args = append(args, "--gid="+config.Group)
+	}
+	for _, key := range slices.Sorted(maps.Keys(config.Environment)) {
+		args = append(args, "--setenv="+key+"="+config.Environment[key])
+	}
+	if config.EnvironmentFile != "" {
+		args = append(args, "--property=EnvironmentFile="+config.EnvironmentFile)
+	}
+	if config.StandardOutPath != "" {
+		args = append(args, "--property=StandardOutput=append:"+config.StandardOutPath)
+	}
+	if config.StandardErrorPath != "" {
+		args = append(args, "--property=StandardError=append:"+config.StandardErrorPath)
+	}
+	args = append(args, "--", config.Program)
+	return append(args, config.Arguments...)
+}
+
+// CreateTimer creates a oneshot service and a .timer unit that runs it on the
+// configured calendar schedule, then enables and starts the timer.
+func (p *SystemdProvider) CreateTimer(ctx context.Context, config models.TimerConfig, scope models.Scope) ([]string, error) {
+	logger.Debug("creating systemd timer", "name", config.Name, "onCalendar", config.OnCalendar, "scope", scope)
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	targetDir, err := unitDir(scope)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		logger.Error("failed to create directory", "dir", targetDir, "error", err)
+		return nil, fmt.Errorf("failed to create directory %s: %w", targetDir, err)
+	}
+
+	serviceUnit := config.Name + ".service"
+	timerUnit := config.Name + ".timer"
+	servicePath := filepath.Join(targetDir, serviceUnit)
+	timerPath := filepath.Join(targetDir, timerUnit)
+
+	for _, path := range []string{servicePath, timerPath} {
+		if _, err := os.Stat(path); err == nil {
+			logger.Warn("timer unit already exists", "name", config.Name, "path", path)
+			return nil, fmt.Errorf("service %s %w", config.Name, ErrAlreadyExists)
+		}
+	}
+
+	serviceContent, timerContent := generateTimerUnits(config)
+
+	logger.Debug("writing timer units", "service", servicePath, "timer", timerPath)
+	if err := os.WriteFile(servicePath, []byte(serviceContent), 0644); err != nil {
+		logger.Error("failed to write unit file", "path", servicePath, "error", err)
+		return nil, fmt.Errorf("failed to write unit file: %w", err)
+	}
+	if err := os.WriteFile(timerPath, []byte(timerContent), 0644); err != nil {
+		logger.Error("failed to write unit file", "path", timerPath, "error", err)
+		os.Remove(servicePath)
+		return nil, fmt.Errorf("failed to write unit file: %w", err)
+	}
+
+	if err := p.daemonReload(ctx, scope); err != nil {
+		logger.Error("daemon reload failed, cleaning up", "error", err)
+		os.Remove(servicePath)
+		os.Remove(timerPath)
+		return nil, fmt.Errorf("failed to reload systemd: %w", err)
+	}
+
+	if err := p.runSystemctl(ctx, "enable", timerUnit, scope); err != nil {
+		p.removeTimerUnits(ctx, timerUnit, scope, servicePath, timerPath)
+		return nil, fmt.Errorf("failed to enable timer: %w", err)
+	}
+	if err := p.runSystemctl(ctx, "start", timerUnit, scope); err != nil {
+		p.removeTimerUnits(ctx, timerUnit, scope, servicePath, timerPath)
+		return nil, fmt.Errorf("failed to start timer: %w", err)
+	}
+
+	logger.Debug("timer created successfully", "name", config.Name)
+	return []string{serviceUnit, timerUnit}, nil
+}
+
+// removeTimerUnits undoes a CreateTimer whose timer could not be enabled or
+// started, so a retry doesn't find the units already there. It carries on
+// after the request is cancelled, since that is often why the timer failed.
+func (p *SystemdProvider) removeTimerUnits(ctx context.Context, timerUnit string, scope models.Scope, paths ...string) {
+	logger.Error("timer failed to activate, cleaning up", "name", timerUnit)
+	ctx = context.WithoutCancel(ctx)
+	// The timer may have been enabled before start failed
+	p.runSystemctl(ctx, "disable", timerUnit, scope)
+	for _, path := range paths {
+		os.Remove(path)
+	}
+	p.daemonReload(ctx, scope)
+}
+
+// generateTimerUnits creates the oneshot service and timer unit contents for
+// a scheduled job
+func generateTimerUnits(config models.TimerConfig) (string, string) {
+	var service strings.Builder
+	service.WriteString("[Unit]\n")
+	service.WriteString(fmt.Sprintf("Description=%s job\n", config.Name))
+	service.WriteString("\n")
+	service.WriteString("[Service]\n")
+	service.WriteString("Type=oneshot\n")
+	service.WriteString(fmt.Sprintf("ExecStart=%s\n", execCommandLine(config.Program, config.Arguments)))
+
+	return service.String(), generateTimerUnit(config.Name, config.OnCalendar, config.Persistent)
+}
+
+// generateTimerUnit creates the .timer unit content that activates the
+// service of the same name on a calendar schedule
+func generateTimerUnit(name, onCalendar string, persistent bool) string {
+	var timer strings.Builder
+	timer.WriteString("[Unit]\n")
+	timer.WriteString(fmt.Sprintf("Description=%s timer\n", name))
+	timer.WriteString("\n")
+	timer.WriteString("[Timer]\n")
+	timer.WriteString(fmt.Sprintf("OnCalendar=%s\n", onCalendar))
+	if persistent {
+		timer.WriteString("Persistent=true\n")
+	}
+	timer.WriteString("\n")
+	timer.WriteString("[Install]\n")
+	timer.WriteString("WantedBy=timers.target\n")
+
+	return timer.String()
+}
+
+// DeleteTimer stops and disables a timer, then removes its timer and service
+// units
+func (p *SystemdProvider) DeleteTimer(ctx context.Context, name string, scope models.Scope) error {
+	logger.Debug("deleting systemd timer", "name", name, "scope", scope)
+
+	targetDir, err := unitDir(scope)
+	if err != nil {
+		return err
+	}
+
+	timerUnit := name + ".timer"
+	timerPath := filepath.Join(targetDir, timerUnit)
+	if _, err := os.Stat(timerPath); os.IsNotExist(err) {
+		logger.Error("timer not found for deletion", "name", name, "path", timerPath)
+		return fmt.Errorf("timer %w: %s", ErrNotFound, name)
+	}
+
+	_ = p.runSystemctl(ctx, "stop", timerUnit, scope)
+	_ = p.runSystemctl(ctx, "disable", timerUnit, scope)
+
+	for _, path := range []string{timerPath, filepath.Join(targetDir, name+".service")} {
+		logger.Debug("removing unit file", "path", path)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			logger.Error("failed to delete unit file", "path", path, "error", err)
+			return fmt.Errorf("failed to delete unit file: %w", err)
+		}
+	}
+
+	if err := p.daemonReload(ctx, scope); err != nil {
+		logger.Error("daemon reload failed", "error", err)
+		return fmt.Errorf("failed to reload systemd: %w", err)
+	}
+
+	logger.Debug("timer deleted successfully", "name", name)
+	return nil
+}
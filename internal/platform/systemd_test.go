@@ -0,0 +1,1218 @@
+package platform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"autorun/internal/models"
+)
+
+func TestUnitName(t *testing.T) {
+	cases := []struct {
+		name string
+		want string
+	}{
+		{name: "sshd", want: "sshd.service"},
+		{name: "sshd.service", want: "sshd.service"},
+		{name: "backup.timer", want: "backup.timer"},
+		{name: "getty@tty1", want: "getty@tty1.service"},
+		{name: "getty@tty1.service", want: "getty@tty1.service"},
+		{name: "openvpn@client.conf", want: "openvpn@client.conf.service"},
+		{name: "backup@daily.timer", want: "backup@daily.timer"},
+		{name: "getty@", want: "getty@.service"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := unitName(tc.name); got != tc.want {
+				t.Fatalf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestUnitStatus(t *testing.T) {
+	cases := []struct {
+		active, sub, want string
+	}{
+		{"active", "running", models.StatusRunning},
+		{"active", "waiting", models.StatusRunning},
+		{"active", "exited", models.StatusStopped},
+		{"inactive", "dead", models.StatusStopped},
+		{"failed", "failed", models.StatusFailed},
+		{"activating", "start-pre", models.StatusStarting},
+		{"activating", "auto-restart", models.StatusStarting},
+		{"reloading", "reload", models.StatusStarting},
+		{"deactivating", "stop-sigterm", models.StatusStopping},
+		{"maintenance", "", models.StatusUnknown},
+	}
+
+	for _, tc := range cases {
+		if got := unitStatus(tc.active, tc.sub); got != tc.want {
+			t.Errorf("unitStatus(%q, %q) = %q, expected %q", tc.active, tc.sub, got, tc.want)
+		}
+	}
+}
+
+func TestUnitFileEnabled(t *testing.T) {
+	for state, want := range map[string]bool{
+		"enabled":         true,
+		"enabled-runtime": true,
+		"static":          true,
+		"indirect":        true,
+		"generated":       true,
+		"alias":           true,
+		"disabled":        false,
+		"masked":          false,
+		"linked":          false,
+		"transient":       false,
+		"":                false,
+	} {
+		if got := unitFileEnabled(state); got != want {
+			t.Errorf("unitFileEnabled(%q) = %v, expected %v", state, got, want)
+		}
+	}
+}
+
+func TestSplitInstance(t *testing.T) {
+	cases := []struct {
+		unit, template, instance string
+		ok                       bool
+	}{
+		{"getty@tty1.service", "getty@.service", "tty1", true},
+		{"openvpn@client.conf.service", "openvpn@.service", "client.conf", true},
+		{"backup@daily.timer", "backup@.timer", "daily", true},
+		{"getty@.service", "", "", false},
+		{"sshd.service", "", "", false},
+	}
+
+	for _, tc := range cases {
+		template, instance, ok := splitInstance(tc.unit)
+		if template != tc.template || instance != tc.instance || ok != tc.ok {
+			t.Errorf("splitInstance(%q) = %q, %q, %v; expected %q, %q, %v",
+				tc.unit, template, instance, ok, tc.template, tc.instance, tc.ok)
+		}
+	}
+}
+
+func TestSystemdTemplateUnits(t *testing.T) {
+	runner := &fakeRunner{handle: func(name string, args []string) ([]byte, error) {
+		if args[0] == "show" {
+			return []byte("Id=getty@tty1.service\nLoadState=loaded\nActiveState=active\nSubState=running\nUnitFileState=enabled\n"), nil
+		}
+		return nil, nil
+	}}
+	p := &SystemdProvider{runner: runner}
+
+	if err := p.Restart(t.Context(), "getty@tty1", models.ScopeSystem); err != nil {
+		t.Fatalf("Restart: %v", err)
+	}
+	if err := p.Enable(t.Context(), "getty@", models.ScopeSystem); err != nil {
+		t.Fatalf("Enable: %v", err)
+	}
+	svc, err := p.GetService(t.Context(), "getty@tty1.service", models.ScopeSystem)
+	if err != nil {
+		t.Fatalf("GetService: %v", err)
+	}
+	if svc.Name != "getty@tty1" || svc.Status != models.StatusRunning {
+		t.Fatalf("expected running getty@tty1, got %+v", svc)
+	}
+
+	want := []string{
+		"systemctl restart getty@tty1.service",
+		"systemctl enable getty@.service",
+		"systemctl show --property=" + getServiceProperties + " getty@tty1.service",
+	}
+	if cmds := runner.commands(); !slices.Equal(cmds, want) {
+		t.Fatalf("expected %v, got %v", want, cmds)
+	}
+
+	// A template has nothing to start until it is given an instance
+	err = p.Start(t.Context(), "getty@", models.ScopeSystem)
+	if err == nil || !strings.Contains(err.Error(), "getty@<instance>.service") {
+		t.Fatalf("expected an error naming an instance, got %v", err)
+	}
+	if len(runner.commands()) != len(want) {
+		t.Fatalf("expected the template start to be refused without running systemctl")
+	}
+}
+
+func TestGenerateTimerUnits(t *testing.T) {
+	service, timer := generateTimerUnits(models.TimerConfig{
+		Name:       "backup",
+		Program:    "/usr/local/bin/backup",
+		Arguments:  []string{"--full"},
+		OnCalendar: "*-*-* 02:00:00",
+		Persistent: true,
+	})
+
+	for _, want := range []string{"Type=oneshot\n", "ExecStart=/usr/local/bin/backup --full\n"} {
+		if !strings.Contains(service, want) {
+			t.Fatalf("expected service unit to contain %q, got:\n%s", want, service)
+		}
+	}
+	if strings.Contains(service, "[Install]") {
+		t.Fatalf("expected timer-driven service to have no [Install] section, got:\n%s", service)
+	}
+
+	for _, want := range []string{"[Timer]\n", "OnCalendar=*-*-* 02:00:00\n", "Persistent=true\n", "WantedBy=timers.target\n"} {
+		if !strings.Contains(timer, want) {
+			t.Fatalf("expected timer unit to contain %q, got:\n%s", want, timer)
+		}
+	}
+}
+
+func TestParseDocumentation(t *testing.T) {
+	cases := []struct {
+		name   string
+		output string
+		want   []string
+	}{
+		{
+			name:   "multiple",
+			output: "Documentation=man:sshd(8) man:sshd_config(5) https://www.openssh.com/\n",
+			want:   []string{"man:sshd(8)", "man:sshd_config(5)", "https://www.openssh.com/"},
+		},
+		{name: "single", output: "Documentation=https://example.com/docs\n", want: []string{"https://example.com/docs"}},
+		{name: "empty", output: "Documentation=\n", want: nil},
+		{name: "value only", output: "man:cron(8)\n", want: []string{"man:cron(8)"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := parseDocumentation(tc.output); !slices.Equal(got, tc.want) {
+				t.Fatalf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParseUnitFile_RoundTrip(t *testing.T) {
+	cases := []struct {
+		name   string
+		config models.ServiceConfig
+	}{
+		{
+			name: "minimal",
+			config: models.ServiceConfig{
+				Description: "Minimal",
+				Program:     "/usr/bin/true",
+				Type:        "oneshot",
+				WantedBy:    "multi-user.target",
+			},
+		},
+		{
+			name: "no default after",
+			config: models.ServiceConfig{
+				Description:    "Database client",
+				Program:        "/usr/bin/true",
+				Type:           "simple",
+				WantedBy:       "multi-user.target",
+				After:          []string{"postgresql.service"},
+				NoDefaultAfter: true,
+			},
+		},
+		{
+			name: "restart policy",
+			config: models.ServiceConfig{
+				Description:   "Flaky worker",
+				Program:       "/usr/bin/true",
+				Type:          "simple",
+				WantedBy:      "multi-user.target",
+				KeepAlive:     true,
+				RestartPolicy: "on-failure",
+				RestartSec:    10,
+			},
+		},
+		{
+			name: "keepalive with delay",
+			config: models.ServiceConfig{
+				Description: "Slow restarts",
+				Program:     "/usr/bin/true",
+				Type:        "simple",
+				WantedBy:    "multi-user.target",
+				KeepAlive:   true,
+				RestartSec:  30,
+			},
+		},
+		{
+			name: "percent signs",
+			config: models.ServiceConfig{
+				Description:       "100% uptime for %n",
+				Program:           "/usr/bin/true",
+				Type:              "simple",
+				WantedBy:          "multi-user.target",
+				WorkingDirectory:  "/srv/%i",
+				EnvironmentFile:   "/etc/%h.env",
+				Environment:       map[string]string{"FORMAT": "%Y-%m-%d", "RATIO": "50%%"},
+				StandardOutPath:   "/var/log/%p.out",
+				StandardErrorPath: "/var/log/%p.err",
+			},
+		},
+		{
+			name: "full",
+			config: models.ServiceConfig{
+				Description:       "Web worker",
+				Type:              "notify",
+				WantedBy:          "graphical.target",
+				After:             []string{"network-online.target", "postgresql.service"},
+				Requires:          []string{"postgresql.service"},
+				Wants:             []string{"network-online.target", "redis.service"},
+				Program:           "/usr/local/bin/worker",
+				Arguments:         []string{"--port", "8080", "--name", "my worker", `--msg=say "hi"`, `C:\tmp`, "100%", "$HOME", ""},
+				WorkingDirectory:  "/srv/worker",
+				EnvironmentFile:   "/etc/worker/env",
+				ExecStartPre:      []string{"mkdir -p /run/worker", `chown www-data "/run/my worker"`},
+				ExecStopPost:      []string{"rm -rf /run/worker"},
+				User:              "www-data",
+				Group:             "www-data",
+				Environment:       map[string]string{"MODE": "prod", "GREETING": "hello world", "EMPTY": "", "QUOTED": `say "hi" C:\tmp`},
+				KeepAlive:         true,
+				StandardOutPath:   "/var/log/worker.out",
+				StandardErrorPath: "/var/log/worker.err",
+			},
+		},
+	}
+
+	p := &SystemdProvider{}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			content := p.generateUnitFile(tc.config, models.ScopeSystem)
+			got, err := parseUnitFile(content)
+			if err != nil {
+				t.Fatalf("parseUnitFile: %v\n%s", err, content)
+			}
+			if !reflect.DeepEqual(got, tc.config) {
+				t.Fatalf("round trip mismatch\nwant %+v\ngot  %+v\nunit:\n%s", tc.config, got, content)
+			}
+		})
+	}
+}
+
+func TestParseUnitFile(t *testing.T) {
+	content := `# managed elsewhere
+[Unit]
+Description=Hand written
+After=network.target
+
+[Service]
+ExecStart=-/usr/bin/app 'single quoted' "double \"escaped\"" \
+    continued
+Environment=A=1 "B=two words"
+Environment='C=three'
+Restart=on-failure
+StandardOutput=journal
+StandardError=append:/var/log/app.err
+
+[Install]
+WantedBy=multi-user.target
+`
+	got, err := parseUnitFile(content)
+	if err != nil {
+		t.Fatalf("parseUnitFile: %v", err)
+	}
+
+	want := models.ServiceConfig{
+		Description:       "Hand written",
+		Program:           "/usr/bin/app",
+		Arguments:         []string{"single quoted", `double "escaped"`, "continued"},
+		Environment:       map[string]string{"A": "1", "B": "two words", "C": "three"},
+		KeepAlive:         true,
+		RestartPolicy:     "on-failure",
+		StandardErrorPath: "/var/log/app.err",
+		WantedBy:          "multi-user.target",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %+v\ngot  %+v", want, got)
+	}
+}
+
+func TestParseUnitFile_Errors(t *testing.T) {
+	cases := map[string]string{
+		"no ExecStart":     "[Service]\nType=simple\n",
+		"unterminated":     "[Service]\nExecStart=/bin/app \"open\n",
+		"bad environment":  "[Service]\nExecStart=/bin/app\nEnvironment=NOVALUE\n",
+		"line without key": "[Service]\nExecStart=/bin/app\ngarbage\n",
+		"empty exec":       "[Service]\nExecStart=\n",
+	}
+
+	for name, content := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := parseUnitFile(content); err == nil {
+				t.Fatalf("expected error for:\n%s", content)
+			}
+		})
+	}
+}
+
+func TestExecCommandLine(t *testing.T) {
+	cases := []struct {
+		name      string
+		program   string
+		arguments []string
+		want      string
+	}{
+		{name: "plain", program: "/bin/app", arguments: []string{"--port", "80"}, want: `/bin/app --port 80`},
+		{name: "space", program: "/bin/app", arguments: []string{"two words"}, want: `/bin/app "two words"`},
+		{name: "double quote", program: "/bin/app", arguments: []string{`--msg=say "hi"`}, want: `/bin/app "--msg=say \"hi\""`},
+		{name: "single quote", program: "/bin/app", arguments: []string{"it's"}, want: `/bin/app "it's"`},
+		{name: "backslash", program: "/bin/app", arguments: []string{`a\b`}, want: `/bin/app "a\\b"`},
+		{name: "percent", program: "/bin/app", arguments: []string{"100%"}, want: `/bin/app 100%%`},
+		{name: "dollar", program: "/bin/app", arguments: []string{"$HOME"}, want: `/bin/app $$HOME`},
+		{name: "semicolon", program: "/bin/app", arguments: []string{";"}, want: `/bin/app ";"`},
+		{name: "empty", program: "/bin/app", arguments: []string{""}, want: `/bin/app ""`},
+		{name: "program with space", program: "/opt/my app/run", want: `"/opt/my app/run"`},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := execCommandLine(tc.program, tc.arguments); got != tc.want {
+				t.Fatalf("expected %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParseStatusCGroup(t *testing.T) {
+	output := `● nginx.service - A high performance web server
+     Loaded: loaded (/lib/systemd/system/nginx.service; enabled; preset: enabled)
+     Active: active (running) since Mon 2024-01-01 10:00:00 UTC; 1h ago
+   Main PID: 1201 (nginx)
+      Tasks: 4 (limit: 4915)
+     CGroup: /system.slice/nginx.service
+             ├─1201 "nginx: master process /usr/sbin/nginx -g daemon on;"
+             ├─1202 "nginx: worker process"
+             ├─helper.scope
+             │ └─1300 /usr/bin/helper --watch
+             └─1203 "nginx: worker process"
+
+Jan 01 10:00:00 host systemd[1]: Started nginx.service.
+`
+	want := []models.Process{
+		{PID: 1201, Command: `"nginx: master process /usr/sbin/nginx -g daemon on;"`},
+		{PID: 1202, Command: `"nginx: worker process"`},
+		{PID: 1300, Command: "/usr/bin/helper --watch"},
+		{PID: 1203, Command: `"nginx: worker process"`},
+	}
+	if got := parseStatusCGroup(output); !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %+v\ngot  %+v", want, got)
+	}
+
+	ascii := "   CGroup: /user.slice/app.service\n           |-10 /bin/app\n           `-11 /bin/app --child\n"
+	wantASCII := []models.Process{{PID: 10, Command: "/bin/app"}, {PID: 11, Command: "/bin/app --child"}}
+	if got := parseStatusCGroup(ascii); !reflect.DeepEqual(got, wantASCII) {
+		t.Fatalf("want %+v\ngot  %+v", wantASCII, got)
+	}
+
+	inactive := "○ app.service\n     Loaded: loaded\n     Active: inactive (dead)\n"
+	if got := parseStatusCGroup(inactive); len(got) != 0 {
+		t.Fatalf("expected no processes for inactive unit, got %+v", got)
+	}
+}
+
+func TestGenerateUnitFile_Hooks(t *testing.T) {
+	p := &SystemdProvider{}
+	unit := p.generateUnitFile(models.ServiceConfig{
+		Program:      "/bin/app",
+		ExecStartPre: []string{"mkdir -p /run/app", `touch "/run/app/100% ready"`},
+		ExecStopPost: []string{"rm -f /run/app/pid"},
+	}, models.ScopeUser)
+
+	for _, want := range []string{
+		"ExecStartPre=mkdir -p /run/app\n",
+		"ExecStartPre=touch \"/run/app/100%% ready\"\n",
+		"ExecStopPost=rm -f /run/app/pid\n",
+	} {
+		if !strings.Contains(unit, want) {
+			t.Fatalf("expected unit to contain %q, got:\n%s", want, unit)
+		}
+	}
+}
+
+func TestGenerateUnitFile_RestartPolicy(t *testing.T) {
+	cases := []struct {
+		name   string
+		config models.ServiceConfig
+		want   []string
+		absent []string
+	}{
+		{name: "none", config: models.ServiceConfig{}, absent: []string{"Restart=", "RestartSec="}},
+		{name: "keepalive default", config: models.ServiceConfig{KeepAlive: true}, want: []string{"Restart=always\n", "RestartSec=5\n"}},
+		{name: "keepalive with delay", config: models.ServiceConfig{KeepAlive: true, RestartSec: 30}, want: []string{"Restart=always\n", "RestartSec=30\n"}},
+		{name: "on-failure", config: models.ServiceConfig{RestartPolicy: "on-failure", RestartSec: 2}, want: []string{"Restart=on-failure\n", "RestartSec=2\n"}},
+		{name: "policy overrides keepalive", config: models.ServiceConfig{KeepAlive: true, RestartPolicy: "no"}, want: []string{"Restart=no\n"}, absent: []string{"RestartSec="}},
+	}
+
+	p := &SystemdProvider{}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.config.Program = "/bin/app"
+			unit := p.generateUnitFile(tc.config, models.ScopeUser)
+			for _, want := range tc.want {
+				if !strings.Contains(unit, want) {
+					t.Fatalf("expected unit to contain %q, got:\n%s", want, unit)
+				}
+			}
+			for _, absent := range tc.absent {
+				if strings.Contains(unit, absent) {
+					t.Fatalf("expected unit not to contain %q, got:\n%s", absent, unit)
+				}
+			}
+		})
+	}
+}
+
+func TestSystemdServiceStates(t *testing.T) {
+	output := `Id=nginx.service
+LoadState=loaded
+ActiveState=active
+SubState=running
+UnitFileState=enabled
+MainPID=1201
+
+Id=ghost.service
+LoadState=not-found
+ActiveState=inactive
+SubState=dead
+UnitFileState=
+MainPID=0
+
+Id=backup.service
+LoadState=loaded
+ActiveState=failed
+SubState=failed
+UnitFileState=disabled
+MainPID=0
+`
+	runner := &fakeRunner{handle: func(name string, args []string) ([]byte, error) {
+		return []byte(output), nil
+	}}
+	p := &SystemdProvider{runner: runner}
+
+	states, err := p.ServiceStates(t.Context(), []string{"nginx", "ghost", "backup.service"}, models.ScopeSystem)
+	if err != nil {
+		t.Fatalf("ServiceStates: %v", err)
+	}
+
+	want := map[string]models.ServiceState{
+		"nginx":          {Status: models.StatusRunning, Enabled: true, EnableState: "enabled", PID: 1201},
+		"ghost":          {Status: models.StatusUnknown, NotFound: true},
+		"backup.service": {Status: models.StatusFailed, EnableState: "disabled"},
+	}
+	if !reflect.DeepEqual(states, want) {
+		t.Fatalf("want %+v\ngot  %+v", want, states)
+	}
+
+	cmds := runner.commands()
+	if len(cmds) != 1 || !strings.HasSuffix(cmds[0], "nginx.service ghost.service backup.service") {
+		t.Fatalf("expected a single systemctl show for all units, got %q", cmds)
+	}
+}
+
+func TestGenerateUnitFile_WantedBy(t *testing.T) {
+	cases := []struct {
+		name     string
+		scope    models.Scope
+		wantedBy string
+		want     string
+	}{
+		{name: "user default", scope: models.ScopeUser, want: "WantedBy=default.target\n"},
+		{name: "system default", scope: models.ScopeSystem, want: "WantedBy=multi-user.target\n"},
+		{name: "explicit user", scope: models.ScopeUser, wantedBy: "graphical-session.target", want: "WantedBy=graphical-session.target\n"},
+		{name: "explicit system", scope: models.ScopeSystem, wantedBy: "network-online.target", want: "WantedBy=network-online.target\n"},
+	}
+
+	p := &SystemdProvider{}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			unit := p.generateUnitFile(models.ServiceConfig{Program: "/bin/app", WantedBy: tc.wantedBy}, tc.scope)
+			if !strings.Contains(unit, "[Install]\n"+tc.want) {
+				t.Fatalf("expected [Install] %q, got:\n%s", tc.want, unit)
+			}
+		})
+	}
+}
+
+func TestGenerateUnitFile_EscapesSpecifiers(t *testing.T) {
+	p := &SystemdProvider{}
+	content := p.generateUnitFile(models.ServiceConfig{
+		Name:             "report",
+		Description:      "100% done",
+		Program:          "/usr/bin/true",
+		WorkingDirectory: "/srv/%i",
+		Environment:      map[string]string{"ZONE": "%Z", "FORMAT": "%Y", "MODE": "prod"},
+	}, models.ScopeSystem)
+
+	for _, want := range []string{"Description=100%% done\n", "WorkingDirectory=/srv/%%i\n"} {
+		if !strings.Contains(content, want) {
+			t.Fatalf("expected %q in unit:\n%s", want, content)
+		}
+	}
+	env := "Environment=\"FORMAT=%%Y\"\nEnvironment=\"MODE=prod\"\nEnvironment=\"ZONE=%%Z\"\n"
+	if !strings.Contains(content, env) {
+		t.Fatalf("expected sorted, escaped environment %q in unit:\n%s", env, content)
+	}
+}
+
+func TestGenerateUnitFile_Dependencies(t *testing.T) {
+	cases := []struct {
+		name   string
+		config models.ServiceConfig
+		want   []string
+		absent []string
+	}{
+		{
+			name:   "default",
+			config: models.ServiceConfig{},
+			want:   []string{"After=network.target\n"},
+			absent: []string{"Requires=", "Wants="},
+		},
+		{
+			name:   "requires keeps default ordering",
+			config: models.ServiceConfig{Requires: []string{"postgresql.service"}, Wants: []string{"redis.service"}},
+			want:   []string{"After=network.target\n", "Requires=postgresql.service\n", "Wants=redis.service\n"},
+		},
+		{
+			name:   "explicit after keeps default ordering",
+			config: models.ServiceConfig{After: []string{"postgresql.service", "network-online.target"}},
+			want:   []string{"After=network.target postgresql.service network-online.target\n"},
+		},
+		{
+			name:   "no default after",
+			config: models.ServiceConfig{After: []string{"postgresql.service"}, NoDefaultAfter: true},
+			want:   []string{"After=postgresql.service\n"},
+			absent: []string{"network.target"},
+		},
+		{
+			name:   "no ordering at all",
+			config: models.ServiceConfig{NoDefaultAfter: true},
+			absent: []string{"After="},
+		},
+	}
+
+	p := &SystemdProvider{}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.config.Program = "/bin/app"
+			unit := p.generateUnitFile(tc.config, models.ScopeSystem)
+			unitSection := unit[:strings.Index(unit, "[Service]")]
+			for _, want := range tc.want {
+				if !strings.Contains(unitSection, want) {
+					t.Fatalf("expected [Unit] to contain %q, got:\n%s", want, unit)
+				}
+			}
+			for _, absent := range tc.absent {
+				if strings.Contains(unit, absent) {
+					t.Fatalf("expected unit not to contain %q, got:\n%s", absent, unit)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateWantedBy(t *testing.T) {
+	cases := map[string]bool{
+		"":                         true,
+		"multi-user.target":        true,
+		"graphical-session.target": true,
+		"default":                  false,
+		"nginx.service":            false,
+		".target":                  false,
+		"multi user.target":        false,
+		"../evil.target":           false,
+	}
+	for target, ok := range cases {
+		t.Run(target, func(t *testing.T) {
+			if err := validateWantedBy(target); (err == nil) != ok {
+				t.Fatalf("expected ok=%v, got %v", ok, err)
+			}
+		})
+	}
+}
+
+func TestCountJournalPriorities(t *testing.T) {
+	output := `{"PRIORITY":"3","__CURSOR":"s=1"}
+{"PRIORITY":"4","__CURSOR":"s=2"}
+{"PRIORITY":"4","__CURSOR":"s=3"}
+{"PRIORITY":"2","__CURSOR":"s=4"}
+{"PRIORITY":"0","__CURSOR":"s=5"}
+{"PRIORITY":"6","__CURSOR":"s=6"}
+-- No entries --
+{"__CURSOR":"s=7"}
+`
+	got := countJournalPriorities(output)
+	want := models.LogCounts{Errors: 3, Warnings: 2}
+	if got != want {
+		t.Fatalf("want %+v, got %+v", want, got)
+	}
+}
+
+func TestParseJournalEntry(t *testing.T) {
+	cases := []struct {
+		name string
+		line string
+		want models.LogEntry
+		ok   bool
+	}{
+		{
+			name: "error",
+			line: `{"__REALTIME_TIMESTAMP":"1740830400000000","PRIORITY":"3","MESSAGE":"failed to bind"}`,
+			want: models.LogEntry{Time: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC), Level: "error", Message: "failed to bind"},
+			ok:   true,
+		},
+		{
+			name: "critical",
+			line: `{"PRIORITY":"0","MESSAGE":"panic"}`,
+			want: models.LogEntry{Level: "critical", Message: "panic"},
+			ok:   true,
+		},
+		{
+			name: "binary message",
+			line: `{"PRIORITY":"6","MESSAGE":[104,105]}`,
+			want: models.LogEntry{Level: "info", Message: "hi"},
+			ok:   true,
+		},
+		{
+			name: "no priority",
+			line: `{"MESSAGE":"hello"}`,
+			want: models.LogEntry{Level: "info", Message: "hello"},
+			ok:   true,
+		},
+		{name: "not json", line: "-- No entries --"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := parseJournalEntry(tc.line)
+			if ok != tc.ok {
+				t.Fatalf("expected ok=%v, got %v", tc.ok, ok)
+			}
+			if !ok {
+				return
+			}
+			tc.want.Raw = tc.line
+			if got != tc.want {
+				t.Fatalf("want %+v, got %+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestJournalPriorityArgs(t *testing.T) {
+	if got := journalPriorityArgs("error"); !slices.Equal(got, []string{"-p", "err"}) {
+		t.Fatalf("expected -p err, got %q", got)
+	}
+	if got := journalPriorityArgs("critical"); !slices.Equal(got, []string{"-p", "crit"}) {
+		t.Fatalf("expected -p crit, got %q", got)
+	}
+	if got := journalPriorityArgs(""); got != nil {
+		t.Fatalf("expected no args without a level, got %q", got)
+	}
+}
+
+func TestSystemdLogCounts_Window(t *testing.T) {
+	runner := &fakeRunner{}
+	p := &SystemdProvider{runner: runner}
+
+	if _, err := p.LogCounts(t.Context(), "web", models.ScopeSystem, time.Time{}); err != nil {
+		t.Fatalf("LogCounts: %v", err)
+	}
+	if _, err := p.LogCounts(t.Context(), "web", models.ScopeSystem, time.Unix(1700000000, 0)); err != nil {
+		t.Fatalf("LogCounts: %v", err)
+	}
+
+	cmds := runner.commands()
+	if !strings.Contains(cmds[0], "--boot") || !strings.HasSuffix(cmds[0], "-u web.service") {
+		t.Fatalf("expected since-boot query for web.service, got %q", cmds[0])
+	}
+	if !strings.Contains(cmds[1], "--since=@1700000000") {
+		t.Fatalf("expected --since for window, got %q", cmds[1])
+	}
+}
+
+func TestSystemdCapabilities(t *testing.T) {
+	caps := (&SystemdProvider{}).Capabilities()
+	if caps.Mask || caps.Reload {
+		t.Fatalf("expected mask and reload to be unavailable without endpoints, got %+v", caps)
+	}
+	if !caps.Timers || !caps.Dependencies || !caps.Hooks || !caps.ResetFailed || !caps.Logs {
+		t.Fatalf("expected the implemented features to be reported, got %+v", caps)
+	}
+}
+
+func TestSystemdLogs_MissingJournalctl(t *testing.T) {
+	runner := &fakeRunner{}
+	p := &SystemdProvider{runner: runner, noJournal: true}
+
+	if p.Capabilities().Logs {
+		t.Fatal("expected logs to be reported unavailable")
+	}
+	if _, err := p.StreamLogs(t.Context(), "web", models.ScopeSystem, models.LogStreamOptions{}); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("expected ErrNotSupported, got %v", err)
+	}
+	if _, err := p.StreamLogEntries(t.Context(), "web", models.ScopeSystem, models.LogStreamOptions{}); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("expected ErrNotSupported, got %v", err)
+	}
+	if _, err := p.LogCounts(t.Context(), "web", models.ScopeSystem, time.Time{}); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("expected ErrNotSupported, got %v", err)
+	}
+	if cmds := runner.commands(); len(cmds) != 0 {
+		t.Fatalf("expected no commands, got %v", cmds)
+	}
+}
+
+func TestGenerateUnitFile_Schedule(t *testing.T) {
+	p := &SystemdProvider{}
+	unit := p.generateUnitFile(models.ServiceConfig{
+		Name:     "backup",
+		Program:  "/usr/local/bin/backup",
+		Schedule: "daily",
+	}, models.ScopeSystem)
+
+	if !strings.Contains(unit, "Type=oneshot\n") {
+		t.Fatalf("expected scheduled service to be oneshot, got:\n%s", unit)
+	}
+	if strings.Contains(unit, "[Install]") {
+		t.Fatalf("expected scheduled service to have no [Install] section, got:\n%s", unit)
+	}
+
+	timer := generateTimerUnit("backup", "daily", false)
+	for _, want := range []string{"OnCalendar=daily\n", "WantedBy=timers.target\n"} {
+		if !strings.Contains(timer, want) {
+			t.Fatalf("expected timer unit to contain %q, got:\n%s", want, timer)
+		}
+	}
+	if strings.Contains(timer, "Persistent=") {
+		t.Fatalf("expected no Persistent= in timer, got:\n%s", timer)
+	}
+}
+
+func TestValidateSchedule(t *testing.T) {
+	cases := []struct {
+		name   string
+		config models.ServiceConfig
+		ok     bool
+	}{
+		{name: "unscheduled", config: models.ServiceConfig{KeepAlive: true}, ok: true},
+		{name: "scheduled", config: models.ServiceConfig{Schedule: "*-*-* 02:00:00"}, ok: true},
+		{name: "explicit oneshot", config: models.ServiceConfig{Schedule: "daily", Type: "oneshot"}, ok: true},
+		{name: "restart no", config: models.ServiceConfig{Schedule: "daily", RestartPolicy: "no"}, ok: true},
+		{name: "simple type", config: models.ServiceConfig{Schedule: "daily", Type: "simple"}},
+		{name: "keep alive", config: models.ServiceConfig{Schedule: "daily", KeepAlive: true}},
+		{name: "restart on failure", config: models.ServiceConfig{Schedule: "daily", RestartPolicy: "on-failure"}},
+		{name: "multi-line", config: models.ServiceConfig{Schedule: "daily\nExecStart=/bin/evil"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := validateSchedule(tc.config); (err == nil) != tc.ok {
+				t.Fatalf("expected ok=%v, got %v", tc.ok, err)
+			}
+		})
+	}
+}
+
+func TestTimerUnitName(t *testing.T) {
+	for name, want := range map[string]string{"backup": "backup.timer", "backup.service": "backup.timer"} {
+		if got := timerUnitName(name); got != want {
+			t.Fatalf("timerUnitName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestSystemdUnitType(t *testing.T) {
+	cases := map[string]string{
+		"nginx.service": models.TypeService,
+		"backup.timer":  models.TypeTimer,
+		"docker.socket": models.TypeSocket,
+		"unknown":       models.TypeService,
+	}
+	for unit, want := range cases {
+		if got := systemdUnitType(unit); got != want {
+			t.Fatalf("systemdUnitType(%q) = %q, want %q", unit, got, want)
+		}
+	}
+}
+
+func TestSystemdResetFailed(t *testing.T) {
+	runner := &fakeRunner{}
+	p := &SystemdProvider{runner: runner}
+
+	if err := p.ResetFailed(t.Context(), "web", models.ScopeSystem); err != nil {
+		t.Fatalf("ResetFailed: %v", err)
+	}
+	if cmds := runner.commands(); len(cmds) != 1 || cmds[0] != "systemctl reset-failed web.service" {
+		t.Fatalf("unexpected commands: %v", cmds)
+	}
+}
+
+func TestNewSystemdProvider_UsesRunner(t *testing.T) {
+	runner := &fakeRunner{}
+	p, err := NewSystemdProvider(Options{Runner: runner})
+	if err != nil {
+		t.Fatalf("NewSystemdProvider: %v", err)
+	}
+
+	if err := p.Start(t.Context(), "web", models.ScopeSystem); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	want := []string{"systemctl is-system-running", "systemctl start web.service"}
+	if cmds := runner.commands(); !slices.Equal(cmds, want) {
+		t.Fatalf("expected systemctl to run through the injected runner, got %v", cmds)
+	}
+}
+
+func TestNewSystemdProvider_NotRunning(t *testing.T) {
+	for name, handle := range map[string]func(string, []string) ([]byte, error){
+		"offline": func(string, []string) ([]byte, error) {
+			return []byte("offline\n"), &fakeExitError{code: 1}
+		},
+		"not booted": func(string, []string) ([]byte, error) {
+			return nil, notBootedError()
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewSystemdProvider(Options{Runner: &fakeRunner{handle: handle}})
+			if !errors.Is(err, ErrSystemdNotRunning) {
+				t.Fatalf("expected ErrSystemdNotRunning, got %v", err)
+			}
+		})
+	}
+
+	// A degraded system still works, it only has failed units
+	runner := &fakeRunner{handle: func(string, []string) ([]byte, error) {
+		return []byte("degraded\n"), &fakeExitError{code: 1}
+	}}
+	if _, err := NewSystemdProvider(Options{Runner: runner}); err != nil {
+		t.Fatalf("expected a degraded system to be accepted, got %v", err)
+	}
+}
+
+// notBootedError is what systemctl fails with where systemd isn't PID 1
+func notBootedError() error {
+	return &exec.ExitError{Stderr: []byte("System has not been booted with systemd as init system (PID 1). Can't operate.\n")}
+}
+
+func TestSystemdListUnits_NotBooted(t *testing.T) {
+	runner := &fakeRunner{handle: func(string, []string) ([]byte, error) {
+		return nil, notBootedError()
+	}}
+	p := &SystemdProvider{runner: runner}
+
+	_, err := p.ListServices(t.Context(), models.ScopeSystem)
+	if !errors.Is(err, ErrSystemdNotRunning) {
+		t.Fatalf("expected ErrSystemdNotRunning, got %v", err)
+	}
+}
+
+func TestSystemdListUnits_EmptyOutput(t *testing.T) {
+	for _, output := range []string{"", " \n", "[]"} {
+		runner := &fakeRunner{handle: func(string, []string) ([]byte, error) {
+			return []byte(output), nil
+		}}
+		p := &SystemdProvider{runner: runner}
+
+		units, err := p.listUnits(t.Context(), models.ScopeSystem)
+		if err != nil {
+			t.Fatalf("%q: listUnits: %v", output, err)
+		}
+		if len(units) != 0 {
+			t.Fatalf("%q: expected no units, got %v", output, units)
+		}
+	}
+}
+
+func TestSystemdFillFailureDetails(t *testing.T) {
+	output := `Id=backup.service
+ExecMainStatus=3
+Result=exit-code
+
+Id=worker.service
+ExecMainStatus=0
+Result=timeout
+`
+	runner := &fakeRunner{handle: func(name string, args []string) ([]byte, error) {
+		return []byte(output), nil
+	}}
+	p := &SystemdProvider{runner: runner}
+
+	services := []models.Service{
+		{Name: "nginx", Status: models.StatusRunning},
+		{Name: "backup", Status: models.StatusFailed},
+		{Name: "worker", Status: models.StatusFailed},
+	}
+	p.fillFailureDetails(t.Context(), services, models.ScopeSystem)
+
+	if services[0].ExitCode != 0 || services[0].FailureReason != "" {
+		t.Fatalf("expected no details for a running service, got %+v", services[0])
+	}
+	if services[1].ExitCode != 3 || services[1].FailureReason != "exit-code" {
+		t.Fatalf("unexpected details for backup: %+v", services[1])
+	}
+	if services[2].ExitCode != 0 || services[2].FailureReason != "timeout" {
+		t.Fatalf("unexpected details for worker: %+v", services[2])
+	}
+
+	cmds := runner.commands()
+	if len(cmds) != 1 || !strings.HasSuffix(cmds[0], "--property=Id,ExecMainStatus,Result backup.service worker.service") {
+		t.Fatalf("expected one systemctl show for the failed units, got %q", cmds)
+	}
+}
+
+func TestSystemdFillFailureDetails_NoneFailed(t *testing.T) {
+	runner := &fakeRunner{}
+	p := &SystemdProvider{runner: runner}
+
+	p.fillFailureDetails(t.Context(), []models.Service{{Name: "nginx", Status: models.StatusRunning}}, models.ScopeSystem)
+	if cmds := runner.commands(); len(cmds) != 0 {
+		t.Fatalf("expected no commands when nothing failed, got %q", cmds)
+	}
+}
+
+func TestSystemdRunAs(t *testing.T) {
+	p := &SystemdProvider{}
+	unit := p.generateUnitFile(models.ServiceConfig{Name: "web", Program: "/bin/web", User: "www-data"}, models.ScopeSystem)
+	config, err := parseUnitFile(unit)
+	if err != nil {
+		t.Fatalf("parseUnitFile: %v", err)
+	}
+	if config.User != "www-data" {
+		t.Fatalf("expected User=www-data in generated unit, got %q", config.User)
+	}
+
+	cases := []struct {
+		name  string
+		props map[string]string
+		ps    string
+		want  string
+	}{
+		{name: "User= set", props: map[string]string{"User": config.User, "MainPID": "812"}, want: "www-data"},
+		{name: "main process owner", props: map[string]string{"MainPID": "812"}, ps: "postgres\n", want: "postgres"},
+		{name: "system default", props: map[string]string{"MainPID": "0"}, want: "root"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			runner := &fakeRunner{handle: func(name string, args []string) ([]byte, error) {
+				return []byte(tc.ps), nil
+			}}
+			p := &SystemdProvider{runner: runner}
+			if got := p.runAs(t.Context(), tc.props, models.ScopeSystem); got != tc.want {
+				t.Fatalf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestSystemdGetService(t *testing.T) {
+	output := `Id=backup.service
+LoadState=loaded
+ActiveState=failed
+SubState=failed
+UnitFileState=enabled
+Description=Nightly backup
+Documentation=man:backup(1) https://example.com/backup
+User=backup
+MainPID=0
+ExecMainStatus=2
+Result=exit-code
+`
+	runner := &fakeRunner{handle: func(name string, args []string) ([]byte, error) {
+		return []byte(output), nil
+	}}
+	p := &SystemdProvider{runner: runner}
+
+	svc, err := p.GetService(t.Context(), "backup", models.ScopeSystem)
+	if err != nil {
+		t.Fatalf("GetService: %v", err)
+	}
+	want := &models.Service{
+		Name:          "backup",
+		DisplayName:   "backup",
+		Status:        models.StatusFailed,
+		Enabled:       true,
+		EnableState:   "enabled",
+		Scope:         models.ScopeSystem,
+		Description:   "Nightly backup",
+		Type:          models.TypeService,
+		Documentation: []string{"man:backup(1)", "https://example.com/backup"},
+		RunAs:         "backup",
+		ExitCode:      2,
+		FailureReason: "exit-code",
+	}
+	if !reflect.DeepEqual(svc, want) {
+		t.Fatalf("want %+v\ngot  %+v", want, svc)
+	}
+
+	cmds := runner.commands()
+	if len(cmds) != 1 || !strings.HasPrefix(cmds[0], "systemctl show ") || !strings.HasSuffix(cmds[0], " backup.service") {
+		t.Fatalf("expected a single systemctl show, got %q", cmds)
+	}
+}
+
+func TestSystemdGetService_NotFound(t *testing.T) {
+	runner := &fakeRunner{handle: func(name string, args []string) ([]byte, error) {
+		return []byte("Id=ghost.service\nLoadState=not-found\nActiveState=inactive\n"), nil
+	}}
+	p := &SystemdProvider{runner: runner}
+
+	if _, err := p.GetService(t.Context(), "ghost", models.ScopeSystem); !errors.Is(err, ErrNotFound) || err.Error() != "service not found: ghost" {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+}
+
+func TestSystemdListServices_BatchesEnabledState(t *testing.T) {
+	units := `[
+		{"unit":"nginx.service","load":"loaded","active":"active","sub":"running","description":"nginx"},
+		{"unit":"cron.service","load":"loaded","active":"active","sub":"running","description":"cron"},
+		{"unit":"getty@tty1.service","load":"loaded","active":"active","sub":"running","description":"Getty on tty1"},
+		{"unit":"dbus.service","load":"loaded","active":"active","sub":"running","description":"D-Bus"}
+	]`
+	unitFiles := `[
+		{"unit_file":"/lib/systemd/system/nginx.service","state":"enabled","preset":"enabled"},
+		{"unit_file":"/lib/systemd/system/cron.service","state":"disabled","preset":"enabled"},
+		{"unit_file":"/lib/systemd/system/getty@.service","state":"enabled","preset":"enabled"},
+		{"unit_file":"/lib/systemd/system/dbus.service","state":"static","preset":null}
+	]`
+
+	cases := []struct {
+		name         string
+		unitFilesErr error
+		wantIsEnable []string
+	}{
+		{name: "batched", wantIsEnable: []string{"getty@tty1.service"}},
+		{name: "fallback", unitFilesErr: errors.New("unknown output mode"), wantIsEnable: []string{"nginx.service", "cron.service", "getty@tty1.service", "dbus.service"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			runner := &fakeRunner{handle: func(name string, args []string) ([]byte, error) {
+				switch args[0] {
+				case "list-units":
+					return []byte(units), nil
+				case "list-unit-files":
+					if tc.unitFilesErr != nil {
+						return nil, tc.unitFilesErr
+					}
+					return []byte(unitFiles), nil
+				case "is-enabled":
+					switch args[1] {
+					case "cron.service":
+						return []byte("disabled\n"), nil
+					case "dbus.service":
+						return []byte("static\n"), nil
+					case "getty@tty1.service":
+						return []byte("indirect\n"), nil
+					}
+					return []byte("enabled\n"), nil
+				}
+				return nil, nil
+			}}
+			p := &SystemdProvider{runner: runner}
+
+			services, err := p.ListServices(t.Context(), models.ScopeSystem)
+			if err != nil {
+				t.Fatalf("ListServices: %v", err)
+			}
+			enabled := map[string]string{}
+			for _, svc := range services {
+				enabled[svc.Name] = fmt.Sprintf("%v %s", svc.Enabled, svc.EnableState)
+			}
+			want := map[string]string{
+				"nginx":      "true enabled",
+				"cron":       "false disabled",
+				"getty@tty1": "true indirect",
+				"dbus":       "true static",
+			}
+			if !reflect.DeepEqual(enabled, want) {
+				t.Fatalf("want %v, got %v", want, enabled)
+			}
+
+			var isEnabled []string
+			for _, cmd := range runner.commands() {
+				if rest, ok := strings.CutPrefix(cmd, "systemctl is-enabled "); ok {
+					isEnabled = append(isEnabled, rest)
+				}
+			}
+			if !slices.Equal(isEnabled, tc.wantIsEnable) {
+				t.Fatalf("expected is-enabled for %v, got %v", tc.wantIsEnable, isEnabled)
+			}
+		})
+	}
+}
+
+func TestSystemdRunArgs(t *testing.T) {
+	config := models.ServiceConfig{
+		Program:          "/usr/bin/rsync",
+		Arguments:        []string{"-a", "src", "dst"},
+		WorkingDirectory: "/srv",
+		Environment:      map[string]string{"B": "2", "A": "1"},
+		StandardOutPath:  "/var/log/sync.log",
+		User:             "backup",
+	}
+	got := systemdRunArgs("run-1234abcd", config)
+	want := []string{
+		"--unit=run-1234abcd.service",
+		"--working-directory=/srv",
+		"--uid=backup",
+		"--setenv=A=1",
+		"--setenv=B=2",
+		"--property=StandardOutput=append:/var/log/sync.log",
+		"--", "/usr/bin/rsync", "-a", "src", "dst",
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+}
+
+func TestSystemdRunTransient(t *testing.T) {
+	runner := &fakeRunner{}
+	p := &SystemdProvider{runner: runner}
+
+	name, err := p.RunTransient(t.Context(), models.ServiceConfig{Program: "/bin/true"}, models.ScopeUser)
+	if err != nil {
+		t.Fatalf("RunTransient: %v", err)
+	}
+	if !strings.HasPrefix(name, transientPrefix) {
+		t.Fatalf("expected a generated run- name, got %q", name)
+	}
+	want := "systemd-run --user --unit=" + name + ".service -- /bin/true"
+	if cmds := runner.commands(); len(cmds) != 1 || cmds[0] != want {
+		t.Fatalf("expected %q, got %q", want, cmds)
+	}
+}
+
+func TestSystemdRemoveTimerUnits(t *testing.T) {
+	runner := &fakeRunner{}
+	p := &SystemdProvider{runner: runner}
+
+	dir := t.TempDir()
+	var paths []string
+	for _, unit := range []string{"backup.service", "backup.timer"} {
+		path := filepath.Join(dir, unit)
+		if err := os.WriteFile(path, []byte("[Unit]\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	p.removeTimerUnits(ctx, "backup.timer", models.ScopeSystem, paths...)
+
+	for _, path := range paths {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("expected %s to be removed, got %v", path, err)
+		}
+	}
+	want := []string{"systemctl disable backup.timer", "systemctl daemon-reload"}
+	if cmds := runner.commands(); !slices.Equal(cmds, want) {
+		t.Fatalf("expected %v, got %v", want, cmds)
+	}
+}
@@ -0,0 +1,434 @@
+package platform
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"autorun/internal/logger"
+	"autorun/internal/models"
+)
+
+// SysVProvider implements ServiceProvider for Linux systems that boot with
+// plain /etc/init.d scripts. It manages the scripts already installed and
+// can't write new ones, since their conventions differ between
+// distributions.
+type SysVProvider struct {
+	runner   CommandRunner
+	timeouts Timeouts
+
+	// enableTool is the command that maintains the rc?.d links: chkconfig,
+	// update-rc.d, or "" if neither is installed
+	enableTool string
+
+	// initDir holds the scripts, rcDir the rc?.d link directories, runDir
+	// pid files, logDir log files and procRoot process information. Tests
+	// point them at temporary directories.
+	initDir  string
+	rcDir    string
+	runDir   string
+	logDir   string
+	procRoot string
+
+	// tailCommand builds the `tail` command for log streams; nil runs the
+	// real one. Tests substitute a stand-in process.
+	tailCommand commandFunc
+}
+
+// NewSysVProvider creates a new SysV init provider
+func NewSysVProvider(opts Options) (*SysVProvider, error) {
+	p := &SysVProvider{
+		runner:   opts.runner(),
+		timeouts: opts.Timeouts,
+		initDir:  "/etc/init.d",
+		rcDir:    "/etc",
+		runDir:   "/var/run",
+		logDir:   "/var/log",
+		procRoot: "/proc",
+	}
+	for _, tool := range []string{"chkconfig", "update-rc.d"} {
+		if _, err := exec.LookPath(tool); err == nil {
+			p.enableTool = tool
+			break
+		}
+	}
+	logger.Debug("sysv provider", "enableTool", p.enableTool)
+	return p, nil
+}
+
+func (p *SysVProvider) Name() string {
+	return "sysv"
+}
+
+// Capabilities reports SysV init's feature set, which has none of the
+// optional features besides tailing log files
+func (p *SysVProvider) Capabilities() models.Capabilities {
+	return models.Capabilities{Logs: true}
+}
+
+// sysvIgnored are files in /etc/init.d that aren't services
+var sysvIgnored = []string{"README", "skeleton", "functions", "rc", "rcS", "rc.local"}
+
+// sysvBackupSuffixes mark copies left behind by package managers
+var sysvBackupSuffixes = []string{".dpkg-old", ".dpkg-new", ".dpkg-dist", ".rpmnew", ".rpmsave", ".orig", "~"}
+
+// scripts returns the names of the service scripts in initDir, sorted
+func (p *SysVProvider) scripts() ([]string, error) {
+	entries, err := os.ReadDir(p.initDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", p.initDir, err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if slices.Contains(sysvIgnored, name) || models.ValidateServiceName(name) != nil {
+			continue
+		}
+		if slices.ContainsFunc(sysvBackupSuffixes, func(suffix string) bool { return strings.HasSuffix(name, suffix) }) {
+			continue
+		}
+		// Follows symlinks, which some distributions use for scripts
+		info, err := os.Stat(filepath.Join(p.initDir, name))
+		if err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0111 == 0 {
+			continue
+		}
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// exists reports whether name is an init script
+func (p *SysVProvider) exists(name string) (bool, error) {
+	return fileExists(filepath.Join(p.initDir, name))
+}
+
+// status runs `service <name> status` and maps its LSB exit code: 0 is
+// running, 1 and 2 mean the service died leaving a pid or lock file
+// behind, and 3 is stopped
+func (p *SysVProvider) status(ctx context.Context, name string) string {
+	_, err := runCommand(ctx, p.runner, p.timeouts, OpStatus, "service", name, "status")
+	if err == nil {
+		return models.StatusRunning
+	}
+	code, ok := ExitCode(err)
+	switch {
+	case !ok:
+		return models.StatusUnknown
+	case code == 3:
+		return models.StatusStopped
+	case code == 1 || code == 2:
+		return models.StatusFailed
+	default:
+		return models.StatusUnknown
+	}
+}
+
+// rcLink matches a start link in an rc?.d directory, e.g. S20nginx
+var rcLink = regexp.MustCompile(`^S\d\d(.+)$`)
+
+// enabledScripts returns the scripts with a start link in any multi-user
+// runlevel (2 to 5). The links are read directly so this works whichever
+// tool maintains them.
+func (p *SysVProvider) enabledScripts() map[string]bool {
+	enabled := make(map[string]bool)
+	for _, level := range []string{"2", "3", "4", "5"} {
+		entries, err := os.ReadDir(filepath.Join(p.rcDir, "rc"+level+".d"))
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if m := rcLink.FindStringSubmatch(entry.Name()); m != nil {
+				enabled[m[1]] = true
+			}
+		}
+	}
+	return enabled
+}
+
+// description reads the LSB header of an init script, e.g.
+// "# Short-Description: nginx web server"
+func (p *SysVProvider) description(name string) string {
+	file, err := os.Open(filepath.Join(p.initDir, name))
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "### END INIT INFO") {
+			break
+		}
+		if value, ok := strings.CutPrefix(line, "# Short-Description:"); ok {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+// ListServices lists every init script with its status. SysV has no bulk
+// status query, so each script is asked in turn.
+func (p *SysVProvider) ListServices(ctx context.Context, scope models.Scope) ([]models.Service, error) {
+	if scope != models.ScopeSystem {
+		return []models.Service{}, nil
+	}
+
+	names, err := p.scripts()
+	if err != nil {
+		return nil, err
+	}
+	enabled := p.enabledScripts()
+
+	services := make([]models.Service, 0, len(names))
+	for _, name := range names {
+		services = append(services, models.Service{
+			Name:        name,
+			DisplayName: name,
+			Status:      p.status(ctx, name),
+			Enabled:     enabled[name],
+			Scope:       scope,
+			Description: p.description(name),
+			Type:        models.TypeService,
+		})
+	}
+	return services, nil
+}
+
+func (p *SysVProvider) GetService(ctx context.Context, name string, scope models.Scope) (*models.Service, error) {
+	if err := systemOnly("SysV init", scope); err != nil {
+		return nil, err
+	}
+	if exists, err := p.exists(name); err != nil {
+		return nil, err
+	} else if !exists {
+		return nil, fmt.Errorf("service %w: %s", ErrNotFound, name)
+	}
+
+	svc := &models.Service{
+		Name:        name,
+		DisplayName: name,
+		Status:      p.status(ctx, name),
+		Enabled:     p.enabledScripts()[name],
+		Scope:       scope,
+		Description: p.description(name),
+		Type:        models.TypeService,
+		RunAs:       "root",
+	}
+	if pid := p.pid(name); pid > 0 {
+		if owner := processOwner(ctx, p.runner, p.timeouts, pid); owner != "" {
+			svc.RunAs = owner
+		}
+	}
+	return svc, nil
+}
+
+func (p *SysVProvider) ServiceStates(ctx context.Context, names []string, scope models.Scope) (map[string]models.ServiceState, error) {
+	states := make(map[string]models.ServiceState, len(names))
+	enabled := p.enabledScripts()
+	for _, name := range names {
+		exists, err := p.exists(name)
+		if err != nil {
+			return nil, err
+		}
+		if scope != models.ScopeSystem || !exists {
+			states[name] = models.ServiceState{Status: models.StatusUnknown, NotFound: true}
+			continue
+		}
+		states[name] = models.ServiceState{
+			Status:  p.status(ctx, name),
+			Enabled: enabled[name],
+			PID:     p.pid(name),
+		}
+	}
+	return states, nil
+}
+
+// runAction runs a command that changes a service
+func (p *SysVProvider) runAction(ctx context.Context, name string, scope models.Scope, command string, args ...string) error {
+	if err := systemOnly("SysV init", scope); err != nil {
+		return err
+	}
+	if exists, err := p.exists(name); err != nil {
+		return err
+	} else if !exists {
+		return fmt.Errorf("service %w: %s", ErrNotFound, name)
+	}
+
+	logger.Debug("executing "+command, "args", args)
+	if output, err := runCommand(ctx, p.runner, p.timeouts, OpAction, command, args...); err != nil {
+		text := strings.TrimSpace(commandOutput(output, err))
+		logger.Error(command+" failed", "args", args, "error", err, "output", text)
+		if text == "" {
+			text = err.Error()
+		}
+		return newCommandError(err, fmt.Sprintf("%s %s failed: %s", command, strings.Join(args, " "), text))
+	}
+	return nil
+}
+
+func (p *SysVProvider) Start(ctx context.Context, name string, scope models.Scope) error {
+	return p.runAction(ctx, name, scope, "service", name, "start")
+}
+
+func (p *SysVProvider) Stop(ctx context.Context, name string, scope models.Scope) error {
+	return p.runAction(ctx, name, scope, "service", name, "stop")
+}
+
+func (p *SysVProvider) Restart(ctx context.Context, name string, scope models.Scope) error {
+	return p.runAction(ctx, name, scope, "service", name, "restart")
+}
+
+// Enable adds the script's start links with chkconfig or update-rc.d
+func (p *SysVProvider) Enable(ctx context.Context, name string, scope models.Scope) error {
+	switch p.enableTool {
+	case "chkconfig":
+		return p.runAction(ctx, name, scope, "chkconfig", name, "on")
+	case "update-rc.d":
+		// defaults installs the links if the script has none yet; enable
+		// turns kill links left by an earlier disable back into start links
+		if err := p.runAction(ctx, name, scope, "update-rc.d", name, "defaults"); err != nil {
+			return err
+		}
+		return p.runAction(ctx, name, scope, "update-rc.d", name, "enable")
+	}
+	return notSupported("enabling services needs chkconfig or update-rc.d")
+}
+
+// Disable removes the script's start links with chkconfig or update-rc.d
+func (p *SysVProvider) Disable(ctx context.Context, name string, scope models.Scope) error {
+	switch p.enableTool {
+	case "chkconfig":
+		return p.runAction(ctx, name, scope, "chkconfig", name, "off")
+	case "update-rc.d":
+		return p.runAction(ctx, name, scope, "update-rc.d", name, "disable")
+	}
+	return notSupported("disabling services needs chkconfig or update-rc.d")
+}
+
+// ResetFailed is not supported: SysV init keeps no failed state
+func (p *SysVProvider) ResetFailed(ctx context.Context, name string, scope models.Scope) error {
+	return notSupported("SysV init keeps no failed state to reset")
+}
+
+// pid reads the service's pid file, /var/run/<name>.pid, or returns 0. It
+// is the usual location but scripts are free to put it elsewhere.
+func (p *SysVProvider) pid(name string) int {
+	data, err := os.ReadFile(filepath.Join(p.runDir, name+".pid"))
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0
+	}
+	return pid
+}
+
+// Processes returns the main process named by the service's pid file.
+// SysV init doesn't track forked children.
+func (p *SysVProvider) Processes(ctx context.Context, name string, scope models.Scope) ([]models.Process, error) {
+	if err := systemOnly("SysV init", scope); err != nil {
+		return nil, err
+	}
+	if exists, err := p.exists(name); err != nil {
+		return nil, err
+	} else if !exists {
+		return nil, fmt.Errorf("service %w: %s", ErrNotFound, name)
+	}
+
+	processes := []models.Process{}
+	pid := p.pid(name)
+	if pid == 0 {
+		return processes, nil
+	}
+	data, err := os.ReadFile(filepath.Join(p.procRoot, strconv.Itoa(pid), "cmdline"))
+	if err != nil {
+		// A stale pid file
+		return processes, nil
+	}
+	command := strings.TrimSpace(strings.ReplaceAll(string(data), "\x00", " "))
+	return append(processes, models.Process{PID: pid, Command: command}), nil
+}
+
+// LogCounts is not supported: SysV services log to plain files that carry
+// no levels
+func (p *SysVProvider) LogCounts(ctx context.Context, name string, scope models.Scope, since time.Time) (models.LogCounts, error) {
+	return models.LogCounts{}, notSupported("SysV init has no log levels")
+}
+
+// streamFiles returns /var/log/<name>.log, the only log location common
+// enough to guess
+func (p *SysVProvider) streamFiles(name string, scope models.Scope) ([]string, error) {
+	if err := systemOnly("SysV init", scope); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(p.logDir, name+".log")
+	if exists, err := fileExists(path); err != nil {
+		return nil, err
+	} else if !exists {
+		return nil, notSupported("no log file found for %s at %s", name, path)
+	}
+	return []string{path}, nil
+}
+
+func (p *SysVProvider) StreamLogs(ctx context.Context, name string, scope models.Scope, opts models.LogStreamOptions) (<-chan string, error) {
+	files, err := p.streamFiles(name, scope)
+	if err != nil {
+		return nil, err
+	}
+	return tailLines(ctx, p.tailCommand, files, opts)
+}
+
+func (p *SysVProvider) StreamLogEntries(ctx context.Context, name string, scope models.Scope, opts models.LogStreamOptions) (<-chan models.LogEntry, error) {
+	files, err := p.streamFiles(name, scope)
+	if err != nil {
+		return nil, err
+	}
+	return tailEntries(ctx, p.tailCommand, files, opts)
+}
+
+// ServiceExists reports whether an init script named name exists
+func (p *SysVProvider) ServiceExists(ctx context.Context, name string, scope models.Scope) (bool, error) {
+	if scope != models.ScopeSystem {
+		return false, nil
+	}
+	return p.exists(name)
+}
+
+// CreateService is not supported: init script conventions differ too much
+// between distributions to generate one
+func (p *SysVProvider) CreateService(ctx context.Context, config models.ServiceConfig, scope models.Scope) error {
+	return notSupported("creating services is not supported for SysV init; add a script to %s", p.initDir)
+}
+
+// DeleteService is not supported: scripts are owned by the packages that
+// installed them
+func (p *SysVProvider) DeleteService(ctx context.Context, name string, scope models.Scope) error {
+	return notSupported("deleting services is not supported for SysV init")
+}
+
+// RunTransient is not supported: SysV init only runs scripts
+func (p *SysVProvider) RunTransient(ctx context.Context, config models.ServiceConfig, scope models.Scope) (string, error) {
+	return "", notSupported("transient services are not supported by SysV init")
+}
+
+// CreateTimer is not supported: SysV init has no scheduler
+func (p *SysVProvider) CreateTimer(ctx context.Context, config models.TimerConfig, scope models.Scope) ([]string, error) {
+	return nil, notSupported("timers are not supported by SysV init; use cron")
+}
+
+// DeleteTimer is not supported: SysV init has no scheduler
+func (p *SysVProvider) DeleteTimer(ctx context.Context, name string, scope models.Scope) error {
+	return notSupported("timers are not supported by SysV init; use cron")
+}
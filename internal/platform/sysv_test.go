@@ -0,0 +1,184 @@
+package platform
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+
+	"autorun/internal/models"
+)
+
+func newTestSysVProvider(t *testing.T, runner *fakeRunner) *SysVProvider {
+	t.Helper()
+	root := t.TempDir()
+	p := &SysVProvider{
+		runner:   runner,
+		initDir:  filepath.Join(root, "init.d"),
+		rcDir:    root,
+		runDir:   filepath.Join(root, "run"),
+		logDir:   filepath.Join(root, "log"),
+		procRoot: filepath.Join(root, "proc"),
+	}
+	for _, dir := range []string{p.initDir, p.runDir, p.logDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return p
+}
+
+// writeInitScript installs an executable script in the provider's initDir
+func writeInitScript(t *testing.T, p *SysVProvider, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(p.initDir, name), []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSysVListServices(t *testing.T) {
+	runner := &fakeRunner{handle: func(name string, args []string) ([]byte, error) {
+		switch args[0] {
+		case "nginx":
+			return nil, nil
+		case "cron":
+			return nil, &fakeExitError{code: 3}
+		case "mysql":
+			return nil, &fakeExitError{code: 1}
+		}
+		return nil, &fakeExitError{code: 4}
+	}}
+	p := newTestSysVProvider(t, runner)
+	writeInitScript(t, p, "nginx", "#!/bin/sh\n### BEGIN INIT INFO\n# Short-Description: nginx web server\n### END INIT INFO\n")
+	writeInitScript(t, p, "cron", "#!/bin/sh\n")
+	writeInitScript(t, p, "mysql", "#!/bin/sh\n")
+	writeInitScript(t, p, "skeleton", "#!/bin/sh\n")
+	writeInitScript(t, p, "cron.dpkg-old", "#!/bin/sh\n")
+	if err := os.WriteFile(filepath.Join(p.initDir, "README"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	rc2 := filepath.Join(p.rcDir, "rc2.d")
+	if err := os.MkdirAll(rc2, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, link := range []string{"S01nginx", "K01cron"} {
+		if err := os.Symlink("../init.d/x", filepath.Join(rc2, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	services, err := p.ListServices(t.Context(), models.ScopeSystem)
+	if err != nil {
+		t.Fatalf("ListServices: %v", err)
+	}
+	want := []models.Service{
+		{Name: "cron", DisplayName: "cron", Status: models.StatusStopped, Scope: models.ScopeSystem, Type: models.TypeService},
+		{Name: "mysql", DisplayName: "mysql", Status: models.StatusFailed, Scope: models.ScopeSystem, Type: models.TypeService},
+		{Name: "nginx", DisplayName: "nginx", Status: models.StatusRunning, Enabled: true, Scope: models.ScopeSystem, Description: "nginx web server", Type: models.TypeService},
+	}
+	if !reflect.DeepEqual(services, want) {
+		t.Fatalf("expected %+v, got %+v", want, services)
+	}
+
+	services, err = p.ListServices(t.Context(), models.ScopeUser)
+	if err != nil || len(services) != 0 {
+		t.Fatalf("expected no user services, got %v, %v", services, err)
+	}
+}
+
+func TestSysVEnableDisable(t *testing.T) {
+	cases := []struct {
+		tool        string
+		wantEnable  []string
+		wantDisable []string
+	}{
+		{tool: "chkconfig", wantEnable: []string{"chkconfig web on"}, wantDisable: []string{"chkconfig web off"}},
+		{tool: "update-rc.d", wantEnable: []string{"update-rc.d web defaults", "update-rc.d web enable"}, wantDisable: []string{"update-rc.d web disable"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.tool, func(t *testing.T) {
+			runner := &fakeRunner{}
+			p := newTestSysVProvider(t, runner)
+			p.enableTool = tc.tool
+			writeInitScript(t, p, "web", "#!/bin/sh\n")
+
+			if err := p.Enable(t.Context(), "web", models.ScopeSystem); err != nil {
+				t.Fatalf("Enable: %v", err)
+			}
+			if got := runner.commands(); !slices.Equal(got, tc.wantEnable) {
+				t.Fatalf("expected %v, got %v", tc.wantEnable, got)
+			}
+			if err := p.Disable(t.Context(), "web", models.ScopeSystem); err != nil {
+				t.Fatalf("Disable: %v", err)
+			}
+			if got := runner.commands()[len(tc.wantEnable):]; !slices.Equal(got, tc.wantDisable) {
+				t.Fatalf("expected %v, got %v", tc.wantDisable, got)
+			}
+		})
+	}
+
+	p := newTestSysVProvider(t, &fakeRunner{})
+	writeInitScript(t, p, "web", "#!/bin/sh\n")
+	if err := p.Enable(t.Context(), "web", models.ScopeSystem); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("expected ErrNotSupported without a tool, got %v", err)
+	}
+}
+
+func TestSysVActions(t *testing.T) {
+	runner := &fakeRunner{}
+	p := newTestSysVProvider(t, runner)
+	writeInitScript(t, p, "web", "#!/bin/sh\n")
+
+	for _, action := range []func(context.Context, string, models.Scope) error{p.Start, p.Stop, p.Restart} {
+		if err := action(t.Context(), "web", models.ScopeSystem); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	want := []string{"service web start", "service web stop", "service web restart"}
+	if got := runner.commands(); !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+
+	if err := p.Start(t.Context(), "missing", models.ScopeSystem); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if err := p.Start(t.Context(), "web", models.ScopeUser); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("expected ErrNotSupported for user scope, got %v", err)
+	}
+}
+
+func TestSysVProcesses_FromPidFile(t *testing.T) {
+	p := newTestSysVProvider(t, &fakeRunner{})
+	writeInitScript(t, p, "web", "#!/bin/sh\n")
+	if err := os.WriteFile(filepath.Join(p.runDir, "web.pid"), []byte("42\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	proc := filepath.Join(p.procRoot, "42")
+	if err := os.MkdirAll(proc, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(proc, "cmdline"), []byte("/usr/sbin/web\x00-d\x00"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	processes, err := p.Processes(t.Context(), "web", models.ScopeSystem)
+	if err != nil {
+		t.Fatalf("Processes: %v", err)
+	}
+	want := []models.Process{{PID: 42, Command: "/usr/sbin/web -d"}}
+	if !reflect.DeepEqual(processes, want) {
+		t.Fatalf("expected %v, got %v", want, processes)
+	}
+}
+
+func TestSysVStreamLogs_NoLogFile(t *testing.T) {
+	p := newTestSysVProvider(t, &fakeRunner{})
+	writeInitScript(t, p, "web", "#!/bin/sh\n")
+
+	if _, err := p.StreamLogs(t.Context(), "web", models.ScopeSystem, models.LogStreamOptions{}); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("expected ErrNotSupported, got %v", err)
+	}
+}
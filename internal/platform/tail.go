@@ -0,0 +1,71 @@
+package platform
+
+import (
+	"context"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"autorun/internal/logger"
+	"autorun/internal/models"
+)
+
+// commandFunc builds a long-running command for a log stream. Providers
+// hold one so tests can substitute a stand-in process.
+type commandFunc func(ctx context.Context, args ...string) *exec.Cmd
+
+// tailFiles follows plain log files with `tail -F`, starting with the last
+// opts.History lines of each. Such files carry no levels, so a level filter
+// is refused rather than dropping every line.
+func tailFiles(ctx context.Context, command commandFunc, files []string, opts models.LogStreamOptions, done func(), emit func(line string) bool) error {
+	if opts.Level != "" {
+		return notSupported("log files carry no log levels")
+	}
+
+	// -F keeps following across log rotation; -q drops the per-file headers
+	args := append([]string{"-q", "-F", "-n", strconv.Itoa(opts.History)}, files...)
+	var cmd *exec.Cmd
+	if command != nil {
+		cmd = command(ctx, args...)
+	} else {
+		cmd = exec.CommandContext(ctx, "tail", args...)
+	}
+	logger.Debug("starting tail", "args", args)
+	return followCommand(cmd, nil, done, emit)
+}
+
+// tailLines streams the lines of plain log files
+func tailLines(ctx context.Context, command commandFunc, files []string, opts models.LogStreamOptions) (<-chan string, error) {
+	ch := make(chan string, 100)
+	err := tailFiles(ctx, command, files, opts, func() { close(ch) }, func(line string) bool {
+		select {
+		case <-ctx.Done():
+			return false
+		case ch <- line:
+			return true
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
+
+// tailEntries wraps each line of plain log files in an entry stamped with
+// the time it was read, since the format of the lines is up to the service
+func tailEntries(ctx context.Context, command commandFunc, files []string, opts models.LogStreamOptions) (<-chan models.LogEntry, error) {
+	ch := make(chan models.LogEntry, 100)
+	err := tailFiles(ctx, command, files, opts, func() { close(ch) }, func(line string) bool {
+		entry := models.LogEntry{Time: time.Now().UTC(), Level: "info", Message: line, Raw: line}
+		select {
+		case <-ctx.Done():
+			return false
+		case ch <- entry:
+			return true
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
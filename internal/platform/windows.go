@@ -0,0 +1,590 @@
+//go:build windows
+
+package platform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+
+	"autorun/internal/logger"
+	"autorun/internal/models"
+)
+
+// windowsStopPoll is how often a stopping service is checked while Stop and
+// Restart wait for it
+const windowsStopPoll = 250 * time.Millisecond
+
+// WindowsProvider implements ServiceProvider for Win32 services through the
+// service control manager. Windows services are machine-wide, so the user
+// scope is always empty.
+type WindowsProvider struct {
+	// runner and timeouts run wevtutil for event log queries
+	runner   CommandRunner
+	timeouts Timeouts
+
+	// pollInterval is how often followed event logs are queried
+	pollInterval time.Duration
+}
+
+// NewWindowsProvider creates a new Windows service provider
+func NewWindowsProvider(opts Options) (*WindowsProvider, error) {
+	return &WindowsProvider{
+		runner:       opts.runner(),
+		timeouts:     opts.Timeouts,
+		pollInterval: eventPollInterval,
+	}, nil
+}
+
+// newWindowsProvider is called by Detect, which is built on every platform
+func newWindowsProvider(opts Options) (ServiceProvider, error) {
+	return NewWindowsProvider(opts)
+}
+
+func (p *WindowsProvider) Name() string {
+	return "windows"
+}
+
+// Capabilities reports the service control manager's feature set. Requires
+// maps to service dependencies and logs come from the Event Log; everything
+// else optional is missing.
+func (p *WindowsProvider) Capabilities() models.Capabilities {
+	return models.Capabilities{Dependencies: true, Logs: true}
+}
+
+// scmError describes a failed service control manager call. Access denied
+// already matches ErrPermission through syscall.Errno; missing and
+// duplicate services are mapped to ErrNotFound and ErrAlreadyExists.
+func scmError(err error, name, action string) error {
+	switch {
+	case errors.Is(err, windows.ERROR_SERVICE_DOES_NOT_EXIST):
+		return fmt.Errorf("service %w: %s", ErrNotFound, name)
+	case errors.Is(err, windows.ERROR_SERVICE_EXISTS):
+		return fmt.Errorf("service %s %w", name, ErrAlreadyExists)
+	}
+	return fmt.Errorf("failed to %s %s: %w", action, name, err)
+}
+
+// connect opens the service control manager with the given access rights.
+// mgr.Connect asks for full access, which only administrators have.
+func connect(access uint32) (*mgr.Mgr, error) {
+	h, err := windows.OpenSCManager(nil, nil, access)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the service control manager: %w", err)
+	}
+	return &mgr.Mgr{Handle: h}, nil
+}
+
+// withService opens a service with the given access rights and calls fn
+func withService(name, action string, access uint32, fn func(s *mgr.Service) error) error {
+	m, err := connect(windows.SC_MANAGER_CONNECT)
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	namePtr, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return err
+	}
+	h, err := windows.OpenService(m.Handle, namePtr, access)
+	if err != nil {
+		return scmError(err, name, action)
+	}
+	s := &mgr.Service{Name: name, Handle: h}
+	defer s.Close()
+	return fn(s)
+}
+
+// queryAccess reads a service's status and configuration
+const queryAccess = windows.SERVICE_QUERY_STATUS | windows.SERVICE_QUERY_CONFIG
+
+// windowsStatus maps a service state to a status. A stopped service that
+// exited with an error is failed; ERROR_SERVICE_NEVER_STARTED is a service
+// that simply hasn't run yet.
+func windowsStatus(status svc.Status) string {
+	switch status.State {
+	case svc.Running:
+		return models.StatusRunning
+	case svc.Stopped:
+		code := syscall.Errno(status.Win32ExitCode)
+		if code != 0 && code != windows.ERROR_SERVICE_NEVER_STARTED {
+			return models.StatusFailed
+		}
+		return models.StatusStopped
+	default:
+		// Start, stop, pause and continue pending, and paused
+		return models.StatusUnknown
+	}
+}
+
+// describe reads one service into a models.Service, also returning the
+// status and configuration it was built from
+func describe(s *mgr.Service) (models.Service, svc.Status, mgr.Config, error) {
+	status, err := s.Query()
+	if err != nil {
+		return models.Service{}, status, mgr.Config{}, scmError(err, s.Name, "query")
+	}
+	config, err := s.Config()
+	if err != nil {
+		return models.Service{}, status, config, scmError(err, s.Name, "read configuration of")
+	}
+
+	service := models.Service{
+		Name:        s.Name,
+		DisplayName: config.DisplayName,
+		Status:      windowsStatus(status),
+		Enabled:     config.StartType == mgr.StartAutomatic,
+		Scope:       models.ScopeSystem,
+		Description: config.Description,
+		Type:        models.TypeService,
+	}
+	if service.DisplayName == "" {
+		service.DisplayName = s.Name
+	}
+	if service.Status == models.StatusFailed {
+		service.ExitCode = int(status.Win32ExitCode)
+		if status.ServiceSpecificExitCode != 0 {
+			service.ExitCode = int(status.ServiceSpecificExitCode)
+		}
+	}
+	return service, status, config, nil
+}
+
+// ListServices lists every Win32 service known to the service control
+// manager, sorted by name
+func (p *WindowsProvider) ListServices(ctx context.Context, scope models.Scope) ([]models.Service, error) {
+	if scope != models.ScopeSystem {
+		return []models.Service{}, nil
+	}
+
+	m, err := connect(windows.SC_MANAGER_CONNECT | windows.SC_MANAGER_ENUMERATE_SERVICE)
+	if err != nil {
+		return nil, err
+	}
+	defer m.Disconnect()
+
+	names, err := m.ListServices()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list services: %w", err)
+	}
+	sort.Strings(names)
+
+	services := make([]models.Service, 0, len(names))
+	for _, name := range names {
+		err := withService(name, "query", queryAccess, func(s *mgr.Service) error {
+			service, _, _, err := describe(s)
+			if err == nil {
+				services = append(services, service)
+			}
+			return err
+		})
+		if err != nil {
+			// Services can disappear or deny queries between listing and
+			// opening them
+			logger.Debug("skipping service", "name", name, "error", err)
+		}
+	}
+	return services, nil
+}
+
+func (p *WindowsProvider) GetService(ctx context.Context, name string, scope models.Scope) (*models.Service, error) {
+	if err := systemOnly("Windows", scope); err != nil {
+		return nil, err
+	}
+
+	var service models.Service
+	err := withService(name, "query", queryAccess, func(s *mgr.Service) error {
+		var config mgr.Config
+		var err error
+		service, _, config, err = describe(s)
+		service.RunAs = config.ServiceStartName
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &service, nil
+}
+
+func (p *WindowsProvider) ServiceStates(ctx context.Context, names []string, scope models.Scope) (map[string]models.ServiceState, error) {
+	states := make(map[string]models.ServiceState, len(names))
+	for _, name := range names {
+		if scope != models.ScopeSystem {
+			states[name] = models.ServiceState{Status: models.StatusUnknown, NotFound: true}
+			continue
+		}
+		err := withService(name, "query", queryAccess, func(s *mgr.Service) error {
+			service, status, _, err := describe(s)
+			if err != nil {
+				return err
+			}
+			states[name] = models.ServiceState{
+				Status:  service.Status,
+				Enabled: service.Enabled,
+				PID:     int(status.ProcessId),
+			}
+			return nil
+		})
+		if errors.Is(err, ErrNotFound) {
+			states[name] = models.ServiceState{Status: models.StatusUnknown, NotFound: true}
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return states, nil
+}
+
+func (p *WindowsProvider) Start(ctx context.Context, name string, scope models.Scope) error {
+	if err := systemOnly("Windows", scope); err != nil {
+		return err
+	}
+	return withService(name, "start", windows.SERVICE_START|windows.SERVICE_QUERY_STATUS, func(s *mgr.Service) error {
+		if err := s.Start(); err != nil && !errors.Is(err, windows.ERROR_SERVICE_ALREADY_RUNNING) {
+			return scmError(err, name, "start")
+		}
+		return nil
+	})
+}
+
+func (p *WindowsProvider) Stop(ctx context.Context, name string, scope models.Scope) error {
+	if err := systemOnly("Windows", scope); err != nil {
+		return err
+	}
+	return withService(name, "stop", windows.SERVICE_STOP|windows.SERVICE_QUERY_STATUS, func(s *mgr.Service) error {
+		return p.stop(ctx, s)
+	})
+}
+
+// stop asks a service to stop and waits for it to, bounded by the action
+// timeout and ctx, so a following start doesn't fail with the service still
+// stopping
+func (p *WindowsProvider) stop(ctx context.Context, s *mgr.Service) error {
+	status, err := s.Control(svc.Stop)
+	if errors.Is(err, windows.ERROR_SERVICE_NOT_ACTIVE) {
+		return nil
+	}
+	if err != nil {
+		return scmError(err, s.Name, "stop")
+	}
+
+	deadline := time.Now().Add(p.timeouts.For(OpAction))
+	ticker := time.NewTicker(windowsStopPoll)
+	defer ticker.Stop()
+	for status.State != svc.Stopped {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%w waiting for %s to stop", ErrTimeout, s.Name)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		if status, err = s.Query(); err != nil {
+			return scmError(err, s.Name, "query")
+		}
+	}
+	return nil
+}
+
+func (p *WindowsProvider) Restart(ctx context.Context, name string, scope models.Scope) error {
+	if err := systemOnly("Windows", scope); err != nil {
+		return err
+	}
+	return withService(name, "restart", windows.SERVICE_START|windows.SERVICE_STOP|windows.SERVICE_QUERY_STATUS, func(s *mgr.Service) error {
+		if err := p.stop(ctx, s); err != nil {
+			return err
+		}
+		if err := s.Start(); err != nil {
+			return scmError(err, name, "start")
+		}
+		return nil
+	})
+}
+
+// setStartType changes whether a service starts at boot
+func setStartType(name string, startType uint32) error {
+	return withService(name, "configure", windows.SERVICE_CHANGE_CONFIG|windows.SERVICE_QUERY_CONFIG, func(s *mgr.Service) error {
+		config, err := s.Config()
+		if err != nil {
+			return scmError(err, name, "read configuration of")
+		}
+		config.StartType = startType
+		if err := s.UpdateConfig(config); err != nil {
+			return scmError(err, name, "configure")
+		}
+		return nil
+	})
+}
+
+// Enable sets the service to start automatically at boot
+func (p *WindowsProvider) Enable(ctx context.Context, name string, scope models.Scope) error {
+	if err := systemOnly("Windows", scope); err != nil {
+		return err
+	}
+	return setStartType(name, mgr.StartAutomatic)
+}
+
+// Disable sets the service to manual start. Like a disabled systemd unit it
+// can still be started on demand, which the Disabled start type forbids.
+func (p *WindowsProvider) Disable(ctx context.Context, name string, scope models.Scope) error {
+	if err := systemOnly("Windows", scope); err != nil {
+		return err
+	}
+	return setStartType(name, mgr.StartManual)
+}
+
+// ResetFailed is not supported: the service control manager keeps no
+// failed state beyond the last exit code
+func (p *WindowsProvider) ResetFailed(ctx context.Context, name string, scope models.Scope) error {
+	return notSupported("Windows services have no failed state to reset")
+}
+
+// Processes returns the service's process. Services sharing an svchost
+// process report the same PID.
+func (p *WindowsProvider) Processes(ctx context.Context, name string, scope models.Scope) ([]models.Process, error) {
+	if err := systemOnly("Windows", scope); err != nil {
+		return nil, err
+	}
+
+	processes := []models.Process{}
+	err := withService(name, "query", queryAccess, func(s *mgr.Service) error {
+		status, err := s.Query()
+		if err != nil {
+			return scmError(err, name, "query")
+		}
+		if status.ProcessId == 0 {
+			return nil
+		}
+		config, err := s.Config()
+		if err != nil {
+			return scmError(err, name, "read configuration of")
+		}
+		processes = append(processes, models.Process{PID: int(status.ProcessId), Command: config.BinaryPathName})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return processes, nil
+}
+
+// LogCounts counts warning- and error-level events the service logged to
+// the Application event log, since boot if since is zero
+func (p *WindowsProvider) LogCounts(ctx context.Context, name string, scope models.Scope, since time.Time) (models.LogCounts, error) {
+	if err := systemOnly("Windows", scope); err != nil {
+		return models.LogCounts{}, err
+	}
+	window := windows.DurationSinceBoot()
+	if !since.IsZero() {
+		window = time.Since(since)
+	}
+	return eventLogCounts(ctx, p.runner, p.timeouts, name, window)
+}
+
+// StreamLogs follows the events the service logs to the Application event
+// log, using the service name as the event source
+func (p *WindowsProvider) StreamLogs(ctx context.Context, name string, scope models.Scope, opts models.LogStreamOptions) (<-chan string, error) {
+	if err := systemOnly("Windows", scope); err != nil {
+		return nil, err
+	}
+	ch := make(chan string, 100)
+	err := followEventLog(ctx, p.runner, p.timeouts, name, opts, p.pollInterval, func() { close(ch) }, func(entry models.LogEntry) bool {
+		select {
+		case <-ctx.Done():
+			return false
+		case ch <- entry.Raw:
+			return true
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
+
+func (p *WindowsProvider) StreamLogEntries(ctx context.Context, name string, scope models.Scope, opts models.LogStreamOptions) (<-chan models.LogEntry, error) {
+	if err := systemOnly("Windows", scope); err != nil {
+		return nil, err
+	}
+	ch := make(chan models.LogEntry, 100)
+	err := followEventLog(ctx, p.runner, p.timeouts, name, opts, p.pollInterval, func() { close(ch) }, func(entry models.LogEntry) bool {
+		select {
+		case <-ctx.Done():
+			return false
+		case ch <- entry:
+			return true
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
+
+// ServiceExists reports whether the service control manager knows name
+func (p *WindowsProvider) ServiceExists(ctx context.Context, name string, scope models.Scope) (bool, error) {
+	if scope != models.ScopeSystem {
+		return false, nil
+	}
+	err := withService(name, "query", windows.SERVICE_QUERY_STATUS, func(*mgr.Service) error { return nil })
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// CreateService registers a new service. The program must implement the
+// service control protocol (e.g. with golang.org/x/sys/windows/svc); a
+// plain executable is killed by the service control manager after it
+// fails to report running.
+func (p *WindowsProvider) CreateService(ctx context.Context, config models.ServiceConfig, scope models.Scope) error {
+	logger.Debug("creating windows service", "name", config.Name, "program", config.Program, "scope", scope)
+
+	if err := systemOnly("Windows", scope); err != nil {
+		return err
+	}
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	if err := validateRestart(config); err != nil {
+		return err
+	}
+	switch {
+	case config.Type != "" && config.Type != models.DefaultServiceType:
+		return notSupported("service type %s is not supported on Windows", config.Type)
+	case config.Schedule != "":
+		return notSupported("schedules are not supported on Windows; use Task Scheduler")
+	case config.WorkingDirectory != "":
+		return notSupported("workingDirectory is not supported on Windows")
+	case config.User != "" || config.Group != "":
+		return notSupported("running as another account needs its password; set it in services.msc")
+	case config.EnvironmentFile != "":
+		return notSupported("environmentFile is not supported on Windows")
+	case config.StandardOutPath != "" || config.StandardErrorPath != "":
+		return notSupported("standardOutPath and standardErrorPath are not supported on Windows")
+	case len(config.After) > 0 || len(config.Wants) > 0:
+		return notSupported("after and wants are not supported on Windows; use requires")
+	case len(config.ExecStartPre) > 0 || len(config.ExecStopPost) > 0:
+		return notSupported("execStartPre and execStopPost are not supported on Windows")
+	}
+	m, err := connect(windows.SC_MANAGER_CONNECT | windows.SC_MANAGER_CREATE_SERVICE)
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	startType := uint32(mgr.StartManual)
+	if config.RunAtLoad {
+		startType = mgr.StartAutomatic
+	}
+	description := config.Description
+	if description == "" {
+		description = config.Name + " service"
+	}
+	s, err := m.CreateService(config.Name, config.Program, mgr.Config{
+		StartType:    startType,
+		DisplayName:  config.Name,
+		Description:  description,
+		Dependencies: slices.Clone(config.Requires),
+	}, config.Arguments...)
+	if err != nil {
+		return scmError(err, config.Name, "create")
+	}
+	defer s.Close()
+
+	if err := configureService(s, config); err != nil {
+		logger.Error("failed to configure service, removing it", "name", config.Name, "error", err)
+		s.Delete()
+		return err
+	}
+
+	if config.RunAtLoad {
+		if err := s.Start(); err != nil {
+			logger.Error("failed to start service", "name", config.Name, "error", err)
+			return fmt.Errorf("failed to start service: %w", scmError(err, config.Name, "start"))
+		}
+	}
+	logger.Debug("service created successfully", "name", config.Name)
+	return nil
+}
+
+// configureService applies the settings mgr.Config has no field for: the
+// restart policy as recovery actions, and environment variables as the
+// service key's Environment value
+func configureService(s *mgr.Service, config models.ServiceConfig) error {
+	if policy, sec := restartPolicy(config); policy != "" && policy != "no" {
+		delay := time.Duration(sec) * time.Second
+		actions := []mgr.RecoveryAction{{Type: mgr.ServiceRestart, Delay: delay}}
+		if err := s.SetRecoveryActions(actions, windows.INFINITE); err != nil {
+			return scmError(err, s.Name, "set recovery actions of")
+		}
+		// Recovery actions normally only run when a service crashes; always
+		// also restarts it after it stops itself with an error
+		if policy == "always" {
+			if err := s.SetRecoveryActionsOnNonCrashFailures(true); err != nil {
+				return scmError(err, s.Name, "set recovery actions of")
+			}
+		}
+	}
+
+	if len(config.Environment) > 0 {
+		key, err := registry.OpenKey(registry.LOCAL_MACHINE, `SYSTEM\CurrentControlSet\Services\`+s.Name, registry.SET_VALUE)
+		if err != nil {
+			return fmt.Errorf("failed to open registry key of %s: %w", s.Name, err)
+		}
+		defer key.Close()
+
+		env := make([]string, 0, len(config.Environment))
+		for k, v := range config.Environment {
+			env = append(env, k+"="+v)
+		}
+		sort.Strings(env)
+		if err := key.SetStringsValue("Environment", env); err != nil {
+			return fmt.Errorf("failed to set environment of %s: %w", s.Name, err)
+		}
+	}
+	return nil
+}
+
+// DeleteService stops a service and removes it from the service control
+// manager. Windows finishes the removal once every handle is closed.
+func (p *WindowsProvider) DeleteService(ctx context.Context, name string, scope models.Scope) error {
+	logger.Debug("deleting windows service", "name", name, "scope", scope)
+	if err := systemOnly("Windows", scope); err != nil {
+		return err
+	}
+	return withService(name, "delete", windows.DELETE|windows.SERVICE_STOP|windows.SERVICE_QUERY_STATUS, func(s *mgr.Service) error {
+		if err := p.stop(ctx, s); err != nil {
+			logger.Warn("failed to stop service before deletion", "name", name, "error", err)
+		}
+		if err := s.Delete(); err != nil {
+			return scmError(err, name, "delete")
+		}
+		return nil
+	})
+}
+
+// RunTransient is not supported: Windows has no transient services
+func (p *WindowsProvider) RunTransient(ctx context.Context, config models.ServiceConfig, scope models.Scope) (string, error) {
+	return "", notSupported("transient services are not supported on Windows")
+}
+
+// CreateTimer is not supported: scheduled jobs belong to Task Scheduler
+func (p *WindowsProvider) CreateTimer(ctx context.Context, config models.TimerConfig, scope models.Scope) ([]string, error) {
+	return nil, notSupported("timers are not supported on Windows; use Task Scheduler")
+}
+
+// DeleteTimer is not supported: scheduled jobs belong to Task Scheduler
+func (p *WindowsProvider) DeleteTimer(ctx context.Context, name string, scope models.Scope) error {
+	return notSupported("timers are not supported on Windows; use Task Scheduler")
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package platform
+
+import "fmt"
+
+// newWindowsProvider is called by Detect, which is built on every platform;
+// the Windows provider itself only builds on Windows
+func newWindowsProvider(opts Options) (ServiceProvider, error) {
+	return nil, fmt.Errorf("the Windows provider is only available on Windows")
+}
@@ -8,6 +8,9 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -16,43 +19,162 @@ import (
 	"autorun/internal/platform"
 )
 
-// findAvailablePort finds the first available port starting from startPort.
-// It tries up to maxAttempts ports before giving up.
-func findAvailablePort(host string, startPort, maxAttempts int) (int, error) {
+// version and commit are set at build time via
+// -ldflags "-X main.version=... -X main.commit=..."
+var (
+	version = "dev"
+	commit  = "dev"
+)
+
+// listenAvailable listens on the first available port starting from
+// startPort, trying up to maxAttempts ports before giving up. The listener
+// is kept open and served directly, so no other process can take the port
+// in between.
+func listenAvailable(host string, startPort, maxAttempts int) (net.Listener, int, error) {
 	for i := 0; i < maxAttempts; i++ {
 		port := startPort + i
-		addr := fmt.Sprintf("%s:%d", host, port)
-		listener, err := net.Listen("tcp", addr)
+		listener, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
 		if err == nil {
-			listener.Close()
-			return port, nil
+			return listener, port, nil
+		}
+		if maxAttempts == 1 {
+			return nil, 0, err
+		}
+	}
+	return nil, 0, fmt.Errorf("no available port found in range %d-%d", startPort, startPort+maxAttempts-1)
+}
+
+// defaultInstanceName returns the hostname, or "autorun" if it is unknown
+func defaultInstanceName() string {
+	if name, err := os.Hostname(); err == nil && name != "" {
+		return name
+	}
+	return "autorun"
+}
+
+// splitList splits a comma-separated flag value, dropping blank entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
 		}
 	}
-	return 0, fmt.Errorf("no available port found in range %d-%d", startPort, startPort+maxAttempts-1)
+	return items
+}
+
+// hiddenFlags are left out of -help: they exist for development, not for
+// running autorun on a real host
+var hiddenFlags = []string{"provider"}
+
+// usage prints the flag defaults without hiddenFlags
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
+	visible := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	visible.SetOutput(flag.CommandLine.Output())
+	flag.VisitAll(func(f *flag.Flag) {
+		if !slices.Contains(hiddenFlags, f.Name) {
+			visible.Var(f.Value, f.Name, f.Usage)
+			visible.Lookup(f.Name).DefValue = f.DefValue
+		}
+	})
+	visible.PrintDefaults()
 }
 
 func main() {
+	pidFile := flag.String("pidfile", "", "Write the process ID to this file, refusing to start if it names a running process")
+	force := flag.Bool("force", false, "Start even if the -pidfile names a running process")
+	configFile := flag.String("config", "", "Read flag values from this YAML or JSON file (keys are flag names; command-line flags take precedence)")
 	port := flag.Int("port", 8080, "Starting port to listen on (will auto-increment if in use)")
+	autoPort := flag.Bool("auto-port", true, "Try the next ports if -port is in use; with -auto-port=false, fail instead")
 	listen := flag.String("listen", "127.0.0.1", "Address to bind to")
 	verbose := flag.Bool("verbose", false, "Enable debug logging (or set LOG_LEVEL=debug)")
 	flag.BoolVar(verbose, "v", false, "Enable debug logging (shorthand)")
+	logFormat := flag.String("log-format", "", "Log output format: text (default) or json (or set LOG_FORMAT)")
+	logFile := flag.String("log-file", "", "Write logs to this file instead of stderr, creating its directory")
+	logMaxSize := flag.Int("log-max-size-mb", logger.DefaultMaxSizeMB, "Rotate the -log-file once it reaches this many megabytes")
+	logMaxBackups := flag.Int("log-max-backups", logger.DefaultMaxBackups, "Rotated -log-file copies to keep (0 keeps all)")
+	logMaxAge := flag.Int("log-max-age-days", logger.DefaultMaxAgeDays, "Delete rotated -log-file copies older than this many days (0 keeps all)")
+	corsOrigins := flag.String("cors-origins", "", "Comma-separated browser origins allowed to call the API cross-site, e.g. http://localhost:5173 (* allows any)")
+	allowPatterns := flag.String("allow-pattern", "", "Comma-separated globs, e.g. myapp-*; only matching services are listed and can be managed")
+	denyPatterns := flag.String("deny-pattern", "", "Comma-separated globs of services to hide and refuse to manage, even if -allow-pattern matches")
+	readOnly := flag.Bool("read-only", false, "Refuse every change (start, stop, create, delete, ...) with 403; lists and logs still work")
+	metrics := flag.Bool("metrics", false, "Serve Prometheus metrics at /metrics")
+	auditFile := flag.String("audit-file", "", "Append a JSON line to this file for every service change made through the API")
+	stopSignal := flag.String("stop-signal", "SIGTERM", "Signal sent when stopping launchd services via launchctl kill")
+	stopTimeout := flag.Duration("stop-timeout", 0, "Send SIGKILL if a launchd service hasn't exited this long after the stop signal (0 disables)")
+	commandTimeouts := flag.String("command-timeouts", "", "Per-operation command timeouts, e.g. list=1m,status=3s,action=90s,reload=1m")
+	streamRetries := flag.Int("stream-retries", api.DefaultStreamRetries, "Times to retry starting a log stream before giving up (0 disables retries)")
+	streamBackoff := flag.Duration("stream-retry-backoff", 500*time.Millisecond, "Delay before the first log stream retry (doubles each attempt)")
+	streamStartTimeout := flag.Duration("stream-start-timeout", 30*time.Second, "How long one attempt to start a log stream may take before it is retried")
+	watchInterval := flag.Duration("watch-interval", api.DefaultWatchInterval, "How often the status watcher polls for service changes (minimum 500ms)")
+	restartCooldown := flag.Duration("restart-cooldown", 0, "Reject restarts of a service within this long of its last restart with 429 (0 disables)")
+	listCacheTTL := flag.Duration("list-cache-ttl", 2*time.Second, "How long to reuse a service list for repeated list requests (0 disables caching)")
+	maxListSize := flag.Int("max-list-size", api.DefaultMaxListSize, "Maximum number of services a list request returns; longer lists are truncated")
+	instanceName := flag.String("instance-name", defaultInstanceName(), "Label identifying this autorun instance (defaults to the hostname)")
+	remote := flag.String("remote", "", "Manage services on a remote host over SSH, given as [user@]host[:port] (the host must be in ~/.ssh/known_hosts)")
+	remoteKey := flag.String("remote-key", "", "Private key file for -remote (defaults to ssh-agent and ~/.ssh/id_*)")
+	providerName := flag.String("provider", "", "Service backend to use instead of detecting the platform: mock (in-memory demo services)")
+	flag.Usage = usage
 	flag.Parse()
 
+	if *configFile != "" {
+		if err := loadConfigFile(flag.CommandLine, *configFile); err != nil {
+			fmt.Fprintln(os.Stderr, "autorun:", err)
+			os.Exit(2)
+		}
+	}
+
 	// Initialize logger
-	logger.Init(*verbose)
+	if err := logger.Init(logger.Config{
+		Verbose:    *verbose,
+		Format:     *logFormat,
+		File:       *logFile,
+		MaxSizeMB:  *logMaxSize,
+		MaxBackups: *logMaxBackups,
+		MaxAgeDays: *logMaxAge,
+	}); err != nil {
+		fmt.Fprintln(os.Stderr, "autorun:", err)
+		os.Exit(2)
+	}
 
-	// Find an available port starting from the specified port
-	actualPort, err := findAvailablePort(*listen, *port, 100)
+	for _, patterns := range []string{*allowPatterns, *denyPatterns} {
+		if err := api.ValidatePatterns(splitList(patterns)); err != nil {
+			logger.Error("invalid service pattern", "error", err)
+			os.Exit(2)
+		}
+	}
+
+	// Under systemd socket activation, serve the socket systemd passed in.
+	// Otherwise listen on the first available port starting from the
+	// specified port, or only on that port without -auto-port.
+	listener, err := activationListener()
 	if err != nil {
-		logger.Error("failed to find available port", "error", err)
+		logger.Error("failed to use the socket from systemd", "error", err)
 		os.Exit(1)
 	}
-	if actualPort != *port {
-		logger.Info("port in use, using alternative", "requested", *port, "actual", actualPort)
+	bindHost := *listen
+	if listener != nil {
+		bindHost, _, _ = net.SplitHostPort(listener.Addr().String())
+		logger.Info("using socket from systemd", "address", listener.Addr().String())
+	} else {
+		attempts := 100
+		if !*autoPort {
+			attempts = 1
+		}
+		var actualPort int
+		listener, actualPort, err = listenAvailable(*listen, *port, attempts)
+		if err != nil {
+			logger.Error("failed to listen", "port", *port, "error", err)
+			os.Exit(1)
+		}
+		if actualPort != *port {
+			logger.Info("port in use, using alternative", "requested", *port, "actual", actualPort)
+		}
 	}
 
 	// Warn about security implications of non-localhost binding
-	if *listen != "127.0.0.1" && *listen != "localhost" {
+	if !isLocalhost(bindHost) {
 		fmt.Fprintln(os.Stderr, "")
 		fmt.Fprintln(os.Stderr, "╔════════════════════════════════════════════════════════════════╗")
 		fmt.Fprintln(os.Stderr, "║                        ⚠️  WARNING ⚠️                            ║")
@@ -71,14 +193,43 @@ func main() {
 		fmt.Fprintln(os.Stderr, "")
 	}
 
-	// Detect platform and create provider
-	provider, err := platform.Detect()
+	timeouts, err := platform.ParseTimeouts(*commandTimeouts)
 	if err != nil {
-		logger.Error("failed to detect platform", "error", err)
+		logger.Error("invalid command timeouts", "error", err)
 		os.Exit(1)
 	}
 
-	logger.Info("detected platform", "platform", provider.Name())
+	// Detect platform and create provider, unless a backend was chosen
+	opts := platform.Options{
+		StopSignal:  *stopSignal,
+		StopTimeout: *stopTimeout,
+		Timeouts:    timeouts,
+		Remote:      *remote,
+		RemoteKey:   *remoteKey,
+	}
+	var provider platform.ServiceProvider
+	switch {
+	case *providerName == "" && *remote != "":
+		provider, err = platform.NewSSHProvider(opts)
+		if err != nil {
+			logger.Error("failed to connect to remote host", "remote", *remote, "error", err)
+			os.Exit(1)
+		}
+	case *providerName == "":
+		provider, err = platform.Detect(opts)
+		if err != nil {
+			logger.Error("failed to detect platform", "error", err)
+			os.Exit(1)
+		}
+	case *providerName == "mock":
+		logger.Warn("using the mock provider; services are in memory and nothing on this host is managed")
+		provider = platform.NewMockProvider()
+	default:
+		logger.Error("unknown provider", "provider", *providerName)
+		os.Exit(1)
+	}
+
+	logger.Info("detected platform", "platform", provider.Name(), "version", version, "commit", commit)
 
 	// Get embedded frontend
 	frontendFS, err := GetFrontendFS()
@@ -87,11 +238,65 @@ func main() {
 		os.Exit(1)
 	}
 
+	var audit *api.AuditLogger
+	if *auditFile != "" {
+		audit, err = api.NewAuditLogger(*auditFile)
+		if err != nil {
+			logger.Error("failed to open audit log", "path", *auditFile, "error", err)
+			os.Exit(1)
+		}
+		defer audit.Close()
+		logger.Info("auditing service changes", "path", *auditFile)
+	}
+
+	var collector *api.Metrics
+	if *metrics {
+		collector = api.NewMetrics()
+		metricsCtx, stopMetrics := context.WithCancel(context.Background())
+		defer stopMetrics()
+		go collector.Run(metricsCtx, provider)
+		logger.Info("serving metrics", "path", "/metrics")
+	}
+
 	// Create router
-	router := api.NewRouter(provider, frontendFS)
+	router := api.NewRouter(provider, frontendFS, api.Options{
+		Version:            version,
+		Commit:             commit,
+		InstanceName:       *instanceName,
+		StreamRetries:      *streamRetries,
+		StreamRetryBackoff: *streamBackoff,
+		StreamStartTimeout: *streamStartTimeout,
+		WatchInterval:      *watchInterval,
+		RestartCooldown:    *restartCooldown,
+		ListCacheTTL:       *listCacheTTL,
+		MaxListSize:        *maxListSize,
+		Audit:              audit,
+		Metrics:            collector,
+		ReadOnly:           *readOnly,
+		AllowPatterns:      splitList(*allowPatterns),
+		DenyPatterns:       splitList(*denyPatterns),
+		CORSOrigins:        splitList(*corsOrigins),
+	})
+
+	// The pid file is written once setup can no longer fail, so none of
+	// the exits above leave it behind. os.Exit skips deferred calls, so
+	// the exits below remove it themselves.
+	removePID := func() {}
+	if *pidFile != "" {
+		if err := writePIDFile(*pidFile, *force); err != nil {
+			logger.Error("failed to write pid file", "error", err)
+			os.Exit(1)
+		}
+		removePID = func() {
+			if err := removePIDFile(*pidFile); err != nil {
+				logger.Warn("failed to remove pid file", "path", *pidFile, "error", err)
+			}
+		}
+		defer removePID()
+	}
 
 	// Start server
-	addr := fmt.Sprintf("%s:%d", *listen, actualPort)
+	addr := listener.Addr().String()
 	logger.Info("starting server", "address", fmt.Sprintf("http://%s", addr))
 
 	srv := &http.Server{
@@ -105,7 +310,7 @@ func main() {
 
 	serverErr := make(chan error, 1)
 	go func() {
-		serverErr <- srv.ListenAndServe()
+		serverErr <- srv.Serve(listener)
 	}()
 
 	sigCh := make(chan os.Signal, 1)
@@ -117,6 +322,7 @@ func main() {
 	case err := <-serverErr:
 		if err != nil && err != http.ErrServerClosed {
 			logger.Error("server failed", "error", err)
+			removePID()
 			os.Exit(1)
 		}
 		return
@@ -124,6 +330,9 @@ func main() {
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
+	if err := router.Shutdown(ctx); err != nil {
+		logger.Warn("log streams did not finish before shutdown", "error", err)
+	}
 	if err := srv.Shutdown(ctx); err != nil {
 		logger.Warn("graceful shutdown failed", "error", err)
 		if err := srv.Close(); err != nil {
@@ -133,6 +342,7 @@ func main() {
 
 	if err := <-serverErr; err != nil && err != http.ErrServerClosed {
 		logger.Error("server failed", "error", err)
+		removePID()
 		os.Exit(1)
 	}
 }
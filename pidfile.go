@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// writePIDFile writes the current process ID to path. If the file already
+// names a running process, autorun is probably running twice, so it
+// refuses unless force is set. A file left behind by a process that has
+// exited is replaced. The file is created exclusively, so of two instances
+// starting together only one gets it.
+func writePIDFile(path string, force bool) error {
+	content := []byte(strconv.Itoa(os.Getpid()) + "\n")
+	for {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err == nil {
+			_, err = f.Write(content)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return fmt.Errorf("failed to write pid file: %w", err)
+			}
+			return nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return fmt.Errorf("failed to create pid file: %w", err)
+		}
+
+		data, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue // removed in the meantime
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read pid file: %w", err)
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		alive := err == nil && pid > 0 && pid != os.Getpid() && processAlive(pid)
+		switch {
+		case alive && !force:
+			return fmt.Errorf("pid file %s belongs to running process %d; use -force to start anyway", path, pid)
+		case alive:
+			if err := os.WriteFile(path, content, 0o644); err != nil {
+				return fmt.Errorf("failed to write pid file: %w", err)
+			}
+			return nil
+		}
+
+		// Stale: remove it and race any other instance for a fresh one
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to remove stale pid file: %w", err)
+		}
+	}
+}
+
+// removePIDFile removes path if it still holds this process's ID, so a
+// second instance started with -force keeps its own file
+func removePIDFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(string(data)) != strconv.Itoa(os.Getpid()) {
+		return nil
+	}
+	return os.Remove(path)
+}
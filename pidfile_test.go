@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestWritePIDFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "autorun.pid")
+	self := strconv.Itoa(os.Getpid()) + "\n"
+
+	if err := writePIDFile(path, false); err != nil {
+		t.Fatalf("writePIDFile: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != self {
+		t.Fatalf("expected our pid, got %q", data)
+	}
+
+	// The parent process (go test) is alive, so its pid file is respected
+	parent := strconv.Itoa(os.Getppid())
+	os.WriteFile(path, []byte(parent+"\n"), 0o644)
+	err := writePIDFile(path, false)
+	if err == nil || !strings.Contains(err.Error(), "running process "+parent) {
+		t.Fatalf("expected a running-process error, got %v", err)
+	}
+	if err := writePIDFile(path, true); err != nil {
+		t.Fatalf("writePIDFile with force: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != self {
+		t.Fatalf("expected force to overwrite the file, got %q", data)
+	}
+
+	// Garbage isn't a running process
+	os.WriteFile(path, []byte("not a pid"), 0o644)
+	if err := writePIDFile(path, false); err != nil {
+		t.Fatalf("writePIDFile over garbage: %v", err)
+	}
+}
+
+func TestRemovePIDFile_OnlyOwnPID(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "autorun.pid")
+	os.WriteFile(path, []byte(strconv.Itoa(os.Getppid())), 0o644)
+	if err := removePIDFile(path); err != nil {
+		t.Fatalf("removePIDFile: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatal("removed another process's pid file")
+	}
+
+	writePIDFile(path, true)
+	if err := removePIDFile(path); err != nil {
+		t.Fatalf("removePIDFile: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatal("expected our pid file to be removed")
+	}
+}